@@ -10,13 +10,24 @@ import (
 
 	"github.com/yisaer/arxml-converter/ap/parser"
 	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/e2e"
+	"github.com/yisaer/arxml-converter/someip"
 )
 
 type ArXMLConverter struct {
 	Parser       *parser.Parser
+	config       converter.IDlConverterConfig
 	idlModule    *idlAst.Module
 	idlConverter *converter.IDLConverter
 	transformer  *ast.TransformHelper
+	e2eCheckers  *e2e.Checkers
+}
+
+type resolvedType struct {
+	name       string
+	elementRef string
+	typeKey    string
+	typeRef    typeref.TypeRef
 }
 
 func NewConverterWithDoc(doc *etree.Document, config converter.IDlConverterConfig) (*ArXMLConverter, error) {
@@ -25,7 +36,9 @@ func NewConverterWithDoc(doc *etree.Document, config converter.IDlConverterConfi
 		return nil, err
 	}
 	c := &ArXMLConverter{
-		Parser: parser,
+		Parser:      parser,
+		config:      config,
+		e2eCheckers: e2e.NewCheckers(),
 	}
 	if err := c.Parser.Parse(); err != nil {
 		return nil, err
@@ -49,7 +62,9 @@ func NewConverter(path string, config converter.IDlConverterConfig) (*ArXMLConve
 		return nil, err
 	}
 	c := &ArXMLConverter{
-		Parser: parser,
+		Parser:      parser,
+		config:      config,
+		e2eCheckers: e2e.NewCheckers(),
 	}
 	if err := c.Parser.Parse(); err != nil {
 		return nil, err
@@ -67,53 +82,174 @@ func NewConverter(path string, config converter.IDlConverterConfig) (*ArXMLConve
 	return c, nil
 }
 
+// DecodeWithID 解析 event 与 field notifier, eventID 为 method 时按 request 解析
 func (c *ArXMLConverter) DecodeWithID(serviceID, eventID int, data []byte) (string, interface{}, error) {
-	name, t, err := c.GetTypeByID(serviceID, eventID)
+	return c.DecodeMessage(serviceID, eventID, someip.MessageTypeRequest, data)
+}
+
+// DecodeMessage 根据 SOME/IP message type 解析 payload, method 的 request 解析 IN/INOUT 参数, response 解析 OUT/INOUT 参数
+func (c *ArXMLConverter) DecodeMessage(serviceID, methodID int, messageType someip.MessageType, data []byte) (string, interface{}, error) {
+	return c.DecodeVersionedMessage(serviceID, ast.AnyMajorVersion, methodID, messageType, data)
+}
+
+// DecodeVersionedMessage 以 SOME/IP header 中的 interface version 选择 service 部署, E2E 保护的 event 只去掉 E2E header
+func (c *ArXMLConverter) DecodeVersionedMessage(serviceID, majorVersion, methodID int, messageType someip.MessageType, data []byte) (string, interface{}, error) {
+	name, result, _, err := c.DecodeProtectedMessage("", serviceID, majorVersion, methodID, messageType, nil, data)
+	return name, result, err
+}
+
+// DecodeProtectedMessage 校验并去掉 E2E header 后解析 payload. upperHeader 为 SOME/IP header 中 request id 开始的 8 字节,
+// 为 nil 时不校验. source 标识发送端, E2E counter 按 source 分别校验. 未配置 E2E 保护的元素返回的 e2e.Result 为 nil
+func (c *ArXMLConverter) DecodeProtectedMessage(source string, serviceID, majorVersion, methodID int, messageType someip.MessageType, upperHeader, data []byte) (string, interface{}, *e2e.Result, error) {
+	svc, err := c.Parser.LookupService(serviceID, majorVersion)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
+	}
+	if method, ok := svc.Methods[methodID]; ok {
+		name, result, err := c.decodeMethod(svc, method, messageType, data)
+		return name, result, nil, err
+	}
+	if accessor, ok := svc.FieldAccessors[methodID]; ok {
+		switch {
+		case !messageType.IsRequest() && !messageType.IsResponse():
+			return "", nil, nil, fmt.Errorf("unsupported message type 0x%02x for field %v", uint8(messageType), accessor.Name())
+		case accessor.Kind == parser.FieldGetter && messageType.IsRequest():
+			// getter request 不携带 payload
+			return accessor.Name(), nil, nil, nil
+		}
+	}
+	r, err := c.resolveTypeByID(svc, methodID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var e2eResult *e2e.Result
+	if cfg, ok := c.Parser.E2EProtections[r.elementRef]; ok {
+		checker, err := c.e2eCheckers.Get(source, r.elementRef, cfg)
+		if err != nil {
+			return r.name, nil, nil, err
+		}
+		e2eResult, data, err = checker.Check(upperHeader, data)
+		if err != nil {
+			return r.name, nil, nil, fmt.Errorf("event %v: %v", r.name, err)
+		}
 	}
+	if c.transformer.RequiresSomeIPDecoder(r.typeKey) {
+		result, err := c.newSomeIPDecoder(r.elementRef).DecodeByRef(r.typeKey, data)
+		return r.name, result, e2eResult, err
+	}
+	result, _, err := c.idlConverter.ParseDataByType(data, r.typeRef, *c.idlModule)
+	return r.name, result, e2eResult, err
+}
 
-	result, _, err := c.idlConverter.ParseDataByType(data, t, *c.idlModule)
-	return name, result, err
+func (c *ArXMLConverter) decodeMethod(svc *parser.Service, method parser.Method, messageType someip.MessageType, data []byte) (string, interface{}, error) {
+	targetInterface, ok := c.Parser.Interfaces[svc.ServiceInterfaceRef]
+	if !ok {
+		return "", nil, fmt.Errorf("interface %v not found for serviceID %v", svc.ServiceInterfaceRef, svc.ServiceID)
+	}
+	targetMethod, ok := targetInterface.Methods[method.MethodRef]
+	if !ok {
+		return "", nil, fmt.Errorf("method %v not found in interface %v", method.MethodRef, targetInterface.Shortname)
+	}
+	var args []*ast.Argument
+	switch {
+	case messageType.IsRequest():
+		args = ast.RequestArguments(targetMethod.Arguments)
+	case messageType.IsResponse():
+		args = ast.ResponseArguments(targetMethod.Arguments)
+	default:
+		return "", nil, fmt.Errorf("unsupported message type 0x%02x for method %v", uint8(messageType), method.ShortName)
+	}
+	result, err := c.newSomeIPDecoder(method.MethodRef).DecodeArguments(args, data)
+	return method.ShortName, result, err
+}
+
+func (c *ArXMLConverter) newSomeIPDecoder(elementRef string) *someip.Decoder {
+	props := c.Parser.GetTransformationProps(elementRef)
+	return someip.NewDecoder(someip.Config{
+		IsLittleEndian:          c.config.IsLittleEndian,
+		LengthFieldLength:       c.config.LengthFieldLength,
+		ArrayLengthFieldLength:  props.SizeOfArrayLengthField,
+		StringLengthFieldLength: props.SizeOfStringLengthField,
+		StructLengthFieldLength: props.SizeOfStructLengthField,
+		UnionLengthFieldLength:  props.SizeOfUnionLengthField,
+		UnionTypeSelectorLength: props.SizeOfUnionTypeSelectorField,
+	}, c.transformer)
 }
 
 func (c *ArXMLConverter) GetTypeByID(serviceID, eventID int) (string, typeref.TypeRef, error) {
-	svc, ok := c.Parser.Services[serviceID]
-	if !ok {
-		return "", nil, fmt.Errorf("service %v not found", serviceID)
+	svc, err := c.Parser.LookupService(serviceID, ast.AnyMajorVersion)
+	if err != nil {
+		return "", nil, err
 	}
-	interfaceRef := ast.ExtractTypeNameFromRef(svc.ServiceInterfaceRef)
-	targetInterface, ok := c.Parser.Interfaces[interfaceRef]
+	r, err := c.resolveTypeByID(svc, eventID)
+	if err != nil {
+		return "", nil, err
+	}
+	return r.name, r.typeRef, nil
+}
+
+func (c *ArXMLConverter) resolveTypeByID(svc *parser.Service, eventID int) (*resolvedType, error) {
+	serviceID := svc.ServiceID
+	targetInterface, ok := c.Parser.Interfaces[svc.ServiceInterfaceRef]
 	if !ok {
-		return "", nil, fmt.Errorf("interface %v not found for serviceID %v", interfaceRef, serviceID)
+		return nil, fmt.Errorf("interface %v not found for serviceID %v", svc.ServiceInterfaceRef, serviceID)
 	}
 	event, ok := svc.Events[eventID]
 	if ok {
-		eventRef := ast.ExtractTypeNameFromRef(event.EventRef)
-		targetEvent, ok := targetInterface.Events[eventRef]
+		targetEvent, ok := targetInterface.Events[event.EventRef]
 		if !ok {
-			return "", nil, fmt.Errorf("event %v not found in interface %v", eventRef, targetInterface.Shortname)
+			return nil, fmt.Errorf("event %v not found in interface %v", event.EventRef, targetInterface.Shortname)
 		}
-		typeRef := ast.ExtractTypeNameFromRef(targetEvent.TypeRef)
-		targetTypRef, ok := c.transformer.GetConverterRef()[typeRef]
+		targetTypRef, ok := c.transformer.GetConverterRef()[targetEvent.TypeRef]
 		if !ok {
-			return "", nil, fmt.Errorf("type %v not found in interface %v event %v", typeRef, interfaceRef, eventRef)
+			return nil, fmt.Errorf("type %v not found in interface %v event %v", targetEvent.TypeRef, targetInterface.Shortname, event.EventRef)
 		}
-		return event.ShortName, targetTypRef, nil
+		return &resolvedType{name: event.ShortName, elementRef: event.EventRef, typeKey: targetEvent.TypeRef, typeRef: targetTypRef}, nil
 	}
 	fieldNotify, ok := svc.FieldNotify[eventID]
 	if ok {
-		fieldNotifyRef := ast.ExtractTypeNameFromRef(fieldNotify.FieldRef)
-		targetField, ok := targetInterface.Fields[fieldNotifyRef]
-		if !ok {
-			return "", nil, fmt.Errorf("field %v not found in interface %v", fieldNotifyRef, targetInterface.Shortname)
-		}
-		typeRef := ast.ExtractTypeNameFromRef(targetField.TypeRef)
-		targetTypRef, ok := c.transformer.GetConverterRef()[typeRef]
-		if !ok {
-			return "", nil, fmt.Errorf("type %v not found in interface %v field %v", typeRef, interfaceRef, fieldNotifyRef)
+		return c.resolveFieldType(targetInterface, fieldNotify.ShortName, fieldNotify.FieldRef)
+	}
+	// getter/setter 的 request 与 response 均为 field 类型 (getter request 除外)
+	accessor, ok := svc.FieldAccessors[eventID]
+	if ok {
+		return c.resolveFieldType(targetInterface, accessor.Name(), accessor.FieldRef)
+	}
+	return nil, fmt.Errorf("unknown eventID:%v in serviceID:%v", eventID, serviceID)
+}
+
+func (c *ArXMLConverter) resolveFieldType(targetInterface *parser.ServiceInterface, name, fieldRef string) (*resolvedType, error) {
+	targetField, ok := targetInterface.Fields[fieldRef]
+	if !ok {
+		return nil, fmt.Errorf("field %v not found in interface %v", fieldRef, targetInterface.Shortname)
+	}
+	targetTypRef, ok := c.transformer.GetConverterRef()[targetField.TypeRef]
+	if !ok {
+		return nil, fmt.Errorf("type %v not found in interface %v field %v", targetField.TypeRef, targetInterface.Shortname, fieldRef)
+	}
+	return &resolvedType{name: name, elementRef: fieldRef, typeKey: targetField.TypeRef, typeRef: targetTypRef}, nil
+}
+
+// LookupServiceInstance 根据报文的 IP, 传输协议, 端口与 service id 查找对应的 service instance 部署
+func (c *ArXMLConverter) LookupServiceInstance(ip string, protocol parser.TransportProtocol, port, serviceID int) (*parser.ServiceInstanceDeployment, error) {
+	for _, d := range c.Parser.LookupDeployments(ip, protocol, port) {
+		if d.Instance.ServiceID == serviceID {
+			return d, nil
 		}
-		return fieldNotify.ShortName, targetTypRef, nil
 	}
-	return "", nil, fmt.Errorf("unknown eventID:%v in serviceID:%v", serviceID, eventID)
+	return nil, fmt.Errorf("no service instance of service %v deployed on %v %v:%v", serviceID, protocol, ip, port)
+}
+
+// GetEventGroup 返回 service 的 event group 及其成员 event
+func (c *ArXMLConverter) GetEventGroup(serviceID, eventGroupID int) (*ast.EventGroup, error) {
+	eg, ok := c.Parser.EventGroups.Lookup(uint16(serviceID), uint16(eventGroupID))
+	if !ok {
+		return nil, fmt.Errorf("no event group %v found in service %v", eventGroupID, serviceID)
+	}
+	return eg, nil
+}
+
+// FindEventGroups 返回包含该 event 的 event group
+func (c *ArXMLConverter) FindEventGroups(serviceID, eventID int) []*ast.EventGroup {
+	return c.Parser.EventGroups.FindByEvent(uint16(serviceID), uint16(eventID))
 }
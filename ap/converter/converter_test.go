@@ -6,6 +6,11 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"github.com/yisaer/idl-parser/converter"
+
+	"github.com/yisaer/arxml-converter/ap/parser"
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/e2e"
+	"github.com/yisaer/arxml-converter/someip"
 )
 
 func TestS1APCase(t *testing.T) {
@@ -33,3 +38,183 @@ func TestS1APCase(t *testing.T) {
 	}, v)
 	require.Equal(t, "reportWiFiApList", name)
 }
+
+func TestS1APUnionCase(t *testing.T) {
+	c, err := NewConverter("../../test/s1_ap_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	// length 4, type selector 1 (WiFiApNum)
+	data, err := hex.DecodeString("000000040000000100000003")
+	require.NoError(t, err)
+	name, v, err := c.DecodeWithID(33282, 32772, data)
+	require.NoError(t, err)
+	require.Equal(t, "reportWiFiApIdentifier", name)
+	require.Equal(t, map[string]interface{}{"WiFiApNum": int32(3)}, v)
+
+	// length 64, type selector 2 (WiFiApName)
+	name64 := make([]byte, 64)
+	copy(name64, []byte("\xef\xbb\xbfEnglish WIFI"))
+	data, err = hex.DecodeString("0000004000000002")
+	require.NoError(t, err)
+	_, v, err = c.DecodeWithID(33282, 32772, append(data, name64...))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"WiFiApName": "English WIFI"}, v)
+
+	data, err = hex.DecodeString("0000000000000003")
+	require.NoError(t, err)
+	_, _, err = c.DecodeWithID(33282, 32772, data)
+	require.Error(t, err)
+}
+
+func TestS1APTLVCase(t *testing.T) {
+	c, err := NewConverter("../../test/s1_ap_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	name64 := make([]byte, 64)
+	copy(name64, []byte("\xef\xbb\xbfEnglish WIFI"))
+	// data id 3 (wiFiEncryption, wire type 2), 未知 data id 9 (wire type 2), data id 1 (wiFiApName, wire type 6)
+	data, err := hex.DecodeString("200300000002200900000007" + "60010040")
+	require.NoError(t, err)
+	name, v, err := c.DecodeWithID(33282, 32773, append(data, name64...))
+	require.NoError(t, err)
+	require.Equal(t, "reportWiFiApDetail", name)
+	require.Equal(t, map[string]interface{}{
+		"wiFiApName":     "English WIFI",
+		"wiFiEncryption": int32(2),
+	}, v)
+
+	// 缺少非 optional 成员 wiFiApName
+	data, err = hex.DecodeString("2002000000382003" + "00000002")
+	require.NoError(t, err)
+	_, _, err = c.DecodeWithID(33282, 32773, data)
+	require.Error(t, err)
+}
+
+func TestS1APMethodCase(t *testing.T) {
+	c, err := NewConverter("../../test/s1_ap_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	name64 := make([]byte, 64)
+	copy(name64, []byte("\xef\xbb\xbfEnglish WIFI"))
+	name, v, err := c.DecodeMessage(33282, 5, someip.MessageTypeRequest, name64)
+	require.NoError(t, err)
+	require.Equal(t, "removeWiFiLoginInfo", name)
+	require.Equal(t, map[string]interface{}{"WiFiApName_para1": "English WIFI"}, v)
+
+	name, v, err = c.DecodeMessage(33282, 5, someip.MessageTypeResponse, []byte{0x00, 0x01})
+	require.NoError(t, err)
+	require.Equal(t, "removeWiFiLoginInfo", name)
+	require.Equal(t, map[string]interface{}{"paraOut": uint16(1)}, v)
+
+	_, _, err = c.DecodeMessage(33282, 5, someip.MessageTypeError, []byte{0x00, 0x01})
+	require.Error(t, err)
+}
+
+func TestS1APFieldCase(t *testing.T) {
+	c, err := NewConverter("../../test/s1_ap_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	value := []byte{0x00, 0x00, 0x00, 0x01}
+
+	name, v, err := c.DecodeWithID(33282, 32774, value)
+	require.NoError(t, err)
+	require.Equal(t, "wiFiSwitch", name)
+	require.Equal(t, int32(1), v)
+
+	// getter request 不携带 payload
+	name, v, err = c.DecodeMessage(33282, 6, someip.MessageTypeRequest, nil)
+	require.NoError(t, err)
+	require.Equal(t, "wiFiSwitch.getter", name)
+	require.Nil(t, v)
+
+	name, v, err = c.DecodeMessage(33282, 6, someip.MessageTypeResponse, value)
+	require.NoError(t, err)
+	require.Equal(t, "wiFiSwitch.getter", name)
+	require.Equal(t, int32(1), v)
+
+	for _, messageType := range []someip.MessageType{someip.MessageTypeRequest, someip.MessageTypeResponse} {
+		name, v, err = c.DecodeMessage(33282, 7, messageType, value)
+		require.NoError(t, err)
+		require.Equal(t, "wiFiSwitch.setter", name)
+		require.Equal(t, int32(1), v)
+	}
+
+	_, _, err = c.DecodeMessage(33282, 7, someip.MessageTypeError, value)
+	require.Error(t, err)
+}
+
+func TestS1APLookupServiceInstance(t *testing.T) {
+	c, err := NewConverter("../../test/s1_ap_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	d, err := c.LookupServiceInstance("192.168.62.1", parser.TransportTCP, 30552, 33282)
+	require.NoError(t, err)
+	require.Equal(t, "INI_WiFiStation_TBOX_SomeipPIns_1", d.Instance.ShortName)
+	_, err = c.LookupServiceInstance("192.168.62.1", parser.TransportTCP, 30552, 1)
+	require.Error(t, err)
+}
+
+func TestS1APEventGroup(t *testing.T) {
+	c, err := NewConverter("../../test/s1_ap_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	eg, err := c.GetEventGroup(33282, 1)
+	require.NoError(t, err)
+	require.Equal(t, "INI_WiFiStation_1_EventGroup", eg.ShortName)
+	require.Equal(t, []uint16{32769, 32770, 32771}, eg.EventIDs)
+	require.Equal(t, &ast.EventGroupMulticast{Threshold: 2, Address: "239.0.0.62", Port: 30501}, eg.Multicast)
+
+	egs := c.FindEventGroups(33282, 32770)
+	require.Len(t, egs, 1)
+	require.Equal(t, uint16(1), egs[0].EventGroupID)
+	require.Empty(t, c.FindEventGroups(33282, 32774))
+
+	_, err = c.GetEventGroup(33282, 2)
+	require.Error(t, err)
+}
+
+func TestS1APE2EProtection(t *testing.T) {
+	c, err := NewConverter("../../test/s1_ap_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	cfg, ok := c.Parser.E2EProtections["/interfaces/INI_WiFiStation/reportWiFiSwitchStatus"]
+	require.True(t, ok)
+	require.Equal(t, &e2e.Config{
+		Profile:           e2e.Profile04,
+		DataID:            4660,
+		Offset:            8,
+		UpperHeaderLength: 8,
+		MinDataLength:     12,
+		MaxDataLength:     32,
+		MaxDeltaCounter:   1,
+	}, cfg)
+
+	upperHeader := []byte{0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0x02, 0x00}
+	data, err := cfg.Protect(upperHeader, []byte{0x00, 0x00, 0x00, 0x01}, 7)
+	require.NoError(t, err)
+	name, v, result, err := c.DecodeProtectedMessage("", 33282, ast.AnyMajorVersion, 32771, someip.MessageTypeNotification, upperHeader, data)
+	require.NoError(t, err)
+	require.Equal(t, "reportWiFiSwitchStatus", name)
+	require.Equal(t, int32(1), v)
+	require.Equal(t, e2e.StatusOK, result.Status)
+	require.Equal(t, uint32(7), result.Counter)
+
+	_, _, result, err = c.DecodeProtectedMessage("", 33282, ast.AnyMajorVersion, 32771, someip.MessageTypeNotification, upperHeader, data)
+	require.NoError(t, err)
+	require.Equal(t, e2e.StatusRepeated, result.Status)
+	// 不同发送端的 counter 分别校验
+	_, _, result, err = c.DecodeProtectedMessage("192.168.62.2:30552", 33282, ast.AnyMajorVersion, 32771, someip.MessageTypeNotification, upperHeader, data)
+	require.NoError(t, err)
+	require.Equal(t, e2e.StatusOK, result.Status)
+
+	// 不提供 upper header 时只去掉 E2E header
+	name, v, err = c.DecodeWithID(33282, 32771, data)
+	require.NoError(t, err)
+	require.Equal(t, "reportWiFiSwitchStatus", name)
+	require.Equal(t, int32(1), v)
+
+	data[len(data)-1] = 0x02
+	_, v, result, err = c.DecodeProtectedMessage("", 33282, ast.AnyMajorVersion, 32771, someip.MessageTypeNotification, upperHeader, data)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), v)
+	require.Equal(t, e2e.StatusError, result.Status)
+
+	// 未配置 E2E 保护的 event 不返回 E2E 状态
+	_, v, result, err = c.DecodeProtectedMessage("", 33282, ast.AnyMajorVersion, 32774, someip.MessageTypeNotification, upperHeader, []byte{0x00, 0x00, 0x00, 0x01})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), v)
+	require.Nil(t, result)
+}
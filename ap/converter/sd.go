@@ -0,0 +1,48 @@
+package converter
+
+import (
+	"github.com/yisaer/arxml-converter/ap/parser"
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/someip"
+)
+
+// DecodeSD 解析 SOME/IP-SD payload, 并以 ARXML 中的 SHORT-NAME 标注 service, instance 与 eventgroup
+func (c *ArXMLConverter) DecodeSD(payload []byte) (*someip.SDMessage, error) {
+	return someip.DecodeSD(payload, sdCatalog{p: c.Parser})
+}
+
+type sdCatalog struct {
+	p *parser.Parser
+}
+
+// ServiceName 返回 service interface 的 SHORT-NAME
+func (c sdCatalog) ServiceName(serviceID uint16, majorVersion uint8) string {
+	version := int(majorVersion)
+	if majorVersion == someip.SDAnyMajorVersion {
+		version = ast.AnyMajorVersion
+	}
+	svc, err := c.p.LookupService(int(serviceID), version)
+	if err != nil {
+		return ""
+	}
+	if si, ok := c.p.Interfaces[svc.ServiceInterfaceRef]; ok {
+		return si.Shortname
+	}
+	return svc.ShortName
+}
+
+func (c sdCatalog) InstanceName(serviceID, instanceID uint16) string {
+	instance, ok := c.p.LookupServiceInstanceByID(int(serviceID), int(instanceID))
+	if !ok {
+		return ""
+	}
+	return instance.ShortName
+}
+
+func (c sdCatalog) EventgroupName(serviceID, eventgroupID uint16) string {
+	eg, ok := c.p.EventGroups.Lookup(serviceID, eventgroupID)
+	if !ok {
+		return ""
+	}
+	return eg.ShortName
+}
@@ -3,11 +3,11 @@ package parser
 import (
 	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/beevik/etree"
 
 	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/util"
 )
 
 func (p *Parser) searchDataTypes(arPackageElements []*etree.Element) error {
@@ -38,7 +38,7 @@ func (p *Parser) parseDataTypes() error {
 		if err != nil {
 			return fmt.Errorf("index %d STD-CPP-IMPLEMENTATION-DATA-TYPE has err:%v", index, err.Error())
 		}
-		p.DataTypes[strings.ToLower(dt.ShorName)] = dt
+		p.DataTypes[util.GetArPath(dataType)] = dt
 	}
 	return nil
 }
@@ -61,8 +61,12 @@ func (p *Parser) parseDataType(d *etree.Element) (*ast.DataType, error) {
 		if ref == nil {
 			return nil, fmt.Errorf("no TYPE-REFERENCE-REF")
 		}
-		dt.TypReference = &ast.TypReference{Ref: ref.Text()}
-		if strings.Contains(strings.ToLower(dt.TypReference.Ref), "string") {
+		refPath, err := p.index.RefPath(ref)
+		if err != nil {
+			return nil, err
+		}
+		dt.TypReference = &ast.TypReference{Ref: refPath}
+		if p.isStringType(refPath) {
 			stringSize := d.SelectElement("ARRAY-SIZE")
 			if stringSize == nil {
 				return nil, fmt.Errorf("no ARRAY-SIZE for TYPE-REFERENCE-REF string")
@@ -86,8 +90,12 @@ func (p *Parser) parseDataType(d *etree.Element) (*ast.DataType, error) {
 		if typRef == nil {
 			return nil, fmt.Errorf("no TEMPLATE-TYPE-REF in CPP-TEMPLATE-ARGUMENT")
 		}
+		refPath, err := p.index.RefPath(typRef)
+		if err != nil {
+			return nil, err
+		}
 		dt.Vector = &ast.Vector{
-			RefType: typRef.Text(),
+			RefType: refPath,
 		}
 	case "ARRAY":
 		arraySize := d.SelectElement("ARRAY-SIZE")
@@ -118,15 +126,27 @@ func (p *Parser) parseDataType(d *etree.Element) (*ast.DataType, error) {
 		if typRef == nil {
 			return nil, fmt.Errorf("no TEMPLATE-TYPE-REF in CPP-TEMPLATE-ARGUMENT")
 		}
+		refPath, err := p.index.RefPath(typRef)
+		if err != nil {
+			return nil, err
+		}
 		dt.Array = &ast.Array{
 			ArraySize: as,
 			Inplace:   ip,
-			RefType:   typRef.Text(),
+			RefType:   refPath,
+		}
+	case "ASSOCIATIVE_MAP":
+		if err := p.ParseMap(dt, d); err != nil {
+			return nil, err
 		}
 	case "STRUCTURE":
 		if err := p.ParseStructure(dt, d); err != nil {
 			return nil, err
 		}
+	case "VARIANT":
+		if err := p.ParseVariant(dt, d); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, fmt.Errorf("invalid category: %s", dt.Category)
 	}
@@ -164,10 +184,116 @@ func (p *Parser) ParseStructure(dt *ast.DataType, d *etree.Element) error {
 		if trd == nil {
 			return fmt.Errorf("no TYPE-REFERENCE-REF in TYPE-REFERENCE")
 		}
+		refPath, err := p.index.RefPath(trd)
+		if err != nil {
+			return err
+		}
+		isOptional, err := util.GetIsOptional(cppElement)
+		if err != nil {
+			return err
+		}
 		str.ShorName = sn.Text()
 		str.InPlace = ip
-		str.Ref = trd.Text()
+		str.Ref = refPath
+		str.IsOptional = isOptional
+		if dataID, ok := p.tlvDataIDs[util.GetArPath(cppElement)]; ok {
+			str.DataID = &dataID
+		}
 		dt.Structure.STRList = append(dt.Structure.STRList, str)
 	}
 	return nil
 }
+
+// ParseVariant 解析 VARIANT, 每个 CPP-TEMPLATE-ARGUMENT 为一个 union 成员, 成员名取引用类型的 SHORT-NAME,
+// SHORT-NAME 相同的成员追加参数序号以区分
+func (p *Parser) ParseVariant(dt *ast.DataType, d *etree.Element) error {
+	args := d.SelectElement("TEMPLATE-ARGUMENTS")
+	if args == nil {
+		return fmt.Errorf("no TEMPLATE-ARGUMENTS")
+	}
+	cppArgs := args.SelectElements("CPP-TEMPLATE-ARGUMENT")
+	if len(cppArgs) < 1 {
+		return fmt.Errorf("no CPP-TEMPLATE-ARGUMENT in TEMPLATE-ARGUMENTS")
+	}
+	dt.Union = &ast.Union{
+		Members: make([]*ast.StructureTypRef, 0, len(cppArgs)),
+	}
+	for _, cppArg := range cppArgs {
+		typRef := cppArg.SelectElement("TEMPLATE-TYPE-REF")
+		if typRef == nil {
+			return fmt.Errorf("no TEMPLATE-TYPE-REF in CPP-TEMPLATE-ARGUMENT")
+		}
+		refPath, err := p.index.RefPath(typRef)
+		if err != nil {
+			return err
+		}
+		member := &ast.StructureTypRef{
+			Ref: refPath,
+		}
+		if inPlace := cppArg.SelectElement("INPLACE"); inPlace != nil {
+			ip, err := strconv.ParseBool(inPlace.Text())
+			if err != nil {
+				return fmt.Errorf("invalid INPLACE: %s", inPlace.Text())
+			}
+			member.InPlace = ip
+		}
+		dt.Union.Members = append(dt.Union.Members, member)
+	}
+	nameCount := make(map[string]int, len(dt.Union.Members))
+	for _, member := range dt.Union.Members {
+		nameCount[util.ExtractLast(member.Ref)]++
+	}
+	used := make(map[string]bool, len(dt.Union.Members))
+	for index, member := range dt.Union.Members {
+		name := util.ExtractLast(member.Ref)
+		if nameCount[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, index)
+		}
+		for used[name] {
+			name = fmt.Sprintf("%s_%d", name, index)
+		}
+		used[name] = true
+		member.ShorName = name
+	}
+	return nil
+}
+
+// ParseMap 解析 ASSOCIATIVE_MAP, 两个 CPP-TEMPLATE-ARGUMENT 依次为 key 与 value 的类型
+func (p *Parser) ParseMap(dt *ast.DataType, d *etree.Element) error {
+	args := d.SelectElement("TEMPLATE-ARGUMENTS")
+	if args == nil {
+		return fmt.Errorf("no TEMPLATE-ARGUMENTS")
+	}
+	cppArgs := args.SelectElements("CPP-TEMPLATE-ARGUMENT")
+	if len(cppArgs) != 2 {
+		return fmt.Errorf("ASSOCIATIVE_MAP expects 2 CPP-TEMPLATE-ARGUMENT, got %v", len(cppArgs))
+	}
+	refs := make([]string, 0, len(cppArgs))
+	for _, cppArg := range cppArgs {
+		typRef := cppArg.SelectElement("TEMPLATE-TYPE-REF")
+		if typRef == nil {
+			return fmt.Errorf("no TEMPLATE-TYPE-REF in CPP-TEMPLATE-ARGUMENT")
+		}
+		refPath, err := p.index.RefPath(typRef)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, refPath)
+	}
+	dt.Map = &ast.Map{
+		KeyRef:   refs[0],
+		ValueRef: refs[1],
+	}
+	return nil
+}
+
+// isStringType 判断引用的类型是否为字符串: 文档中定义的类型按 CATEGORY 判断,
+// 未在文档中定义的标准类型按类型名匹配
+func (p *Parser) isStringType(refPath string) bool {
+	if target, ok := p.index.Lookup(refPath); ok {
+		category, err := util.GetCategory(target)
+		return err == nil && category == "STRING"
+	}
+	kind, ok := ast.GetStandardBasicKind(refPath)
+	return ok && kind == ast.BasicString
+}
@@ -0,0 +1,374 @@
+package parser
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+type ServiceInstanceKind string
+
+const (
+	ProvidedServiceInstance ServiceInstanceKind = "provided"
+	RequiredServiceInstance ServiceInstanceKind = "required"
+)
+
+type TransportProtocol string
+
+const (
+	TransportTCP TransportProtocol = "TCP"
+	TransportUDP TransportProtocol = "UDP"
+)
+
+// ServiceInstance 对应 PROVIDED/REQUIRED-SOMEIP-SERVICE-INSTANCE
+type ServiceInstance struct {
+	ShortName  string
+	Path       string
+	Kind       ServiceInstanceKind
+	ServiceID  int
+	InstanceID int
+	// DeploymentRef 为 SOMEIP-SERVICE-INTERFACE-DEPLOYMENT 的 AR 路径
+	DeploymentRef string
+}
+
+type NetworkAddress struct {
+	IP   string
+	Mask string
+}
+
+type VLAN struct {
+	ShortName  string
+	Identifier int
+}
+
+// ServiceInstanceDeployment 为 SOMEIP-SERVICE-INSTANCE-TO-MACHINE-MAPPING 中一个 service instance 的部署信息
+type ServiceInstanceDeployment struct {
+	Instance        *ServiceInstance
+	Machine         string
+	NetworkEndpoint string
+	Addresses       []NetworkAddress
+	// VLAN 为 nil 时 network endpoint 所在 channel 未配置 VLAN
+	VLAN    *VLAN
+	TCPPort int
+	UDPPort int
+}
+
+// Endpoints 返回部署使用的传输协议与端口
+func (d *ServiceInstanceDeployment) Endpoints() map[TransportProtocol]int {
+	endpoints := make(map[TransportProtocol]int, 2)
+	if d.TCPPort > 0 {
+		endpoints[TransportTCP] = d.TCPPort
+	}
+	if d.UDPPort > 0 {
+		endpoints[TransportUDP] = d.UDPPort
+	}
+	return endpoints
+}
+
+type endpointKey struct {
+	ip       string
+	protocol TransportProtocol
+	port     int
+}
+
+func (p *Parser) parseDeployments(eles *etree.Element) error {
+	for _, kind := range []ServiceInstanceKind{ProvidedServiceInstance, RequiredServiceInstance} {
+		tag, idTag := "PROVIDED-SOMEIP-SERVICE-INSTANCE", "SERVICE-INSTANCE-ID"
+		if kind == RequiredServiceInstance {
+			tag, idTag = "REQUIRED-SOMEIP-SERVICE-INSTANCE", "REQUIRED-SERVICE-INSTANCE-ID"
+		}
+		for _, e := range eles.SelectElements(tag) {
+			instance, err := p.parseServiceInstance(e, kind, idTag)
+			if err != nil {
+				return fmt.Errorf("parsing %v: %w", tag, err)
+			}
+			p.ServiceInstances[instance.Path] = instance
+		}
+	}
+	for _, mapping := range eles.SelectElements("SOMEIP-SERVICE-INSTANCE-TO-MACHINE-MAPPING") {
+		deployments, err := p.parseServiceInstanceToMachineMapping(mapping)
+		if err != nil {
+			return fmt.Errorf("parsing SOMEIP-SERVICE-INSTANCE-TO-MACHINE-MAPPING: %w", err)
+		}
+		for _, d := range deployments {
+			p.Deployments = append(p.Deployments, d)
+			for _, addr := range d.Addresses {
+				for protocol, port := range d.Endpoints() {
+					key := endpointKey{ip: addr.IP, protocol: protocol, port: port}
+					p.endpoints[key] = append(p.endpoints[key], d)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Parser) parseServiceInstance(e *etree.Element, kind ServiceInstanceKind, idTag string) (*ServiceInstance, error) {
+	sn, err := util.GetShortname(e)
+	if err != nil {
+		return nil, err
+	}
+	instance := &ServiceInstance{
+		ShortName: sn,
+		Path:      util.GetArPath(e),
+		Kind:      kind,
+	}
+	idRaw := e.SelectElement(idTag)
+	if idRaw == nil {
+		return nil, fmt.Errorf("no %v in service instance %v", idTag, sn)
+	}
+	instance.InstanceID, err = strconv.Atoi(strings.TrimSpace(idRaw.Text()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v in service instance %v", idTag, sn)
+	}
+	refRaw := e.SelectElement("SERVICE-INTERFACE-DEPLOYMENT-REF")
+	if refRaw == nil {
+		return nil, fmt.Errorf("no SERVICE-INTERFACE-DEPLOYMENT-REF in service instance %v", sn)
+	}
+	target, err := p.index.Resolve(refRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE-INTERFACE-DEPLOYMENT-REF in service instance %v: %v", sn, err)
+	}
+	instance.DeploymentRef = util.GetArPath(target)
+	sid := target.SelectElement("SERVICE-INTERFACE-ID")
+	if sid == nil {
+		return nil, fmt.Errorf("no SERVICE-INTERFACE-ID in deployment %v", instance.DeploymentRef)
+	}
+	instance.ServiceID, err = strconv.Atoi(strings.TrimSpace(sid.Text()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE-INTERFACE-ID in deployment %v", instance.DeploymentRef)
+	}
+	if kind == ProvidedServiceInstance {
+		if err := p.parseProvidedEventGroups(e); err != nil {
+			return nil, fmt.Errorf("service instance %v: %v", sn, err)
+		}
+	}
+	return instance, nil
+}
+
+// parseProvidedEventGroups 解析 SOMEIP-PROVIDED-EVENT-GROUP 的组播配置
+func (p *Parser) parseProvidedEventGroups(e *etree.Element) error {
+	pegs := e.SelectElement("PROVIDED-EVENT-GROUPS")
+	if pegs == nil {
+		return nil
+	}
+	for _, peg := range pegs.SelectElements("SOMEIP-PROVIDED-EVENT-GROUP") {
+		refRaw := peg.SelectElement("EVENT-GROUP-REF")
+		if refRaw == nil {
+			return fmt.Errorf("no EVENT-GROUP-REF in provided event group")
+		}
+		target, err := p.index.Resolve(refRaw)
+		if err != nil {
+			return fmt.Errorf("invalid EVENT-GROUP-REF in provided event group: %v", err)
+		}
+		eg, ok := p.eventGroupPaths[util.GetArPath(target)]
+		if !ok {
+			return fmt.Errorf("event group %v not found", util.GetArPath(target))
+		}
+		multicast, err := parseEventGroupMulticast(peg)
+		if err != nil {
+			return fmt.Errorf("event group %v: %v", eg.ShortName, err)
+		}
+		if multicast != nil && eg.Multicast == nil {
+			eg.Multicast = multicast
+		}
+	}
+	return nil
+}
+
+// parseEventGroupMulticast 未配置组播地址与阈值时返回 nil
+func parseEventGroupMulticast(peg *etree.Element) (*ast.EventGroupMulticast, error) {
+	multicast := &ast.EventGroupMulticast{}
+	configured := false
+	for _, tag := range []string{"IPV-4-MULTICAST-IP-ADDRESS", "IPV-6-MULTICAST-IP-ADDRESS"} {
+		raw := peg.SelectElement(tag)
+		if raw == nil {
+			continue
+		}
+		ip := net.ParseIP(strings.TrimSpace(raw.Text()))
+		if ip == nil || !ip.IsMulticast() {
+			return nil, fmt.Errorf("invalid %v %v", tag, raw.Text())
+		}
+		multicast.Address = ip.String()
+		configured = true
+		break
+	}
+	for tag, value := range map[string]*int{"EVENT-MULTICAST-UDP-PORT": &multicast.Port, "MULTICAST-THRESHOLD": &multicast.Threshold} {
+		raw := peg.SelectElement(tag)
+		if raw == nil {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(raw.Text()))
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid %v %v", tag, raw.Text())
+		}
+		*value = v
+		configured = true
+	}
+	if !configured {
+		return nil, nil
+	}
+	return multicast, nil
+}
+
+func (p *Parser) parseServiceInstanceToMachineMapping(mapping *etree.Element) ([]*ServiceInstanceDeployment, error) {
+	sn, err := util.GetShortname(mapping)
+	if err != nil {
+		return nil, err
+	}
+	connectorRef := mapping.SelectElement("COMMUNICATION-CONNECTOR-REF")
+	if connectorRef == nil {
+		return nil, fmt.Errorf("no COMMUNICATION-CONNECTOR-REF in %v", sn)
+	}
+	connector, err := p.index.Resolve(connectorRef)
+	if err != nil {
+		return nil, fmt.Errorf("invalid COMMUNICATION-CONNECTOR-REF in %v: %v", sn, err)
+	}
+	template := &ServiceInstanceDeployment{}
+	if machine := findAncestor(connector, "MACHINE-DESIGN"); machine != nil {
+		template.Machine, err = util.GetShortname(machine)
+		if err != nil {
+			return nil, err
+		}
+	}
+	nepRef := connector.SelectElement("UNICAST-NETWORK-ENDPOINT-REF")
+	if nepRef == nil {
+		return nil, fmt.Errorf("no UNICAST-NETWORK-ENDPOINT-REF in connector %v", util.GetArPath(connector))
+	}
+	nep, err := p.index.Resolve(nepRef)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UNICAST-NETWORK-ENDPOINT-REF in connector %v: %v", util.GetArPath(connector), err)
+	}
+	template.NetworkEndpoint = util.GetArPath(nep)
+	template.Addresses, err = parseNetworkEndpointAddresses(nep)
+	if err != nil {
+		return nil, fmt.Errorf("network endpoint %v: %v", template.NetworkEndpoint, err)
+	}
+	if channel := findAncestor(nep, "ETHERNET-PHYSICAL-CHANNEL"); channel != nil {
+		template.VLAN, err = parseVLAN(channel)
+		if err != nil {
+			return nil, fmt.Errorf("channel %v: %v", util.GetArPath(channel), err)
+		}
+	}
+	for tag, port := range map[string]*int{"TCP-PORT": &template.TCPPort, "UDP-PORT": &template.UDPPort} {
+		raw := mapping.SelectElement(tag)
+		if raw == nil {
+			continue
+		}
+		*port, err = strconv.Atoi(strings.TrimSpace(raw.Text()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v in %v", tag, sn)
+		}
+	}
+
+	refs := mapping.SelectElement("SERVICE-INSTANCE-REFS")
+	if refs == nil {
+		return nil, fmt.Errorf("no SERVICE-INSTANCE-REFS in %v", sn)
+	}
+	deployments := make([]*ServiceInstanceDeployment, 0)
+	for _, ref := range refs.SelectElements("SERVICE-INSTANCE-REF") {
+		target, err := p.index.Resolve(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SERVICE-INSTANCE-REF in %v: %v", sn, err)
+		}
+		instance, ok := p.ServiceInstances[util.GetArPath(target)]
+		if !ok {
+			return nil, fmt.Errorf("service instance %v not found for %v", util.GetArPath(target), sn)
+		}
+		d := *template
+		d.Instance = instance
+		deployments = append(deployments, &d)
+	}
+	return deployments, nil
+}
+
+func parseNetworkEndpointAddresses(nep *etree.Element) ([]NetworkAddress, error) {
+	addresses := nep.SelectElement("NETWORK-ENDPOINT-ADDRESSES")
+	if addresses == nil {
+		return nil, fmt.Errorf("no NETWORK-ENDPOINT-ADDRESSES")
+	}
+	result := make([]NetworkAddress, 0)
+	for _, config := range addresses.ChildElements() {
+		var addrTag, maskTag string
+		switch config.Tag {
+		case "IPV-4-CONFIGURATION":
+			addrTag, maskTag = "IPV-4-ADDRESS", "NETWORK-MASK"
+		case "IPV-6-CONFIGURATION":
+			addrTag, maskTag = "IPV-6-ADDRESS", "IP-ADDRESS-PREFIX-LENGTH"
+		default:
+			continue
+		}
+		addr := config.SelectElement(addrTag)
+		if addr == nil {
+			continue
+		}
+		ip := net.ParseIP(strings.TrimSpace(addr.Text()))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid %v %v", addrTag, addr.Text())
+		}
+		na := NetworkAddress{IP: ip.String()}
+		if mask := config.SelectElement(maskTag); mask != nil {
+			na.Mask = strings.TrimSpace(mask.Text())
+		}
+		result = append(result, na)
+	}
+	return result, nil
+}
+
+func parseVLAN(channel *etree.Element) (*VLAN, error) {
+	vlan := channel.SelectElement("VLAN")
+	if vlan == nil {
+		return nil, nil
+	}
+	sn, err := util.GetShortname(vlan)
+	if err != nil {
+		return nil, err
+	}
+	v := &VLAN{ShortName: sn}
+	if id := vlan.SelectElement("VLAN-IDENTIFIER"); id != nil {
+		v.Identifier, err = strconv.Atoi(strings.TrimSpace(id.Text()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid VLAN-IDENTIFIER %v", id.Text())
+		}
+	}
+	return v, nil
+}
+
+func findAncestor(e *etree.Element, tag string) *etree.Element {
+	for parent := e.Parent(); parent != nil; parent = parent.Parent() {
+		if parent.Tag == tag {
+			return parent
+		}
+	}
+	return nil
+}
+
+// LookupDeployments 根据 IP, 传输协议与端口查找部署在该 endpoint 上的 service instance
+func (p *Parser) LookupDeployments(ip string, protocol TransportProtocol, port int) []*ServiceInstanceDeployment {
+	if parsed := net.ParseIP(strings.TrimSpace(ip)); parsed != nil {
+		ip = parsed.String()
+	}
+	return p.endpoints[endpointKey{ip: ip, protocol: protocol, port: port}]
+}
+
+// LookupServiceInstanceByID 根据 service id 与 instance id 查找 service instance, 同时存在 provided 与 required
+// instance 时返回 provided instance
+func (p *Parser) LookupServiceInstanceByID(serviceID, instanceID int) (*ServiceInstance, bool) {
+	var found *ServiceInstance
+	for _, instance := range p.ServiceInstances {
+		if instance.ServiceID != serviceID || instance.InstanceID != instanceID {
+			continue
+		}
+		if found == nil || instance.Kind == ProvidedServiceInstance && found.Kind != ProvidedServiceInstance ||
+			instance.Kind == found.Kind && instance.Path < found.Path {
+			found = instance
+		}
+	}
+	return found, found != nil
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/e2e"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+const (
+	// SOME/IP 的 E2E header 默认位于 SOME/IP header 之后, upper header 为 request id 开始的 8 字节
+	defaultE2EOffset      = 8
+	defaultE2EUpperHeader = 8
+)
+
+// parseE2EProtections 解析 END-2-END-EVENT-PROTECTION-PROPS, 以受保护 event 的 AR 路径为 key
+func (p *Parser) parseE2EProtections(autoSar *etree.Element) error {
+	for _, props := range autoSar.FindElements("//END-2-END-EVENT-PROTECTION-PROPS") {
+		sn, err := util.GetShortname(props)
+		if err != nil {
+			return fmt.Errorf("END-2-END-EVENT-PROTECTION-PROPS has err:%v", err)
+		}
+		profileRef := props.SelectElement("E-2-E-PROFILE-CONFIGURATION-REF")
+		if profileRef == nil {
+			return fmt.Errorf("no E-2-E-PROFILE-CONFIGURATION-REF in e2e props %v", sn)
+		}
+		profileConfig, err := p.index.Resolve(profileRef)
+		if err != nil {
+			return fmt.Errorf("invalid E-2-E-PROFILE-CONFIGURATION-REF in e2e props %v: %v", sn, err)
+		}
+		eventRef := props.FindElement("EVENT-IREF/TARGET-EVENT-REF")
+		if eventRef == nil {
+			eventRef = props.SelectElement("EVENT-REF")
+		}
+		if eventRef == nil {
+			return fmt.Errorf("no EVENT-IREF in e2e props %v", sn)
+		}
+		eventPath, err := p.index.RefPath(eventRef)
+		if err != nil {
+			return fmt.Errorf("invalid EVENT-IREF in e2e props %v: %v", sn, err)
+		}
+		cfg, err := e2e.ParseConfig(profileConfig, props, defaultE2EOffset, defaultE2EUpperHeader)
+		if err != nil {
+			return fmt.Errorf("invalid e2e props %v: %v", sn, err)
+		}
+		p.E2EProtections[eventPath] = cfg
+	}
+	return nil
+}
@@ -6,6 +6,9 @@ import (
 	"strings"
 
 	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/util"
 )
 
 func (p *Parser) parseIautoSar() error {
@@ -22,7 +25,20 @@ func (p *Parser) parseIautoSar() error {
 		if err != nil {
 			return fmt.Errorf("parsing service interface: %w", err)
 		}
-		p.Services[service.ServiceID] = service
+		key := service.Key()
+		if existed, ok := p.Services[key]; ok {
+			return fmt.Errorf("service %v major version %v deployed by both %v and %v", service.ServiceID, service.MajorVersion, existed.ShortName, service.ShortName)
+		}
+		p.Services[key] = service
+		for _, eg := range service.EventGroups {
+			p.EventGroups.Register(eg)
+		}
+	}
+	if err := p.parseTransformationProps(eles); err != nil {
+		return fmt.Errorf("parsing transformation props: %w", err)
+	}
+	if err := p.parseDeployments(eles); err != nil {
+		return fmt.Errorf("parsing deployments: %w", err)
 	}
 	return nil
 }
@@ -31,6 +47,10 @@ func (p *Parser) ParseServiceInterface(si *etree.Element) (*Service, error) {
 	s := &Service{
 		Events:      make(map[int]Event),
 		FieldNotify: make(map[int]FieldNotify),
+		Methods:     make(map[int]Method),
+
+		FieldAccessors: make(map[int]FieldAccessor),
+		EventGroups:    make(map[int]*ast.EventGroup),
 	}
 	sn := si.SelectElement("SHORT-NAME")
 	if sn == nil {
@@ -50,11 +70,32 @@ func (p *Parser) ParseServiceInterface(si *etree.Element) (*Service, error) {
 	if refRaw == nil {
 		return nil, fmt.Errorf("no SERVICE-INTERFACE REF in service %v", s.ShortName)
 	}
-	s.ServiceInterfaceRef = refRaw.Text()
-	if s.ServiceInterfaceRef == "" {
+	if refRaw.Text() == "" {
 		return nil, fmt.Errorf("no SERVICE-INTERFACE REF in service %v", s.ShortName)
 	}
+	target, err := p.index.Resolve(refRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE-INTERFACE REF in service %v: %v", s.ShortName, err)
+	}
+	s.ServiceInterfaceRef = util.GetArPath(target)
+	// 优先使用 deployment 的 SERVICE-INTERFACE-VERSION, 未配置时使用 SERVICE-INTERFACE 的版本
+	versionElement := si.SelectElement("SERVICE-INTERFACE-VERSION")
+	if versionElement == nil {
+		versionElement = target
+	}
+	if err := parseServiceVersion(versionElement, s); err != nil {
+		return nil, fmt.Errorf("invalid version in service %v: %v", s.ShortName, err)
+	}
 
+	// event, field notifier, getter/setter 与 method 共用 SOME/IP header 中的 ID, 不能重复
+	ids := make(map[int]string)
+	addID := func(id int, name string) error {
+		if exist, ok := ids[id]; ok {
+			return fmt.Errorf("duplicate ID %v in service %v: %v and %v", id, s.ShortName, exist, name)
+		}
+		ids[id] = name
+		return nil
+	}
 	eds := si.SelectElement("EVENT-DEPLOYMENTS")
 	if eds != nil {
 		eventdeployments := eds.SelectElements("SOMEIP-EVENT-DEPLOYMENT")
@@ -77,8 +118,15 @@ func (p *Parser) ParseServiceInterface(si *etree.Element) (*Service, error) {
 			if refRaw == nil {
 				return nil, fmt.Errorf("no EVENT-REF in service %v, event %v", s.ShortName, event.ShortName)
 			}
-			event.EventRef = refRaw.Text()
+			target, err := p.index.Resolve(refRaw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EVENT-REF in service %v, event %v: %v", s.ShortName, event.ShortName, err)
+			}
+			event.EventRef = util.GetArPath(target)
 			event.EventID = eid
+			if err := addID(eid, "event "+event.ShortName); err != nil {
+				return nil, err
+			}
 			s.Events[eid] = event
 		}
 	}
@@ -96,7 +144,11 @@ func (p *Parser) ParseServiceInterface(si *etree.Element) (*Service, error) {
 			if refRaw == nil || refRaw.Text() == "" {
 				return nil, fmt.Errorf("no FIELD-REF in service %v field deployment %v", s.ShortName, fieldNotify.ShortName)
 			}
-			fieldNotify.FieldRef = refRaw.Text()
+			target, err := p.index.Resolve(refRaw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid FIELD-REF in service %v field deployment %v: %v", s.ShortName, fieldNotify.ShortName, err)
+			}
+			fieldNotify.FieldRef = util.GetArPath(target)
 			notifier := fd.SelectElement("NOTIFIER")
 			if notifier != nil {
 				eidraw := notifier.SelectElement("EVENT-ID")
@@ -108,32 +160,191 @@ func (p *Parser) ParseServiceInterface(si *etree.Element) (*Service, error) {
 					return nil, fmt.Errorf("invalid EVENT-ID in service %v, fieldNotify %v", s.ShortName, fieldNotify.ShortName)
 				}
 				fieldNotify.EventID = eid
+				if err := addID(eid, "field notifier "+fieldNotify.ShortName); err != nil {
+					return nil, err
+				}
 				s.FieldNotify[eid] = fieldNotify
 			}
+			for _, kind := range []FieldAccessorKind{FieldGetter, FieldSetter} {
+				accessor, err := parseFieldAccessor(fd, kind)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %v in service %v field deployment %v: %v", kind, s.ShortName, fieldNotify.ShortName, err)
+				}
+				if accessor == nil {
+					continue
+				}
+				accessor.ShortName = fieldNotify.ShortName
+				accessor.FieldRef = fieldNotify.FieldRef
+				if err := addID(accessor.MethodID, fmt.Sprintf("field %v %v", kind, fieldNotify.ShortName)); err != nil {
+					return nil, err
+				}
+				s.FieldAccessors[accessor.MethodID] = *accessor
+			}
 
 		}
 	}
-	if len(s.Events) < 1 && len(s.FieldNotify) < 1 {
-		return nil, fmt.Errorf("no events/FieldsNotify in service %v", sn.Text())
+	mds := si.SelectElement("METHOD-DEPLOYMENTS")
+	if mds != nil {
+		for _, md := range mds.SelectElements("SOMEIP-METHOD-DEPLOYMENT") {
+			method := Method{}
+			msn := md.SelectElement("SHORT-NAME")
+			if msn == nil {
+				return nil, fmt.Errorf("no SHORT-NAME in service %v method deployment", s.ShortName)
+			}
+			method.ShortName = msn.Text()
+			midraw := md.SelectElement("METHOD-ID")
+			if midraw == nil {
+				return nil, fmt.Errorf("no METHOD-ID in service %v, method %v", s.ShortName, method.ShortName)
+			}
+			mid, err := strconv.Atoi(midraw.Text())
+			if err != nil {
+				return nil, fmt.Errorf("invalid METHOD-ID in service %v, method %v", s.ShortName, method.ShortName)
+			}
+			refRaw := md.SelectElement("METHOD-REF")
+			if refRaw == nil {
+				return nil, fmt.Errorf("no METHOD-REF in service %v, method %v", s.ShortName, method.ShortName)
+			}
+			target, err := p.index.Resolve(refRaw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid METHOD-REF in service %v, method %v: %v", s.ShortName, method.ShortName, err)
+			}
+			method.MethodRef = util.GetArPath(target)
+			method.MethodID = mid
+			if err := addID(mid, "method "+method.ShortName); err != nil {
+				return nil, err
+			}
+			s.Methods[mid] = method
+		}
+	}
+	egs := si.SelectElement("EVENT-GROUPS")
+	if egs != nil {
+		for _, eg := range egs.SelectElements("SOMEIP-EVENT-GROUP") {
+			group, err := p.parseEventGroup(eg, s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid event group in service %v: %v", s.ShortName, err)
+			}
+			s.EventGroups[int(group.EventGroupID)] = group
+		}
+	}
+	if len(s.Events) < 1 && len(s.FieldNotify) < 1 && len(s.Methods) < 1 && len(s.FieldAccessors) < 1 {
+		return nil, fmt.Errorf("no events/FieldsNotify/methods in service %v", sn.Text())
 	}
 	return s, s.Validate()
 }
 
+func parseServiceVersion(e *etree.Element, s *Service) error {
+	if major := e.SelectElement("MAJOR-VERSION"); major != nil {
+		// SOME/IP header 的 interface version 为 8 bit
+		v, err := strconv.ParseUint(strings.TrimSpace(major.Text()), 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid MAJOR-VERSION %v", major.Text())
+		}
+		s.MajorVersion = int(v)
+	}
+	if minor := e.SelectElement("MINOR-VERSION"); minor != nil {
+		v, err := strconv.ParseUint(strings.TrimSpace(minor.Text()), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid MINOR-VERSION %v", minor.Text())
+		}
+		s.MinorVersion = int(v)
+	}
+	return nil
+}
+
+// parseEventGroup 解析 SOMEIP-EVENT-GROUP, EVENT-REFS 引用 event deployment 或 field notifier
+func (p *Parser) parseEventGroup(eg *etree.Element, s *Service) (*ast.EventGroup, error) {
+	sn, err := util.GetShortname(eg)
+	if err != nil {
+		return nil, err
+	}
+	idraw := eg.SelectElement("EVENT-GROUP-ID")
+	if idraw == nil {
+		return nil, fmt.Errorf("no EVENT-GROUP-ID in event group %v", sn)
+	}
+	id, err := util.ToUint16(idraw.Text())
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT-GROUP-ID in event group %v", sn)
+	}
+	group := &ast.EventGroup{
+		ShortName:    sn,
+		ServiceID:    uint16(s.ServiceID),
+		EventGroupID: id,
+		EventIDs:     make([]uint16, 0),
+	}
+	p.eventGroupPaths[util.GetArPath(eg)] = group
+	refs := eg.SelectElement("EVENT-REFS")
+	if refs == nil {
+		return group, nil
+	}
+	for _, ref := range refs.SelectElements("EVENT-REF") {
+		target, err := p.index.Resolve(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EVENT-REF in event group %v: %v", sn, err)
+		}
+		eidraw := target.SelectElement("EVENT-ID")
+		if eidraw == nil {
+			return nil, fmt.Errorf("no EVENT-ID in %v referenced by event group %v", util.GetArPath(target), sn)
+		}
+		eid, err := util.ToUint16(eidraw.Text())
+		if err != nil {
+			return nil, fmt.Errorf("invalid EVENT-ID in %v referenced by event group %v", util.GetArPath(target), sn)
+		}
+		group.AddEvent(eid)
+	}
+	return group, nil
+}
+
+// parseFieldAccessor 解析 field deployment 的 GET/SET, 未配置时返回 nil
+func parseFieldAccessor(fd *etree.Element, kind FieldAccessorKind) (*FieldAccessor, error) {
+	tags := []string{"GET", "GETTER"}
+	if kind == FieldSetter {
+		tags = []string{"SET", "SETTER"}
+	}
+	var md *etree.Element
+	for _, tag := range tags {
+		if md = fd.SelectElement(tag); md != nil {
+			break
+		}
+	}
+	if md == nil {
+		return nil, nil
+	}
+	midraw := md.SelectElement("METHOD-ID")
+	if midraw == nil {
+		return nil, fmt.Errorf("no METHOD-ID")
+	}
+	mid, err := strconv.Atoi(midraw.Text())
+	if err != nil {
+		return nil, fmt.Errorf("invalid METHOD-ID %v", midraw.Text())
+	}
+	return &FieldAccessor{MethodID: mid, Kind: kind}, nil
+}
+
 func (s *Service) Validate() error {
 	if len(s.ServiceInterfaceRef) < 1 {
 		return fmt.Errorf("no service interface ref in service %v", s.ShortName)
 	}
-	serviceRef := strings.ToLower(s.ServiceInterfaceRef)
+	serviceRef := s.ServiceInterfaceRef + "/"
 	for _, event := range s.Events {
-		if !strings.HasPrefix(strings.ToLower(event.EventRef), serviceRef) {
+		if !strings.HasPrefix(event.EventRef, serviceRef) {
 			return fmt.Errorf("invalid event ref in service %v event %v, serviceRef:%v, eventRef:%v", s.ShortName, event.EventRef, s.ServiceInterfaceRef, event.EventRef)
 		}
 	}
 	for _, field := range s.FieldNotify {
-		if !strings.HasPrefix(strings.ToLower(field.FieldRef), serviceRef) {
+		if !strings.HasPrefix(field.FieldRef, serviceRef) {
 			return fmt.Errorf("invalid field ref in service %v field %v, serviceRef:%v, fieldRef:%v", s.ShortName, field.FieldRef, s.ServiceInterfaceRef, field.FieldRef)
 		}
 	}
+	for _, accessor := range s.FieldAccessors {
+		if !strings.HasPrefix(accessor.FieldRef, serviceRef) {
+			return fmt.Errorf("invalid field ref in service %v field %v %v, serviceRef:%v, fieldRef:%v", s.ShortName, accessor.ShortName, accessor.Kind, s.ServiceInterfaceRef, accessor.FieldRef)
+		}
+	}
+	for _, method := range s.Methods {
+		if !strings.HasPrefix(method.MethodRef, serviceRef) {
+			return fmt.Errorf("invalid method ref in service %v method %v, serviceRef:%v, methodRef:%v", s.ShortName, method.ShortName, s.ServiceInterfaceRef, method.MethodRef)
+		}
+	}
 	return nil
 }
 
@@ -141,9 +352,20 @@ type Service struct {
 	ShortName           string
 	ServiceInterfaceRef string
 
-	ServiceID   int
-	Events      map[int]Event
-	FieldNotify map[int]FieldNotify
+	ServiceID    int
+	MajorVersion int
+	MinorVersion int
+	Events       map[int]Event
+	FieldNotify  map[int]FieldNotify
+	Methods      map[int]Method
+	// FieldAccessors 以 getter/setter 的 method id 为 key
+	FieldAccessors map[int]FieldAccessor
+	// EventGroups 以 event group id 为 key
+	EventGroups map[int]*ast.EventGroup
+}
+
+func (s *Service) Key() ast.ServiceKey {
+	return ast.ServiceKey{ServiceID: uint16(s.ServiceID), MajorVersion: uint8(s.MajorVersion)}
 }
 
 type Event struct {
@@ -157,3 +379,29 @@ type FieldNotify struct {
 	ShortName string
 	FieldRef  string
 }
+
+type Method struct {
+	MethodID  int
+	ShortName string
+	MethodRef string
+}
+
+type FieldAccessorKind string
+
+const (
+	FieldGetter FieldAccessorKind = "getter"
+	FieldSetter FieldAccessorKind = "setter"
+)
+
+// FieldAccessor 为 field 的 getter/setter method
+type FieldAccessor struct {
+	MethodID  int
+	ShortName string
+	FieldRef  string
+	Kind      FieldAccessorKind
+}
+
+// Name 返回包含 accessor 类型的名称, 如 wiFiSwitch.getter
+func (a FieldAccessor) Name() string {
+	return a.ShortName + "." + string(a.Kind)
+}
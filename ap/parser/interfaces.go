@@ -5,6 +5,9 @@ import (
 	"strings"
 
 	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/util"
 )
 
 func (p *Parser) parseInterfaces() error {
@@ -22,7 +25,7 @@ func (p *Parser) parseInterfaces() error {
 		if err != nil {
 			return fmt.Errorf("parsing index %v service interface failed, err:%v", index, err)
 		}
-		p.Interfaces[strings.ToLower(si.Shortname)] = si
+		p.Interfaces[si.Path] = si
 	}
 	return nil
 }
@@ -34,8 +37,10 @@ func (p *Parser) parseServiceInterface(e *etree.Element) (*ServiceInterface, err
 	}
 	si := &ServiceInterface{
 		Shortname: sn.Text(),
+		Path:      util.GetArPath(e),
 		Events:    make(map[string]ServiceInterfaceEvent),
 		Fields:    make(map[string]ServiceInterfaceField),
+		Methods:   make(map[string]ServiceInterfaceMethod),
 	}
 	es := e.SelectElement("EVENTS")
 	if es != nil {
@@ -50,9 +55,13 @@ func (p *Parser) parseServiceInterface(e *etree.Element) (*ServiceInterface, err
 			if typref == nil {
 				return nil, fmt.Errorf("no TYPE-TREF in serviceInterface %v event vdp %v", si.Shortname, eventShortname)
 			}
-			si.Events[strings.ToLower(eventShortname)] = ServiceInterfaceEvent{
+			typeRef, err := p.index.RefPath(typref)
+			if err != nil {
+				return nil, fmt.Errorf("invalid TYPE-TREF in serviceInterface %v event vdp %v: %v", si.Shortname, eventShortname, err)
+			}
+			si.Events[util.GetArPath(vdp)] = ServiceInterfaceEvent{
 				ShortName: eventShortname,
-				TypeRef:   typref.Text(),
+				TypeRef:   typeRef,
 			}
 		}
 	}
@@ -70,22 +79,84 @@ func (p *Parser) parseServiceInterface(e *etree.Element) (*ServiceInterface, err
 			if typref == nil {
 				return nil, fmt.Errorf("no TYPE-TREF in service interface %v field %v", si.Shortname, fieldShortname)
 			}
-			si.Fields[strings.ToLower(fieldShortname)] = ServiceInterfaceField{
+			typeRef, err := p.index.RefPath(typref)
+			if err != nil {
+				return nil, fmt.Errorf("invalid TYPE-TREF in service interface %v field %v: %v", si.Shortname, fieldShortname, err)
+			}
+			si.Fields[util.GetArPath(field)] = ServiceInterfaceField{
 				ShortName: fieldShortname,
-				TypeRef:   typref.Text(),
+				TypeRef:   typeRef,
+			}
+		}
+	}
+	ms := e.SelectElement("METHODS")
+	if ms != nil {
+		for _, operation := range ms.SelectElements("CLIENT-SERVER-OPERATION") {
+			method, err := p.parseClientServerOperation(operation)
+			if err != nil {
+				return nil, fmt.Errorf("invalid method in service interface %v: %v", si.Shortname, err)
 			}
+			si.Methods[util.GetArPath(operation)] = *method
 		}
 	}
-	if len(si.Events) < 1 && len(si.Fields) < 1 {
-		return nil, fmt.Errorf("no EVENTS/Fields found in service interface %v", si.Shortname)
+	if len(si.Events) < 1 && len(si.Fields) < 1 && len(si.Methods) < 1 {
+		return nil, fmt.Errorf("no EVENTS/Fields/Methods found in service interface %v", si.Shortname)
 	}
 	return si, nil
 }
 
+func (p *Parser) parseClientServerOperation(operation *etree.Element) (*ServiceInterfaceMethod, error) {
+	sn, err := util.GetShortname(operation)
+	if err != nil {
+		return nil, err
+	}
+	method := &ServiceInterfaceMethod{
+		ShortName: sn,
+		Arguments: make([]*ast.Argument, 0),
+	}
+	args := operation.SelectElement("ARGUMENTS")
+	if args == nil {
+		return method, nil
+	}
+	for _, adp := range args.SelectElements("ARGUMENT-DATA-PROTOTYPE") {
+		argSN, err := util.GetShortname(adp)
+		if err != nil {
+			return nil, fmt.Errorf("method %v: %v", sn, err)
+		}
+		typref := adp.SelectElement("TYPE-TREF")
+		if typref == nil {
+			return nil, fmt.Errorf("no TYPE-TREF in method %v argument %v", sn, argSN)
+		}
+		typeRef, err := p.index.RefPath(typref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TYPE-TREF in method %v argument %v: %v", sn, argSN, err)
+		}
+		direction := adp.SelectElement("DIRECTION")
+		if direction == nil {
+			return nil, fmt.Errorf("no DIRECTION in method %v argument %v", sn, argSN)
+		}
+		arg := &ast.Argument{
+			ShortName: argSN,
+			TypeRef:   typeRef,
+			Direction: ast.ArgumentDirection(strings.TrimSpace(direction.Text())),
+		}
+		switch arg.Direction {
+		case ast.ArgumentIn, ast.ArgumentOut, ast.ArgumentInOut:
+		default:
+			return nil, fmt.Errorf("invalid DIRECTION %v in method %v argument %v", arg.Direction, sn, argSN)
+		}
+		method.Arguments = append(method.Arguments, arg)
+	}
+	return method, nil
+}
+
 type ServiceInterface struct {
 	Shortname string
-	Events    map[string]ServiceInterfaceEvent
-	Fields    map[string]ServiceInterfaceField
+	Path      string
+	// Events, Fields 与 Methods 以 AR 绝对路径为 key
+	Events  map[string]ServiceInterfaceEvent
+	Fields  map[string]ServiceInterfaceField
+	Methods map[string]ServiceInterfaceMethod
 }
 
 type ServiceInterfaceEvent struct {
@@ -97,3 +168,9 @@ type ServiceInterfaceField struct {
 	ShortName string
 	TypeRef   string
 }
+
+type ServiceInterfaceMethod struct {
+	ShortName string
+	// Arguments 按定义顺序排列
+	Arguments []*ast.Argument
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/beevik/etree"
 
 	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/e2e"
+	"github.com/yisaer/arxml-converter/util"
 )
 
 type Parser struct {
@@ -15,16 +17,43 @@ type Parser struct {
 	dataTypesElement  *etree.Element
 	interfacesElement *etree.Element
 
+	// Interfaces 与 DataTypes 以 AR 绝对路径为 key
 	Interfaces map[string]*ServiceInterface
 	DataTypes  map[string]*ast.DataType
-	Services   map[int]*Service
+	// Services 以 service id 与 major version 为 key
+	Services map[ast.ServiceKey]*Service
+
+	TransformationProps        map[string]*TransformationProps
+	ElementTransformationProps map[string]string
+
+	// ServiceInstances 以 AR 绝对路径为 key
+	ServiceInstances map[string]*ServiceInstance
+	Deployments      []*ServiceInstanceDeployment
+	endpoints        map[endpointKey][]*ServiceInstanceDeployment
+
+	EventGroups *ast.EventGroupRegistry
+	// eventGroupPaths 以 SOMEIP-EVENT-GROUP 的 AR 绝对路径为 key
+	eventGroupPaths map[string]*ast.EventGroup
+
+	// E2EProtections 以受保护 event 的 AR 绝对路径为 key
+	E2EProtections map[string]*e2e.Config
+
+	index      *util.ArIndex
+	tlvDataIDs map[string]uint16
 }
 
 func NewParserWithDoc(doc *etree.Document) (*Parser, error) {
 	p := &Parser{Doc: doc}
 	p.Interfaces = make(map[string]*ServiceInterface)
 	p.DataTypes = make(map[string]*ast.DataType)
-	p.Services = make(map[int]*Service)
+	p.Services = make(map[ast.ServiceKey]*Service)
+	p.TransformationProps = make(map[string]*TransformationProps)
+	p.ElementTransformationProps = make(map[string]string)
+	p.ServiceInstances = make(map[string]*ServiceInstance)
+	p.endpoints = make(map[endpointKey][]*ServiceInstanceDeployment)
+	p.EventGroups = ast.NewEventGroupRegistry()
+	p.eventGroupPaths = make(map[string]*ast.EventGroup)
+	p.E2EProtections = make(map[string]*e2e.Config)
 	return p, nil
 }
 
@@ -36,7 +65,14 @@ func NewParser(path string) (*Parser, error) {
 	p := &Parser{Path: path, Doc: doc}
 	p.Interfaces = make(map[string]*ServiceInterface)
 	p.DataTypes = make(map[string]*ast.DataType)
-	p.Services = make(map[int]*Service)
+	p.Services = make(map[ast.ServiceKey]*Service)
+	p.TransformationProps = make(map[string]*TransformationProps)
+	p.ElementTransformationProps = make(map[string]string)
+	p.ServiceInstances = make(map[string]*ServiceInstance)
+	p.endpoints = make(map[endpointKey][]*ServiceInstanceDeployment)
+	p.EventGroups = ast.NewEventGroupRegistry()
+	p.eventGroupPaths = make(map[string]*ast.EventGroup)
+	p.E2EProtections = make(map[string]*e2e.Config)
 	return p, nil
 }
 
@@ -45,18 +81,31 @@ func (p *Parser) Parse() error {
 	if autoSar == nil {
 		return fmt.Errorf("no autosar")
 	}
+	index, err := util.NewArIndex(autoSar)
+	if err != nil {
+		return fmt.Errorf("index ar packages: %w", err)
+	}
+	p.index = index
 	if err := p.search(autoSar); err != nil {
 		return err
 	}
 	if err := p.parseInterfaces(); err != nil {
 		return err
 	}
+	tlvDataIDs, err := util.ParseTLVDataIDs(autoSar)
+	if err != nil {
+		return fmt.Errorf("parse TLV data ids: %w", err)
+	}
+	p.tlvDataIDs = tlvDataIDs
 	if err := p.parseDataTypes(); err != nil {
 		return err
 	}
 	if err := p.parseIautoSar(); err != nil {
 		return err
 	}
+	if err := p.parseE2EProtections(autoSar); err != nil {
+		return fmt.Errorf("parsing e2e protections: %w", err)
+	}
 	return nil
 }
 
@@ -108,3 +157,25 @@ func (p *Parser) searchInterfaces(arPackageElements []*etree.Element) error {
 	}
 	return fmt.Errorf("no interfaces find in ar package")
 }
+
+// LookupService 根据 service id 与 interface major version 查找 service 部署,
+// majorVersion 为 ast.AnyMajorVersion 时 service 必须只有一个部署
+func (p *Parser) LookupService(serviceID, majorVersion int) (*Service, error) {
+	var found *Service
+	for key, svc := range p.Services {
+		if !key.Matches(uint16(serviceID), majorVersion) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("service %v has several major versions deployed, interface version is required", serviceID)
+		}
+		found = svc
+	}
+	if found == nil {
+		if majorVersion == ast.AnyMajorVersion {
+			return nil, fmt.Errorf("service %v not found", serviceID)
+		}
+		return nil, fmt.Errorf("service %v major version %v not found", serviceID, majorVersion)
+	}
+	return found, nil
+}
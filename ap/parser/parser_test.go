@@ -3,7 +3,10 @@ package parser
 import (
 	"testing"
 
+	"github.com/beevik/etree"
 	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/arxml-converter/ast"
 )
 
 func TestParser(t *testing.T) {
@@ -11,3 +14,177 @@ func TestParser(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, p.Parse())
 }
+
+func TestParseDeployments(t *testing.T) {
+	p, err := NewParser("../../test/s1_ap_test.xml")
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+	require.Len(t, p.ServiceInstances, 2)
+	require.Len(t, p.Deployments, 2)
+
+	deployments := p.LookupDeployments("192.168.62.1", TransportTCP, 30552)
+	require.Len(t, deployments, 1)
+	d := deployments[0]
+	require.Equal(t, "INI_WiFiStation_TBOX_SomeipPIns_1", d.Instance.ShortName)
+	require.Equal(t, ProvidedServiceInstance, d.Instance.Kind)
+	require.Equal(t, 33282, d.Instance.ServiceID)
+	require.Equal(t, 1, d.Instance.InstanceID)
+	require.Equal(t, "TBOX_machineDesign", d.Machine)
+	require.Equal(t, []NetworkAddress{{IP: "192.168.62.1", Mask: "255.255.255.0"}}, d.Addresses)
+	require.Equal(t, &VLAN{ShortName: "VLAN62", Identifier: 62}, d.VLAN)
+	require.Equal(t, map[TransportProtocol]int{TransportTCP: 30552}, d.Endpoints())
+
+	deployments = p.LookupDeployments("192.168.62.4", TransportTCP, 31452)
+	require.Len(t, deployments, 1)
+	require.Equal(t, RequiredServiceInstance, deployments[0].Instance.Kind)
+	require.Equal(t, "CDC_machineDesign", deployments[0].Machine)
+
+	require.Empty(t, p.LookupDeployments("192.168.62.1", TransportTCP, 31452))
+	require.Empty(t, p.LookupDeployments("192.168.62.1", TransportUDP, 30552))
+}
+
+func TestLookupDeploymentsByProtocol(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_ap_test.xml"))
+	// required instance 改为部署在 TBOX 同一端口的 UDP 上
+	mapping := doc.FindElement("//SOMEIP-SERVICE-INSTANCE-TO-MACHINE-MAPPING[SHORT-NAME='INI_WiFiStation_SomeipRIns_1_CDC_Mapping']")
+	require.NotNil(t, mapping)
+	mapping.SelectElement("COMMUNICATION-CONNECTOR-REF").SetText("/IAUTOSAR/TBOX_machineDesign/TBOX_EthConnector1")
+	port := mapping.SelectElement("TCP-PORT")
+	port.Tag = "UDP-PORT"
+	port.SetText("30552")
+	p, err := NewParserWithDoc(doc)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+
+	deployments := p.LookupDeployments("192.168.62.1", TransportTCP, 30552)
+	require.Len(t, deployments, 1)
+	require.Equal(t, ProvidedServiceInstance, deployments[0].Instance.Kind)
+	deployments = p.LookupDeployments("192.168.62.1", TransportUDP, 30552)
+	require.Len(t, deployments, 1)
+	require.Equal(t, RequiredServiceInstance, deployments[0].Instance.Kind)
+}
+
+// addServiceVersion 复制 service interface deployment 并修改其 major version
+func addServiceVersion(t *testing.T, doc *etree.Document, shortName, majorVersion string) {
+	deployment := doc.FindElement("//SOMEIP-SERVICE-INTERFACE-DEPLOYMENT")
+	require.NotNil(t, deployment)
+	cp := deployment.Copy()
+	cp.SelectElement("SHORT-NAME").SetText(shortName)
+	cp.FindElement("SERVICE-INTERFACE-VERSION/MAJOR-VERSION").SetText(majorVersion)
+	deployment.Parent().AddChild(cp)
+}
+
+func TestParseServiceVersions(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_ap_test.xml"))
+	addServiceVersion(t, doc, "INI_WiFiStation_Someip_Deployment_V2", "2")
+	p, err := NewParserWithDoc(doc)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+	require.Len(t, p.Services, 2)
+
+	svc, err := p.LookupService(33282, 1)
+	require.NoError(t, err)
+	require.Equal(t, "INI_WiFiStation_Someip_Deployment", svc.ShortName)
+	require.Equal(t, 1, svc.MinorVersion)
+	svc, err = p.LookupService(33282, 2)
+	require.NoError(t, err)
+	require.Equal(t, "INI_WiFiStation_Someip_Deployment_V2", svc.ShortName)
+	_, err = p.LookupService(33282, ast.AnyMajorVersion)
+	require.Error(t, err)
+	_, err = p.LookupService(33282, 3)
+	require.Error(t, err)
+
+	doc = etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_ap_test.xml"))
+	addServiceVersion(t, doc, "INI_WiFiStation_Someip_Deployment_Dup", "1")
+	p, err = NewParserWithDoc(doc)
+	require.NoError(t, err)
+	require.Error(t, p.Parse())
+}
+
+func TestParseTypeReferenceString(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_ap_test.xml"))
+	// 路径中包含 string 的非字符串类型不要求 ARRAY-SIZE
+	elements := doc.FindElement("//AR-PACKAGE[SHORT-NAME='dataTypes']/ELEMENTS")
+	require.NotNil(t, elements)
+	for name, ref := range map[string]string{
+		"StringLength": "/AUTOSAR/StdTypes/uint16_t",
+		"Speed":        "/dataTypes/StringLength",
+	} {
+		dt := elements.CreateElement("STD-CPP-IMPLEMENTATION-DATA-TYPE")
+		dt.CreateElement("SHORT-NAME").SetText(name)
+		dt.CreateElement("CATEGORY").SetText("TYPE_REFERENCE")
+		r := dt.CreateElement("TYPE-REFERENCE-REF")
+		r.CreateAttr("DEST", "STD-CPP-IMPLEMENTATION-DATA-TYPE")
+		r.SetText(ref)
+	}
+	p, err := NewParserWithDoc(doc)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+	require.Equal(t, int64(0), p.DataTypes["/dataTypes/Speed"].StringSize)
+	require.Equal(t, int64(64), p.DataTypes["/dataTypes/WiFiApName"].StringSize)
+
+	// 引用字符串类型时必须配置 ARRAY-SIZE
+	doc = etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_ap_test.xml"))
+	apName := doc.FindElement("//STD-CPP-IMPLEMENTATION-DATA-TYPE[SHORT-NAME='WiFiApName']")
+	require.NotNil(t, apName)
+	apName.RemoveChild(apName.SelectElement("ARRAY-SIZE"))
+	p, err = NewParserWithDoc(doc)
+	require.NoError(t, err)
+	require.Error(t, p.Parse())
+}
+
+func TestParseVariantMemberNames(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_ap_test.xml"))
+	elements := doc.FindElement("//AR-PACKAGE[SHORT-NAME='dataTypes']/ELEMENTS")
+	require.NotNil(t, elements)
+	dt := elements.CreateElement("STD-CPP-IMPLEMENTATION-DATA-TYPE")
+	dt.CreateElement("SHORT-NAME").SetText("Strength")
+	dt.CreateElement("CATEGORY").SetText("VARIANT")
+	args := dt.CreateElement("TEMPLATE-ARGUMENTS")
+	for _, ref := range []string{"/dataTypes/WiFiStrength", "/Legacy/WiFiStrength", "/AUTOSAR/StdTypes/uint8_t"} {
+		r := args.CreateElement("CPP-TEMPLATE-ARGUMENT").CreateElement("TEMPLATE-TYPE-REF")
+		r.CreateAttr("DEST", "STD-CPP-IMPLEMENTATION-DATA-TYPE")
+		r.SetText(ref)
+	}
+	p, err := NewParserWithDoc(doc)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+	variant, ok := p.DataTypes["/dataTypes/Strength"]
+	require.True(t, ok)
+	names := make([]string, 0, len(variant.Union.Members))
+	for _, member := range variant.Union.Members {
+		names = append(names, member.ShorName)
+	}
+	require.Equal(t, []string{"WiFiStrength_0", "WiFiStrength_1", "uint8_t"}, names)
+}
+
+func TestParseDuplicateDeploymentIDs(t *testing.T) {
+	testcases := []struct {
+		path string
+		id   string
+	}{
+		// getter 与 method 的 ID 相同
+		{"//SOMEIP-FIELD-DEPLOYMENT/GET/METHOD-ID", "5"},
+		// setter 与 event 的 ID 相同
+		{"//SOMEIP-FIELD-DEPLOYMENT/SET/METHOD-ID", "32769"},
+		// field notifier 与 event 的 ID 相同
+		{"//SOMEIP-FIELD-DEPLOYMENT/NOTIFIER/EVENT-ID", "32770"},
+	}
+	for _, tc := range testcases {
+		doc := etree.NewDocument()
+		require.NoError(t, doc.ReadFromFile("../../test/s1_ap_test.xml"))
+		id := doc.FindElement(tc.path)
+		require.NotNil(t, id, tc.path)
+		id.SetText(tc.id)
+		p, err := NewParserWithDoc(doc)
+		require.NoError(t, err)
+		err = p.Parse()
+		require.ErrorContains(t, err, "duplicate ID "+tc.id, tc.path)
+	}
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/someip"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+type TransformationProps struct {
+	ShortName                    string
+	SizeOfArrayLengthField       int
+	SizeOfStringLengthField      int
+	SizeOfStructLengthField      int
+	SizeOfUnionLengthField       int
+	SizeOfUnionTypeSelectorField int
+}
+
+func newTransformationProps(shortName string) *TransformationProps {
+	return &TransformationProps{
+		ShortName:                    shortName,
+		SizeOfArrayLengthField:       4,
+		SizeOfStringLengthField:      4,
+		SizeOfUnionLengthField:       someip.DefaultUnionLengthFieldLength,
+		SizeOfUnionTypeSelectorField: someip.DefaultUnionTypeSelectorLength,
+	}
+}
+
+// GetTransformationProps 返回 event/field/method AR 路径对应的 SOME/IP transformation props, 未配置时返回默认值
+func (p *Parser) GetTransformationProps(elementRef string) *TransformationProps {
+	propsRef, ok := p.ElementTransformationProps[elementRef]
+	if ok {
+		if props, ok := p.TransformationProps[propsRef]; ok {
+			return props
+		}
+	}
+	return newTransformationProps("")
+}
+
+func (p *Parser) parseTransformationProps(eles *etree.Element) error {
+	for _, propsSet := range eles.SelectElements("TRANSFORMATION-PROPS-SET") {
+		propss := propsSet.SelectElement("TRANSFORMATION-PROPSS")
+		if propss == nil {
+			continue
+		}
+		for _, tp := range propss.SelectElements("AP-SOMEIP-TRANSFORMATION-PROPS") {
+			sn, err := util.GetShortname(tp)
+			if err != nil {
+				return fmt.Errorf("AP-SOMEIP-TRANSFORMATION-PROPS has err:%v", err)
+			}
+			props := newTransformationProps(sn)
+			sizes := map[string]*int{
+				"SIZE-OF-ARRAY-LENGTH-FIELD":        &props.SizeOfArrayLengthField,
+				"SIZE-OF-STRING-LENGTH-FIELD":       &props.SizeOfStringLengthField,
+				"SIZE-OF-STRUCT-LENGTH-FIELD":       &props.SizeOfStructLengthField,
+				"SIZE-OF-UNION-LENGTH-FIELD":        &props.SizeOfUnionLengthField,
+				"SIZE-OF-UNION-TYPE-SELECTOR-FIELD": &props.SizeOfUnionTypeSelectorField,
+			}
+			for tag, target := range sizes {
+				e := tp.SelectElement(tag)
+				if e == nil {
+					continue
+				}
+				v, err := util.ToInt64(e.Text())
+				if err != nil {
+					return fmt.Errorf("invalid %v in transformation props %v", tag, sn)
+				}
+				*target = int(v)
+			}
+			p.TransformationProps[util.GetArPath(tp)] = props
+		}
+	}
+	for _, mappingSet := range eles.SelectElements("TRANSFORMATION-PROPS-TO-SERVICE-INTERFACE-ELEMENT-MAPPING-SET") {
+		mappings := mappingSet.SelectElement("MAPPINGS")
+		if mappings == nil {
+			continue
+		}
+		for _, mapping := range mappings.SelectElements("TRANSFORMATION-PROPS-TO-SERVICE-INTERFACE-ELEMENT-MAPPING") {
+			propsRefElement := mapping.SelectElement("TRANSFORMATION-PROPS-REF")
+			if propsRefElement == nil {
+				continue
+			}
+			propsRef, err := p.index.RefPath(propsRefElement)
+			if err != nil {
+				return err
+			}
+			for _, refs := range []string{"EVENT-REFS", "FIELD-REFS", "METHOD-REFS"} {
+				refsElement := mapping.SelectElement(refs)
+				if refsElement == nil {
+					continue
+				}
+				for _, ref := range refsElement.ChildElements() {
+					elementRef, err := p.index.RefPath(ref)
+					if err != nil {
+						return err
+					}
+					p.ElementTransformationProps[elementRef] = propsRef
+				}
+			}
+		}
+	}
+	return nil
+}
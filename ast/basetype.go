@@ -0,0 +1,131 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BaseType 对应 SW-BASE-TYPE
+type BaseType struct {
+	ShortName         string    `json:"short_name"`
+	Size              int       `json:"size"`
+	Encoding          string    `json:"encoding"`
+	NativeDeclaration string    `json:"native_declaration"`
+	Kind              BasicKind `json:"kind"`
+}
+
+func NewBaseType(shortName string, size int, encoding, nativeDeclaration string) (*BaseType, error) {
+	bt := &BaseType{
+		ShortName:         shortName,
+		Size:              size,
+		Encoding:          encoding,
+		NativeDeclaration: nativeDeclaration,
+	}
+	kind, err := resolveBaseTypeKind(bt)
+	if err != nil {
+		return nil, err
+	}
+	bt.Kind = kind
+	return bt, nil
+}
+
+// StringEncoding 返回字符串 base type 的编码, UTF-16 与 UCS-2 以 16 bit 为编码单元
+func (bt *BaseType) StringEncoding() StringEncoding {
+	switch strings.ToUpper(strings.TrimSpace(bt.Encoding)) {
+	case "UTF-16", "UCS-2":
+		return StringUTF16
+	}
+	return StringUTF8
+}
+
+type BaseTypeRegistry struct {
+	baseTypes map[string]*BaseType
+}
+
+func NewBaseTypeRegistry() *BaseTypeRegistry {
+	return &BaseTypeRegistry{
+		baseTypes: make(map[string]*BaseType),
+	}
+}
+
+// Register 以 SW-BASE-TYPE 的 AR 绝对路径登记 base type
+func (r *BaseTypeRegistry) Register(path string, bt *BaseType) {
+	r.baseTypes[path] = bt
+}
+
+func (r *BaseTypeRegistry) Lookup(ref string) (*BaseType, bool) {
+	if r == nil {
+		return nil, false
+	}
+	bt, ok := r.baseTypes[strings.TrimSpace(ref)]
+	return bt, ok
+}
+
+func (r *BaseTypeRegistry) GetBaseTypes() map[string]*BaseType {
+	return r.baseTypes
+}
+
+// resolveBaseTypeKind 根据 BASE-TYPE-ENCODING 与 BASE-TYPE-SIZE 推导基础类型, 未配置 encoding 时使用 NATIVE-DECLARATION
+func resolveBaseTypeKind(bt *BaseType) (BasicKind, error) {
+	encoding := strings.ToUpper(strings.TrimSpace(bt.Encoding))
+	if encoding == "" {
+		encoding = encodingFromNativeDeclaration(bt.NativeDeclaration)
+	}
+	switch encoding {
+	case "2C":
+		switch bt.Size {
+		case 8:
+			return BasicInt8, nil
+		case 16:
+			return BasicInt16, nil
+		case 32:
+			return BasicInt32, nil
+		case 64:
+			return BasicInt64, nil
+		}
+	case "NONE":
+		switch bt.Size {
+		case 8:
+			return BasicUint8, nil
+		case 16:
+			return BasicUint16, nil
+		case 32:
+			return BasicUint32, nil
+		case 64:
+			return BasicUint64, nil
+		}
+	case "IEEE754":
+		switch bt.Size {
+		case 32:
+			return BasicFloat, nil
+		case 64:
+			return BasicDouble, nil
+		}
+	case "BOOLEAN":
+		if bt.Size <= 8 {
+			return BasicBool, nil
+		}
+	case "UTF-8", "UTF-16", "UCS-2", "ISO-8859-1", "ISO-8859-2", "WINDOWS-1252":
+		return BasicString, nil
+	}
+	return "", fmt.Errorf("unsupported base type %v: BASE-TYPE-SIZE %v, BASE-TYPE-ENCODING %q, NATIVE-DECLARATION %q",
+		bt.ShortName, bt.Size, bt.Encoding, bt.NativeDeclaration)
+}
+
+func encodingFromNativeDeclaration(nativeDeclaration string) string {
+	decl := strings.ToLower(strings.TrimSpace(nativeDeclaration))
+	switch {
+	case decl == "":
+		return ""
+	case decl == "bool" || decl == "boolean":
+		return "BOOLEAN"
+	case decl == "float" || decl == "double":
+		return "IEEE754"
+	case strings.HasPrefix(decl, "unsigned") || strings.HasPrefix(decl, "uint"):
+		return "NONE"
+	case strings.HasPrefix(decl, "signed") || strings.HasPrefix(decl, "int") ||
+		decl == "char" || decl == "short" || decl == "long" || decl == "long long":
+		return "2C"
+	}
+	return ""
+}
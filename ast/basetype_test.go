@@ -0,0 +1,67 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBaseType(t *testing.T) {
+	testcases := []struct {
+		shortName         string
+		size              int
+		encoding          string
+		nativeDeclaration string
+		kind              BasicKind
+	}{
+		{"sint8", 8, "2C", "signed char", BasicInt8},
+		{"uint8", 8, "NONE", "unsigned char", BasicUint8},
+		{"MySignedShort", 16, "2C", "", BasicInt16},
+		{"float32", 32, "IEEE754", "float", BasicFloat},
+		{"float64", 64, "IEEE754", "double", BasicDouble},
+		{"boolean", 8, "BOOLEAN", "boolean", BasicBool},
+		{"utf_8", 8, "UTF-8", "", BasicString},
+		{"MyUint32", 32, "", "unsigned int", BasicUint32},
+		{"MyInt64", 64, "", "long long", BasicInt64},
+	}
+	r := NewBaseTypeRegistry()
+	for _, tc := range testcases {
+		bt, err := NewBaseType(tc.shortName, tc.size, tc.encoding, tc.nativeDeclaration)
+		require.NoError(t, err, tc.shortName)
+		require.Equal(t, tc.kind, bt.Kind, tc.shortName)
+		r.Register("/DataTypes/BaseTypes/"+tc.shortName, bt)
+	}
+	bt, ok := r.Lookup("/DataTypes/BaseTypes/MySignedShort")
+	require.True(t, ok)
+	require.Equal(t, BasicInt16, bt.Kind)
+	_, ok = r.Lookup("/OtherTypes/MySignedShort")
+	require.False(t, ok)
+
+	_, err := NewBaseType("float16", 16, "IEEE754", "")
+	require.EqualError(t, err, `unsupported base type float16: BASE-TYPE-SIZE 16, BASE-TYPE-ENCODING "IEEE754", NATIVE-DECLARATION ""`)
+	_, err = NewBaseType("bcd", 8, "BCD-P", "")
+	require.Error(t, err)
+}
+
+func TestGetBasicKind(t *testing.T) {
+	r := NewBaseTypeRegistry()
+	bt, err := NewBaseType("MyInt8", 8, "2C", "")
+	require.NoError(t, err)
+	r.Register("/BaseTypes/MyInt8", bt)
+	th := NewTransformHelper(map[string]*DataType{
+		"Level": NewBasicDataType("Level", "VALUE", "/BaseTypes/MyInt8", BasicInt8),
+	})
+	th.SetBaseTypes(r)
+
+	kind, ok := th.GetBasicKind(&TypReference{Ref: "/BaseTypes/MyInt8"})
+	require.True(t, ok)
+	require.Equal(t, BasicInt8, kind)
+	kind, ok = th.GetBasicKind(&TypReference{Ref: "/AUTOSAR/StdTypes/int8_t"})
+	require.True(t, ok)
+	require.Equal(t, BasicInt8, kind)
+	// 不再按子串猜测类型
+	_, ok = th.GetBasicKind(&TypReference{Ref: "/BaseTypes/myfloatish"})
+	require.False(t, ok)
+
+	require.True(t, th.RequiresSomeIPDecoder("Level"))
+}
@@ -0,0 +1,88 @@
+package ast
+
+// CompuMethod 对应 COMPU-METHOD 的 COMPU-INTERNAL-TO-PHYS, 将 signal 的原始值转换为物理值
+type CompuMethod struct {
+	ShortName string        `json:"short_name"`
+	Category  string        `json:"category"`
+	Scales    []*CompuScale `json:"scales"`
+}
+
+// CompuScale 为 COMPU-SCALE. Text 不为空时为 TEXTTABLE 的取值,
+// 否则物理值为 Numerator 与 Denominator 两个多项式 (低次项在前) 之比
+type CompuScale struct {
+	LowerLimit  *float64  `json:"lower_limit,omitempty"`
+	UpperLimit  *float64  `json:"upper_limit,omitempty"`
+	Text        string    `json:"text,omitempty"`
+	Numerator   []float64 `json:"numerator,omitempty"`
+	Denominator []float64 `json:"denominator,omitempty"`
+}
+
+func (s *CompuScale) contains(raw float64) bool {
+	if s.LowerLimit != nil && raw < *s.LowerLimit {
+		return false
+	}
+	if s.UpperLimit != nil && raw > *s.UpperLimit {
+		return false
+	}
+	return true
+}
+
+// Apply 将原始值转换为物理值, TEXTTABLE 返回 string, 线性与有理函数返回 float64,
+// 没有匹配的 COMPU-SCALE 时返回 false
+func (m *CompuMethod) Apply(raw float64) (interface{}, bool) {
+	if m == nil || m.Category == "IDENTICAL" {
+		return raw, true
+	}
+	for _, scale := range m.Scales {
+		if scale.Text != "" {
+			if scale.contains(raw) {
+				return scale.Text, true
+			}
+			continue
+		}
+		// 只有一个线性 scale 时不限制范围
+		if len(scale.Numerator) == 0 || (len(m.Scales) > 1 && !scale.contains(raw)) {
+			continue
+		}
+		denominator := 1.0
+		if len(scale.Denominator) > 0 {
+			denominator = polynomial(scale.Denominator, raw)
+		}
+		if denominator == 0 {
+			continue
+		}
+		return polynomial(scale.Numerator, raw) / denominator, true
+	}
+	return nil, false
+}
+
+func polynomial(coeffs []float64, x float64) float64 {
+	result := 0.0
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		result = result*x + coeffs[i]
+	}
+	return result
+}
+
+// CompuMethodRegistry 以 COMPU-METHOD 的 AR 路径登记 compu method
+type CompuMethodRegistry struct {
+	compuMethods map[string]*CompuMethod
+}
+
+func NewCompuMethodRegistry() *CompuMethodRegistry {
+	return &CompuMethodRegistry{
+		compuMethods: make(map[string]*CompuMethod),
+	}
+}
+
+func (r *CompuMethodRegistry) Register(path string, m *CompuMethod) {
+	r.compuMethods[path] = m
+}
+
+func (r *CompuMethodRegistry) Lookup(ref string) (*CompuMethod, bool) {
+	if r == nil {
+		return nil, false
+	}
+	m, ok := r.compuMethods[ref]
+	return m, ok
+}
@@ -0,0 +1,44 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompuMethodApply(t *testing.T) {
+	zero, one, ten := 0.0, 1.0, 10.0
+	texttable := &CompuMethod{ShortName: "CM_Enum", Category: "TEXTTABLE", Scales: []*CompuScale{
+		{LowerLimit: &zero, UpperLimit: &zero, Text: "OFF"},
+		{LowerLimit: &one, UpperLimit: &one, Text: "ON"},
+	}}
+	v, ok := texttable.Apply(1)
+	require.True(t, ok)
+	require.Equal(t, "ON", v)
+	_, ok = texttable.Apply(2)
+	require.False(t, ok)
+
+	linear := &CompuMethod{ShortName: "CM_Linear", Category: "LINEAR", Scales: []*CompuScale{
+		{Numerator: []float64{-10, 0.5}, Denominator: []float64{1}},
+	}}
+	v, ok = linear.Apply(100)
+	require.True(t, ok)
+	require.Equal(t, 40.0, v)
+
+	// SCALE_LINEAR_AND_TEXTTABLE 按范围选择 scale
+	mixed := &CompuMethod{ShortName: "CM_Mixed", Category: "SCALE_LINEAR_AND_TEXTTABLE", Scales: []*CompuScale{
+		{LowerLimit: &zero, UpperLimit: &ten, Numerator: []float64{0, 2}},
+		{LowerLimit: &ten, Text: "INVALID"},
+	}}
+	v, ok = mixed.Apply(3)
+	require.True(t, ok)
+	require.Equal(t, 6.0, v)
+	v, ok = mixed.Apply(11)
+	require.True(t, ok)
+	require.Equal(t, "INVALID", v)
+
+	var identical *CompuMethod
+	v, ok = identical.Apply(5)
+	require.True(t, ok)
+	require.Equal(t, 5.0, v)
+}
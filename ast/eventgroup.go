@@ -0,0 +1,127 @@
+package ast
+
+import "sort"
+
+// EventGroup 为 SOME/IP event group, EventIDs 为成员 event 的 id, 不包含 service id
+type EventGroup struct {
+	ShortName    string               `json:"short_name"`
+	ServiceID    uint16               `json:"service_id"`
+	EventGroupID uint16               `json:"event_group_id"`
+	EventIDs     []uint16             `json:"event_ids"`
+	Multicast    *EventGroupMulticast `json:"multicast,omitempty"`
+}
+
+// EventGroupMulticast 为 event group 的组播配置, 未配置组播时为 nil
+type EventGroupMulticast struct {
+	// Threshold 为切换为组播发送的订阅者数量, 0 表示未配置
+	Threshold int    `json:"threshold"`
+	Address   string `json:"address,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	// ControlType 为 CP SO-AD-ROUTING-GROUP 的 EVENT-GROUP-CONTROL-TYPE
+	ControlType string `json:"control_type,omitempty"`
+}
+
+func (eg *EventGroup) HasEvent(eventID uint16) bool {
+	for _, id := range eg.EventIDs {
+		if id == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddEvent 按 id 升序加入成员 event, 已存在时忽略
+func (eg *EventGroup) AddEvent(eventID uint16) {
+	if eg.HasEvent(eventID) {
+		return
+	}
+	eg.EventIDs = append(eg.EventIDs, eventID)
+	sort.Slice(eg.EventIDs, func(i, j int) bool { return eg.EventIDs[i] < eg.EventIDs[j] })
+}
+
+type eventGroupKey struct {
+	serviceID    uint16
+	eventGroupID uint16
+}
+
+type EventGroupRegistry struct {
+	eventGroups map[eventGroupKey]*EventGroup
+}
+
+func NewEventGroupRegistry() *EventGroupRegistry {
+	return &EventGroupRegistry{
+		eventGroups: make(map[eventGroupKey]*EventGroup),
+	}
+}
+
+// Register 登记 event group, 同一 service 下相同 id 的 event group 合并成员 event 与组播配置,
+// 已登记的配置优先
+func (r *EventGroupRegistry) Register(eg *EventGroup) *EventGroup {
+	key := eventGroupKey{serviceID: eg.ServiceID, eventGroupID: eg.EventGroupID}
+	existed, ok := r.eventGroups[key]
+	if !ok {
+		r.eventGroups[key] = eg
+		return eg
+	}
+	for _, id := range eg.EventIDs {
+		existed.AddEvent(id)
+	}
+	existed.mergeMulticast(eg.Multicast)
+	return existed
+}
+
+func (eg *EventGroup) mergeMulticast(m *EventGroupMulticast) {
+	if m == nil {
+		return
+	}
+	if eg.Multicast == nil {
+		eg.Multicast = m
+		return
+	}
+	if eg.Multicast.Threshold == 0 {
+		eg.Multicast.Threshold = m.Threshold
+	}
+	if eg.Multicast.Address == "" {
+		eg.Multicast.Address, eg.Multicast.Port = m.Address, m.Port
+	}
+	if eg.Multicast.ControlType == "" {
+		eg.Multicast.ControlType = m.ControlType
+	}
+}
+
+func (r *EventGroupRegistry) Lookup(serviceID, eventGroupID uint16) (*EventGroup, bool) {
+	if r == nil {
+		return nil, false
+	}
+	eg, ok := r.eventGroups[eventGroupKey{serviceID: serviceID, eventGroupID: eventGroupID}]
+	return eg, ok
+}
+
+// GetByService 返回 service 的全部 event group, 按 event group id 升序
+func (r *EventGroupRegistry) GetByService(serviceID uint16) []*EventGroup {
+	return r.filter(func(eg *EventGroup) bool { return eg.ServiceID == serviceID })
+}
+
+// FindByEvent 返回包含该 event 的 event group, 一个 event 可以属于多个 event group
+func (r *EventGroupRegistry) FindByEvent(serviceID, eventID uint16) []*EventGroup {
+	return r.filter(func(eg *EventGroup) bool { return eg.ServiceID == serviceID && eg.HasEvent(eventID) })
+}
+
+func (r *EventGroupRegistry) filter(match func(eg *EventGroup) bool) []*EventGroup {
+	result := make([]*EventGroup, 0)
+	if r == nil {
+		return result
+	}
+	for _, eg := range r.eventGroups {
+		if match(eg) {
+			result = append(result, eg)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ServiceID != result[j].ServiceID {
+			return result[i].ServiceID < result[j].ServiceID
+		}
+		return result[i].EventGroupID < result[j].EventGroupID
+	})
+	return result
+}
@@ -0,0 +1,56 @@
+package ast
+
+// ArgumentDirection 为 client/server operation 参数的方向
+type ArgumentDirection string
+
+const (
+	ArgumentIn    ArgumentDirection = "IN"
+	ArgumentOut   ArgumentDirection = "OUT"
+	ArgumentInOut ArgumentDirection = "INOUT"
+)
+
+// Argument 为 method 的参数, 按定义顺序序列化在 request/response payload 中
+type Argument struct {
+	ShortName string            `json:"short_name"`
+	TypeRef   string            `json:"type_ref"`
+	Direction ArgumentDirection `json:"direction"`
+}
+
+// RequestArguments 返回 request 中携带的 IN/INOUT 参数
+func RequestArguments(args []*Argument) []*Argument {
+	return filterArguments(args, ArgumentIn, ArgumentInOut)
+}
+
+// ResponseArguments 返回 response 中携带的 OUT/INOUT 参数
+func ResponseArguments(args []*Argument) []*Argument {
+	return filterArguments(args, ArgumentOut, ArgumentInOut)
+}
+
+func filterArguments(args []*Argument, directions ...ArgumentDirection) []*Argument {
+	result := make([]*Argument, 0, len(args))
+	for _, arg := range args {
+		for _, direction := range directions {
+			if arg.Direction == direction {
+				result = append(result, arg)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// ApplicationError 为 client/server operation 的 POSSIBLE-ERROR, 以 SOME/IP header 中的 return code 传输
+type ApplicationError struct {
+	ShortName string `json:"short_name"`
+	ErrorCode uint8  `json:"error_code"`
+}
+
+// LookupApplicationError 返回 error code 对应的 application error
+func LookupApplicationError(errors []*ApplicationError, errorCode uint8) (*ApplicationError, bool) {
+	for _, e := range errors {
+		if e.ErrorCode == errorCode {
+			return e, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,19 @@
+package ast
+
+// AnyMajorVersion 表示未指定 interface version, 此时 service 只能有一个部署
+const AnyMajorVersion = -1
+
+// ServiceKey 以 service id 与 major version 区分同一 service 不同版本的部署,
+// major version 对应 SOME/IP header 中的 interface version
+type ServiceKey struct {
+	ServiceID    uint16
+	MajorVersion uint8
+}
+
+// Matches 判断 key 是否满足查找条件, majorVersion 为 AnyMajorVersion 时只比较 service id
+func (k ServiceKey) Matches(serviceID uint16, majorVersion int) bool {
+	if k.ServiceID != serviceID {
+		return false
+	}
+	return majorVersion == AnyMajorVersion || int(k.MajorVersion) == majorVersion
+}
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	idlAst "github.com/yisaer/idl-parser/ast"
@@ -10,49 +11,179 @@ import (
 )
 
 type TransformHelper struct {
+	// DataTypes 以 AR 绝对路径为 key
 	DataTypes         map[string]*DataType
 	convertedTypeRefs map[string]typeref.TypeRef
+	typeNames         map[string]string
+	baseTypes         *BaseTypeRegistry
 }
 
 func NewTransformHelper(dataTypes map[string]*DataType) *TransformHelper {
 	return &TransformHelper{
 		DataTypes:         dataTypes,
 		convertedTypeRefs: make(map[string]typeref.TypeRef),
+		typeNames:         newTypeNames(dataTypes),
 	}
 }
 
+// newTypeNames 为每个 DataType 分配 idl 类型名, SHORT-NAME 重复时使用完整 AR 路径生成类型名.
+// 生成的类型名与已有类型名冲突时追加数字后缀, 按路径排序保证结果稳定
+func newTypeNames(dataTypes map[string]*DataType) map[string]string {
+	counts := make(map[string]int, len(dataTypes))
+	for _, dt := range dataTypes {
+		counts[strings.ToLower(dt.ShorName)]++
+	}
+	typeNames := make(map[string]string, len(dataTypes))
+	used := make(map[string]bool, len(dataTypes))
+	duplicated := make([]string, 0)
+	for path, dt := range dataTypes {
+		if counts[strings.ToLower(dt.ShorName)] > 1 {
+			duplicated = append(duplicated, path)
+			continue
+		}
+		typeNames[path] = dt.ShorName
+		used[strings.ToLower(dt.ShorName)] = true
+	}
+	sort.Strings(duplicated)
+	for _, path := range duplicated {
+		base := strings.Join(strings.FieldsFunc(path, func(r rune) bool { return r == '/' }), "_")
+		name := base
+		for i := 2; used[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		typeNames[path] = name
+		used[strings.ToLower(name)] = true
+	}
+	return typeNames
+}
+
+// LookupDataType 根据 AR 绝对路径查找 DataType
+func (t *TransformHelper) LookupDataType(ref string) (*DataType, bool) {
+	dt, ok := t.DataTypes[strings.TrimSpace(ref)]
+	return dt, ok
+}
+
+// GetTypeName 返回 DataType 在 idl module 中的类型名
+func (t *TransformHelper) GetTypeName(ref string) string {
+	return t.typeNames[strings.TrimSpace(ref)]
+}
+
+// SetBaseTypes 设置 SW-BASE-TYPE 注册表, 用于解析直接引用 base type 的成员
+func (t *TransformHelper) SetBaseTypes(baseTypes *BaseTypeRegistry) {
+	t.baseTypes = baseTypes
+}
+
+// GetBasicKind 依次根据 TypReference 自身类型, SW-BASE-TYPE 注册表与标准类型名确定基础类型
+func (t *TransformHelper) GetBasicKind(tr *TypReference) (BasicKind, bool) {
+	if tr.Kind != "" {
+		return tr.Kind, true
+	}
+	if bt, ok := t.baseTypes.Lookup(tr.Ref); ok {
+		return bt.Kind, true
+	}
+	return GetStandardBasicKind(tr.Ref)
+}
+
+func (t *TransformHelper) getBasicType(tr *TypReference) typeref.TypeRef {
+	kind, ok := t.GetBasicKind(tr)
+	if !ok {
+		return nil
+	}
+	return GetBasicTypeFromRef(&TypReference{Ref: tr.Ref, StringSize: tr.StringSize, Kind: kind})
+}
+
+// RequiresSomeIPDecoder 判断类型是否包含 idl-parser 无法解析的结构 (如 union, map, TLV 结构体, 有符号 8 bit 整数, UTF-16 字符串, 带 BOM 的定长字符串), 需要使用 someip 解码
+func (t *TransformHelper) RequiresSomeIPDecoder(ref string) bool {
+	dt, ok := t.LookupDataType(ref)
+	if !ok {
+		return false
+	}
+	return t.requiresSomeIPDecoder(dt, make(map[*DataType]bool))
+}
+
+func (t *TransformHelper) requiresSomeIPDecoder(dt *DataType, visited map[*DataType]bool) bool {
+	if visited[dt] {
+		return false
+	}
+	visited[dt] = true
+	var refs []string
+	switch {
+	case dt.Union != nil, dt.Map != nil:
+		return true
+	case dt.Structure == nil && dt.TypReference != nil:
+		if _, ok := t.LookupDataType(dt.TypReference.Ref); !ok {
+			kind, _ := t.GetBasicKind(dt.TypReference)
+			return kind == BasicInt8 || dt.TypReference.Encoding == StringUTF16 || dt.TypReference.StringSize > 0
+		}
+		refs = append(refs, dt.TypReference.Ref)
+	case dt.Category == "ARRAY" && dt.Array != nil:
+		refs = append(refs, dt.Array.RefType)
+	case dt.Category == "VECTOR" && dt.Vector != nil:
+		refs = append(refs, dt.Vector.RefType)
+	case dt.Structure != nil:
+		if dt.Structure.IsTLV() {
+			return true
+		}
+		for _, str := range dt.Structure.STRList {
+			refs = append(refs, str.Ref)
+		}
+	}
+	for _, ref := range refs {
+		sub, ok := t.LookupDataType(ref)
+		if !ok {
+			if kind, _ := t.GetBasicKind(&TypReference{Ref: ref}); kind == BasicInt8 {
+				return true
+			}
+			continue
+		}
+		if t.requiresSomeIPDecoder(sub, visited) {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *TransformHelper) GetConverterRef() map[string]typeref.TypeRef {
 	return t.convertedTypeRefs
 }
 
 func (t *TransformHelper) TransformIntoModule() (*idlAst.Module, error) {
-	for _, dt := range t.DataTypes {
-		if dt.Category != "ARRAY" && dt.Category != "VECTOR" {
-			typeRef, err := t.convertDataTypeToTypeRef(dt)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert datatype %s: %w", dt.ShorName, err)
-			}
-			t.convertedTypeRefs[strings.ToLower(dt.ShorName)] = typeRef
-		}
+	paths, err := t.sortDataTypes()
+	if err != nil {
+		return nil, err
 	}
-	for _, dt := range t.DataTypes {
-		if dt.Category == "ARRAY" || dt.Category == "VECTOR" {
-			typeRef, err := t.convertDataTypeToTypeRef(dt)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert datatype %s: %w", dt.ShorName, err)
-			}
-			t.convertedTypeRefs[strings.ToLower(dt.ShorName)] = typeRef
+	for _, path := range paths {
+		dt := t.DataTypes[path]
+		typeRef, err := t.convertDataTypeToTypeRef(path, dt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert datatype %s: %w", dt.ShorName, err)
 		}
+		t.convertedTypeRefs[path] = typeRef
 	}
 	var content []idlAst.ModuleContent
-	for _, dt := range t.DataTypes {
+	for _, path := range paths {
+		dt := t.DataTypes[path]
 		if dt.Category == "STRUCTURE" && dt.Structure != nil {
-			structContent, err := t.transformStructure(dt)
+			structContent, err := t.transformStructure(path, dt)
 			if err != nil {
 				return nil, fmt.Errorf("failed to convert structure %s: %w", dt.ShorName, err)
 			}
 			content = append(content, *structContent)
 		}
+		if dt.Union != nil {
+			unionContent, err := t.transformUnion(path, dt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert union %s: %w", dt.ShorName, err)
+			}
+			content = append(content, *unionContent)
+		}
+		if dt.Map != nil {
+			entryContent, err := t.transformMapEntry(path, dt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert map %s: %w", dt.ShorName, err)
+			}
+			content = append(content, *entryContent)
+		}
 	}
 	module := &idlAst.Module{
 		Name:    "ArXMLDataTypes",
@@ -62,8 +193,76 @@ func (t *TransformHelper) TransformIntoModule() (*idlAst.Module, error) {
 	return module, nil
 }
 
+// sortDataTypes 按依赖关系对 DataTypes 排序, 被引用的类型排在引用者之前, 同一层级按 AR 路径排序以保证输出确定.
+// 类型之间存在循环引用时返回错误
+func (t *TransformHelper) sortDataTypes() ([]string, error) {
+	paths := make([]string, 0, len(t.DataTypes))
+	for path := range t.DataTypes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	sorted := make([]string, 0, len(paths))
+	done := make(map[string]bool, len(paths))
+	var stack []string
+	var visit func(path string) error
+	visit = func(path string) error {
+		if done[path] {
+			return nil
+		}
+		for i, p := range stack {
+			if p == path {
+				return fmt.Errorf("reference cycle found: %s", strings.Join(append(stack[i:], path), " -> "))
+			}
+		}
+		stack = append(stack, path)
+		for _, ref := range dataTypeDependencies(t.DataTypes[path]) {
+			ref = strings.TrimSpace(ref)
+			if _, ok := t.DataTypes[ref]; !ok {
+				continue
+			}
+			if err := visit(ref); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		done[path] = true
+		sorted = append(sorted, path)
+		return nil
+	}
+	for _, path := range paths {
+		if err := visit(path); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// dataTypeDependencies 返回 DataType 直接引用的类型
+func dataTypeDependencies(dt *DataType) []string {
+	var refs []string
+	switch {
+	case dt.Union != nil:
+		for _, member := range dt.Union.Members {
+			refs = append(refs, member.Ref)
+		}
+	case dt.Map != nil:
+		refs = append(refs, dt.Map.KeyRef, dt.Map.ValueRef)
+	case dt.Structure != nil:
+		for _, str := range dt.Structure.STRList {
+			refs = append(refs, str.Ref)
+		}
+	case dt.Vector != nil:
+		refs = append(refs, dt.Vector.RefType)
+	case dt.Array != nil:
+		refs = append(refs, dt.Array.RefType)
+	case dt.TypReference != nil:
+		refs = append(refs, dt.TypReference.Ref)
+	}
+	return refs
+}
+
 // convertStructure 将 ArXML Structure 转换为 idlAst Struct
-func (t *TransformHelper) transformStructure(dt *DataType) (*struct_type.Struct, error) {
+func (t *TransformHelper) transformStructure(path string, dt *DataType) (*struct_type.Struct, error) {
 	if dt.Structure == nil {
 		return nil, fmt.Errorf("structure is nil for %s", dt.ShorName)
 	}
@@ -77,12 +276,55 @@ func (t *TransformHelper) transformStructure(dt *DataType) (*struct_type.Struct,
 		fields = append(fields, struct_type.Field{Type: fieldType, Name: strField.ShorName})
 	}
 	return &struct_type.Struct{
-		Name:   dt.ShorName,
+		Name:   t.typeNames[path],
 		Fields: fields,
 		Type:   "Struct",
 	}, nil
 }
 
+// transformUnion 将 Union 的成员登记为 idlAst Struct, 仅用于保证 module 中的类型引用完整,
+// union 的实际解码由 someip 完成
+func (t *TransformHelper) transformUnion(path string, dt *DataType) (*struct_type.Struct, error) {
+	var fields []struct_type.Field
+	for _, member := range dt.Union.Members {
+		fieldType, err := t.transformField(member)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert member %s: %w", member.ShorName, err)
+		}
+		fields = append(fields, struct_type.Field{Type: fieldType, Name: member.ShorName})
+	}
+	return &struct_type.Struct{
+		Name:   t.typeNames[path],
+		Fields: fields,
+		Type:   "Struct",
+	}, nil
+}
+
+// transformMapEntry 将 Map 的 key/value 登记为 idlAst Struct, map 在 module 中表示为该 Struct 的 sequence,
+// map 的实际解码由 someip 完成
+func (t *TransformHelper) transformMapEntry(path string, dt *DataType) (*struct_type.Struct, error) {
+	keyType, err := t.createTypeRef(dt.Map.KeyRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert key: %w", err)
+	}
+	valueType, err := t.createTypeRef(dt.Map.ValueRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert value: %w", err)
+	}
+	return &struct_type.Struct{
+		Name: mapEntryTypeName(t.typeNames[path]),
+		Fields: []struct_type.Field{
+			{Type: keyType, Name: "key"},
+			{Type: valueType, Name: "value"},
+		},
+		Type: "Struct",
+	}, nil
+}
+
+func mapEntryTypeName(mapName string) string {
+	return mapName + "_Entry"
+}
+
 // convertField 将 ArXML StructureTypRef 转换为 idlAst Field
 func (t *TransformHelper) transformField(strField *StructureTypRef) (typeref.TypeRef, error) {
 	typeRef, err := t.createTypeRef(strField.Ref)
@@ -94,16 +336,19 @@ func (t *TransformHelper) transformField(strField *StructureTypRef) (typeref.Typ
 
 // createTypeRef 根据引用字符串创建对应的 TypeRef
 func (t *TransformHelper) createTypeRef(ref string) (typeref.TypeRef, error) {
-	key := ExtractTypeNameFromRef(ref)
-	targetType, ok := t.convertedTypeRefs[key]
+	targetType, ok := t.convertedTypeRefs[strings.TrimSpace(ref)]
 	if !ok {
+		// CP union 成员可直接引用 base type
+		if basicType := t.getBasicType(&TypReference{Ref: ref}); basicType != nil {
+			return basicType, nil
+		}
 		return nil, fmt.Errorf("failed to convert type ref %s", ref)
 	}
 	return targetType, nil
 }
 
 // convertDataTypeToTypeRef 将 ArXML DataType 转换为 typeref.TypeRef
-func (t *TransformHelper) convertDataTypeToTypeRef(dt *DataType) (typeref.TypeRef, error) {
+func (t *TransformHelper) convertDataTypeToTypeRef(path string, dt *DataType) (typeref.TypeRef, error) {
 	switch {
 	case dt.Category == "TYPE_REFERENCE" || dt.TypReference != nil:
 		return t.convertTypReference(dt.TypReference)
@@ -112,7 +357,11 @@ func (t *TransformHelper) convertDataTypeToTypeRef(dt *DataType) (typeref.TypeRe
 	case dt.Category == "VECTOR":
 		return t.convertVector(dt.Vector)
 	case dt.Category == "STRUCTURE":
-		return t.convertStructure(dt.Structure, dt.ShorName)
+		return t.convertStructure(dt.Structure, t.typeNames[path])
+	case dt.Union != nil:
+		return t.convertUnion(dt.Union, t.typeNames[path])
+	case dt.Map != nil:
+		return typeref.NewSequence(typeref.NewTypeName(mapEntryTypeName(t.typeNames[path]))), nil
 	default:
 		return nil, fmt.Errorf("unsupported category: %s", dt.Category)
 	}
@@ -123,7 +372,11 @@ func (t *TransformHelper) convertTypReference(tr *TypReference) (typeref.TypeRef
 	if tr == nil {
 		return nil, fmt.Errorf("typReference is nil")
 	}
-	if basicType := GetBasicTypeFromRef(tr); basicType != nil {
+	// 引用其它 DataType 的 typedef, 被引用类型已按依赖顺序先行转换
+	if typeRef, ok := t.convertedTypeRefs[strings.TrimSpace(tr.Ref)]; ok {
+		return typeRef, nil
+	}
+	if basicType := t.getBasicType(tr); basicType != nil {
 		return basicType, nil
 	}
 	return nil, fmt.Errorf("unknown type reference: %s", tr.Ref)
@@ -164,23 +417,24 @@ func (t *TransformHelper) convertStructure(structData *Structure, structName str
 	return typeref.NewTypeName(structName), nil
 }
 
+// convertUnion 转换 Union 为 TypeRef
+func (t *TransformHelper) convertUnion(unionData *Union, unionName string) (typeref.TypeRef, error) {
+	if unionData == nil {
+		return nil, fmt.Errorf("union is nil")
+	}
+	if len(unionData.Members) < 1 {
+		return nil, fmt.Errorf("union %s has no members", unionName)
+	}
+	return typeref.NewTypeName(unionName), nil
+}
+
 // convertRefToTypeRef 根据引用字符串转换 TypeRef
 func (t *TransformHelper) convertRefToTypeRef(ref string) (typeref.TypeRef, error) {
-	if typeRef, exists := t.convertedTypeRefs[strings.ToLower(ExtractTypeNameFromRef(ref))]; exists {
+	if typeRef, exists := t.convertedTypeRefs[strings.TrimSpace(ref)]; exists {
 		return typeRef, nil
 	}
-	return nil, fmt.Errorf("unkown ref %v", ref)
-}
-
-func ExtractTypeNameFromRef(ref string) string {
-	extractedRef := extractType(ref)
-	return strings.ToLower(extractedRef)
-}
-
-func extractType(ref string) string {
-	parts := strings.Split(ref, "/")
-	if len(parts) > 0 {
-		return parts[len(parts)-1]
+	if basicType := t.getBasicType(&TypReference{Ref: ref}); basicType != nil {
+		return basicType, nil
 	}
-	return ref
+	return nil, fmt.Errorf("unkown ref %v", ref)
 }
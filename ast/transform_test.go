@@ -0,0 +1,103 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yisaer/idl-parser/ast/typeref"
+)
+
+func TestTypeNamesWithSameShortName(t *testing.T) {
+	th := NewTransformHelper(map[string]*DataType{
+		"/A/Point": NewStructureDataType("Point", "STRUCTURE", &Structure{
+			STRList: []*StructureTypRef{{ShorName: "x", Ref: "/AUTOSAR/StdTypes/uint8_t"}},
+		}),
+		"/B/Point": NewStructureDataType("Point", "STRUCTURE", &Structure{
+			STRList: []*StructureTypRef{{ShorName: "x", Ref: "/AUTOSAR/StdTypes/uint16_t"}},
+		}),
+		"/B/Line": NewStructureDataType("Line", "STRUCTURE", &Structure{
+			STRList: []*StructureTypRef{{ShorName: "from", Ref: "/B/Point"}},
+		}),
+	})
+	_, err := th.TransformIntoModule()
+	require.NoError(t, err)
+	require.Equal(t, "A_Point", th.GetTypeName("/A/Point"))
+	require.Equal(t, "B_Point", th.GetTypeName("/B/Point"))
+	require.Equal(t, "Line", th.GetTypeName("/B/Line"))
+	require.Equal(t, "B_Point", th.GetConverterRef()["/B/Point"].TypeName())
+}
+
+func TestTypeNamesWithGeneratedCollision(t *testing.T) {
+	newPoint := func(sn string) *DataType {
+		return NewStructureDataType(sn, "STRUCTURE", &Structure{
+			STRList: []*StructureTypRef{{ShorName: "x", Ref: "/AUTOSAR/StdTypes/uint8_t"}},
+		})
+	}
+	th := NewTransformHelper(map[string]*DataType{
+		"/A/B_C":   newPoint("B_C"),
+		"/A_B/C":   newPoint("C"),
+		"/A/B/C":   newPoint("C"),
+		"/Pkg/Foo": newPoint("Foo"),
+		"/Foo":     newPoint("Foo"),
+		"/Pkg_Foo": newPoint("Pkg_Foo"),
+	})
+	_, err := th.TransformIntoModule()
+	require.NoError(t, err)
+	require.Equal(t, "B_C", th.GetTypeName("/A/B_C"))
+	require.Equal(t, "Pkg_Foo", th.GetTypeName("/Pkg_Foo"))
+	require.Equal(t, "A_B_C", th.GetTypeName("/A/B/C"))
+	require.Equal(t, "A_B_C_2", th.GetTypeName("/A_B/C"))
+	require.Equal(t, "Foo", th.GetTypeName("/Foo"))
+	require.Equal(t, "Pkg_Foo_2", th.GetTypeName("/Pkg/Foo"))
+}
+
+func TestTransformMap(t *testing.T) {
+	th := NewTransformHelper(map[string]*DataType{
+		"/dataTypes/Names": NewMapDataType("Names", "ASSOCIATIVE_MAP", &Map{
+			KeyRef:   "/AUTOSAR/StdTypes/uint16_t",
+			ValueRef: "/AUTOSAR/StdTypes/string",
+		}),
+	})
+	m, err := th.TransformIntoModule()
+	require.NoError(t, err)
+	require.Len(t, m.Content, 1)
+	require.IsType(t, typeref.NewSequence(typeref.NewTypeName("Names_Entry")), th.GetConverterRef()["/dataTypes/Names"])
+	require.True(t, th.RequiresSomeIPDecoder("/dataTypes/Names"))
+}
+
+func TestTransformNestedArrays(t *testing.T) {
+	th := NewTransformHelper(map[string]*DataType{
+		"/dataTypes/Row":    {ShorName: "Row", Category: "VECTOR", Vector: &Vector{RefType: "/AUTOSAR/StdTypes/uint8_t"}},
+		"/dataTypes/Matrix": NewArrayDataType("Matrix", "ARRAY", "/dataTypes/Row", 3),
+		"/dataTypes/Alias":  {ShorName: "Alias", Category: "TYPE_REFERENCE", TypReference: &TypReference{Ref: "/dataTypes/Matrix"}},
+		"/dataTypes/Cells":  {ShorName: "Cells", Category: "VECTOR", Vector: &Vector{RefType: "/dataTypes/Cell"}},
+		"/dataTypes/Cell": NewStructureDataType("Cell", "STRUCTURE", &Structure{
+			STRList: []*StructureTypRef{{ShorName: "values", Ref: "/dataTypes/Alias"}},
+		}),
+	})
+	m, err := th.TransformIntoModule()
+	require.NoError(t, err)
+	require.Len(t, m.Content, 1)
+	refs := th.GetConverterRef()
+	require.Equal(t, refs["/dataTypes/Matrix"], refs["/dataTypes/Alias"])
+	require.Equal(t, typeref.NewArrayType(typeref.NewSequence(typeref.NewOctetType()), 3), refs["/dataTypes/Matrix"])
+	require.Equal(t, typeref.NewSequence(typeref.NewTypeName("Cell")), refs["/dataTypes/Cells"])
+
+	// 输出顺序与 map 遍历顺序无关
+	for i := 0; i < 10; i++ {
+		paths, err := th.sortDataTypes()
+		require.NoError(t, err)
+		require.Equal(t, []string{"/dataTypes/Row", "/dataTypes/Matrix", "/dataTypes/Alias", "/dataTypes/Cell", "/dataTypes/Cells"}, paths)
+	}
+}
+
+func TestTransformReferenceCycle(t *testing.T) {
+	th := NewTransformHelper(map[string]*DataType{
+		"/dataTypes/Node": NewStructureDataType("Node", "STRUCTURE", &Structure{
+			STRList: []*StructureTypRef{{ShorName: "children", Ref: "/dataTypes/Nodes"}},
+		}),
+		"/dataTypes/Nodes": {ShorName: "Nodes", Category: "VECTOR", Vector: &Vector{RefType: "/dataTypes/Node"}},
+	})
+	_, err := th.TransformIntoModule()
+	require.ErrorContains(t, err, "reference cycle found: /dataTypes/Node -> /dataTypes/Nodes -> /dataTypes/Node")
+}
@@ -7,6 +7,8 @@ type DataType struct {
 	Array *Array
 	*Vector
 	*Structure
+	*Union
+	*Map
 }
 
 func NewArrayDataType(shortname, category, arrayRef string, arraySize int64) *DataType {
@@ -30,13 +32,32 @@ func NewStructureDataType(shortname, category string, s *Structure) *DataType {
 	return dt
 }
 
-func NewBasicDataType(shortname, category string, ref string) *DataType {
+func NewUnionDataType(shortname, category string, u *Union) *DataType {
+	dt := &DataType{
+		ShorName: shortname,
+		Category: category,
+	}
+	dt.Union = u
+	return dt
+}
+
+func NewMapDataType(shortname, category string, m *Map) *DataType {
+	dt := &DataType{
+		ShorName: shortname,
+		Category: category,
+	}
+	dt.Map = m
+	return dt
+}
+
+func NewBasicDataType(shortname, category string, ref string, kind BasicKind) *DataType {
 	dt := &DataType{
 		ShorName: shortname,
 		Category: category,
 	}
 	trf := &TypReference{
-		Ref: ref,
+		Ref:  ref,
+		Kind: kind,
 	}
 	dt.TypReference = trf
 	return dt
@@ -56,8 +77,29 @@ func NewStringDataType(shortname, category string, stringSize int64) *DataType {
 }
 
 type TypReference struct {
-	Ref        string `json:"ref"`
-	StringSize int64  `json:"string_size"`
+	Ref string `json:"ref"`
+	// StringSize 为定长字符串在 payload 中占用的字节数, 0 表示带长度字段的变长字符串
+	StringSize int64 `json:"string_size"`
+	// Kind 为由 SW-BASE-TYPE 解析出的基础类型
+	Kind BasicKind `json:"kind,omitempty"`
+	// Encoding 为字符串声明的编码, payload 中带 BOM 时以 BOM 为准
+	Encoding StringEncoding `json:"encoding,omitempty"`
+}
+
+type StringEncoding string
+
+const (
+	StringUTF8  StringEncoding = "UTF-8"
+	StringUTF16 StringEncoding = "UTF-16"
+)
+
+// FixedStringSize 返回内容长度为 length 个编码单元的定长字符串在 payload 中占用的字节数, 包含 BOM 与结束符.
+// 发送端按配置的长度填充, UTF-8 的编码单元为 1 字节, UTF-16 为 2 字节
+func (e StringEncoding) FixedStringSize(length int64) int64 {
+	if e == StringUTF16 {
+		return 2 + length*2 + 2
+	}
+	return 3 + length + 1
 }
 
 type Vector struct {
@@ -75,7 +117,31 @@ type Structure struct {
 }
 
 type StructureTypRef struct {
-	InPlace  bool   `json:"in_place"`
-	Ref      string `json:"ref"`
-	ShorName string `json:"shor_name"`
+	InPlace    bool   `json:"in_place"`
+	Ref        string `json:"ref"`
+	ShorName   string `json:"shor_name"`
+	IsOptional bool   `json:"is_optional"`
+	// DataID 为 SOME/IP TLV 编码使用的 data id, 为 nil 时该成员不使用 TLV 编码
+	DataID *uint16 `json:"data_id,omitempty"`
+}
+
+// IsTLV 当任一成员配置了 data id 时, 该结构体使用 TLV 编码
+func (s *Structure) IsTLV() bool {
+	for _, str := range s.STRList {
+		if str.DataID != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Union 对应 AP VARIANT 和 CP UNION, 成员顺序即 SOME/IP type selector 的取值顺序 (从 1 开始)
+type Union struct {
+	Members []*StructureTypRef `json:"members"`
+}
+
+// Map 对应 AP ASSOCIATIVE_MAP, SOME/IP 中序列化为带长度字段的 key/value 序列
+type Map struct {
+	KeyRef   string `json:"key_ref"`
+	ValueRef string `json:"value_ref"`
 }
@@ -6,37 +6,106 @@ import (
 	"github.com/yisaer/idl-parser/ast/typeref"
 )
 
+type BasicKind string
+
+const (
+	BasicString BasicKind = "string"
+	BasicUint8  BasicKind = "uint8"
+	BasicUint16 BasicKind = "uint16"
+	BasicUint32 BasicKind = "uint32"
+	BasicUint64 BasicKind = "uint64"
+	BasicBool   BasicKind = "bool"
+	BasicInt8   BasicKind = "int8"
+	BasicInt16  BasicKind = "int16"
+	BasicInt32  BasicKind = "int32"
+	BasicInt64  BasicKind = "int64"
+	BasicFloat  BasicKind = "float"
+	BasicDouble BasicKind = "double"
+)
+
+// stdCppTypes 为 AP AUTOSAR/StdTypes 中的标准 C++ 类型
+var stdCppTypes = map[string]BasicKind{
+	"string":   BasicString,
+	"uint8_t":  BasicUint8,
+	"uint16_t": BasicUint16,
+	"uint32_t": BasicUint32,
+	"uint64_t": BasicUint64,
+	"bool":     BasicBool,
+	"int8_t":   BasicInt8,
+	"int16_t":  BasicInt16,
+	"int32_t":  BasicInt32,
+	"int64_t":  BasicInt64,
+	"float":    BasicFloat,
+	"double":   BasicDouble,
+}
+
+// platformTypes 为 CP AUTOSAR Platform Types, 用于 SW-BASE-TYPE 定义不在当前文件中的情况
+var platformTypes = map[string]BasicKind{
+	"boolean": BasicBool,
+	"uint8":   BasicUint8,
+	"uint16":  BasicUint16,
+	"uint32":  BasicUint32,
+	"uint64":  BasicUint64,
+	"sint8":   BasicInt8,
+	"sint16":  BasicInt16,
+	"sint32":  BasicInt32,
+	"sint64":  BasicInt64,
+	"float32": BasicFloat,
+	"float64": BasicDouble,
+}
+
+// GetBasicKindFromRef 优先使用解析时由 SW-BASE-TYPE 确定的类型, 否则按标准类型名精确匹配
+func GetBasicKindFromRef(tr *TypReference) (BasicKind, bool) {
+	if tr.Kind != "" {
+		return tr.Kind, true
+	}
+	return GetStandardBasicKind(tr.Ref)
+}
+
+// GetStandardBasicKind 按 AP 标准 C++ 类型与 CP Platform Types 名称精确匹配基础类型,
+// 仅用于引用的类型未在当前文件中定义的情况
+func GetStandardBasicKind(ref string) (BasicKind, bool) {
+	name := strings.ToLower(ref[strings.LastIndex(ref, "/")+1:])
+	if kind, ok := stdCppTypes[name]; ok {
+		return kind, true
+	}
+	kind, ok := platformTypes[name]
+	return kind, ok
+}
+
 func GetBasicTypeFromRef(tr *TypReference) typeref.TypeRef {
-	ref := tr.Ref
-	typeName := ExtractTypeNameFromRef(ref)
-	lowerName := strings.ToLower(typeName)
-	switch {
-	case strings.Contains(lowerName, "string"):
+	kind, ok := GetBasicKindFromRef(tr)
+	if !ok {
+		return nil
+	}
+	switch kind {
+	case BasicString:
 		if tr.StringSize > 0 {
 			return typeref.NewFixedLengthStringType(int(tr.StringSize))
 		}
 		return typeref.NewStringType()
-	case strings.Contains(lowerName, "uint8"):
+	case BasicUint8:
 		return typeref.NewOctetType()
-	case strings.Contains(lowerName, "uint16"):
+	case BasicUint16:
 		return typeref.NewUnsignedShortType()
-	case strings.Contains(lowerName, "uint32"):
+	case BasicUint32:
 		return typeref.NewUnsignedLong()
-	case strings.Contains(lowerName, "uint64"):
+	case BasicUint64:
 		return typeref.NewUnsignedLongLong()
-	case strings.Contains(lowerName, "bool"):
+	case BasicBool:
 		return typeref.NewBooleanType()
-	case strings.Contains(lowerName, "int8"):
+	case BasicInt8:
+		// idl 中没有有符号 8 bit 类型, 包含 int8 的类型由 someip 解码
 		return typeref.NewOctetType()
-	case strings.Contains(lowerName, "int16"):
+	case BasicInt16:
 		return typeref.NewShortType()
-	case strings.Contains(lowerName, "int32"):
+	case BasicInt32:
 		return typeref.NewLongType()
-	case strings.Contains(lowerName, "int64"):
+	case BasicInt64:
 		return typeref.NewLongLongType()
-	case strings.Contains(lowerName, "float"):
+	case BasicFloat:
 		return typeref.NewFloatType()
-	case strings.Contains(lowerName, "double"):
+	case BasicDouble:
 		return typeref.NewDoubleType()
 	}
 	return nil
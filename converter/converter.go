@@ -9,7 +9,10 @@ import (
 	"github.com/yisaer/idl-parser/converter"
 
 	apconverter "github.com/yisaer/arxml-converter/ap/converter"
+	"github.com/yisaer/arxml-converter/ast"
 	cpconverter "github.com/yisaer/arxml-converter/cp/converter"
+	"github.com/yisaer/arxml-converter/e2e"
+	"github.com/yisaer/arxml-converter/someip"
 	"github.com/yisaer/arxml-converter/util"
 )
 
@@ -27,6 +30,8 @@ type ArxmlConverter struct {
 	apArxmlConverter *apconverter.ArXMLConverter
 	doc              *etree.Document
 	version          AutosarXsdVersion
+	// reassembler 重组 DecodeSegmentedSomeIP 收到的 SOME/IP-TP 分段
+	reassembler *someip.Reassembler
 }
 
 func NewConverter(path string, config converter.IDlConverterConfig) (*ArxmlConverter, error) {
@@ -56,6 +61,7 @@ func NewConverter(path string, config converter.IDlConverterConfig) (*ArxmlConve
 		if err != nil {
 			return nil, err
 		}
+		c.reassembler = c.cpArxmlConverter.NewReassembler(someip.ReassemblerConfig{})
 		return c, nil
 	}
 	if isAp {
@@ -63,6 +69,7 @@ func NewConverter(path string, config converter.IDlConverterConfig) (*ArxmlConve
 		if err != nil {
 			return nil, err
 		}
+		c.reassembler = someip.NewReassembler(someip.ReassemblerConfig{}, nil)
 		return c, nil
 	}
 
@@ -89,6 +96,182 @@ func (c *ArxmlConverter) Decode(serviceID uint16, eventID uint16, data []byte) (
 	return "", nil, fmt.Errorf("no converter found")
 }
 
+// DecodeMessage 根据 SOME/IP message type 解析 payload, 用于区分 method 的 request 与 response.
+// error 消息需要 header 中的 return code, 需使用 DecodeSomeIP 解析
+func (c *ArxmlConverter) DecodeMessage(serviceID uint16, methodID uint16, messageType someip.MessageType, data []byte) (string, interface{}, error) {
+	if err := checkMessageType(messageType); err != nil {
+		return "", nil, err
+	}
+	if c.apArxmlConverter != nil {
+		return c.apArxmlConverter.DecodeMessage(int(serviceID), int(methodID), messageType, data)
+	}
+	if c.cpArxmlConverter != nil {
+		return c.cpArxmlConverter.ConvertMessage(serviceID, ast.AnyMajorVersion, MergeUint16ToUint32(serviceID, methodID), messageType, 0, data)
+	}
+	return "", nil, fmt.Errorf("no converter found")
+}
+
+// SDMessageName 为 DecodeSomeIP 解析 SOME/IP-SD 报文时返回的名称
+const SDMessageName = "ServiceDiscovery"
+
+// DecodeSomeIP 解析完整的 SOME/IP 报文, 以 header 中的 interface version 选择 service 部署.
+// SOME/IP-SD 报文解析为 *someip.SDMessage
+func (c *ArxmlConverter) DecodeSomeIP(message []byte) (string, interface{}, error) {
+	h, payload, err := someip.ParseHeader(message)
+	if err != nil {
+		return "", nil, err
+	}
+	if h.IsSD() {
+		sd, err := c.DecodeSD(payload)
+		return SDMessageName, sd, err
+	}
+	if c.cpArxmlConverter != nil {
+		// CP 的 application error 以 return code 传输
+		return c.cpArxmlConverter.ConvertMessage(h.ServiceID, int(h.InterfaceVersion), MergeUint16ToUint32(h.ServiceID, h.MethodID), h.MessageType, h.ReturnCode, payload)
+	}
+	if c.apArxmlConverter != nil {
+		return c.apArxmlConverter.DecodeVersionedMessage(int(h.ServiceID), int(h.InterfaceVersion), int(h.MethodID), h.MessageType, payload)
+	}
+	return "", nil, fmt.Errorf("no converter found")
+}
+
+// DecodeProtectedSomeIP 与 DecodeSomeIP 相同, 并以 SOME/IP header 中 request id 开始的 8 字节为 upper header
+// 校验 E2E 保护的元素. source 标识发送端 (如发送端的 IP 与端口), 不同发送端的 E2E counter 分别校验.
+// 未配置 E2E 保护时返回的 e2e.Result 为 nil
+func (c *ArxmlConverter) DecodeProtectedSomeIP(source string, message []byte) (string, interface{}, *e2e.Result, error) {
+	h, payload, err := someip.ParseHeader(message)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if h.IsSD() {
+		sd, err := c.DecodeSD(payload)
+		return SDMessageName, sd, nil, err
+	}
+	upperHeader := message[8:someip.HeaderLength]
+	if c.apArxmlConverter != nil {
+		return c.apArxmlConverter.DecodeProtectedMessage(source, int(h.ServiceID), int(h.InterfaceVersion), int(h.MethodID), h.MessageType, upperHeader, payload)
+	}
+	if c.cpArxmlConverter != nil {
+		return c.cpArxmlConverter.ConvertProtectedMessage(source, h.ServiceID, int(h.InterfaceVersion), MergeUint16ToUint32(h.ServiceID, h.MethodID), h.MessageType, h.ReturnCode, upperHeader, payload)
+	}
+	return "", nil, nil, fmt.Errorf("no converter found")
+}
+
+// DecodeSegmentedSomeIP 与 DecodeSomeIP 相同, 并重组 SOME/IP-TP 分段. 收到最后一个分段时解析重组后的报文,
+// 否则第三个返回值为 false
+func (c *ArxmlConverter) DecodeSegmentedSomeIP(message []byte) (string, interface{}, bool, error) {
+	message, complete, err := c.reassembler.Add(message)
+	if err != nil || !complete {
+		return "", nil, false, err
+	}
+	key, v, err := c.DecodeSomeIP(message)
+	return key, v, true, err
+}
+
+// DecodeChannelSomeIP 与 DecodeProtectedSomeIP 相同, CP 中只在 channel 对应的物理通道中查找 header id,
+// 用于不同物理通道上使用相同 header id 的 ECU
+func (c *ArxmlConverter) DecodeChannelSomeIP(channel, source string, message []byte) (string, interface{}, *e2e.Result, error) {
+	if c.cpArxmlConverter == nil {
+		return c.DecodeProtectedSomeIP(source, message)
+	}
+	h, payload, err := someip.ParseHeader(message)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if h.IsSD() {
+		sd, err := c.DecodeSD(payload)
+		return SDMessageName, sd, nil, err
+	}
+	return c.cpArxmlConverter.ConvertChannelMessage(channel, source, h.ServiceID, int(h.InterfaceVersion), MergeUint16ToUint32(h.ServiceID, h.MethodID), h.MessageType, h.ReturnCode, message[8:someip.HeaderLength], payload)
+}
+
+// DecodeVersionedMessage 与 DecodeMessage 相同, 以 interface version 选择 service
+func (c *ArxmlConverter) DecodeVersionedMessage(serviceID uint16, methodID uint16, interfaceVersion uint8, messageType someip.MessageType, data []byte) (string, interface{}, error) {
+	if err := checkMessageType(messageType); err != nil {
+		return "", nil, err
+	}
+	if c.apArxmlConverter != nil {
+		return c.apArxmlConverter.DecodeVersionedMessage(int(serviceID), int(interfaceVersion), int(methodID), messageType, data)
+	}
+	if c.cpArxmlConverter != nil {
+		return c.cpArxmlConverter.ConvertMessage(serviceID, int(interfaceVersion), MergeUint16ToUint32(serviceID, methodID), messageType, 0, data)
+	}
+	return "", nil, fmt.Errorf("no converter found")
+}
+
+// checkMessageType 拒绝没有 return code 无法解析的 error 消息
+func checkMessageType(messageType someip.MessageType) error {
+	if messageType.IsError() {
+		return fmt.Errorf("message type 0x%02x carries an application error, use DecodeSomeIP to decode it with the return code", uint8(messageType))
+	}
+	return nil
+}
+
+// DecodeSignals 解析 CP 中 header id 对应 PDU 的全部 I-SIGNAL, 返回 PDU 的 SHORT-NAME
+func (c *ArxmlConverter) DecodeSignals(serviceID uint16, methodID uint16, messageType someip.MessageType, data []byte) (string, []*cpconverter.Signal, error) {
+	if c.cpArxmlConverter != nil {
+		return c.cpArxmlConverter.ConvertSignals(serviceID, ast.AnyMajorVersion, MergeUint16ToUint32(serviceID, methodID), messageType, data)
+	}
+	return "", nil, fmt.Errorf("signal decoding requires a cp arxml")
+}
+
+// DecodeFrame 解析 CP 中 CAN/LIN/FlexRay 通道上 identifier 对应的 frame, 返回 frame triggering 的 SHORT-NAME
+func (c *ArxmlConverter) DecodeFrame(channel string, canID uint32, data []byte) (string, []*cpconverter.Signal, error) {
+	if c.cpArxmlConverter != nil {
+		return c.cpArxmlConverter.DecodeFrame(channel, canID, data)
+	}
+	return "", nil, fmt.Errorf("frame decoding requires a cp arxml")
+}
+
+// DecodePDU 按位解析 CP 中 AR 路径对应的 I-SIGNAL-I-PDU, CONTAINER-I-PDU 或 MULTIPLEXED-I-PDU
+func (c *ArxmlConverter) DecodePDU(pduRef string, data []byte) ([]*cpconverter.Signal, error) {
+	if c.cpArxmlConverter != nil {
+		return c.cpArxmlConverter.DecodePDU(pduRef, data)
+	}
+	return nil, fmt.Errorf("pdu decoding requires a cp arxml")
+}
+
+// DecodeSecuredPDU 解析 CP 中的 SECURED-I-PDU, 返回 authentic PDU 的 signal 与截断后的 freshness value 及 MAC, verifier 可以为 nil
+func (c *ArxmlConverter) DecodeSecuredPDU(pduRef string, data []byte, verifier cpconverter.SecOCVerifier) (*cpconverter.SecuredPayload, error) {
+	if c.cpArxmlConverter != nil {
+		return c.cpArxmlConverter.DecodeSecuredPDU(pduRef, data, verifier)
+	}
+	return nil, fmt.Errorf("pdu decoding requires a cp arxml")
+}
+
+// DecodeSD 解析 SOME/IP-SD payload, entry 中的 service, instance 与 eventgroup 以 ARXML 中的 SHORT-NAME 标注
+func (c *ArxmlConverter) DecodeSD(payload []byte) (*someip.SDMessage, error) {
+	if c.apArxmlConverter != nil {
+		return c.apArxmlConverter.DecodeSD(payload)
+	}
+	if c.cpArxmlConverter != nil {
+		return c.cpArxmlConverter.DecodeSD(payload)
+	}
+	return someip.DecodeSD(payload, nil)
+}
+
+// GetEventGroup 返回 event group 及其成员 event, 用于按 event group id 列出 event
+func (c *ArxmlConverter) GetEventGroup(serviceID uint16, eventGroupID uint16) (*ast.EventGroup, error) {
+	if c.apArxmlConverter != nil {
+		return c.apArxmlConverter.GetEventGroup(int(serviceID), int(eventGroupID))
+	}
+	if c.cpArxmlConverter != nil {
+		return c.cpArxmlConverter.GetEventGroup(serviceID, eventGroupID)
+	}
+	return nil, fmt.Errorf("no converter found")
+}
+
+// FindEventGroups 返回包含该 event 的 event group
+func (c *ArxmlConverter) FindEventGroups(serviceID uint16, eventID uint16) ([]*ast.EventGroup, error) {
+	if c.apArxmlConverter != nil {
+		return c.apArxmlConverter.FindEventGroups(int(serviceID), int(eventID)), nil
+	}
+	if c.cpArxmlConverter != nil {
+		return c.cpArxmlConverter.FindEventGroups(serviceID, eventID), nil
+	}
+	return nil, fmt.Errorf("no converter found")
+}
+
 func MergeUint16ToUint32(high16, low16 uint16) uint32 {
 	return uint32(high16)<<16 | uint32(low16)
 }
@@ -171,7 +354,6 @@ func (c *ArxmlConverter) IsCP() (bool, error) {
 	var SoAdRoutingGroups *etree.Element
 	var System *etree.Element
 	var Topology *etree.Element
-	var DataTypeMappingSets *etree.Element
 	arpackagesElement := autosarElement.SelectElement("AR-PACKAGES")
 	if arpackagesElement == nil {
 		return false, nil
@@ -195,9 +377,6 @@ func (c *ArxmlConverter) IsCP() (bool, error) {
 			System = arg
 		case "Topology":
 			Topology = arg
-		case "DataTypeMappingSets":
-			DataTypeMappingSets = arg
-
 		}
 	}
 	if DataTypes == nil {
@@ -218,8 +397,5 @@ func (c *ArxmlConverter) IsCP() (bool, error) {
 	if Topology == nil {
 		return false, nil
 	}
-	if DataTypeMappingSets == nil {
-		return false, nil
-	}
 	return true, nil
 }
@@ -6,6 +6,9 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"github.com/yisaer/idl-parser/converter"
+
+	"github.com/yisaer/arxml-converter/e2e"
+	"github.com/yisaer/arxml-converter/someip"
 )
 
 func TestS1APCase(t *testing.T) {
@@ -51,8 +54,112 @@ func TestS1CPCase(t *testing.T) {
 	require.Equal(t, "Test", v)
 }
 
+func TestS1CPDecodeSomeIP(t *testing.T) {
+	c, err := NewConverter("../test/s1_cp_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	// removeWiFiLoginInfo response, para1 = 1
+	message := []byte{0x82, 0x02, 0x00, 0x05, 0x00, 0x00, 0x00, 0x0A, 0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0x80, 0x00, 0x00, 0x01}
+	name, v, err := c.DecodeSomeIP(message)
+	require.NoError(t, err)
+	require.Equal(t, "removeWiFiLoginInfo", name)
+	require.Equal(t, map[string]interface{}{"para1": uint16(1)}, v)
+}
+
+func TestS1CPDecodeErrorMessage(t *testing.T) {
+	c, err := NewConverter("../test/s1_cp_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	// error 消息的 return code 只在 SOME/IP header 中
+	_, _, err = c.DecodeMessage(33282, 5, someip.MessageTypeError, nil)
+	require.ErrorContains(t, err, "DecodeSomeIP")
+	_, _, err = c.DecodeVersionedMessage(33282, 5, 1, someip.MessageTypeError, nil)
+	require.ErrorContains(t, err, "DecodeSomeIP")
+
+	name, v, err := c.DecodeMessage(33282, 5, someip.MessageTypeResponse, []byte{0x00, 0x01})
+	require.NoError(t, err)
+	require.Equal(t, "removeWiFiLoginInfo", name)
+	require.Equal(t, map[string]interface{}{"para1": uint16(1)}, v)
+}
+
+func TestS1CPDecodeSegmentedSomeIP(t *testing.T) {
+	c, err := NewConverter("../test/s1_cp_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	// removeWiFiLoginInfo response 分为 16 字节与 2 字节两个分段, para1 = 1
+	first := []byte{0x82, 0x02, 0x00, 0x05, 0x00, 0x00, 0x00, 0x1C, 0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0xA0, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	_, _, complete, err := c.DecodeSegmentedSomeIP(first)
+	require.NoError(t, err)
+	require.False(t, complete)
+	last := []byte{0x82, 0x02, 0x00, 0x05, 0x00, 0x00, 0x00, 0x0E, 0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0xA0, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00}
+	name, v, complete, err := c.DecodeSegmentedSomeIP(last)
+	require.NoError(t, err)
+	require.True(t, complete)
+	require.Equal(t, "removeWiFiLoginInfo", name)
+	require.Equal(t, map[string]interface{}{"para1": uint16(1)}, v)
+}
+
 func SplitUint32ToUint16(num uint32) (high16, low16 uint16) {
 	high16 = uint16(num >> 16) // 高16位
 	low16 = uint16(num)        // 低16位
 	return
 }
+
+func TestS1APDecodeSomeIP(t *testing.T) {
+	c, err := NewConverter("../test/s1_ap_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	// wiFiSwitch notifier, interface version 1
+	message := []byte{0x82, 0x02, 0x80, 0x06, 0x00, 0x00, 0x00, 0x0C, 0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	name, v, err := c.DecodeSomeIP(message)
+	require.NoError(t, err)
+	require.Equal(t, "wiFiSwitch", name)
+	require.Equal(t, int32(1), v)
+
+	// 没有部署 interface version 2
+	message[13] = 0x02
+	_, _, err = c.DecodeSomeIP(message)
+	require.Error(t, err)
+}
+
+func TestS1APDecodeProtectedSomeIP(t *testing.T) {
+	c, err := NewConverter("../test/s1_ap_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	cfg := c.apArxmlConverter.Parser.E2EProtections["/interfaces/INI_WiFiStation/reportWiFiSwitchStatus"]
+	require.NotNil(t, cfg)
+	header := []byte{0x82, 0x02, 0x80, 0x03, 0x00, 0x00, 0x00, 0x18, 0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0x02, 0x00}
+	payload, err := cfg.Protect(header[8:], []byte{0x00, 0x00, 0x00, 0x01}, 1)
+	require.NoError(t, err)
+	name, v, result, err := c.DecodeProtectedSomeIP("", append(header, payload...))
+	require.NoError(t, err)
+	require.Equal(t, "reportWiFiSwitchStatus", name)
+	require.Equal(t, int32(1), v)
+	require.Equal(t, e2e.StatusOK, result.Status)
+
+	// session id 属于 upper header, 修改后 CRC 校验失败
+	header[11] = 0x02
+	_, _, result, err = c.DecodeProtectedSomeIP("", append(header, payload...))
+	require.NoError(t, err)
+	require.Equal(t, e2e.StatusError, result.Status)
+}
+
+func TestS1APDecodeSD(t *testing.T) {
+	c, err := NewConverter("../test/s1_ap_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 4})
+	require.NoError(t, err)
+	message, err := hex.DecodeString("ffff8100" + "00000040" + "00000001" + "01010200" +
+		"c0000000" + "00000020" +
+		"01000010" + "82020001" + "01000003" + "00000001" + // OfferService
+		"06000010" + "82020001" + "01000003" + "00000001" + // SubscribeEventgroup
+		"0000000c" + "00090400" + "c0a83e01" + "00067758") // IPv4 endpoint 192.168.62.1 TCP 30552
+	require.NoError(t, err)
+	name, v, err := c.DecodeSomeIP(message)
+	require.NoError(t, err)
+	require.Equal(t, SDMessageName, name)
+	sd, ok := v.(*someip.SDMessage)
+	require.True(t, ok)
+	require.Len(t, sd.Entries, 2)
+	require.Equal(t, "OfferService", sd.Entries[0].Name)
+	require.Equal(t, "INI_WiFiStation", sd.Entries[0].ServiceName)
+	require.Equal(t, "INI_WiFiStation_TBOX_SomeipPIns_1", sd.Entries[0].InstanceName)
+	require.Equal(t, "192.168.62.1", sd.Entries[0].Options[0].Address)
+	require.Equal(t, 30552, sd.Entries[0].Options[0].Port)
+	require.Equal(t, "SubscribeEventgroup", sd.Entries[1].Name)
+	require.Equal(t, "INI_WiFiStation_1_EventGroup", sd.Entries[1].EventgroupName)
+}
@@ -0,0 +1,43 @@
+package converter
+
+import "fmt"
+
+// extractBits 从 data 中读取 length 位无符号整数. little endian (Intel) 时 startPosition 为最低位,
+// big endian (Motorola) 时 startPosition 为最高位, 按字节内从高到低, 跨字节时进入下一字节的最高位
+func extractBits(data []byte, startPosition, length int, bigEndian bool) (uint64, error) {
+	if length < 1 || length > 64 {
+		return 0, fmt.Errorf("invalid signal length %v", length)
+	}
+	if startPosition < 0 {
+		return 0, fmt.Errorf("invalid start position %v", startPosition)
+	}
+	var value uint64
+	pos := startPosition
+	for i := 0; i < length; i++ {
+		if pos/8 >= len(data) {
+			return 0, fmt.Errorf("bit %v exceeds %v bytes", pos, len(data))
+		}
+		bit := uint64(data[pos/8]>>(pos%8)) & 1
+		if bigEndian {
+			value = value<<1 | bit
+			if pos%8 == 0 {
+				pos += 15
+			} else {
+				pos--
+			}
+		} else {
+			value |= bit << i
+			pos++
+		}
+	}
+	return value, nil
+}
+
+// signExtend 将 length 位的补码扩展为 int64
+func signExtend(v uint64, length int) int64 {
+	if length >= 64 {
+		return int64(v)
+	}
+	shift := 64 - length
+	return int64(v<<shift) >> shift
+}
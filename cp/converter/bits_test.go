@@ -0,0 +1,40 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractBits(t *testing.T) {
+	data := []byte{0x12, 0x34, 0x56}
+	// Intel: bit 4 起 12 位
+	v, err := extractBits(data, 4, 12, false)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x341), v)
+	// Motorola: bit 7 为最高位, 跨越两个字节
+	v, err = extractBits(data, 7, 16, true)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x1234), v)
+	v, err = extractBits(data, 3, 8, true)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x23), v)
+
+	_, err = extractBits(data, 16, 9, false)
+	require.Error(t, err)
+	_, err = extractBits(data, 0, 0, false)
+	require.Error(t, err)
+
+	require.Equal(t, int64(-2), signExtend(0x3FE, 10))
+	require.Equal(t, int64(0x1FE), signExtend(0x1FE, 10))
+	require.Equal(t, int64(-1), signExtend(0xFFFFFFFFFFFFFFFF, 64))
+}
+
+func TestBitField(t *testing.T) {
+	data := []byte{0xAB, 0xCD, 0xEF}
+	require.Equal(t, []byte{0xCD, 0xEF}, bitField(data, 8, 16))
+	// 4 位 freshness 之后为 20 位 MAC
+	require.Equal(t, []byte{0x0A}, bitField(data, 0, 4))
+	require.Equal(t, []byte{0x0B, 0xCD, 0xEF}, bitField(data, 4, 20))
+	require.Equal(t, []byte{}, bitField(data, 24, 0))
+}
@@ -4,10 +4,14 @@ import (
 	"fmt"
 
 	"github.com/beevik/etree"
+	idlAst "github.com/yisaer/idl-parser/ast"
 	"github.com/yisaer/idl-parser/ast/typeref"
 	"github.com/yisaer/idl-parser/converter"
 
+	"github.com/yisaer/arxml-converter/ast"
 	"github.com/yisaer/arxml-converter/cp/parser"
+	"github.com/yisaer/arxml-converter/e2e"
+	"github.com/yisaer/arxml-converter/someip"
 )
 
 type ArxmlCPConverter struct {
@@ -15,10 +19,20 @@ type ArxmlCPConverter struct {
 	config       converter.IDlConverterConfig
 	parser       *parser.Parser
 	idlConverter *converter.IDLConverter
+	// module 为 Parse 后不再变化的 idl module, 避免每次解析都从 parser 复制
+	module      idlAst.Module
+	e2eCheckers *e2e.Checkers
 }
 
 func NewArxmlCPConverterWithDoc(doc *etree.Document, config converter.IDlConverterConfig) (*ArxmlCPConverter, error) {
+	return NewArxmlCPConverterForComponent(doc, "", config)
+}
+
+// NewArxmlCPConverterForComponent 使用 software component (AR 路径或 SHORT-NAME) 的 DATA-TYPE-MAPPING-REFS 引用的
+// DATA-TYPE-MAPPING-SET 解析 application data type, component 为空时使用全部 mapping set 的合并结果
+func NewArxmlCPConverterForComponent(doc *etree.Document, component string, config converter.IDlConverterConfig) (*ArxmlCPConverter, error) {
 	p := parser.NewParserWithDoc(doc)
+	p.SetSoftwareComponent(component)
 	if err := p.Parse(); err != nil {
 		return nil, err
 	}
@@ -29,7 +43,9 @@ func NewArxmlCPConverterWithDoc(doc *etree.Document, config converter.IDlConvert
 	return &ArxmlCPConverter{
 		idlConverter: idlConverter,
 		parser:       p,
+		module:       *p.GetModule(),
 		config:       config,
+		e2eCheckers:  e2e.NewCheckers(),
 	}, nil
 }
 
@@ -48,20 +64,155 @@ func NewArxmlCPConverter(path string, config converter.IDlConverterConfig) (*Arx
 	return &ArxmlCPConverter{
 		idlConverter: idlConverter,
 		parser:       p,
+		module:       *p.GetModule(),
 		path:         path,
 		config:       config,
+		e2eCheckers:  e2e.NewCheckers(),
 	}, nil
 }
 
+// GetDataTypeMappingConflicts 返回不同 DATA-TYPE-MAPPING-SET 对同一 application data type 的冲突映射
+func (c *ArxmlCPConverter) GetDataTypeMappingConflicts() []*parser.DataTypeMappingConflict {
+	return c.parser.GetDataTypeMappingConflicts()
+}
+
 func (c *ArxmlCPConverter) Convert(serviceID uint16, headerID uint32, data []byte) (string, interface{}, error) {
-	key, tr, err := c.parser.FindTypeRefByID(serviceID, headerID)
+	return c.ConvertWithVersion(serviceID, ast.AnyMajorVersion, headerID, data)
+}
+
+// ConvertWithVersion 以 SOME/IP header 中的 interface version 选择 service, E2E 保护的 I-SIGNAL 只去掉 E2E header
+func (c *ArxmlCPConverter) ConvertWithVersion(serviceID uint16, majorVersion int, headerID uint32, data []byte) (string, interface{}, error) {
+	key, got, _, err := c.ConvertProtected("", serviceID, majorVersion, headerID, nil, data)
+	return key, got, err
+}
+
+// ConvertProtected 校验并去掉 E2E header 后解析 payload, upperHeader 为 E2E transformer 之前的 header,
+// 配置了 upper header 而 upperHeader 为 nil 时不校验. source 标识发送端, E2E counter 按 source 分别校验.
+// 未配置 E2E 保护的 I-SIGNAL 返回的 e2e.Result 为 nil
+func (c *ArxmlCPConverter) ConvertProtected(source string, serviceID uint16, majorVersion int, headerID uint32, upperHeader, data []byte) (string, interface{}, *e2e.Result, error) {
+	return c.convertProtected("", source, serviceID, majorVersion, headerID, upperHeader, data)
+}
+
+func (c *ArxmlCPConverter) convertProtected(channel, source string, serviceID uint16, majorVersion int, headerID uint32, upperHeader, data []byte) (string, interface{}, *e2e.Result, error) {
+	r, err := c.parser.Resolve(channel, serviceID, majorVersion, headerID, false)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return c.convertResolved(r, source, upperHeader, data)
+}
+
+// convertResolved 以预先解析的 header id 解析 event payload
+func (c *ArxmlCPConverter) convertResolved(r *parser.Resolution, source string, upperHeader, data []byte) (string, interface{}, *e2e.Result, error) {
+	key, path, tr, err := r.GetDataType()
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
+	}
+	e2eResult, data, err := c.checkE2E(source, r.GetISignalRef(), r.GetE2EConfig(), upperHeader, data)
+	if err != nil {
+		return key, nil, nil, err
+	}
+	if r.RequiresSomeIPDecoder() {
+		got, err := c.newSomeIPDecoder().DecodeByRef(path, data)
+		return key, got, e2eResult, err
 	}
-	got, _, err := c.idlConverter.ParseDataByType(data, tr, *c.parser.GetModule())
+	got, _, err := c.idlConverter.ParseDataByType(data, tr, c.module)
+	return key, got, e2eResult, err
+}
+
+// ConvertMessage 根据 SOME/IP message type 解析 payload, method 的 request 解析 IN/INOUT 参数, response 解析 OUT/INOUT 参数,
+// error 消息按 return code 返回 operation 的 *ast.ApplicationError. event 的解析与 ConvertWithVersion 相同
+func (c *ArxmlCPConverter) ConvertMessage(serviceID uint16, majorVersion int, headerID uint32, messageType someip.MessageType, returnCode uint8, data []byte) (string, interface{}, error) {
+	key, got, _, err := c.ConvertProtectedMessage("", serviceID, majorVersion, headerID, messageType, returnCode, nil, data)
 	return key, got, err
 }
 
+// ConvertProtectedMessage 与 ConvertMessage 相同, 并以 upperHeader 校验 E2E 保护的 I-SIGNAL
+func (c *ArxmlCPConverter) ConvertProtectedMessage(source string, serviceID uint16, majorVersion int, headerID uint32, messageType someip.MessageType, returnCode uint8, upperHeader, data []byte) (string, interface{}, *e2e.Result, error) {
+	return c.ConvertChannelMessage("", source, serviceID, majorVersion, headerID, messageType, returnCode, upperHeader, data)
+}
+
+// ConvertChannelMessage 与 ConvertProtectedMessage 相同, 只在 channel 对应的物理通道中查找 header id.
+// channel 为物理通道的 AR 路径或唯一的 SHORT-NAME, 为空时 header id 需只位于一个物理通道
+func (c *ArxmlCPConverter) ConvertChannelMessage(channel, source string, serviceID uint16, majorVersion int, headerID uint32, messageType someip.MessageType, returnCode uint8, upperHeader, data []byte) (string, interface{}, *e2e.Result, error) {
+	response := messageType.IsResponse() || messageType.IsError()
+	r, err := c.parser.Resolve(channel, serviceID, majorVersion, headerID, response)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	operation, err := r.GetOperation()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if operation == nil {
+		// data element 不区分 request 与 response, 表中 response 项即为 request 方向的解析结果
+		return c.convertResolved(r, source, upperHeader, data)
+	}
+	var args []*ast.Argument
+	switch {
+	case messageType.IsError():
+		applicationError, ok := ast.LookupApplicationError(operation.PossibleErrors, returnCode)
+		if !ok {
+			return operation.ShortName, nil, nil, fmt.Errorf("return code 0x%02x is not a possible error of operation %v", returnCode, operation.ShortName)
+		}
+		return operation.ShortName, applicationError, nil, nil
+	case messageType.IsRequest():
+		args = ast.RequestArguments(operation.Arguments)
+	case messageType.IsResponse():
+		args = ast.ResponseArguments(operation.Arguments)
+	default:
+		return "", nil, nil, fmt.Errorf("unsupported message type 0x%02x for operation %v", uint8(messageType), operation.ShortName)
+	}
+	e2eResult, data, err := c.checkE2E(source, r.GetISignalRef(), r.GetE2EConfig(), upperHeader, data)
+	if err != nil {
+		return operation.ShortName, nil, nil, err
+	}
+	got, err := c.newSomeIPDecoder().DecodeArguments(args, data)
+	return operation.ShortName, got, e2eResult, err
+}
+
+// checkE2E 校验并去掉 I-SIGNAL 的 E2E header, 未配置 E2E 保护时原样返回 data
+func (c *ArxmlCPConverter) checkE2E(source, iSignalRef string, cfg *e2e.Config, upperHeader, data []byte) (*e2e.Result, []byte, error) {
+	if cfg == nil {
+		return nil, data, nil
+	}
+	checker, err := c.e2eCheckers.Get(source, iSignalRef, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, data, err := checker.Check(upperHeader, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("i-signal %v: %v", iSignalRef, err)
+	}
+	return result, data, nil
+}
+
+func (c *ArxmlCPConverter) newSomeIPDecoder() *someip.Decoder {
+	return c.newSomeIPDecoderWithEndian(c.config.IsLittleEndian)
+}
+
+func (c *ArxmlCPConverter) newSomeIPDecoderWithEndian(isLittleEndian bool) *someip.Decoder {
+	return someip.NewDecoder(someip.Config{
+		IsLittleEndian:          isLittleEndian,
+		LengthFieldLength:       c.config.LengthFieldLength,
+		UnionLengthFieldLength:  someip.DefaultUnionLengthFieldLength,
+		UnionTypeSelectorLength: someip.DefaultUnionTypeSelectorLength,
+	}, c.parser.GetTransformer())
+}
+
 func (c *ArxmlCPConverter) GetDataTypeByID(serviceID uint16, headerID uint32) (string, typeref.TypeRef, error) {
 	return c.parser.FindTypeRefByID(serviceID, headerID)
 }
+
+// GetEventGroup 返回 service 的 event group 及其成员 event
+func (c *ArxmlCPConverter) GetEventGroup(serviceID, eventGroupID uint16) (*ast.EventGroup, error) {
+	eg, ok := c.parser.GetEventGroups().Lookup(serviceID, eventGroupID)
+	if !ok {
+		return nil, fmt.Errorf("no event group %v found in service %v", eventGroupID, serviceID)
+	}
+	return eg, nil
+}
+
+// FindEventGroups 返回包含该 event 的 event group
+func (c *ArxmlCPConverter) FindEventGroups(serviceID, eventID uint16) []*ast.EventGroup {
+	return c.parser.GetEventGroups().FindByEvent(serviceID, eventID)
+}
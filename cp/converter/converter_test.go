@@ -1,13 +1,20 @@
 package converter
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/beevik/etree"
 	"github.com/stretchr/testify/require"
 	"github.com/yisaer/idl-parser/converter"
 
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/cp/parser/communication"
+	"github.com/yisaer/arxml-converter/e2e"
+	"github.com/yisaer/arxml-converter/someip"
 	"github.com/yisaer/arxml-converter/util"
 )
 
@@ -99,3 +106,746 @@ func TestGetDataType5(t *testing.T) {
 	require.NoError(t, err)
 	fmt.Println(k, v)
 }
+
+func TestEventGroup(t *testing.T) {
+	c, err := NewArxmlCPConverter("../../test/s1_cp_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+	eg, err := c.GetEventGroup(33282, 1)
+	require.NoError(t, err)
+	require.Equal(t, "EH_INI_WiFiStation_1_INI_WiFiStation_EventGroup_VLAN62_TBOX", eg.ShortName)
+	require.Equal(t, []uint16{0x8001, 0x8002, 0x8003}, eg.EventIDs)
+	// routing group 为 ACTIVATION-UNICAST
+	require.Nil(t, eg.Multicast)
+
+	egs := c.FindEventGroups(33282, 0x8002)
+	require.Len(t, egs, 1)
+	require.Equal(t, uint16(1), egs[0].EventGroupID)
+	// method 不属于任何 event group
+	require.Empty(t, c.FindEventGroups(33282, 0x0005))
+}
+
+func TestConvertWithVersion(t *testing.T) {
+	c, err := NewArxmlCPConverter("../../test/s1_cp_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+	testData := []byte{0x00, 0x00, 0x00, 0x05, 'T', 'e', 's', 't', 0x00}
+	key, v, err := c.ConvertWithVersion(33282, 1, 2181169157, testData)
+	require.NoError(t, err)
+	require.Equal(t, "adt_WiFiApName", key)
+	require.Equal(t, "Test", v)
+	_, _, err = c.ConvertWithVersion(33282, 2, 2181169157, testData)
+	require.Error(t, err)
+}
+
+func TestConvertProtected(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	technologies := doc.FindElement("//TRANSFORMATION-TECHNOLOGYS")
+	require.NotNil(t, technologies)
+	technologies.AddChild(newElement(t, `<TRANSFORMATION-TECHNOLOGY>
+	<SHORT-NAME>E2E_Transformer</SHORT-NAME>
+	<PROTOCOL>E2E</PROTOCOL>
+	<TRANSFORMATION-DESCRIPTIONS>
+		<END-TO-END-TRANSFORMATION-DESCRIPTION>
+			<MAX-DELTA-COUNTER>1</MAX-DELTA-COUNTER>
+			<OFFSET>64</OFFSET>
+			<PROFILE-NAME>PROFILE_06</PROFILE-NAME>
+			<UPPER-HEADER-BITS-TO-SHIFT>64</UPPER-HEADER-BITS-TO-SHIFT>
+		</END-TO-END-TRANSFORMATION-DESCRIPTION>
+	</TRANSFORMATION-DESCRIPTIONS>
+	<TRANSFORMER-CLASS>SAFETY</TRANSFORMER-CLASS>
+</TRANSFORMATION-TECHNOLOGY>`))
+	props := doc.FindElement("//I-SIGNAL[SHORT-NAME='Sig_RR_removeWiFiLoginInfo_call_INI_WiFiStation_1_CDC']/TRANSFORMATION-I-SIGNAL-PROPSS")
+	require.NotNil(t, props)
+	props.AddChild(newElement(t, `<END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS>
+	<END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS-VARIANTS>
+		<END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS-CONDITIONAL>
+			<TRANSFORMER-REF DEST="TRANSFORMATION-TECHNOLOGY">/Communication/DataTransformation/Transformer_Configuration/E2E_Transformer</TRANSFORMER-REF>
+			<DATA-IDS>
+				<DATA-ID>4660</DATA-ID>
+			</DATA-IDS>
+		</END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS-CONDITIONAL>
+	</END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS-VARIANTS>
+</END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS>`))
+	c, err := NewArxmlCPConverterWithDoc(doc, converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+	_, cfg, err := c.parser.FindE2EConfig(2181169157)
+	require.NoError(t, err)
+	require.Equal(t, &e2e.Config{Profile: e2e.Profile06, DataID: 4660, Offset: 8, UpperHeaderLength: 8, MaxDeltaCounter: 1}, cfg)
+
+	upperHeader := []byte{0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0x00, 0x00}
+	data, err := cfg.Protect(upperHeader, []byte{0x00, 0x00, 0x00, 0x05, 'T', 'e', 's', 't', 0x00}, 3)
+	require.NoError(t, err)
+	key, v, result, err := c.ConvertProtected("", 33282, 1, 2181169157, upperHeader, data)
+	require.NoError(t, err)
+	require.Equal(t, "adt_WiFiApName", key)
+	require.Equal(t, "Test", v)
+	require.Equal(t, &e2e.Result{Status: e2e.StatusOK, Profile: e2e.Profile06, Counter: 3, DataID: 4660, CRC: result.CRC}, result)
+
+	// 不同发送端的 counter 分别校验
+	_, _, result, err = c.ConvertProtected("", 33282, 1, 2181169157, upperHeader, data)
+	require.NoError(t, err)
+	require.Equal(t, e2e.StatusRepeated, result.Status)
+	_, _, result, err = c.ConvertProtected("192.168.62.2:30552", 33282, 1, 2181169157, upperHeader, data)
+	require.NoError(t, err)
+	require.Equal(t, e2e.StatusOK, result.Status)
+
+	// 不提供 upper header 时只去掉 E2E header
+	_, v, err = c.Convert(33282, 2181169157, data)
+	require.NoError(t, err)
+	require.Equal(t, "Test", v)
+
+	data[2] ^= 0xFF
+	_, _, result, err = c.ConvertProtected("", 33282, 1, 2181169157, upperHeader, data)
+	require.NoError(t, err)
+	require.Equal(t, e2e.StatusError, result.Status)
+}
+
+func TestConvertServiceVersions(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	// 在另一个 socket address 上提供 major version 2 的 service, 其 method header id 对应另一个 PDU
+	socket := doc.FindElement("//SOCKET-ADDRESS[SHORT-NAME='SoAddr_VLAN62_TBOX_TCP_30552']")
+	require.NotNil(t, socket)
+	socketV2 := socket.Copy()
+	socketV2.RemoveAttr("UUID")
+	socketV2.SelectElement("SHORT-NAME").SetText("SoAddr_VLAN62_TBOX_TCP_30553")
+	psi := socketV2.FindElement("APPLICATION-ENDPOINT/PROVIDED-SERVICE-INSTANCES/PROVIDED-SERVICE-INSTANCE")
+	psi.RemoveChild(psi.SelectElement("EVENT-HANDLERS"))
+	psi.FindElement("SD-SERVER-CONFIG/SERVER-SERVICE-MAJOR-VERSION").SetText("2")
+	socket.Parent().AddChild(socketV2)
+
+	bundle := doc.FindElement("//SOCKET-CONNECTION-BUNDLE[SHORT-NAME='SCB_VLAN62_TBOX_TCP_30552']")
+	require.NotNil(t, bundle)
+	bundleV2 := bundle.Copy()
+	bundleV2.SelectElement("SHORT-NAME").SetText("SCB_VLAN62_TBOX_TCP_30553")
+	bundleV2.RemoveChild(bundleV2.SelectElement("BUNDLED-CONNECTIONS"))
+	pdus := bundleV2.SelectElement("PDUS")
+	for _, identifier := range pdus.SelectElements("SOCKET-CONNECTION-IPDU-IDENTIFIER") {
+		if identifier.SelectElement("HEADER-ID").Text() != "2181201922" {
+			pdus.RemoveChild(identifier)
+			continue
+		}
+		identifier.SelectElement("HEADER-ID").SetText("2181169157")
+	}
+	bundleV2.SelectElement("SERVER-PORT-REF").SetText("/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62/SoAddr_VLAN62_TBOX_TCP_30553")
+	bundle.Parent().AddChild(bundleV2)
+
+	c, err := NewArxmlCPConverterWithDoc(doc, converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+	key, v, err := c.ConvertWithVersion(33282, 1, 2181169157, []byte{0x00, 0x00, 0x00, 0x05, 'T', 'e', 's', 't', 0x00})
+	require.NoError(t, err)
+	require.Equal(t, "adt_WiFiApName", key)
+	require.Equal(t, "Test", v)
+
+	r, err := c.parser.Resolve("", 33282, 2, 2181169157, false)
+	require.NoError(t, err)
+	key, _, tr, err := r.GetDataType()
+	require.NoError(t, err)
+	require.Equal(t, "adt_WiFiConnStatus", key)
+	require.Equal(t, "adt_WiFiConnStatus", tr.TypeName())
+
+	// 不指定版本时无法确定 service
+	_, _, err = c.Convert(33282, 2181169157, []byte{0x00, 0x00, 0x00, 0x05, 'T', 'e', 's', 't', 0x00})
+	require.Error(t, err)
+}
+
+func TestConvertMessage(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	csi := doc.FindElement("//CLIENT-SERVER-INTERFACE[SHORT-NAME='INI_WiFiStation_removeWiFiLoginInfo']")
+	require.NotNil(t, csi)
+	csi.InsertChildAt(csi.SelectElement("OPERATIONS").Index(), newElement(t, `<POSSIBLE-ERRORS>
+	<APPLICATION-ERROR>
+		<SHORT-NAME>E_WiFiBusy</SHORT-NAME>
+		<ERROR-CODE>2</ERROR-CODE>
+	</APPLICATION-ERROR>
+</POSSIBLE-ERRORS>`))
+	operation := csi.FindElement("OPERATIONS/CLIENT-SERVER-OPERATION")
+	operation.SelectElement("ARGUMENTS").AddChild(newElement(t, `<ARGUMENT-DATA-PROTOTYPE>
+	<SHORT-NAME>para2</SHORT-NAME>
+	<TYPE-TREF DEST="APPLICATION-PRIMITIVE-DATA-TYPE">/DataTypes/ApplicationDataType/adt_INI_ReturnCode</TYPE-TREF>
+	<DIRECTION>INOUT</DIRECTION>
+</ARGUMENT-DATA-PROTOTYPE>`))
+	operation.AddChild(newElement(t, `<POSSIBLE-ERROR-REFS>
+	<POSSIBLE-ERROR-REF DEST="APPLICATION-ERROR">/SoftwareTypes/Interfaces/INI_WiFiStation_removeWiFiLoginInfo/E_WiFiBusy</POSSIBLE-ERROR-REF>
+</POSSIBLE-ERROR-REFS>`))
+	c, err := NewArxmlCPConverterWithDoc(doc, converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+
+	request := []byte{0x00, 0x00, 0x00, 0x05, 'T', 'e', 's', 't', 0x00, 0x00, 0x07}
+	key, v, err := c.ConvertMessage(33282, 1, 2181169157, someip.MessageTypeRequest, 0, request)
+	require.NoError(t, err)
+	require.Equal(t, "removeWiFiLoginInfo", key)
+	require.Equal(t, map[string]interface{}{"para0": "Test", "para2": uint16(7)}, v)
+
+	// response 经 return PDU 解析 OUT/INOUT 参数
+	key, v, err = c.ConvertMessage(33282, 1, 2181169157, someip.MessageTypeResponse, 0, []byte{0x00, 0x01, 0x00, 0x08})
+	require.NoError(t, err)
+	require.Equal(t, "removeWiFiLoginInfo", key)
+	require.Equal(t, map[string]interface{}{"para1": uint16(1), "para2": uint16(8)}, v)
+
+	key, v, err = c.ConvertMessage(33282, 1, 2181169157, someip.MessageTypeError, 2, nil)
+	require.NoError(t, err)
+	require.Equal(t, "removeWiFiLoginInfo", key)
+	require.Equal(t, &ast.ApplicationError{ShortName: "E_WiFiBusy", ErrorCode: 2}, v)
+	_, _, err = c.ConvertMessage(33282, 1, 2181169157, someip.MessageTypeError, 3, nil)
+	require.Error(t, err)
+
+	// Convert 仍只解析第一个 IN 参数
+	key, v, err = c.Convert(33282, 2181169157, request)
+	require.NoError(t, err)
+	require.Equal(t, "adt_WiFiApName", key)
+	require.Equal(t, "Test", v)
+}
+
+func TestConvertChannelMessage(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	// 另一个 Clusters package 中的 ETHERNET-CLUSTER 使用相同的 header id
+	cluster := doc.FindElement("//ETHERNET-CLUSTER[SHORT-NAME='EthernetCluster']")
+	require.NotNil(t, cluster)
+	clusters := cluster.Parent().Parent()
+	other := clusters.Copy()
+	otherCluster := other.FindElement("ELEMENTS/ETHERNET-CLUSTER")
+	otherCluster.SelectElement("SHORT-NAME").SetText("EthernetCluster2")
+	otherCluster.FindElement(".//ETHERNET-PHYSICAL-CHANNEL/SHORT-NAME").SetText("ChannelCommunication_VLAN63")
+	for _, e := range other.SelectElement("ELEMENTS").ChildElements() {
+		if e != otherCluster {
+			other.SelectElement("ELEMENTS").RemoveChild(e)
+		}
+	}
+	clusters.Parent().AddChild(other)
+	// 同一 bundle 中的第二个 SOCKET-CONNECTION
+	bundled := doc.FindElement("//SOCKET-CONNECTION-BUNDLE[SHORT-NAME='SCB_VLAN62_TBOX_TCP_30552']/BUNDLED-CONNECTIONS")
+	require.NotNil(t, bundled)
+	bundled.AddChild(newElement(t, `<SOCKET-CONNECTION>
+	<CLIENT-PORT-REF DEST="SOCKET-ADDRESS">/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62/SoAddr_VLAN62_CDC_TCP_31452</CLIENT-PORT-REF>
+	<PDUS>
+		<SOCKET-CONNECTION-IPDU-IDENTIFIER>
+			<HEADER-ID>2181169158</HEADER-ID>
+			<PDU-TRIGGERING-REF DEST="PDU-TRIGGERING">/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62/PduTrigger_Pdu_RR_removeWiFiLoginInfo_call_INI_WiFiStation_1_CDC_VLAN62</PDU-TRIGGERING-REF>
+		</SOCKET-CONNECTION-IPDU-IDENTIFIER>
+	</PDUS>
+</SOCKET-CONNECTION>`))
+	c, err := NewArxmlCPConverterWithDoc(doc, converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+
+	request := []byte{0x00, 0x00, 0x00, 0x05, 'T', 'e', 's', 't', 0x00}
+	_, _, err = c.ConvertMessage(33282, 1, 2181169157, someip.MessageTypeRequest, 0, request)
+	require.ErrorContains(t, err, "ambiguous")
+	for _, channel := range []string{"ChannelCommunication_VLAN63", "/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62"} {
+		key, v, _, err := c.ConvertChannelMessage(channel, "", 33282, 1, 2181169157, someip.MessageTypeRequest, 0, nil, request)
+		require.NoError(t, err)
+		require.Equal(t, "removeWiFiLoginInfo", key)
+		require.Equal(t, map[string]interface{}{"para0": "Test"}, v)
+	}
+	// 只位于一个物理通道的 header id 仍可直接查找
+	key, v, err := c.ConvertMessage(33282, 1, 2181169158, someip.MessageTypeRequest, 0, request)
+	require.NoError(t, err)
+	require.Equal(t, "removeWiFiLoginInfo", key)
+	require.Equal(t, map[string]interface{}{"para0": "Test"}, v)
+	_, _, _, err = c.ConvertChannelMessage("ChannelCommunication_VLAN63", "", 33282, 1, 2181169158, someip.MessageTypeRequest, 0, nil, request)
+	require.Error(t, err)
+	_, _, _, err = c.ConvertChannelMessage("ChannelCommunication_VLAN64", "", 33282, 1, 2181169157, someip.MessageTypeRequest, 0, nil, request)
+	require.Error(t, err)
+}
+
+func TestTPConfig(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	doc.FindElement("//AUTOSAR/AR-PACKAGES").AddChild(newElement(t, `<AR-PACKAGE>
+	<SHORT-NAME>TpConfig</SHORT-NAME>
+	<ELEMENTS>
+		<SOMEIP-TP-CONFIG>
+			<SHORT-NAME>SomeipTpConfig_VLAN62</SHORT-NAME>
+			<TP-CHANNELS>
+				<SOMEIP-TP-CHANNEL>
+					<SHORT-NAME>SomeipTpChannel</SHORT-NAME>
+					<RX-TIMEOUT-TIME>0.5</RX-TIMEOUT-TIME>
+				</SOMEIP-TP-CHANNEL>
+			</TP-CHANNELS>
+			<TP-CONNECTIONS>
+				<SOMEIP-TP-CONNECTION>
+					<TP-CHANNEL-REF DEST="SOMEIP-TP-CHANNEL">/TpConfig/SomeipTpConfig_VLAN62/SomeipTpChannel</TP-CHANNEL-REF>
+					<TP-SDU-REF DEST="PDU-TRIGGERING">/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62/PduTrigger_Pdu_RR_removeWiFiLoginInfo_call_INI_WiFiStation_1_CDC_VLAN62</TP-SDU-REF>
+					<TRANSPORT-PDU-REF DEST="PDU-TRIGGERING">/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62/PduTrigger_Pdu_RR_removeWiFiLoginInfo_call_INI_WiFiStation_1_CDC_VLAN62</TRANSPORT-PDU-REF>
+				</SOMEIP-TP-CONNECTION>
+			</TP-CONNECTIONS>
+		</SOMEIP-TP-CONFIG>
+	</ELEMENTS>
+</AR-PACKAGE>`))
+	c, err := NewArxmlCPConverterWithDoc(doc, converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+	provider := tpConfigProvider{p: c.parser}
+	cfg, ok, err := provider.TPConfig(0x8202, 0x0005, 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, &someip.TPConfig{MaxSegmentLength: 184, MaxMessageLength: 204, Timeout: 500 * time.Millisecond}, cfg)
+	_, ok, err = provider.TPConfig(0x8202, 0x8001, 1)
+	require.NoError(t, err)
+	require.False(t, ok)
+	// 未知的 header id 与物理通道返回错误
+	_, _, err = provider.TPConfig(0x8202, 0x0009, 1)
+	require.Error(t, err)
+	_, _, err = tpConfigProvider{p: c.parser, channel: "ChannelCommunication_Unknown"}.TPConfig(0x8202, 0x0005, 1)
+	require.Error(t, err)
+	cfg, ok, err = tpConfigProvider{p: c.parser, channel: "ChannelCommunication_VLAN62"}.TPConfig(0x8202, 0x0005, 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 184, cfg.MaxSegmentLength)
+	require.NotNil(t, c.NewReassembler(someip.ReassemblerConfig{}))
+
+	// LENGTH 不大于 SOME/IP 与 TP header 长度时返回错误
+	doc.FindElement("//I-SIGNAL-I-PDU[SHORT-NAME='Pdu_RR_removeWiFiLoginInfo_call_INI_WiFiStation_1_CDC']/LENGTH").SetText("20")
+	c, err = NewArxmlCPConverterWithDoc(doc, converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+	_, _, err = tpConfigProvider{p: c.parser}.TPConfig(0x8202, 0x0005, 1)
+	require.ErrorContains(t, err, "is not larger than SOME/IP and TP header length")
+}
+
+func TestConvertSignals(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	returnSignal := doc.FindElement("//I-SIGNAL[SHORT-NAME='Sig_RR_removeWiFiLoginInfo_return_INI_WiFiStation_1_CDC']")
+	require.NotNil(t, returnSignal)
+	returnSignal.SelectElement("LENGTH").SetText("16")
+	returnSignal.Parent().AddChild(newElement(t, `<I-SIGNAL-GROUP>
+	<SHORT-NAME>SigGroup_removeWiFiLoginInfo_return</SHORT-NAME>
+	<I-SIGNAL-REFS>
+		<I-SIGNAL-REF DEST="I-SIGNAL">/Communication/Signals/Sig_RR_removeWiFiLoginInfo_return_INI_WiFiStation_1_CDC</I-SIGNAL-REF>
+	</I-SIGNAL-REFS>
+</I-SIGNAL-GROUP>`))
+	mappings := doc.FindElement("//I-SIGNAL-I-PDU[SHORT-NAME='Pdu_RR_removeWiFiLoginInfo_return_INI_WiFiStation_1_CDC']/I-SIGNAL-TO-PDU-MAPPINGS")
+	require.NotNil(t, mappings)
+	// 按 START-POSITION 排列, 与定义顺序无关
+	mappings.InsertChildAt(0, newElement(t, `<I-SIGNAL-TO-I-PDU-MAPPING>
+	<SHORT-NAME>SigPduMapping_SwitchStatus</SHORT-NAME>
+	<I-SIGNAL-REF DEST="I-SIGNAL">/Communication/Signals/Sig_Event_reportWiFiSwitchStatus_INI_WiFiStation_1</I-SIGNAL-REF>
+	<PACKING-BYTE-ORDER>MOST-SIGNIFICANT-BYTE-LAST</PACKING-BYTE-ORDER>
+	<START-POSITION>24</START-POSITION>
+	<UPDATE-INDICATION-BIT-POSITION>16</UPDATE-INDICATION-BIT-POSITION>
+</I-SIGNAL-TO-I-PDU-MAPPING>`))
+	mappings.AddChild(newElement(t, `<I-SIGNAL-TO-I-PDU-MAPPING>
+	<SHORT-NAME>SigPduMapping_Group</SHORT-NAME>
+	<I-SIGNAL-GROUP-REF DEST="I-SIGNAL-GROUP">/Communication/Signals/SigGroup_removeWiFiLoginInfo_return</I-SIGNAL-GROUP-REF>
+	<START-POSITION>0</START-POSITION>
+</I-SIGNAL-TO-I-PDU-MAPPING>`))
+	c, err := NewArxmlCPConverterWithDoc(doc, converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+
+	name, signals, err := c.ConvertSignals(33282, 1, 2181169157, someip.MessageTypeResponse, []byte{0x00, 0x01, 0x01, 0x02, 0x00, 0x00, 0x00})
+	require.NoError(t, err)
+	require.Equal(t, "Pdu_RR_removeWiFiLoginInfo_return_INI_WiFiStation_1_CDC", name)
+	require.Len(t, signals, 2)
+	require.Equal(t, &Signal{Name: "Sig_RR_removeWiFiLoginInfo_return_INI_WiFiStation_1_CDC", DataType: "removeWiFiLoginInfo", Value: map[string]interface{}{"para1": uint16(1)}, Group: "SigGroup_removeWiFiLoginInfo_return"}, signals[0])
+	// MOST-SIGNIFICANT-BYTE-LAST 的 signal 按小端序解析
+	updated := true
+	require.Equal(t, &Signal{Name: "Sig_Event_reportWiFiSwitchStatus_INI_WiFiStation_1", DataType: "adt_WiFiSwitchStatus", Value: int32(2), Updated: &updated}, signals[1])
+
+	// PDU 中多个 I-SIGNAL 时仍以 RETURN-SIGNAL 解析 response
+	key, v, err := c.ConvertMessage(33282, 1, 2181169157, someip.MessageTypeResponse, 0, []byte{0x00, 0x01})
+	require.NoError(t, err)
+	require.Equal(t, "removeWiFiLoginInfo", key)
+	require.Equal(t, map[string]interface{}{"para1": uint16(1)}, v)
+}
+
+// addCanStatusPDU 加入按位布局的 Pdu_Can_Status 及其 signal, 返回 PDUs package 的 ELEMENTS
+func addCanStatusPDU(t *testing.T, doc *etree.Document) *etree.Element {
+	compuMethods := doc.FindElement("//AR-PACKAGE[SHORT-NAME='CompuMethods']/ELEMENTS")
+	require.NotNil(t, compuMethods)
+	compuMethods.AddChild(newElement(t, `<COMPU-METHOD>
+	<SHORT-NAME>CM_Speed</SHORT-NAME>
+	<CATEGORY>LINEAR</CATEGORY>
+	<COMPU-INTERNAL-TO-PHYS>
+		<COMPU-SCALES>
+			<COMPU-SCALE>
+				<COMPU-RATIONAL-COEFFS>
+					<COMPU-NUMERATOR><V>-10</V><V>0.5</V></COMPU-NUMERATOR>
+					<COMPU-DENOMINATOR><V>1</V></COMPU-DENOMINATOR>
+				</COMPU-RATIONAL-COEFFS>
+			</COMPU-SCALE>
+		</COMPU-SCALES>
+	</COMPU-INTERNAL-TO-PHYS>
+</COMPU-METHOD>`))
+	signals := doc.FindElement("//AR-PACKAGE[SHORT-NAME='Signals']/ELEMENTS")
+	require.NotNil(t, signals)
+	for _, raw := range []string{`<I-SIGNAL>
+	<SHORT-NAME>Sig_Can_Speed</SHORT-NAME>
+	<LENGTH>12</LENGTH>
+	<NETWORK-REPRESENTATION-PROPS><SW-DATA-DEF-PROPS-VARIANTS><SW-DATA-DEF-PROPS-CONDITIONAL>
+		<BASE-TYPE-REF DEST="SW-BASE-TYPE">/DataTypes/BaseTypes/uint16</BASE-TYPE-REF>
+		<COMPU-METHOD-REF DEST="COMPU-METHOD">/DataTypes/CompuMethods/CM_Speed</COMPU-METHOD-REF>
+	</SW-DATA-DEF-PROPS-CONDITIONAL></SW-DATA-DEF-PROPS-VARIANTS></NETWORK-REPRESENTATION-PROPS>
+</I-SIGNAL>`, `<I-SIGNAL>
+	<SHORT-NAME>Sig_Can_Temperature</SHORT-NAME>
+	<LENGTH>10</LENGTH>
+	<NETWORK-REPRESENTATION-PROPS><SW-DATA-DEF-PROPS-VARIANTS><SW-DATA-DEF-PROPS-CONDITIONAL>
+		<BASE-TYPE-REF DEST="SW-BASE-TYPE">/DataTypes/BaseTypes/sint32</BASE-TYPE-REF>
+	</SW-DATA-DEF-PROPS-CONDITIONAL></SW-DATA-DEF-PROPS-VARIANTS></NETWORK-REPRESENTATION-PROPS>
+</I-SIGNAL>`, `<I-SIGNAL>
+	<SHORT-NAME>Sig_Can_Switch</SHORT-NAME>
+	<LENGTH>2</LENGTH>
+	<NETWORK-REPRESENTATION-PROPS><SW-DATA-DEF-PROPS-VARIANTS><SW-DATA-DEF-PROPS-CONDITIONAL>
+		<BASE-TYPE-REF DEST="SW-BASE-TYPE">/DataTypes/BaseTypes/uint8</BASE-TYPE-REF>
+		<COMPU-METHOD-REF DEST="COMPU-METHOD">/DataTypes/CompuMethods/CM_WiFiSwitchStatus_Enum</COMPU-METHOD-REF>
+	</SW-DATA-DEF-PROPS-CONDITIONAL></SW-DATA-DEF-PROPS-VARIANTS></NETWORK-REPRESENTATION-PROPS>
+</I-SIGNAL>`} {
+		signals.AddChild(newElement(t, raw))
+	}
+	pdus := doc.FindElement("//AR-PACKAGE[SHORT-NAME='PDUs']/ELEMENTS")
+	require.NotNil(t, pdus)
+	pdus.AddChild(newElement(t, `<I-SIGNAL-I-PDU>
+	<SHORT-NAME>Pdu_Can_Status</SHORT-NAME>
+	<LENGTH>7</LENGTH>
+	<I-SIGNAL-TO-PDU-MAPPINGS>
+		<I-SIGNAL-TO-I-PDU-MAPPING>
+			<SHORT-NAME>Mapping_Speed</SHORT-NAME>
+			<I-SIGNAL-REF DEST="I-SIGNAL">/Communication/Signals/Sig_Can_Speed</I-SIGNAL-REF>
+			<PACKING-BYTE-ORDER>MOST-SIGNIFICANT-BYTE-LAST</PACKING-BYTE-ORDER>
+			<START-POSITION>4</START-POSITION>
+		</I-SIGNAL-TO-I-PDU-MAPPING>
+		<I-SIGNAL-TO-I-PDU-MAPPING>
+			<SHORT-NAME>Mapping_Temperature</SHORT-NAME>
+			<I-SIGNAL-REF DEST="I-SIGNAL">/Communication/Signals/Sig_Can_Temperature</I-SIGNAL-REF>
+			<PACKING-BYTE-ORDER>MOST-SIGNIFICANT-BYTE-FIRST</PACKING-BYTE-ORDER>
+			<START-POSITION>23</START-POSITION>
+		</I-SIGNAL-TO-I-PDU-MAPPING>
+		<I-SIGNAL-TO-I-PDU-MAPPING>
+			<SHORT-NAME>Mapping_Switch</SHORT-NAME>
+			<I-SIGNAL-REF DEST="I-SIGNAL">/Communication/Signals/Sig_Can_Switch</I-SIGNAL-REF>
+			<PACKING-BYTE-ORDER>MOST-SIGNIFICANT-BYTE-LAST</PACKING-BYTE-ORDER>
+			<START-POSITION>48</START-POSITION>
+			<UPDATE-INDICATION-BIT-POSITION>55</UPDATE-INDICATION-BIT-POSITION>
+		</I-SIGNAL-TO-I-PDU-MAPPING>
+	</I-SIGNAL-TO-PDU-MAPPINGS>
+</I-SIGNAL-I-PDU>`))
+	return pdus
+}
+
+func TestDecodeFrame(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	pdus := addCanStatusPDU(t, doc)
+	pdus.AddChild(newElement(t, `<CAN-FRAME>
+	<SHORT-NAME>Frame_Can_Status</SHORT-NAME>
+	<FRAME-LENGTH>8</FRAME-LENGTH>
+	<PDU-TO-FRAME-MAPPINGS>
+		<PDU-TO-FRAME-MAPPING>
+			<SHORT-NAME>Pdu_Can_Status_Mapping</SHORT-NAME>
+			<PACKING-BYTE-ORDER>MOST-SIGNIFICANT-BYTE-LAST</PACKING-BYTE-ORDER>
+			<PDU-REF DEST="I-SIGNAL-I-PDU">/Communication/PDUs/Pdu_Can_Status</PDU-REF>
+			<START-POSITION>8</START-POSITION>
+		</PDU-TO-FRAME-MAPPING>
+	</PDU-TO-FRAME-MAPPINGS>
+</CAN-FRAME>`))
+	clusters := doc.FindElement("//AR-PACKAGE[SHORT-NAME='Clusters']/ELEMENTS")
+	require.NotNil(t, clusters)
+	clusters.AddChild(newElement(t, `<CAN-CLUSTER>
+	<SHORT-NAME>CanCluster</SHORT-NAME>
+	<CAN-CLUSTER-VARIANTS><CAN-CLUSTER-CONDITIONAL>
+		<PHYSICAL-CHANNELS>
+			<CAN-PHYSICAL-CHANNEL>
+				<SHORT-NAME>CanChannel_Body</SHORT-NAME>
+				<FRAME-TRIGGERINGS>
+					<CAN-FRAME-TRIGGERING>
+						<SHORT-NAME>FT_Can_Status</SHORT-NAME>
+						<FRAME-REF DEST="CAN-FRAME">/Communication/PDUs/Frame_Can_Status</FRAME-REF>
+						<CAN-ADDRESSING-MODE>STANDARD</CAN-ADDRESSING-MODE>
+						<IDENTIFIER>291</IDENTIFIER>
+					</CAN-FRAME-TRIGGERING>
+				</FRAME-TRIGGERINGS>
+			</CAN-PHYSICAL-CHANNEL>
+		</PHYSICAL-CHANNELS>
+	</CAN-CLUSTER-CONDITIONAL></CAN-CLUSTER-VARIANTS>
+</CAN-CLUSTER>`))
+	c, err := NewArxmlCPConverterWithDoc(doc, converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+
+	// PDU 位于 frame 的第 1 字节: speed 为 bit 4-15 的 0x0AB, temperature 自 bit 23 起为 Motorola 的 0x3FE, switch 为 1
+	data := []byte{0xFF, 0xB0, 0x0A, 0xFF, 0x80, 0x00, 0x00, 0x81}
+	name, decoded, err := c.DecodeFrame("CanChannel_Body", 0x123, data)
+	require.NoError(t, err)
+	require.Equal(t, "FT_Can_Status", name)
+	require.Len(t, decoded, 3)
+	require.Equal(t, &Signal{Name: "Sig_Can_Speed", DataType: "uint16", Value: 0.5*0xAB - 10, Raw: uint64(0xAB), PDU: "Pdu_Can_Status"}, decoded[0])
+	require.Equal(t, &Signal{Name: "Sig_Can_Temperature", DataType: "sint32", Value: int64(-2), PDU: "Pdu_Can_Status"}, decoded[1])
+	updated := true
+	require.Equal(t, &Signal{Name: "Sig_Can_Switch", DataType: "uint8", Value: "INI_WIFI_OPEN", Raw: uint64(1), PDU: "Pdu_Can_Status", Updated: &updated}, decoded[2])
+
+	// SocketCAN 的 EFF 标志位与 channel 的 AR 路径
+	name, _, err = c.DecodeFrame("/Topology/Clusters/CanCluster/CanChannel_Body", 0x80000123, data)
+	require.NoError(t, err)
+	require.Equal(t, "FT_Can_Status", name)
+	_, _, err = c.DecodeFrame("CanChannel_Body", 0x124, data)
+	require.Error(t, err)
+	_, _, err = c.DecodeFrame("CanChannel_Body", 0x123, data[:3])
+	require.Error(t, err)
+}
+
+func TestDecodePDU(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	pdus := addCanStatusPDU(t, doc)
+	pdus.FindElement("I-SIGNAL-I-PDU[SHORT-NAME='Pdu_Can_Status']").AddChild(newElement(t, `<CONTAINED-I-PDU-PROPS>
+	<HEADER-ID-LONG-HEADER>16</HEADER-ID-LONG-HEADER>
+	<HEADER-ID-SHORT-HEADER>16</HEADER-ID-SHORT-HEADER>
+</CONTAINED-I-PDU-PROPS>`))
+	partPDU := func(name, signal, byteOrder string, startPosition int) string {
+		return fmt.Sprintf(`<I-SIGNAL-I-PDU>
+	<SHORT-NAME>%v</SHORT-NAME>
+	<LENGTH>3</LENGTH>
+	<I-SIGNAL-TO-PDU-MAPPINGS>
+		<I-SIGNAL-TO-I-PDU-MAPPING>
+			<SHORT-NAME>Mapping_%v</SHORT-NAME>
+			<I-SIGNAL-REF DEST="I-SIGNAL">/Communication/Signals/%v</I-SIGNAL-REF>
+			<PACKING-BYTE-ORDER>%v</PACKING-BYTE-ORDER>
+			<START-POSITION>%v</START-POSITION>
+		</I-SIGNAL-TO-I-PDU-MAPPING>
+	</I-SIGNAL-TO-PDU-MAPPINGS>
+</I-SIGNAL-I-PDU>`, name, signal, signal, byteOrder, startPosition)
+	}
+	for _, raw := range []string{
+		partPDU("Pdu_Mux_Static", "Sig_Can_Switch", "MOST-SIGNIFICANT-BYTE-LAST", 4),
+		partPDU("Pdu_Mux_Speed", "Sig_Can_Speed", "MOST-SIGNIFICANT-BYTE-LAST", 8),
+		partPDU("Pdu_Mux_Temperature", "Sig_Can_Temperature", "MOST-SIGNIFICANT-BYTE-FIRST", 15),
+		`<MULTIPLEXED-I-PDU>
+	<SHORT-NAME>Pdu_Mux</SHORT-NAME>
+	<LENGTH>3</LENGTH>
+	<CONTAINED-I-PDU-PROPS>
+		<HEADER-ID-SHORT-HEADER>32</HEADER-ID-SHORT-HEADER>
+	</CONTAINED-I-PDU-PROPS>
+	<DYNAMIC-PARTS>
+		<DYNAMIC-PART>
+			<DYNAMIC-PART-ALTERNATIVES>
+				<DYNAMIC-PART-ALTERNATIVE>
+					<I-PDU-REF DEST="I-SIGNAL-I-PDU">/Communication/PDUs/Pdu_Mux_Speed</I-PDU-REF>
+					<INITIAL-DYNAMIC-PART>true</INITIAL-DYNAMIC-PART>
+					<SELECTOR-FIELD-CODE>1</SELECTOR-FIELD-CODE>
+				</DYNAMIC-PART-ALTERNATIVE>
+				<DYNAMIC-PART-ALTERNATIVE>
+					<I-PDU-REF DEST="I-SIGNAL-I-PDU">/Communication/PDUs/Pdu_Mux_Temperature</I-PDU-REF>
+					<SELECTOR-FIELD-CODE>2</SELECTOR-FIELD-CODE>
+				</DYNAMIC-PART-ALTERNATIVE>
+			</DYNAMIC-PART-ALTERNATIVES>
+		</DYNAMIC-PART>
+	</DYNAMIC-PARTS>
+	<SELECTOR-FIELD-BYTE-ORDER>MOST-SIGNIFICANT-BYTE-LAST</SELECTOR-FIELD-BYTE-ORDER>
+	<SELECTOR-FIELD-LENGTH>4</SELECTOR-FIELD-LENGTH>
+	<SELECTOR-FIELD-START-POSITION>0</SELECTOR-FIELD-START-POSITION>
+	<STATIC-PARTS>
+		<STATIC-PART>
+			<I-PDU-REF DEST="I-SIGNAL-I-PDU">/Communication/PDUs/Pdu_Mux_Static</I-PDU-REF>
+		</STATIC-PART>
+	</STATIC-PARTS>
+</MULTIPLEXED-I-PDU>`,
+		`<CONTAINER-I-PDU>
+	<SHORT-NAME>Pdu_Container</SHORT-NAME>
+	<LENGTH>64</LENGTH>
+	<CONTAINED-PDU-TRIGGERING-REFS>
+		<CONTAINED-PDU-TRIGGERING-REF DEST="PDU-TRIGGERING">/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62/PduTrigger_Pdu_Can_Status</CONTAINED-PDU-TRIGGERING-REF>
+		<CONTAINED-PDU-TRIGGERING-REF DEST="PDU-TRIGGERING">/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62/PduTrigger_Pdu_Mux</CONTAINED-PDU-TRIGGERING-REF>
+	</CONTAINED-PDU-TRIGGERING-REFS>
+	<HEADER-TYPE>SHORT-HEADER</HEADER-TYPE>
+</CONTAINER-I-PDU>`,
+		`<CONTAINER-I-PDU>
+	<SHORT-NAME>Pdu_Container_LE</SHORT-NAME>
+	<LENGTH>64</LENGTH>
+	<CONTAINED-PDU-TRIGGERING-REFS>
+		<CONTAINED-PDU-TRIGGERING-REF DEST="PDU-TRIGGERING">/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62/PduTrigger_Pdu_Can_Status</CONTAINED-PDU-TRIGGERING-REF>
+	</CONTAINED-PDU-TRIGGERING-REFS>
+	<CONTAINER-I-PDU-HEADER-BYTE-ORDER>MOST-SIGNIFICANT-BYTE-LAST</CONTAINER-I-PDU-HEADER-BYTE-ORDER>
+	<HEADER-TYPE>LONG-HEADER</HEADER-TYPE>
+</CONTAINER-I-PDU>`,
+		`<CONTAINER-I-PDU>
+	<SHORT-NAME>Pdu_Container_Short_LE</SHORT-NAME>
+	<LENGTH>64</LENGTH>
+	<CONTAINED-PDU-TRIGGERING-REFS>
+		<CONTAINED-PDU-TRIGGERING-REF DEST="PDU-TRIGGERING">/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62/PduTrigger_Pdu_Can_Status</CONTAINED-PDU-TRIGGERING-REF>
+	</CONTAINED-PDU-TRIGGERING-REFS>
+	<CONTAINER-I-PDU-HEADER-BYTE-ORDER>MOST-SIGNIFICANT-BYTE-LAST</CONTAINER-I-PDU-HEADER-BYTE-ORDER>
+	<HEADER-TYPE>SHORT-HEADER</HEADER-TYPE>
+</CONTAINER-I-PDU>`,
+	} {
+		pdus.AddChild(newElement(t, raw))
+	}
+	triggerings := doc.FindElement("//ETHERNET-PHYSICAL-CHANNEL[SHORT-NAME='ChannelCommunication_VLAN62']/PDU-TRIGGERINGS")
+	require.NotNil(t, triggerings)
+	for pdu, dest := range map[string]string{"Pdu_Can_Status": "I-SIGNAL-I-PDU", "Pdu_Mux": "MULTIPLEXED-I-PDU"} {
+		triggerings.AddChild(newElement(t, fmt.Sprintf(`<PDU-TRIGGERING>
+	<SHORT-NAME>PduTrigger_%v</SHORT-NAME>
+	<I-PDU-REF DEST="%v">/Communication/PDUs/%v</I-PDU-REF>
+</PDU-TRIGGERING>`, pdu, dest, pdu)))
+	}
+	c, err := NewArxmlCPConverterWithDoc(doc, converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+
+	data := []byte{
+		0x00, 0x00, 0x10, 0x07, 0xB0, 0x0A, 0xFF, 0x80, 0x00, 0x00, 0x81, // Pdu_Can_Status
+		0x00, 0x00, 0x99, 0x01, 0xFF, // 未配置的 header id 被跳过
+		0x00, 0x00, 0x20, 0x03, 0x11, 0xAB, 0x00, // Pdu_Mux, selector 1, switch 1
+		0x00, 0x00, 0x00, 0x00, // 填充
+	}
+	signals, err := c.DecodePDU("/Communication/PDUs/Pdu_Container", data)
+	require.NoError(t, err)
+	require.Len(t, signals, 5)
+	require.Equal(t, "Pdu_Can_Status", signals[0].PDU)
+	require.Equal(t, &Signal{Name: "Sig_Can_Temperature", DataType: "sint32", Value: int64(-2), PDU: "Pdu_Can_Status"}, signals[1])
+	require.Equal(t, &Signal{Name: "Sig_Can_Switch", DataType: "uint8", Value: "INI_WIFI_OPEN", Raw: uint64(1), PDU: "Pdu_Mux_Static"}, signals[3])
+	require.Equal(t, &Signal{Name: "Sig_Can_Speed", DataType: "uint16", Value: 0.5*0xAB - 10, Raw: uint64(0xAB), PDU: "Pdu_Mux_Speed"}, signals[4])
+
+	// little endian 的 long header
+	signals, err = c.DecodePDU("/Communication/PDUs/Pdu_Container_LE", []byte{
+		0x10, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0xB0, 0x0A, 0xFF, 0x80, 0x00, 0x00, 0x81, // Pdu_Can_Status
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // 填充
+	})
+	require.NoError(t, err)
+	require.Len(t, signals, 3)
+	require.Equal(t, &Signal{Name: "Sig_Can_Temperature", DataType: "sint32", Value: int64(-2), PDU: "Pdu_Can_Status"}, signals[1])
+	signals, err = c.DecodePDU("/Communication/PDUs/Pdu_Container_Short_LE", []byte{
+		0x07, 0x10, 0x00, 0x00, 0xB0, 0x0A, 0xFF, 0x80, 0x00, 0x00, 0x81, // Pdu_Can_Status
+	})
+	require.NoError(t, err)
+	require.Len(t, signals, 3)
+
+	// selector 2 选择 Motorola 布局的 temperature
+	signals, err = c.DecodePDU("/Communication/PDUs/Pdu_Mux", []byte{0x02, 0xFF, 0x80})
+	require.NoError(t, err)
+	require.Len(t, signals, 2)
+	require.Equal(t, &Signal{Name: "Sig_Can_Temperature", DataType: "sint32", Value: int64(-2), PDU: "Pdu_Mux_Temperature"}, signals[1])
+	_, err = c.DecodePDU("/Communication/PDUs/Pdu_Mux", []byte{0x03, 0xFF, 0x80})
+	require.Error(t, err)
+	_, err = c.DecodePDU("/Communication/PDUs/Pdu_Container", data[:8])
+	require.Error(t, err)
+}
+
+type secocVerifier struct {
+	mac []byte
+}
+
+func (v *secocVerifier) Verify(secured *communication.SecuredPDU, authentic, freshness, mac []byte) (bool, error) {
+	if secured.DataID != 0x1234 || len(authentic) != 7 || len(freshness) != 1 {
+		return false, fmt.Errorf("unexpected secured pdu %v", secured.ShortName)
+	}
+	return bytes.Equal(v.mac, mac), nil
+}
+
+func TestDecodeSecuredPDU(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	pdus := addCanStatusPDU(t, doc)
+	pdus.AddChild(newElement(t, `<SECURE-COMMUNICATION-PROPS-SET>
+	<SHORT-NAME>SecOCProps</SHORT-NAME>
+	<FRESHNESS-PROPSS>
+		<SECURE-COMMUNICATION-FRESHNESS-PROPS>
+			<SHORT-NAME>FV_8</SHORT-NAME>
+			<FRESHNESS-VALUE-LENGTH>32</FRESHNESS-VALUE-LENGTH>
+			<FRESHNESS-VALUE-TX-LENGTH>8</FRESHNESS-VALUE-TX-LENGTH>
+		</SECURE-COMMUNICATION-FRESHNESS-PROPS>
+	</FRESHNESS-PROPSS>
+</SECURE-COMMUNICATION-PROPS-SET>`))
+	pdus.AddChild(newElement(t, `<SECURED-I-PDU>
+	<SHORT-NAME>Pdu_Can_Status_Secured</SHORT-NAME>
+	<LENGTH>16</LENGTH>
+	<FRESHNESS-PROPS-REF DEST="SECURE-COMMUNICATION-FRESHNESS-PROPS">/Communication/PDUs/SecOCProps/FV_8</FRESHNESS-PROPS-REF>
+	<PAYLOAD-REF DEST="PDU-TRIGGERING">/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62/PduTrigger_Pdu_Can_Status</PAYLOAD-REF>
+	<SECURE-COMMUNICATION-PROPS>
+		<AUTH-INFO-TX-LENGTH>24</AUTH-INFO-TX-LENGTH>
+		<DATA-ID>4660</DATA-ID>
+		<FRESHNESS-VALUE-LENGTH>64</FRESHNESS-VALUE-LENGTH>
+		<FRESHNESS-VALUE-TX-LENGTH>64</FRESHNESS-VALUE-TX-LENGTH>
+	</SECURE-COMMUNICATION-PROPS>
+	<USE-SECURED-PDU-HEADER>SECURED-PDU-HEADER-16-BIT</USE-SECURED-PDU-HEADER>
+</SECURED-I-PDU>`))
+	triggerings := doc.FindElement("//ETHERNET-PHYSICAL-CHANNEL[SHORT-NAME='ChannelCommunication_VLAN62']/PDU-TRIGGERINGS")
+	require.NotNil(t, triggerings)
+	triggerings.AddChild(newElement(t, `<PDU-TRIGGERING>
+	<SHORT-NAME>PduTrigger_Pdu_Can_Status</SHORT-NAME>
+	<I-PDU-REF DEST="I-SIGNAL-I-PDU">/Communication/PDUs/Pdu_Can_Status</I-PDU-REF>
+</PDU-TRIGGERING>`))
+	c, err := NewArxmlCPConverterWithDoc(doc, converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+
+	data := []byte{
+		0x00, 0x07, // header
+		0xB0, 0x0A, 0xFF, 0x80, 0x00, 0x00, 0x81, // Pdu_Can_Status
+		0x5A,             // freshness
+		0x01, 0x02, 0x03, // MAC
+		0xFF, 0xFF, 0xFF, 0xFF, // 超出 LENGTH
+	}
+	payload, err := c.DecodeSecuredPDU("/Communication/PDUs/Pdu_Can_Status_Secured", data, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Pdu_Can_Status_Secured", payload.PDU)
+	require.Equal(t, []byte{0x5A}, payload.Freshness)
+	require.Equal(t, []byte{0x01, 0x02, 0x03}, payload.MAC)
+	require.Nil(t, payload.Verified)
+	require.Len(t, payload.Signals, 3)
+	require.Equal(t, &Signal{Name: "Sig_Can_Temperature", DataType: "sint32", Value: int64(-2), PDU: "Pdu_Can_Status"}, payload.Signals[1])
+
+	payload, err = c.DecodeSecuredPDU("/Communication/PDUs/Pdu_Can_Status_Secured", data, &secocVerifier{mac: []byte{0x01, 0x02, 0x03}})
+	require.NoError(t, err)
+	require.True(t, *payload.Verified)
+	payload, err = c.DecodeSecuredPDU("/Communication/PDUs/Pdu_Can_Status_Secured", data, &secocVerifier{mac: []byte{0x01, 0x02, 0x04}})
+	require.NoError(t, err)
+	require.False(t, *payload.Verified)
+
+	// DecodePDU 只解析 authentic PDU
+	signals, err := c.DecodePDU("/Communication/PDUs/Pdu_Can_Status_Secured", data)
+	require.NoError(t, err)
+	require.Equal(t, payload.Signals, signals)
+
+	_, err = c.DecodeSecuredPDU("/Communication/PDUs/Pdu_Can_Status_Secured", data[:5], nil)
+	require.Error(t, err)
+	// header 中的长度超出报文
+	_, err = c.DecodeSecuredPDU("/Communication/PDUs/Pdu_Can_Status_Secured", append([]byte{0x00, 0x09}, data[2:12]...), nil)
+	require.Error(t, err)
+	_, err = c.DecodeSecuredPDU("/Communication/PDUs/Pdu_Can_Status", data, nil)
+	require.Error(t, err)
+}
+
+func newElement(t *testing.T, raw string) *etree.Element {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(raw))
+	return doc.Root()
+}
+
+func TestDecodeSD(t *testing.T) {
+	c, err := NewArxmlCPConverter("../../test/s1_cp_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(t, err)
+	payload, err := hex.DecodeString("80000000" + "00000010" +
+		"07000000" + "82020001" + "01000000" + "00000001" + // SubscribeEventgroupNack
+		"00000000")
+	require.NoError(t, err)
+	sd, err := c.DecodeSD(payload)
+	require.NoError(t, err)
+	require.Len(t, sd.Entries, 1)
+	require.Equal(t, "SubscribeEventgroupNack", sd.Entries[0].Name)
+	require.Equal(t, "PSI_INI_WiFiStation_1_TBOX", sd.Entries[0].ServiceName)
+	require.Equal(t, "PSI_INI_WiFiStation_1_TBOX", sd.Entries[0].InstanceName)
+	require.Equal(t, "EH_INI_WiFiStation_1_INI_WiFiStation_EventGroup_VLAN62_TBOX", sd.Entries[0].EventgroupName)
+}
+
+func BenchmarkConvert(b *testing.B) {
+	c, err := NewArxmlCPConverter("../../test/s1_cp_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(b, err)
+	data := []byte{0x00, 0x00, 0x00, 0x05, 'T', 'e', 's', 't', 0x00}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := c.Convert(33282, 2181169157, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConvertMessage(b *testing.B) {
+	c, err := NewArxmlCPConverter("../../test/s1_cp_test.xml", converter.IDlConverterConfig{IsLittleEndian: false, LengthFieldLength: 4, PaddingLength: 1})
+	require.NoError(b, err)
+	data := []byte{0x00, 0x00, 0x00, 0x05, 'T', 'e', 's', 't', 0x00}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := c.ConvertMessage(33282, 1, 2181169157, someip.MessageTypeRequest, 0, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
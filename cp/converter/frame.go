@@ -0,0 +1,201 @@
+package converter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/cp/parser/communication"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+// canIDMask 去掉 SocketCAN 中 EFF/RTR/ERR 标志位
+const canIDMask = 0x1FFFFFFF
+
+// DecodeFrame 解析 CAN/LIN/FlexRay 通道上 identifier 对应的 frame, 返回 frame triggering 的 SHORT-NAME 与 frame 中全部 PDU 的 signal.
+// channel 为物理通道的 AR 路径, 或唯一的 SHORT-NAME. signal 按位读取, 有符号类型做符号扩展, 配置了 COMPU-METHOD 时 Value 为物理值
+func (c *ArxmlCPConverter) DecodeFrame(channel string, canID uint32, data []byte) (string, []*Signal, error) {
+	frame, err := c.parser.LookupFrame(channel, canID&canIDMask)
+	if err != nil {
+		return "", nil, err
+	}
+	signals := make([]*Signal, 0)
+	for _, framePDU := range frame.PDUs {
+		pduName := util.ExtractLast(framePDU.PDURef)
+		offset, aligned := communication.ByteOffset(framePDU.StartPosition, framePDU.PackingByteOrder)
+		if !aligned {
+			return frame.ShortName, nil, fmt.Errorf("pdu %v at bit %v is not byte aligned", pduName, framePDU.StartPosition)
+		}
+		if offset > len(data) {
+			return frame.ShortName, nil, fmt.Errorf("pdu %v starts at byte %v, frame has %v bytes", pduName, offset, len(data))
+		}
+		pduSignals, err := c.decodePDU(framePDU.PDURef, data[offset:])
+		if err != nil {
+			return frame.ShortName, nil, err
+		}
+		signals = append(signals, pduSignals...)
+	}
+	return frame.ShortName, signals, nil
+}
+
+// DecodePDU 按位解析 AR 路径对应的 I-SIGNAL-I-PDU, CONTAINER-I-PDU, MULTIPLEXED-I-PDU 或 SECURED-I-PDU 中的 signal
+func (c *ArxmlCPConverter) DecodePDU(pduRef string, data []byte) ([]*Signal, error) {
+	return c.decodePDU(pduRef, data)
+}
+
+// decodePDU 以 PDU 的 LENGTH 为上限解析 data, container 与 multiplexed PDU 解析其中的 I-SIGNAL-I-PDU,
+// secured PDU 解析其 authentic PDU
+func (c *ArxmlCPConverter) decodePDU(pduRef string, data []byte) ([]*Signal, error) {
+	if length := c.parser.GetPDULength(pduRef); length >= 0 && length < len(data) {
+		data = data[:length]
+	}
+	if container, ok := c.parser.GetContainerPDU(pduRef); ok {
+		return c.decodeContainerPDU(container, data)
+	}
+	if multiplexed, ok := c.parser.GetMultiplexedPDU(pduRef); ok {
+		return c.decodeMultiplexedPDU(multiplexed, data)
+	}
+	if secured, ok := c.parser.GetSecuredPDU(pduRef); ok {
+		return c.decodeSecuredPDU(secured, data)
+	}
+	return c.decodeSignalPDU(pduRef, data)
+}
+
+// decodeContainerPDU 依次读取 contained PDU 的 header, header id 为 0 时表示其后为填充. 未配置的 header id 被跳过
+func (c *ArxmlCPConverter) decodeContainerPDU(container *communication.ContainerPDU, data []byte) ([]*Signal, error) {
+	headerLength := container.HeaderType.HeaderLength()
+	if headerLength == 0 {
+		return nil, fmt.Errorf("container pdu %v with %v is not supported", container.ShortName, container.HeaderType)
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if container.HeaderByteOrder == communication.ByteOrderLittleEndian {
+		order = binary.LittleEndian
+	}
+	signals := make([]*Signal, 0)
+	for pos := 0; pos+headerLength <= len(data); {
+		var headerID uint32
+		var length int
+		if container.HeaderType == communication.ContainerShortHeader {
+			// short header 为高 24 位 id 与低 8 位长度组成的 32 位值
+			header := order.Uint32(data[pos : pos+4])
+			headerID, length = header>>8, int(header&0xFF)
+		} else {
+			headerID = order.Uint32(data[pos : pos+4])
+			length = int(order.Uint32(data[pos+4 : pos+8]))
+		}
+		if headerID == 0 {
+			break
+		}
+		pos += headerLength
+		if length > len(data)-pos {
+			return nil, fmt.Errorf("container pdu %v: contained pdu 0x%x needs %v bytes, got %v", container.ShortName, headerID, length, len(data)-pos)
+		}
+		if pduRef, ok := container.ContainedPDUs[headerID]; ok {
+			pduSignals, err := c.decodePDU(pduRef, data[pos:pos+length])
+			if err != nil {
+				return nil, fmt.Errorf("container pdu %v: %v", container.ShortName, err)
+			}
+			signals = append(signals, pduSignals...)
+		}
+		pos += length
+	}
+	return signals, nil
+}
+
+// decodeMultiplexedPDU 解析 static part 与 selector field 选择的 dynamic part
+func (c *ArxmlCPConverter) decodeMultiplexedPDU(multiplexed *communication.MultiplexedPDU, data []byte) ([]*Signal, error) {
+	selector, err := extractBits(data, multiplexed.SelectorStartPosition, multiplexed.SelectorLength, multiplexed.SelectorByteOrder == communication.ByteOrderBigEndian)
+	if err != nil {
+		return nil, fmt.Errorf("multiplexed pdu %v selector: %v", multiplexed.ShortName, err)
+	}
+	dynamicPDU, ok := multiplexed.DynamicParts[selector]
+	if !ok {
+		return nil, fmt.Errorf("multiplexed pdu %v has no dynamic part for selector %v", multiplexed.ShortName, selector)
+	}
+	signals := make([]*Signal, 0)
+	for _, pduRef := range append(append([]string{}, multiplexed.StaticPDUs...), dynamicPDU) {
+		pduSignals, err := c.decodeSignalPDU(pduRef, data)
+		if err != nil {
+			return nil, fmt.Errorf("multiplexed pdu %v: %v", multiplexed.ShortName, err)
+		}
+		signals = append(signals, pduSignals...)
+	}
+	return signals, nil
+}
+
+// decodeSignalPDU 按位解析 I-SIGNAL-I-PDU 中除 I-SIGNAL-GROUP 外的全部 signal
+func (c *ArxmlCPConverter) decodeSignalPDU(pduRef string, data []byte) ([]*Signal, error) {
+	pduName := util.ExtractLast(pduRef)
+	mappings, err := c.parser.GetPDUSignals(pduRef)
+	if err != nil {
+		return nil, err
+	}
+	groups := c.signalGroups(mappings)
+	signals := make([]*Signal, 0, len(mappings))
+	for _, mapping := range mappings {
+		if mapping.IsGroup {
+			continue
+		}
+		signal, err := c.decodeFrameSignal(mapping, data)
+		if err != nil {
+			return nil, fmt.Errorf("pdu %v: %v", pduName, err)
+		}
+		signal.PDU = pduName
+		signal.Group = groups[mapping.ISignalRef]
+		signals = append(signals, signal)
+	}
+	return signals, nil
+}
+
+func (c *ArxmlCPConverter) decodeFrameSignal(mapping *communication.SignalMapping, data []byte) (*Signal, error) {
+	name := util.ExtractLast(mapping.ISignalRef)
+	length, ok := c.parser.GetSignalLength(mapping.ISignalRef)
+	if !ok {
+		return nil, fmt.Errorf("no LENGTH in signal %v", name)
+	}
+	bits, err := extractBits(data, mapping.StartPosition, length, mapping.PackingByteOrder == communication.ByteOrderBigEndian)
+	if err != nil {
+		return nil, fmt.Errorf("decode signal %v failed, err:%v", name, err)
+	}
+	baseType, kind, compuMethod := c.parser.GetSignalRepresentation(mapping.ISignalRef)
+	signal := &Signal{Name: name, DataType: baseType}
+	var raw interface{}
+	var physical float64
+	switch {
+	case kind == ast.BasicBool:
+		raw = bits != 0
+	case kind == ast.BasicFloat && length == 32:
+		physical = float64(math.Float32frombits(uint32(bits)))
+		raw = physical
+	case kind == ast.BasicDouble && length == 64:
+		physical = math.Float64frombits(bits)
+		raw = physical
+	case isSignedKind(kind):
+		v := signExtend(bits, length)
+		physical = float64(v)
+		raw = v
+	default:
+		physical = float64(bits)
+		raw = bits
+	}
+	signal.Value = raw
+	if compuMethod != nil && kind != ast.BasicBool {
+		signal.Raw = raw
+		if value, ok := compuMethod.Apply(physical); ok {
+			signal.Value = value
+		}
+	}
+	if updated, ok := mapping.Updated(data); ok {
+		signal.Updated = &updated
+	}
+	return signal, nil
+}
+
+func isSignedKind(kind ast.BasicKind) bool {
+	switch kind {
+	case ast.BasicInt8, ast.BasicInt16, ast.BasicInt32, ast.BasicInt64:
+		return true
+	}
+	return false
+}
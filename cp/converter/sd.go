@@ -0,0 +1,42 @@
+package converter
+
+import (
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/cp/parser"
+	"github.com/yisaer/arxml-converter/someip"
+)
+
+// DecodeSD 解析 SOME/IP-SD payload, 并以 ARXML 中的 SHORT-NAME 标注 service, instance 与 eventgroup
+func (c *ArxmlCPConverter) DecodeSD(payload []byte) (*someip.SDMessage, error) {
+	return someip.DecodeSD(payload, sdCatalog{p: c.parser})
+}
+
+type sdCatalog struct {
+	p *parser.Parser
+}
+
+// ServiceName 返回提供该 service 的 PROVIDED-SERVICE-INSTANCE 的 SHORT-NAME
+func (c sdCatalog) ServiceName(serviceID uint16, majorVersion uint8) string {
+	version := int(majorVersion)
+	if majorVersion == someip.SDAnyMajorVersion {
+		version = ast.AnyMajorVersion
+	}
+	sn, err := c.p.LookupService(serviceID, version)
+	if err != nil {
+		return ""
+	}
+	return sn
+}
+
+func (c sdCatalog) InstanceName(serviceID, instanceID uint16) string {
+	sn, _ := c.p.LookupServiceInstance(serviceID, instanceID)
+	return sn
+}
+
+func (c sdCatalog) EventgroupName(serviceID, eventgroupID uint16) string {
+	eg, ok := c.p.GetEventGroups().Lookup(serviceID, eventgroupID)
+	if !ok {
+		return ""
+	}
+	return eg.ShortName
+}
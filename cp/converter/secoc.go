@@ -0,0 +1,108 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/yisaer/arxml-converter/cp/parser/communication"
+)
+
+// SecOCVerifier 校验 secured PDU 的 MAC, freshness 与 mac 为报文中截断后的值, 密钥与完整 freshness value 由实现方维护
+type SecOCVerifier interface {
+	Verify(secured *communication.SecuredPDU, authentic, freshness, mac []byte) (bool, error)
+}
+
+// SecuredPayload 为 SECURED-I-PDU 的解析结果
+type SecuredPayload struct {
+	PDU       string `json:"pdu"`
+	Authentic []byte `json:"authentic"`
+	Freshness []byte `json:"freshness"`
+	MAC       []byte `json:"mac"`
+	// Verified 为 SecOCVerifier 的校验结果, 未提供 verifier 时为 nil
+	Verified *bool     `json:"verified,omitempty"`
+	Signals  []*Signal `json:"signals"`
+}
+
+// DecodeSecuredPDU 去掉 SECURED-I-PDU 的 header 与 freshness/MAC 尾部, 解析 authentic PDU 中的 signal.
+// verifier 为 nil 时不校验 MAC
+func (c *ArxmlCPConverter) DecodeSecuredPDU(pduRef string, data []byte, verifier SecOCVerifier) (*SecuredPayload, error) {
+	secured, ok := c.parser.GetSecuredPDU(pduRef)
+	if !ok {
+		return nil, fmt.Errorf("no SECURED-I-PDU %v found", pduRef)
+	}
+	if length := c.parser.GetPDULength(pduRef); length >= 0 && length < len(data) {
+		data = data[:length]
+	}
+	payload, err := splitSecuredPDU(secured, data)
+	if err != nil {
+		return nil, err
+	}
+	if verifier != nil {
+		verified, err := verifier.Verify(secured, payload.Authentic, payload.Freshness, payload.MAC)
+		if err != nil {
+			return nil, fmt.Errorf("secured pdu %v verify failed, err:%v", secured.ShortName, err)
+		}
+		payload.Verified = &verified
+	}
+	if payload.Signals, err = c.decodePDU(secured.PayloadPDURef, payload.Authentic); err != nil {
+		return nil, fmt.Errorf("secured pdu %v: %v", secured.ShortName, err)
+	}
+	return payload, nil
+}
+
+// decodeSecuredPDU 只解析 authentic PDU, 用于 frame 与 container 中的 secured PDU
+func (c *ArxmlCPConverter) decodeSecuredPDU(secured *communication.SecuredPDU, data []byte) ([]*Signal, error) {
+	payload, err := splitSecuredPDU(secured, data)
+	if err != nil {
+		return nil, err
+	}
+	signals, err := c.decodePDU(secured.PayloadPDURef, payload.Authentic)
+	if err != nil {
+		return nil, fmt.Errorf("secured pdu %v: %v", secured.ShortName, err)
+	}
+	return signals, nil
+}
+
+// splitSecuredPDU 按 header 中的长度, 或无 header 时按尾部长度切分出 authentic PDU, freshness value 与 MAC
+func splitSecuredPDU(secured *communication.SecuredPDU, data []byte) (*SecuredPayload, error) {
+	headerLength := secured.Header.HeaderLength()
+	trailerLength := secured.TrailerLength()
+	if len(data) < headerLength+trailerLength {
+		return nil, fmt.Errorf("secured pdu %v needs at least %v bytes, got %v", secured.ShortName, headerLength+trailerLength, len(data))
+	}
+	authenticLength := len(data) - headerLength - trailerLength
+	if headerLength > 0 {
+		var length uint64
+		for _, b := range data[:headerLength] {
+			length = length<<8 | uint64(b)
+		}
+		if length > uint64(authenticLength) {
+			return nil, fmt.Errorf("secured pdu %v: header length %v exceeds %v bytes", secured.ShortName, length, authenticLength)
+		}
+		authenticLength = int(length)
+	}
+	authentic := data[headerLength : headerLength+authenticLength]
+	trailer := data[headerLength+authenticLength:]
+	return &SecuredPayload{
+		PDU:       secured.ShortName,
+		Authentic: authentic,
+		Freshness: bitField(trailer, 0, secured.FreshnessTxLength),
+		MAC:       bitField(trailer, secured.FreshnessTxLength, secured.AuthInfoTxLength),
+	}, nil
+}
+
+// bitField 从 data 的第 offset 位 (字节内由高到低) 读取 length 位, 返回右对齐的大端字节
+func bitField(data []byte, offset, length int) []byte {
+	out := make([]byte, (length+7)/8)
+	if length%8 == 0 && offset%8 == 0 {
+		copy(out, data[offset/8:])
+		return out
+	}
+	pad := len(out)*8 - length
+	for i := 0; i < length; i++ {
+		pos := offset + i
+		bit := data[pos/8] >> (7 - pos%8) & 1
+		j := pad + i
+		out[j/8] |= bit << (7 - j%8)
+	}
+	return out
+}
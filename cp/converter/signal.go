@@ -0,0 +1,120 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/cp/parser/communication"
+	"github.com/yisaer/arxml-converter/someip"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+// Signal 为 I-SIGNAL-I-PDU 中解析出的 I-SIGNAL
+type Signal struct {
+	Name     string      `json:"name"`
+	DataType string      `json:"data_type"`
+	Value    interface{} `json:"value"`
+	// Group 为 signal 所属 I-SIGNAL-GROUP 的 SHORT-NAME
+	Group string `json:"group,omitempty"`
+	// Updated 为 update bit 的值, 未配置 update bit 时为 nil
+	Updated *bool `json:"updated,omitempty"`
+	// PDU 与 Raw 只用于 frame 解析, 分别为 signal 所在 PDU 的 SHORT-NAME 与 COMPU-METHOD 转换前的原始值
+	PDU string      `json:"pdu,omitempty"`
+	Raw interface{} `json:"raw,omitempty"`
+}
+
+// ConvertSignals 解析 header id 对应 PDU 中的全部 I-SIGNAL, 返回 PDU 的 SHORT-NAME 与按 START-POSITION 排列的 signal.
+// signal 需按字节对齐, 长度以 I-SIGNAL 的 LENGTH 为上限, 最后一个 signal 可以短于 LENGTH
+func (c *ArxmlCPConverter) ConvertSignals(serviceID uint16, majorVersion int, headerID uint32, messageType someip.MessageType, data []byte) (string, []*Signal, error) {
+	response := messageType.IsResponse() || messageType.IsError()
+	pduRef, mappings, err := c.parser.FindPDUSignals(serviceID, majorVersion, headerID, response)
+	if err != nil {
+		return "", nil, err
+	}
+	pduName := util.ExtractLast(pduRef)
+	groups := c.signalGroups(mappings)
+	signals := make([]*Signal, 0, len(mappings))
+	for _, mapping := range mappings {
+		if mapping.IsGroup {
+			continue
+		}
+		signal, err := c.convertSignal(mapping, data)
+		if err != nil {
+			return pduName, nil, fmt.Errorf("pdu %v: %v", pduName, err)
+		}
+		signal.Group = groups[mapping.ISignalRef]
+		signals = append(signals, signal)
+	}
+	return pduName, signals, nil
+}
+
+// signalGroups 返回 I-SIGNAL 的 AR 路径到所属 I-SIGNAL-GROUP SHORT-NAME 的映射
+func (c *ArxmlCPConverter) signalGroups(mappings []*communication.SignalMapping) map[string]string {
+	groups := make(map[string]string)
+	for _, mapping := range mappings {
+		if mapping.IsGroup {
+			for _, member := range c.parser.GetSignalGroupMembers(mapping.ISignalRef) {
+				groups[member] = util.ExtractLast(mapping.ISignalRef)
+			}
+		}
+	}
+	return groups
+}
+
+func (c *ArxmlCPConverter) convertSignal(mapping *communication.SignalMapping, data []byte) (*Signal, error) {
+	name := util.ExtractLast(mapping.ISignalRef)
+	offset, aligned := mapping.ByteOffset()
+	if !aligned {
+		return nil, fmt.Errorf("signal %v at bit %v is not byte aligned", name, mapping.StartPosition)
+	}
+	if offset > len(data) {
+		return nil, fmt.Errorf("signal %v starts at byte %v, pdu has %v bytes", name, offset, len(data))
+	}
+	end := len(data)
+	if length, ok := c.parser.GetSignalLength(mapping.ISignalRef); ok && offset+length/8 < end {
+		end = offset + length/8
+	}
+	decoder := c.newSomeIPDecoder()
+	switch mapping.PackingByteOrder {
+	case communication.ByteOrderBigEndian:
+		decoder = c.newSomeIPDecoderWithEndian(false)
+	case communication.ByteOrderLittleEndian:
+		decoder = c.newSomeIPDecoderWithEndian(true)
+	}
+	signal := &Signal{Name: name}
+	if err := c.decodeSignalValue(signal, mapping.ISignalRef, decoder, data[offset:end]); err != nil {
+		return nil, fmt.Errorf("decode signal %v failed, err:%v", name, err)
+	}
+	if updated, ok := mapping.Updated(data); ok {
+		signal.Updated = &updated
+	}
+	return signal, nil
+}
+
+// decodeSignalValue 解析 signal 的值, client/server operation 的 I-SIGNAL 解析为参数, DataType 为 operation 的 SHORT-NAME
+func (c *ArxmlCPConverter) decodeSignalValue(signal *Signal, iSignalRef string, decoder *someip.Decoder, data []byte) error {
+	operation, response, err := c.parser.ResolveSignalOperation(iSignalRef)
+	if err != nil {
+		return err
+	}
+	if operation != nil {
+		args := ast.RequestArguments(operation.Arguments)
+		if response {
+			args = ast.ResponseArguments(operation.Arguments)
+		}
+		signal.DataType = operation.ShortName
+		signal.Value, err = decoder.DecodeArguments(args, data)
+		return err
+	}
+	typeRef, err := c.parser.ResolveSignalType(iSignalRef)
+	if err != nil {
+		return err
+	}
+	dt, ok := c.parser.GetTransformer().LookupDataType(typeRef)
+	if !ok {
+		return fmt.Errorf("no data type for %v", typeRef)
+	}
+	signal.DataType = dt.ShorName
+	signal.Value, err = decoder.DecodeByRef(typeRef, data)
+	return err
+}
@@ -0,0 +1,41 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/yisaer/arxml-converter/cp/parser"
+	"github.com/yisaer/arxml-converter/someip"
+)
+
+// NewReassembler 创建以 SOMEIP-TP-CONNECTION 配置限制分段与消息长度的 SOME/IP-TP Reassembler
+func (c *ArxmlCPConverter) NewReassembler(config someip.ReassemblerConfig) *someip.Reassembler {
+	return c.NewChannelReassembler("", config)
+}
+
+// NewChannelReassembler 与 NewReassembler 相同, 只在 channel 对应的物理通道中查找 SOMEIP-TP-CONNECTION
+func (c *ArxmlCPConverter) NewChannelReassembler(channel string, config someip.ReassemblerConfig) *someip.Reassembler {
+	return someip.NewReassembler(config, tpConfigProvider{p: c.parser, channel: channel})
+}
+
+type tpConfigProvider struct {
+	p       *parser.Parser
+	channel string
+}
+
+func (t tpConfigProvider) TPConfig(serviceID, methodID uint16, interfaceVersion uint8) (*someip.TPConfig, bool, error) {
+	connection, ok, err := t.p.GetTpConnection(t.channel, int(interfaceVersion), uint32(serviceID)<<16|uint32(methodID))
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	// transport PDU 的 LENGTH 包含 SOME/IP header 与 SOME/IP-TP header, 分段数据不超过 LENGTH 减去两个 header
+	headerLength := someip.HeaderLength + someip.TPHeaderLength
+	if connection.MaxSegmentLength <= headerLength {
+		return nil, false, fmt.Errorf("transport PDU LENGTH %v of header id 0x%08x is not larger than SOME/IP and TP header length %v",
+			connection.MaxSegmentLength, uint32(serviceID)<<16|uint32(methodID), headerLength)
+	}
+	return &someip.TPConfig{
+		MaxSegmentLength: connection.MaxSegmentLength - headerLength,
+		MaxMessageLength: connection.MaxMessageLength,
+		Timeout:          connection.RxTimeout,
+	}, true, nil
+}
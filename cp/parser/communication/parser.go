@@ -2,23 +2,56 @@ package communication
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/beevik/etree"
 
+	"github.com/yisaer/arxml-converter/e2e"
 	"github.com/yisaer/arxml-converter/util"
 )
 
 type CommunicationParser struct {
 	pdusElement    *etree.Element
 	signalsElement *etree.Element
-	pduRefMap      map[string]string
-	signalRef      map[string]string
+	index          *util.ArIndex
+	// pduRefMap 为 I-SIGNAL-I-PDU 的 AR 路径到 START-POSITION 最小的 I-SIGNAL-REF 的映射
+	pduRefMap map[string]string
+	// pduSignals 为 I-SIGNAL-I-PDU 的 AR 路径到全部 I-SIGNAL-TO-I-PDU-MAPPING 的映射
+	pduSignals map[string][]*SignalMapping
+	// signalRef 为 I-SIGNAL 的 AR 路径到 SYSTEM-SIGNAL-REF 的映射
+	signalRef map[string]string
+	// signalLengths 为 I-SIGNAL 的 AR 路径到 LENGTH (bit) 的映射
+	signalLengths map[string]int
+	// signalRepresentations 以 I-SIGNAL 的 AR 路径为 key
+	signalRepresentations map[string]*SignalRepresentation
+	// pduLengths 为 I-PDU 的 AR 路径到 LENGTH (字节) 的映射
+	pduLengths map[string]int
+	// signalGroups 为 I-SIGNAL-GROUP 的 AR 路径到成员 I-SIGNAL 的 AR 路径的映射
+	signalGroups map[string][]string
+	// containerPDUs, multiplexedPDUs 与 securedPDUs 以 PDU 的 AR 路径为 key
+	containerPDUs   map[string]*ContainerPDU
+	multiplexedPDUs map[string]*MultiplexedPDU
+	securedPDUs     map[string]*SecuredPDU
+	// e2eProtections 以 I-SIGNAL 的 AR 路径为 key
+	e2eProtections map[string]*e2e.Config
 }
 
-func NewCommunicationParser() *CommunicationParser {
+func NewCommunicationParser(index *util.ArIndex) *CommunicationParser {
 	return &CommunicationParser{
-		pduRefMap: make(map[string]string),
-		signalRef: make(map[string]string),
+		index:         index,
+		pduRefMap:     make(map[string]string),
+		pduSignals:    make(map[string][]*SignalMapping),
+		signalRef:     make(map[string]string),
+		signalLengths: make(map[string]int),
+		signalGroups:  make(map[string][]string),
+
+		signalRepresentations: make(map[string]*SignalRepresentation),
+		pduLengths:            make(map[string]int),
+		containerPDUs:         make(map[string]*ContainerPDU),
+		multiplexedPDUs:       make(map[string]*MultiplexedPDU),
+		securedPDUs:           make(map[string]*SecuredPDU),
+
+		e2eProtections: make(map[string]*e2e.Config),
 	}
 }
 
@@ -26,10 +59,52 @@ func (p *CommunicationParser) GetPduRefMap() map[string]string {
 	return p.pduRefMap
 }
 
+// GetPduSignals 返回 I-SIGNAL-I-PDU 中按 START-POSITION 排列的 signal mapping
+func (p *CommunicationParser) GetPduSignals() map[string][]*SignalMapping {
+	return p.pduSignals
+}
+
 func (p *CommunicationParser) GetSignalRefMap() map[string]string {
 	return p.signalRef
 }
 
+// GetSignalLengths 返回 I-SIGNAL 的 LENGTH, 以 bit 为单位
+func (p *CommunicationParser) GetSignalLengths() map[string]int {
+	return p.signalLengths
+}
+
+// GetSignalRepresentations 返回 I-SIGNAL 的 base type 与 compu method
+func (p *CommunicationParser) GetSignalRepresentations() map[string]*SignalRepresentation {
+	return p.signalRepresentations
+}
+
+// GetPduLengths 返回 I-PDU 的 LENGTH, 以字节为单位
+func (p *CommunicationParser) GetPduLengths() map[string]int {
+	return p.pduLengths
+}
+
+func (p *CommunicationParser) GetContainerPDUs() map[string]*ContainerPDU {
+	return p.containerPDUs
+}
+
+func (p *CommunicationParser) GetMultiplexedPDUs() map[string]*MultiplexedPDU {
+	return p.multiplexedPDUs
+}
+
+func (p *CommunicationParser) GetSecuredPDUs() map[string]*SecuredPDU {
+	return p.securedPDUs
+}
+
+// GetSignalGroups 返回 I-SIGNAL-GROUP 的成员 I-SIGNAL
+func (p *CommunicationParser) GetSignalGroups() map[string][]string {
+	return p.signalGroups
+}
+
+// GetE2EProtections 返回 I-SIGNAL 的 E2E 配置, 以 I-SIGNAL 的 AR 路径为 key
+func (p *CommunicationParser) GetE2EProtections() map[string]*e2e.Config {
+	return p.e2eProtections
+}
+
 func (p *CommunicationParser) ParseCommunication(node *etree.Element) (err error) {
 	defer func() {
 		if err != nil {
@@ -96,6 +171,21 @@ func (p *CommunicationParser) parsePDUs(node *etree.Element) error {
 			return fmt.Errorf("parse %v iSignalPDU err: %v", index, err)
 		}
 	}
+	for index, containerPDU := range elements.SelectElements("CONTAINER-I-PDU") {
+		if err := p.parseContainerPDU(containerPDU); err != nil {
+			return fmt.Errorf("parse %v containerPDU err: %v", index, err)
+		}
+	}
+	for index, multiplexedPDU := range elements.SelectElements("MULTIPLEXED-I-PDU") {
+		if err := p.parseMultiplexedPDU(multiplexedPDU); err != nil {
+			return fmt.Errorf("parse %v multiplexedPDU err: %v", index, err)
+		}
+	}
+	for index, securedPDU := range elements.SelectElements("SECURED-I-PDU") {
+		if err := p.parseSecuredPDU(securedPDU); err != nil {
+			return fmt.Errorf("parse %v securedPDU err: %v", index, err)
+		}
+	}
 	return nil
 }
 
@@ -104,22 +194,78 @@ func (p *CommunicationParser) parseiSignalPDU(node *etree.Element) error {
 	if err != nil {
 		return err
 	}
+	pduPath := util.GetArPath(node)
+	if err := p.parsePDULength(node); err != nil {
+		return err
+	}
 	iSignalToPduMappingsElement := node.SelectElement("I-SIGNAL-TO-PDU-MAPPINGS")
 	if iSignalToPduMappingsElement == nil {
 		return nil
 	}
-	iSignalToIPDUMapping := iSignalToPduMappingsElement.SelectElement("I-SIGNAL-TO-I-PDU-MAPPING")
-	if iSignalToIPDUMapping == nil {
-		return nil
+	mappings := make([]*SignalMapping, 0)
+	for index, iSignalToIPDUMapping := range iSignalToPduMappingsElement.SelectElements("I-SIGNAL-TO-I-PDU-MAPPING") {
+		mapping, err := p.parseSignalMapping(iSignalToIPDUMapping)
+		if err != nil {
+			return fmt.Errorf("pdu %v parse %v I-SIGNAL-TO-I-PDU-MAPPING err: %v", sn, index, err)
+		}
+		if mapping != nil {
+			mappings = append(mappings, mapping)
+		}
 	}
-	iSignalRef := iSignalToIPDUMapping.SelectElement("I-SIGNAL-REF")
-	if iSignalRef == nil {
-		return nil
+	sort.SliceStable(mappings, func(i, j int) bool {
+		return mappings[i].StartPosition < mappings[j].StartPosition
+	})
+	for _, mapping := range mappings {
+		if !mapping.IsGroup {
+			p.pduRefMap[pduPath] = mapping.ISignalRef
+			break
+		}
 	}
-	p.pduRefMap[sn] = iSignalRef.Text()
+	p.pduSignals[pduPath] = mappings
 	return nil
 }
 
+func (p *CommunicationParser) parseSignalMapping(node *etree.Element) (*SignalMapping, error) {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return nil, err
+	}
+	mapping := &SignalMapping{
+		ShortName:                   sn,
+		PackingByteOrder:            ByteOrderOpaque,
+		UpdateIndicationBitPosition: -1,
+	}
+	refElement := node.SelectElement("I-SIGNAL-REF")
+	if refElement == nil {
+		refElement = node.SelectElement("I-SIGNAL-GROUP-REF")
+		mapping.IsGroup = true
+	}
+	if refElement == nil {
+		return nil, nil
+	}
+	if mapping.ISignalRef, err = p.index.RefPath(refElement); err != nil {
+		return nil, err
+	}
+	if e := node.SelectElement("PACKING-BYTE-ORDER"); e != nil {
+		mapping.PackingByteOrder = ByteOrder(e.Text())
+	}
+	if e := node.SelectElement("START-POSITION"); e != nil {
+		v, err := util.ToInt64(e.Text())
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid START-POSITION %v", e.Text())
+		}
+		mapping.StartPosition = int(v)
+	}
+	if e := node.SelectElement("UPDATE-INDICATION-BIT-POSITION"); e != nil {
+		v, err := util.ToInt64(e.Text())
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid UPDATE-INDICATION-BIT-POSITION %v", e.Text())
+		}
+		mapping.UpdateIndicationBitPosition = int(v)
+	}
+	return mapping, nil
+}
+
 func (p *CommunicationParser) parseSignals(node *etree.Element) error {
 	elements, err := util.GetElements(node)
 	if err != nil {
@@ -131,6 +277,29 @@ func (p *CommunicationParser) parseSignals(node *etree.Element) error {
 			return fmt.Errorf("parse %v iSignal err: %v", index, err)
 		}
 	}
+	for index, iSignalGroup := range elements.SelectElements("I-SIGNAL-GROUP") {
+		if err := p.parseISignalGroup(iSignalGroup); err != nil {
+			return fmt.Errorf("parse %v iSignalGroup err: %v", index, err)
+		}
+	}
+	return nil
+}
+
+func (p *CommunicationParser) parseISignalGroup(node *etree.Element) error {
+	if _, err := util.GetShortname(node); err != nil {
+		return err
+	}
+	members := make([]string, 0)
+	if refsElement := node.SelectElement("I-SIGNAL-REFS"); refsElement != nil {
+		for _, ref := range refsElement.SelectElements("I-SIGNAL-REF") {
+			member, err := p.index.RefPath(ref)
+			if err != nil {
+				return err
+			}
+			members = append(members, member)
+		}
+	}
+	p.signalGroups[util.GetArPath(node)] = members
 	return nil
 }
 
@@ -139,11 +308,87 @@ func (p *CommunicationParser) parseISignal(node *etree.Element) error {
 	if err != nil {
 		return nil
 	}
+	if err := p.parseE2EProtection(node); err != nil {
+		return fmt.Errorf("i-signal %v: %v", sn, err)
+	}
+	if lengthElement := node.SelectElement("LENGTH"); lengthElement != nil {
+		length, err := util.ToInt64(lengthElement.Text())
+		if err != nil || length < 0 {
+			return fmt.Errorf("i-signal %v has invalid LENGTH %v", sn, lengthElement.Text())
+		}
+		p.signalLengths[util.GetArPath(node)] = int(length)
+	}
+	representation, err := p.parseSignalRepresentation(node.FindElement("NETWORK-REPRESENTATION-PROPS/SW-DATA-DEF-PROPS-VARIANTS/SW-DATA-DEF-PROPS-CONDITIONAL"))
+	if err != nil {
+		return fmt.Errorf("i-signal %v: %v", sn, err)
+	}
+	p.signalRepresentations[util.GetArPath(node)] = representation
 	systemSignalRefElement := node.SelectElement("SYSTEM-SIGNAL-REF")
 	if systemSignalRefElement == nil {
 		return nil
 	}
-	a := systemSignalRefElement.Text()
-	p.signalRef[sn] = a
+	a, err := p.index.RefPath(systemSignalRefElement)
+	if err != nil {
+		return err
+	}
+	p.signalRef[util.GetArPath(node)] = a
+	if representation.CompuMethodRef == "" {
+		if systemSignal, ok := p.index.Lookup(a); ok {
+			physical, err := p.parseSignalRepresentation(systemSignal.FindElement("PHYSICAL-PROPS/SW-DATA-DEF-PROPS-VARIANTS/SW-DATA-DEF-PROPS-CONDITIONAL"))
+			if err != nil {
+				return fmt.Errorf("system signal %v: %v", a, err)
+			}
+			representation.CompuMethodRef = physical.CompuMethodRef
+		}
+	}
+	return nil
+}
+
+func (p *CommunicationParser) parseSignalRepresentation(node *etree.Element) (*SignalRepresentation, error) {
+	representation := &SignalRepresentation{}
+	if node == nil {
+		return representation, nil
+	}
+	var err error
+	if e := node.SelectElement("BASE-TYPE-REF"); e != nil {
+		if representation.BaseTypeRef, err = p.index.RefPath(e); err != nil {
+			return nil, err
+		}
+	}
+	if e := node.SelectElement("COMPU-METHOD-REF"); e != nil {
+		if representation.CompuMethodRef, err = p.index.RefPath(e); err != nil {
+			return nil, err
+		}
+	}
+	return representation, nil
+}
+
+// parseE2EProtection 解析 I-SIGNAL 的 END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS, profile 配置位于
+// TRANSFORMER-REF 引用的 TRANSFORMATION-TECHNOLOGY 的 END-TO-END-TRANSFORMATION-DESCRIPTION
+func (p *CommunicationParser) parseE2EProtection(node *etree.Element) error {
+	props := node.FindElement("TRANSFORMATION-I-SIGNAL-PROPSS/END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS")
+	if props == nil {
+		return nil
+	}
+	if conditional := props.FindElement("END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS-VARIANTS/END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS-CONDITIONAL"); conditional != nil {
+		props = conditional
+	}
+	transformerRef := props.SelectElement("TRANSFORMER-REF")
+	if transformerRef == nil {
+		return fmt.Errorf("no TRANSFORMER-REF in END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS")
+	}
+	transformer, err := p.index.Resolve(transformerRef)
+	if err != nil {
+		return err
+	}
+	description := transformer.FindElement("TRANSFORMATION-DESCRIPTIONS/END-TO-END-TRANSFORMATION-DESCRIPTION")
+	if description == nil {
+		return fmt.Errorf("no END-TO-END-TRANSFORMATION-DESCRIPTION in %v", util.GetArPath(transformer))
+	}
+	cfg, err := e2e.ParseConfig(description, props, 0, 0)
+	if err != nil {
+		return err
+	}
+	p.e2eProtections[util.GetArPath(node)] = cfg
 	return nil
 }
@@ -0,0 +1,320 @@
+package communication
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/util"
+)
+
+// ContainerHeaderType 为 CONTAINER-I-PDU 的 HEADER-TYPE
+type ContainerHeaderType string
+
+const (
+	ContainerShortHeader ContainerHeaderType = "SHORT-HEADER"
+	ContainerLongHeader  ContainerHeaderType = "LONG-HEADER"
+	ContainerNoHeader    ContainerHeaderType = "NO-HEADER"
+)
+
+// HeaderLength 返回每个 contained PDU 前 header 的字节数: short header 为 3 字节 id 与 1 字节长度,
+// long header 为 4 字节 id 与 4 字节长度
+func (t ContainerHeaderType) HeaderLength() int {
+	switch t {
+	case ContainerShortHeader:
+		return 4
+	case ContainerLongHeader:
+		return 8
+	}
+	return 0
+}
+
+// ContainerPDU 为 CONTAINER-I-PDU, ContainedPDUs 为 header id 到 contained I-PDU 的 AR 路径的映射.
+// HeaderByteOrder 为 contained PDU header 的字节序, 未配置时为 big endian
+type ContainerPDU struct {
+	ShortName       string
+	HeaderType      ContainerHeaderType
+	HeaderByteOrder ByteOrder
+	ContainedPDUs   map[uint32]string
+}
+
+// MultiplexedPDU 为 MULTIPLEXED-I-PDU. static part 与 dynamic part 的 I-PDU 中 signal 的位置均相对于 MULTIPLEXED-I-PDU,
+// DynamicParts 为 SELECTOR-FIELD-CODE 到 dynamic part I-PDU 的 AR 路径的映射
+type MultiplexedPDU struct {
+	ShortName             string
+	SelectorStartPosition int
+	SelectorLength        int
+	SelectorByteOrder     ByteOrder
+	StaticPDUs            []string
+	DynamicParts          map[uint64]string
+}
+
+// SecuredPDUHeader 为 SECURED-I-PDU 的 USE-SECURED-PDU-HEADER, header 为大端的 authentic PDU 长度
+type SecuredPDUHeader string
+
+const (
+	SecuredPDUNoHeader    SecuredPDUHeader = "NO-HEADER"
+	SecuredPDUHeader08Bit SecuredPDUHeader = "SECURED-PDU-HEADER-08-BIT"
+	SecuredPDUHeader16Bit SecuredPDUHeader = "SECURED-PDU-HEADER-16-BIT"
+	SecuredPDUHeader32Bit SecuredPDUHeader = "SECURED-PDU-HEADER-32-BIT"
+)
+
+// HeaderLength 返回 secured PDU header 的字节数
+func (h SecuredPDUHeader) HeaderLength() int {
+	switch h {
+	case SecuredPDUHeader08Bit:
+		return 1
+	case SecuredPDUHeader16Bit:
+		return 2
+	case SecuredPDUHeader32Bit:
+		return 4
+	}
+	return 0
+}
+
+// SecuredPDU 为 SecOC 的 SECURED-I-PDU, 报文依次为 header, authentic PDU, 截断后的 freshness value 与 MAC.
+// 长度均以 bit 为单位, AUTHENTICATION-PROPS-REF 与 FRESHNESS-PROPS-REF 中的配置覆盖 SECURE-COMMUNICATION-PROPS
+type SecuredPDU struct {
+	ShortName string
+	// PayloadPDURef 为 authentic PDU 的 AR 路径
+	PayloadPDURef     string
+	Header            SecuredPDUHeader
+	DataID            uint32
+	FreshnessValueID  uint32
+	FreshnessLength   int
+	FreshnessTxLength int
+	AuthInfoTxLength  int
+}
+
+// TrailerLength 返回 freshness value 与 MAC 共占的字节数
+func (s *SecuredPDU) TrailerLength() int {
+	return (s.FreshnessTxLength + s.AuthInfoTxLength + 7) / 8
+}
+
+func (p *CommunicationParser) parseSecuredPDU(node *etree.Element) error {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return err
+	}
+	if err := p.parsePDULength(node); err != nil {
+		return err
+	}
+	secured := &SecuredPDU{ShortName: sn, Header: SecuredPDUNoHeader}
+	if e := node.SelectElement("USE-SECURED-PDU-HEADER"); e != nil {
+		secured.Header = SecuredPDUHeader(e.Text())
+	}
+	switch secured.Header {
+	case SecuredPDUNoHeader, SecuredPDUHeader08Bit, SecuredPDUHeader16Bit, SecuredPDUHeader32Bit:
+	default:
+		return fmt.Errorf("secured pdu %v has unsupported USE-SECURED-PDU-HEADER %v", sn, secured.Header)
+	}
+	payloadRefElement := node.SelectElement("PAYLOAD-REF")
+	if payloadRefElement == nil {
+		return fmt.Errorf("secured pdu %v: no PAYLOAD-REF found", sn)
+	}
+	pduTriggering, err := p.index.Resolve(payloadRefElement)
+	if err != nil {
+		return fmt.Errorf("secured pdu %v: %v", sn, err)
+	}
+	iPDURefElement := pduTriggering.SelectElement("I-PDU-REF")
+	if iPDURefElement == nil {
+		return fmt.Errorf("secured pdu %v: no I-PDU-REF in %v", sn, util.GetArPath(pduTriggering))
+	}
+	if secured.PayloadPDURef, err = p.index.RefPath(iPDURefElement); err != nil {
+		return fmt.Errorf("secured pdu %v: %v", sn, err)
+	}
+	props := []*etree.Element{node.SelectElement("SECURE-COMMUNICATION-PROPS")}
+	for _, tag := range []string{"AUTHENTICATION-PROPS-REF", "FRESHNESS-PROPS-REF"} {
+		refElement := node.SelectElement(tag)
+		if refElement == nil {
+			continue
+		}
+		e, err := p.index.Resolve(refElement)
+		if err != nil {
+			return fmt.Errorf("secured pdu %v: %v", sn, err)
+		}
+		props = append(props, e)
+	}
+	lengths := map[string]*int{
+		"FRESHNESS-VALUE-LENGTH":    &secured.FreshnessLength,
+		"FRESHNESS-VALUE-TX-LENGTH": &secured.FreshnessTxLength,
+		"AUTH-INFO-TX-LENGTH":       &secured.AuthInfoTxLength,
+	}
+	ids := map[string]*uint32{
+		"DATA-ID":            &secured.DataID,
+		"FRESHNESS-VALUE-ID": &secured.FreshnessValueID,
+	}
+	for _, e := range props {
+		if e == nil {
+			continue
+		}
+		for tag, target := range lengths {
+			if e.SelectElement(tag) == nil {
+				continue
+			}
+			if *target, err = selectInt(e, tag); err != nil || *target < 0 {
+				return fmt.Errorf("secured pdu %v has invalid %v", sn, tag)
+			}
+		}
+		for tag, target := range ids {
+			if v := e.SelectElement(tag); v != nil {
+				if *target, err = util.ToUint32(v.Text()); err != nil {
+					return fmt.Errorf("secured pdu %v has invalid %v %v", sn, tag, v.Text())
+				}
+			}
+		}
+	}
+	if secured.FreshnessTxLength > secured.FreshnessLength && secured.FreshnessLength > 0 {
+		return fmt.Errorf("secured pdu %v: FRESHNESS-VALUE-TX-LENGTH %v exceeds FRESHNESS-VALUE-LENGTH %v", sn, secured.FreshnessTxLength, secured.FreshnessLength)
+	}
+	p.securedPDUs[util.GetArPath(node)] = secured
+	return nil
+}
+
+func (p *CommunicationParser) parseContainerPDU(node *etree.Element) error {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return err
+	}
+	if err := p.parsePDULength(node); err != nil {
+		return err
+	}
+	container := &ContainerPDU{
+		ShortName:       sn,
+		HeaderType:      ContainerShortHeader,
+		HeaderByteOrder: ByteOrderBigEndian,
+		ContainedPDUs:   make(map[uint32]string),
+	}
+	if e := node.SelectElement("HEADER-TYPE"); e != nil {
+		container.HeaderType = ContainerHeaderType(e.Text())
+	}
+	if e := node.SelectElement("CONTAINER-I-PDU-HEADER-BYTE-ORDER"); e != nil {
+		container.HeaderByteOrder = ByteOrder(e.Text())
+	}
+	if container.HeaderByteOrder != ByteOrderBigEndian && container.HeaderByteOrder != ByteOrderLittleEndian {
+		return fmt.Errorf("container pdu %v has unsupported CONTAINER-I-PDU-HEADER-BYTE-ORDER %v", sn, container.HeaderByteOrder)
+	}
+	headerTag := "HEADER-ID-SHORT-HEADER"
+	switch container.HeaderType {
+	case ContainerShortHeader:
+	case ContainerLongHeader:
+		headerTag = "HEADER-ID-LONG-HEADER"
+	case ContainerNoHeader:
+		headerTag = ""
+	default:
+		return fmt.Errorf("container pdu %v has unsupported HEADER-TYPE %v", sn, container.HeaderType)
+	}
+	refsElement := node.SelectElement("CONTAINED-PDU-TRIGGERING-REFS")
+	if refsElement != nil && headerTag != "" {
+		for _, ref := range refsElement.SelectElements("CONTAINED-PDU-TRIGGERING-REF") {
+			pduTriggering, err := p.index.Resolve(ref)
+			if err != nil {
+				return fmt.Errorf("container pdu %v: %v", sn, err)
+			}
+			iPDURefElement := pduTriggering.SelectElement("I-PDU-REF")
+			if iPDURefElement == nil {
+				return fmt.Errorf("container pdu %v: no I-PDU-REF in %v", sn, util.GetArPath(pduTriggering))
+			}
+			iPDU, err := p.index.Resolve(iPDURefElement)
+			if err != nil {
+				return fmt.Errorf("container pdu %v: %v", sn, err)
+			}
+			headerIDElement := iPDU.FindElement("CONTAINED-I-PDU-PROPS/" + headerTag)
+			if headerIDElement == nil {
+				return fmt.Errorf("container pdu %v: no %v in contained pdu %v", sn, headerTag, util.GetArPath(iPDU))
+			}
+			headerID, err := util.ToUint32(headerIDElement.Text())
+			if err != nil {
+				return fmt.Errorf("container pdu %v: %v", sn, err)
+			}
+			if other, ok := container.ContainedPDUs[headerID]; ok && other != util.GetArPath(iPDU) {
+				return fmt.Errorf("container pdu %v: contained pdus %v and %v share header id %v", sn, other, util.GetArPath(iPDU), headerID)
+			}
+			container.ContainedPDUs[headerID] = util.GetArPath(iPDU)
+		}
+	}
+	p.containerPDUs[util.GetArPath(node)] = container
+	return nil
+}
+
+func (p *CommunicationParser) parseMultiplexedPDU(node *etree.Element) error {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return err
+	}
+	if err := p.parsePDULength(node); err != nil {
+		return err
+	}
+	multiplexed := &MultiplexedPDU{
+		ShortName:         sn,
+		SelectorByteOrder: ByteOrderLittleEndian,
+		StaticPDUs:        make([]string, 0),
+		DynamicParts:      make(map[uint64]string),
+	}
+	if e := node.SelectElement("SELECTOR-FIELD-BYTE-ORDER"); e != nil {
+		multiplexed.SelectorByteOrder = ByteOrder(e.Text())
+	}
+	if multiplexed.SelectorStartPosition, err = selectInt(node, "SELECTOR-FIELD-START-POSITION"); err != nil {
+		return fmt.Errorf("multiplexed pdu %v: %v", sn, err)
+	}
+	if multiplexed.SelectorLength, err = selectInt(node, "SELECTOR-FIELD-LENGTH"); err != nil {
+		return fmt.Errorf("multiplexed pdu %v: %v", sn, err)
+	}
+	if multiplexed.SelectorLength < 1 || multiplexed.SelectorLength > 64 {
+		return fmt.Errorf("multiplexed pdu %v has invalid SELECTOR-FIELD-LENGTH %v", sn, multiplexed.SelectorLength)
+	}
+	for _, ref := range node.FindElements("STATIC-PARTS/STATIC-PART/I-PDU-REF") {
+		pduRef, err := p.index.RefPath(ref)
+		if err != nil {
+			return fmt.Errorf("multiplexed pdu %v: %v", sn, err)
+		}
+		multiplexed.StaticPDUs = append(multiplexed.StaticPDUs, pduRef)
+	}
+	for _, alternative := range node.FindElements("DYNAMIC-PARTS/DYNAMIC-PART/DYNAMIC-PART-ALTERNATIVES/DYNAMIC-PART-ALTERNATIVE") {
+		refElement := alternative.SelectElement("I-PDU-REF")
+		codeElement := alternative.SelectElement("SELECTOR-FIELD-CODE")
+		if refElement == nil || codeElement == nil {
+			return fmt.Errorf("multiplexed pdu %v: DYNAMIC-PART-ALTERNATIVE needs I-PDU-REF and SELECTOR-FIELD-CODE", sn)
+		}
+		pduRef, err := p.index.RefPath(refElement)
+		if err != nil {
+			return fmt.Errorf("multiplexed pdu %v: %v", sn, err)
+		}
+		code, err := util.ToInt64(codeElement.Text())
+		if err != nil {
+			return fmt.Errorf("multiplexed pdu %v has invalid SELECTOR-FIELD-CODE %v", sn, codeElement.Text())
+		}
+		if other, ok := multiplexed.DynamicParts[uint64(code)]; ok {
+			return fmt.Errorf("multiplexed pdu %v: dynamic parts %v and %v share selector code %v", sn, other, pduRef, code)
+		}
+		multiplexed.DynamicParts[uint64(code)] = pduRef
+	}
+	p.multiplexedPDUs[util.GetArPath(node)] = multiplexed
+	return nil
+}
+
+// parsePDULength 记录 I-PDU 的 LENGTH
+func (p *CommunicationParser) parsePDULength(node *etree.Element) error {
+	lengthElement := node.SelectElement("LENGTH")
+	if lengthElement == nil {
+		return nil
+	}
+	length, err := util.ToInt64(lengthElement.Text())
+	if err != nil {
+		return fmt.Errorf("pdu %v has invalid LENGTH %v", util.GetArPath(node), lengthElement.Text())
+	}
+	p.pduLengths[util.GetArPath(node)] = int(length)
+	return nil
+}
+
+func selectInt(node *etree.Element, tag string) (int, error) {
+	e := node.SelectElement(tag)
+	if e == nil {
+		return 0, fmt.Errorf("no %v found", tag)
+	}
+	v, err := util.ToInt64(e.Text())
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v %v", tag, e.Text())
+	}
+	return int(v), nil
+}
@@ -0,0 +1,52 @@
+package communication
+
+// ByteOrder 为 I-SIGNAL-TO-I-PDU-MAPPING 的 PACKING-BYTE-ORDER
+type ByteOrder string
+
+const (
+	ByteOrderOpaque       ByteOrder = "OPAQUE"
+	ByteOrderBigEndian    ByteOrder = "MOST-SIGNIFICANT-BYTE-FIRST"
+	ByteOrderLittleEndian ByteOrder = "MOST-SIGNIFICANT-BYTE-LAST"
+)
+
+// SignalMapping 为 I-SIGNAL-I-PDU 中的 I-SIGNAL-TO-I-PDU-MAPPING, 位置以 bit 为单位.
+// 大端序 signal 的 StartPosition 为最高位所在的 bit, 其余为最低位所在的 bit
+type SignalMapping struct {
+	ShortName string
+	// ISignalRef 为 I-SIGNAL 或 I-SIGNAL-GROUP 的 AR 路径
+	ISignalRef       string
+	IsGroup          bool
+	StartPosition    int
+	PackingByteOrder ByteOrder
+	// UpdateIndicationBitPosition 为 update bit 的位置, 未配置时为 -1
+	UpdateIndicationBitPosition int
+}
+
+// ByteOffset 返回字节对齐的 signal 在 PDU 中的字节偏移
+func (m *SignalMapping) ByteOffset() (int, bool) {
+	return ByteOffset(m.StartPosition, m.PackingByteOrder)
+}
+
+// ByteOffset 将 START-POSITION 转换为字节偏移, 第二个返回值表示是否按字节对齐
+func ByteOffset(startPosition int, order ByteOrder) (int, bool) {
+	if order == ByteOrderBigEndian {
+		return startPosition / 8, startPosition%8 == 7
+	}
+	return startPosition / 8, startPosition%8 == 0
+}
+
+// Updated 返回 update bit 的值, 第二个返回值表示是否配置了 update bit
+func (m *SignalMapping) Updated(data []byte) (bool, bool) {
+	pos := m.UpdateIndicationBitPosition
+	if pos < 0 || pos/8 >= len(data) {
+		return false, false
+	}
+	return data[pos/8]>>(pos%8)&0x01 == 1, true
+}
+
+// SignalRepresentation 为 I-SIGNAL 在总线上的表示, 来自 NETWORK-REPRESENTATION-PROPS,
+// 未配置 COMPU-METHOD-REF 时使用 SYSTEM-SIGNAL 的 PHYSICAL-PROPS
+type SignalRepresentation struct {
+	BaseTypeRef    string
+	CompuMethodRef string
+}
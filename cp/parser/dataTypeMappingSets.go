@@ -2,52 +2,150 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/beevik/etree"
 
 	"github.com/yisaer/arxml-converter/util"
 )
 
-func (p *Parser) parseDataTypeMappingSets(node *etree.Element) error {
-	elements, err := util.GetElements(node)
-	if err != nil {
-		return err
+// DataTypeMappingConflict 为同一 application data type 在两个 DATA-TYPE-MAPPING-SET 中映射到不同的 implementation data type,
+// 合并时保留文档中先出现的 MappingSet 的映射
+type DataTypeMappingConflict struct {
+	ApplicationDataType       string
+	MappingSet                string
+	ImplementationDataType    string
+	ConflictingSet            string
+	ConflictingImplementation string
+}
+
+func (c *DataTypeMappingConflict) String() string {
+	return fmt.Sprintf("application data type %v is mapped to %v in %v and to %v in %v",
+		c.ApplicationDataType, c.ImplementationDataType, c.MappingSet, c.ConflictingImplementation, c.ConflictingSet)
+}
+
+// parseDataTypeMappingSets 解析文档中全部 DATA-TYPE-MAPPING-SET 并按文档顺序合并.
+// 指定了 software component 时, 其 SWC-INTERNAL-BEHAVIOR 的 DATA-TYPE-MAPPING-REFS 引用的 mapping set 覆盖合并结果
+func (p *Parser) parseDataTypeMappingSets(root *etree.Element) error {
+	mappingSets := make(map[string]map[string]string)
+	// owners 记录合并结果中每个 application data type 的映射来自哪个 mapping set
+	owners := make(map[string]string)
+	for _, dtms := range root.FindElements("//DATA-TYPE-MAPPING-SET") {
+		setPath := util.GetArPath(dtms)
+		mappings, err := p.parseDataTypeMappingSet(dtms)
+		if err != nil {
+			return fmt.Errorf("parse DATA-TYPE-MAPPING-SET %v failed: %w", setPath, err)
+		}
+		mappingSets[setPath] = mappings
+		for _, dtm := range dtms.FindElements("DATA-TYPE-MAPS/DATA-TYPE-MAP/APPLICATION-DATA-TYPE-REF") {
+			adtrKey := strings.TrimSpace(dtm.Text())
+			idtrKey := mappings[adtrKey]
+			owner, ok := owners[adtrKey]
+			if !ok {
+				owners[adtrKey] = setPath
+				p.dataTypeMappings[adtrKey] = idtrKey
+				continue
+			}
+			if existing := p.dataTypeMappings[adtrKey]; existing != idtrKey && owner != setPath {
+				p.dataTypeMappingConflicts = append(p.dataTypeMappingConflicts, &DataTypeMappingConflict{
+					ApplicationDataType:       adtrKey,
+					MappingSet:                owner,
+					ImplementationDataType:    existing,
+					ConflictingSet:            setPath,
+					ConflictingImplementation: idtrKey,
+				})
+			}
+		}
 	}
-	dtms := elements.SelectElement("DATA-TYPE-MAPPING-SET")
-	if dtms == nil {
-		return fmt.Errorf("no DATA-TYPE-MAPPING-SET found")
+	if p.component == "" {
+		return nil
 	}
-	sn, err := util.GetShortname(dtms)
+	component, err := p.findComponent(root, p.component)
 	if err != nil {
 		return err
 	}
-	if sn != "Data_Type_Mappings" {
-		return fmt.Errorf("no Data_Type_Mappings found")
+	// software component 引用的 mapping set 之间不允许冲突
+	componentMappings := make(map[string]string)
+	componentOwners := make(map[string]string)
+	for _, ref := range component.FindElements("INTERNAL-BEHAVIORS/SWC-INTERNAL-BEHAVIOR/DATA-TYPE-MAPPING-REFS/DATA-TYPE-MAPPING-REF") {
+		dtms, err := p.index.Resolve(ref)
+		if err != nil {
+			return fmt.Errorf("software component %v: %w", util.GetArPath(component), err)
+		}
+		setPath := util.GetArPath(dtms)
+		for adtrKey, idtrKey := range mappingSets[setPath] {
+			if existing, ok := componentMappings[adtrKey]; ok && existing != idtrKey {
+				return fmt.Errorf("software component %v: application data type %v is mapped to %v in %v and to %v in %v",
+					util.GetArPath(component), adtrKey, existing, componentOwners[adtrKey], idtrKey, setPath)
+			}
+			componentMappings[adtrKey] = idtrKey
+			componentOwners[adtrKey] = setPath
+		}
+	}
+	for adtrKey, idtrKey := range componentMappings {
+		p.dataTypeMappings[adtrKey] = idtrKey
 	}
+	return nil
+}
+
+func (p *Parser) parseDataTypeMappingSet(dtms *etree.Element) (map[string]string, error) {
+	mappings := make(map[string]string)
 	dtm := dtms.SelectElement("DATA-TYPE-MAPS")
 	if dtm == nil {
-		return fmt.Errorf("no DATA-TYPE-MAPS found")
+		return mappings, nil
 	}
 	subdtms := dtm.SelectElements("DATA-TYPE-MAP")
 	for index, subdtm := range subdtms {
-		if err := p.parseSubDtm(subdtm); err != nil {
-			return fmt.Errorf("parse %v DATA-TYPE-MAP failed: %w", index, err)
+		adtrKey, idtrKey, err := p.parseSubDtm(subdtm)
+		if err != nil {
+			return nil, fmt.Errorf("parse %v DATA-TYPE-MAP failed: %w", index, err)
 		}
+		if existing, ok := mappings[adtrKey]; ok && existing != idtrKey {
+			return nil, fmt.Errorf("application data type %v is mapped to both %v and %v", adtrKey, existing, idtrKey)
+		}
+		mappings[adtrKey] = idtrKey
 	}
-	return nil
+	return mappings, nil
 }
 
-func (p *Parser) parseSubDtm(subdtm *etree.Element) error {
+func (p *Parser) parseSubDtm(subdtm *etree.Element) (string, string, error) {
 	adtr := subdtm.SelectElement("APPLICATION-DATA-TYPE-REF")
 	if adtr == nil {
-		return fmt.Errorf("no APPLICATION-DATA-TYPE-REF found")
+		return "", "", fmt.Errorf("no APPLICATION-DATA-TYPE-REF found")
+	}
+	adtrKey, err := p.index.RefPath(adtr)
+	if err != nil {
+		return "", "", err
 	}
-	adtrKey := extractLast(adtr.Text())
 	idtr := subdtm.SelectElement("IMPLEMENTATION-DATA-TYPE-REF")
 	if idtr == nil {
-		return fmt.Errorf("no IMPLEMENTATION-DATA-TYPE-REF found")
+		return "", "", fmt.Errorf("no IMPLEMENTATION-DATA-TYPE-REF found")
 	}
-	idtrKey := extractLast(idtr.Text())
-	p.dataTypeMappings[adtrKey] = idtrKey
-	return nil
+	idtrKey, err := p.index.RefPath(idtr)
+	if err != nil {
+		return "", "", err
+	}
+	return adtrKey, idtrKey, nil
+}
+
+// findComponent 根据 AR 路径或唯一的 SHORT-NAME 查找 software component type
+func (p *Parser) findComponent(root *etree.Element, component string) (*etree.Element, error) {
+	if e, ok := p.index.Lookup(component); ok && strings.HasSuffix(e.Tag, "SW-COMPONENT-TYPE") {
+		return e, nil
+	}
+	var found *etree.Element
+	for _, sn := range root.FindElements("//SHORT-NAME") {
+		e := sn.Parent()
+		if !strings.HasSuffix(e.Tag, "SW-COMPONENT-TYPE") || strings.TrimSpace(sn.Text()) != component {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("software component %v is ambiguous: %v and %v", component, util.GetArPath(found), util.GetArPath(e))
+		}
+		found = e
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no software component %v found", component)
+	}
+	return found, nil
 }
@@ -2,7 +2,6 @@ package datatypes
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/beevik/etree"
 
@@ -14,24 +13,55 @@ type DataTypesParser struct {
 	implementationDataTypesArPackage *etree.Element
 	applicationDatatypeArPackage     *etree.Element
 
+	// applicationDataTypes 与 implementationDataTypes 以 AR 绝对路径为 key
 	applicationDataTypes    map[string]*ast.DataType
 	implementationDataTypes map[string]*ast.DataType
+	implementationTypedefs  map[string]implementationTypedef
 
 	dataTypeMappings map[string]string
+	tlvDataIDs       map[string]uint16
+	baseTypes        *ast.BaseTypeRegistry
+	// unsupportedBaseTypes 为无法确定基础类型的 SW-BASE-TYPE 的 AR 路径到原因的映射
+	unsupportedBaseTypes map[string]error
+	compuMethods         *ast.CompuMethodRegistry
+	index                *util.ArIndex
 }
 
-func NewDataTypesParser(dataTypeMappings map[string]string) *DataTypesParser {
+func NewDataTypesParser(dataTypeMappings map[string]string, index *util.ArIndex) *DataTypesParser {
 	return &DataTypesParser{
 		dataTypeMappings:        dataTypeMappings,
+		index:                   index,
 		applicationDataTypes:    make(map[string]*ast.DataType),
 		implementationDataTypes: make(map[string]*ast.DataType),
+		implementationTypedefs:  make(map[string]implementationTypedef),
+		baseTypes:               ast.NewBaseTypeRegistry(),
+		unsupportedBaseTypes:    make(map[string]error),
+		compuMethods:            ast.NewCompuMethodRegistry(),
 	}
 }
 
+// SetTLVDataIDs 设置 record element 的 AR 路径到 TLV data id 的映射
+func (dp *DataTypesParser) SetTLVDataIDs(tlvDataIDs map[string]uint16) {
+	dp.tlvDataIDs = tlvDataIDs
+}
+
 func (dp *DataTypesParser) GetApplicationDataTypes() map[string]*ast.DataType {
 	return dp.applicationDataTypes
 }
 
+// GetDataTypes 返回 application data types 与 implementation data types,
+// 以便 union 等直接引用 implementation data type 的成员可以被解析
+func (dp *DataTypesParser) GetDataTypes() map[string]*ast.DataType {
+	dataTypes := make(map[string]*ast.DataType, len(dp.applicationDataTypes)+len(dp.implementationDataTypes))
+	for k, v := range dp.implementationDataTypes {
+		dataTypes[k] = v
+	}
+	for k, v := range dp.applicationDataTypes {
+		dataTypes[k] = v
+	}
+	return dataTypes
+}
+
 func (dp *DataTypesParser) parseApplicationDatatypes(node *etree.Element) error {
 	for index, apdt := range node.FindElements("//APPLICATION-PRIMITIVE-DATA-TYPE") {
 		if err := dp.ParseApplicationDataType(apdt); err != nil {
@@ -66,6 +96,7 @@ func (dp *DataTypesParser) ParseApplicationDataType(root *etree.Element) (err er
 	if err != nil {
 		return fmt.Errorf("parse category failed err:%v", err.Error())
 	}
+	path := util.GetArPath(root)
 	switch category {
 	case "STRING":
 		sddpc, err := util.GetSWDataDefPropsConditional(root)
@@ -80,20 +111,31 @@ func (dp *DataTypesParser) ParseApplicationDataType(root *etree.Element) (err er
 		if err != nil {
 			return err
 		}
-		if !isDynamicString {
-			return fmt.Errorf("fixed length string not supported now")
-		}
 		btr := stp.SelectElement("BASE-TYPE-REF")
 		if btr == nil {
 			return fmt.Errorf("no BASE-TYPE-REF found")
 		}
-		btrRaw := btr.Text()
-		if !strings.Contains(strings.ToUpper(btrRaw), "UTF_8") {
-			return fmt.Errorf("BASE-TYPE ref should be UTF_8, got:%v", btrRaw)
+		btrRaw, err := dp.index.RefPath(btr)
+		if err != nil {
+			return err
+		}
+		encoding, err := dp.resolveStringEncoding(btrRaw)
+		if err != nil {
+			return err
+		}
+		var stringSize int64
+		if !isDynamicString {
+			chars, err := getMaxNumberOfChars(stp)
+			if err != nil {
+				return err
+			}
+			stringSize = encoding.FixedStringSize(chars)
 		}
-		dp.applicationDataTypes[sn] = ast.NewStringDataType(sn, category, 0)
+		dt := ast.NewStringDataType(sn, category, stringSize)
+		dt.Encoding = encoding
+		dp.applicationDataTypes[path] = dt
 	case "VALUE":
-		idtrKey, ok := dp.dataTypeMappings[sn]
+		idtrKey, ok := dp.dataTypeMappings[path]
 		if !ok {
 			return fmt.Errorf("failed to find mapping for applicationDataType:%v", sn)
 		}
@@ -101,8 +143,9 @@ func (dp *DataTypesParser) ParseApplicationDataType(root *etree.Element) (err er
 		if !ok {
 			return fmt.Errorf("failed to implementationDataType:%v for application key:%v", idtrKey, sn)
 		}
-		dt.ShorName = sn
-		dp.applicationDataTypes[sn] = dt
+		adt := *dt
+		adt.ShorName = sn
+		dp.applicationDataTypes[path] = &adt
 	case "ARRAY":
 		element := root.SelectElement("ELEMENT")
 		if element == nil {
@@ -112,7 +155,10 @@ func (dp *DataTypesParser) ParseApplicationDataType(root *etree.Element) (err er
 		if typeRef == nil {
 			return fmt.Errorf("no TYPE-TREF found for sn %v", sn)
 		}
-		arrayRef := util.ExtractLast(typeRef.Text())
+		arrayRef, err := dp.index.RefPath(typeRef)
+		if err != nil {
+			return err
+		}
 		isDynamicArray, err := util.GetArraySizeSemantics(element)
 		if err != nil {
 			return err
@@ -126,9 +172,9 @@ func (dp *DataTypesParser) ParseApplicationDataType(root *etree.Element) (err er
 			if err != nil {
 				return fmt.Errorf("invalid MAX-NUMBER-OF-ELEMENTS element:%v", numberLengthElement.Text())
 			}
-			dp.applicationDataTypes[sn] = ast.NewArrayDataType(sn, category, arrayRef, length)
+			dp.applicationDataTypes[path] = ast.NewArrayDataType(sn, category, arrayRef, length)
 		} else {
-			dp.applicationDataTypes[sn] = ast.NewArrayDataType(sn, category, arrayRef, 0)
+			dp.applicationDataTypes[path] = ast.NewArrayDataType(sn, category, arrayRef, 0)
 		}
 	case "STRUCTURE":
 		elements := root.SelectElement("ELEMENTS")
@@ -150,10 +196,21 @@ func (dp *DataTypesParser) ParseApplicationDataType(root *etree.Element) (err er
 			if typeRef == nil {
 				return fmt.Errorf("no TYPE-REF found for sn %v", recordSN)
 			}
-			ref.Ref = util.ExtractLast(typeRef.Text())
+			ref.Ref, err = dp.index.RefPath(typeRef)
+			if err != nil {
+				return err
+			}
+			isOptional, err := util.GetIsOptional(record)
+			if err != nil {
+				return err
+			}
+			ref.IsOptional = isOptional
+			if dataID, ok := dp.tlvDataIDs[util.GetArPath(record)]; ok {
+				ref.DataID = &dataID
+			}
 			s.STRList = append(s.STRList, ref)
 		}
-		dp.applicationDataTypes[sn] = ast.NewStructureDataType(sn, category, s)
+		dp.applicationDataTypes[path] = ast.NewStructureDataType(sn, category, s)
 	default:
 		return fmt.Errorf("unknown category:%v", category)
 	}
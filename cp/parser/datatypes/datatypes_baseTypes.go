@@ -0,0 +1,103 @@
+package datatypes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+// ParseBaseTypes 解析文件中所有 SW-BASE-TYPE, 需在 ParseDataTypes 之前调用.
+// 不支持的 base type (如 24 bit 整数, BCD 编码) 只在被引用时报错
+func (dp *DataTypesParser) ParseBaseTypes(root *etree.Element) error {
+	for _, swBaseType := range root.FindElements("//SW-BASE-TYPE") {
+		path := util.GetArPath(swBaseType)
+		bt, err := parseBaseType(swBaseType)
+		if err != nil {
+			dp.unsupportedBaseTypes[path] = err
+			continue
+		}
+		dp.baseTypes.Register(path, bt)
+	}
+	return nil
+}
+
+func (dp *DataTypesParser) GetBaseTypes() *ast.BaseTypeRegistry {
+	return dp.baseTypes
+}
+
+func parseBaseType(node *etree.Element) (*ast.BaseType, error) {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return nil, fmt.Errorf("SW-BASE-TYPE has err:%v", err)
+	}
+	var size int64
+	if e := node.SelectElement("BASE-TYPE-SIZE"); e != nil {
+		size, err = util.ToInt64(e.Text())
+		if err != nil {
+			return nil, fmt.Errorf("invalid BASE-TYPE-SIZE in base type %v", sn)
+		}
+	}
+	var encoding, nativeDeclaration string
+	if e := node.SelectElement("BASE-TYPE-ENCODING"); e != nil {
+		encoding = e.Text()
+	}
+	if e := node.SelectElement("NATIVE-DECLARATION"); e != nil {
+		nativeDeclaration = e.Text()
+	}
+	return ast.NewBaseType(sn, int(size), encoding, nativeDeclaration)
+}
+
+// resolveBaseType 根据 BASE-TYPE-REF 确定基础类型, 未定义 SW-BASE-TYPE 时仅接受 AUTOSAR Platform Types
+func (dp *DataTypesParser) resolveBaseType(ref string) (ast.BasicKind, error) {
+	if err, ok := dp.unsupportedBaseTypes[strings.TrimSpace(ref)]; ok {
+		return "", err
+	}
+	if bt, ok := dp.baseTypes.Lookup(ref); ok {
+		return bt.Kind, nil
+	}
+	if kind, ok := ast.GetStandardBasicKind(ref); ok {
+		return kind, nil
+	}
+	return "", fmt.Errorf("unknown base type %v: no SW-BASE-TYPE definition found", ref)
+}
+
+// resolveStringEncoding 根据字符串的 BASE-TYPE-REF 确定编码, 未定义 SW-BASE-TYPE 时根据名称判断
+func (dp *DataTypesParser) resolveStringEncoding(ref string) (ast.StringEncoding, error) {
+	if err, ok := dp.unsupportedBaseTypes[strings.TrimSpace(ref)]; ok {
+		return "", err
+	}
+	if bt, ok := dp.baseTypes.Lookup(ref); ok {
+		if bt.Kind != ast.BasicString {
+			return "", fmt.Errorf("BASE-TYPE ref should be a string encoding, got:%v(%v)", ref, bt.Encoding)
+		}
+		return bt.StringEncoding(), nil
+	}
+	name := strings.ToUpper(ref[strings.LastIndex(ref, "/")+1:])
+	switch {
+	case strings.Contains(name, "UTF_16"), strings.Contains(name, "UCS_2"):
+		return ast.StringUTF16, nil
+	case strings.Contains(name, "UTF_8"):
+		return ast.StringUTF8, nil
+	}
+	return "", fmt.Errorf("BASE-TYPE ref should be UTF_8 or UTF_16, got:%v", ref)
+}
+
+// getMaxNumberOfChars 读取定长字符串的字符数, 未配置 MAX-NUMBER-OF-CHARS 时使用 SW-MAX-TEXT-SIZE
+func getMaxNumberOfChars(stp *etree.Element) (int64, error) {
+	for _, tag := range []string{"MAX-NUMBER-OF-CHARS", "SW-MAX-TEXT-SIZE"} {
+		e := stp.SelectElement(tag)
+		if e == nil {
+			continue
+		}
+		chars, err := util.ToInt64(e.Text())
+		if err != nil || chars < 1 {
+			return 0, fmt.Errorf("invalid %v:%v", tag, e.Text())
+		}
+		return chars, nil
+	}
+	return 0, fmt.Errorf("no MAX-NUMBER-OF-CHARS found for fixed length string")
+}
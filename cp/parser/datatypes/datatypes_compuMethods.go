@@ -0,0 +1,116 @@
+package datatypes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+// ParseCompuMethods 解析文件中所有 COMPU-METHOD 的 COMPU-INTERNAL-TO-PHYS
+func (dp *DataTypesParser) ParseCompuMethods(root *etree.Element) error {
+	for _, compuMethod := range root.FindElements("//COMPU-METHOD") {
+		m, err := parseCompuMethod(compuMethod)
+		if err != nil {
+			return err
+		}
+		dp.compuMethods.Register(util.GetArPath(compuMethod), m)
+	}
+	return nil
+}
+
+func (dp *DataTypesParser) GetCompuMethods() *ast.CompuMethodRegistry {
+	return dp.compuMethods
+}
+
+func parseCompuMethod(node *etree.Element) (*ast.CompuMethod, error) {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return nil, fmt.Errorf("COMPU-METHOD has err:%v", err)
+	}
+	m := &ast.CompuMethod{
+		ShortName: sn,
+		Scales:    make([]*ast.CompuScale, 0),
+	}
+	if category := node.SelectElement("CATEGORY"); category != nil {
+		m.Category = strings.TrimSpace(category.Text())
+	}
+	scales := node.FindElement("COMPU-INTERNAL-TO-PHYS/COMPU-SCALES")
+	if scales == nil {
+		return m, nil
+	}
+	for index, compuScale := range scales.SelectElements("COMPU-SCALE") {
+		scale, err := parseCompuScale(compuScale)
+		if err != nil {
+			return nil, fmt.Errorf("compu method %v parse %v COMPU-SCALE err: %v", sn, index, err)
+		}
+		m.Scales = append(m.Scales, scale)
+	}
+	return m, nil
+}
+
+func parseCompuScale(node *etree.Element) (*ast.CompuScale, error) {
+	scale := &ast.CompuScale{}
+	for tag, target := range map[string]**float64{
+		"LOWER-LIMIT": &scale.LowerLimit,
+		"UPPER-LIMIT": &scale.UpperLimit,
+	} {
+		e := node.SelectElement(tag)
+		if e == nil {
+			continue
+		}
+		// 无穷区间不限制范围
+		if e.SelectAttrValue("INTERVAL-TYPE", "") == "INFINITE" {
+			continue
+		}
+		v, err := parseFloat(e.Text())
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v %v", tag, e.Text())
+		}
+		*target = &v
+	}
+	if vt := node.FindElement("COMPU-CONST/VT"); vt != nil {
+		scale.Text = vt.Text()
+		return scale, nil
+	}
+	coeffs := node.SelectElement("COMPU-RATIONAL-COEFFS")
+	if coeffs == nil {
+		return scale, nil
+	}
+	var err error
+	if scale.Numerator, err = parseCoefficients(coeffs.SelectElement("COMPU-NUMERATOR")); err != nil {
+		return nil, err
+	}
+	if scale.Denominator, err = parseCoefficients(coeffs.SelectElement("COMPU-DENOMINATOR")); err != nil {
+		return nil, err
+	}
+	return scale, nil
+}
+
+func parseCoefficients(node *etree.Element) ([]float64, error) {
+	coeffs := make([]float64, 0)
+	if node == nil {
+		return coeffs, nil
+	}
+	for _, v := range node.SelectElements("V") {
+		f, err := parseFloat(v.Text())
+		if err != nil {
+			return nil, fmt.Errorf("invalid coefficient %v", v.Text())
+		}
+		coeffs = append(coeffs, f)
+	}
+	return coeffs, nil
+}
+
+// parseFloat 解析 ARXML 中的数值, 整数可以为 0x 开头的十六进制
+func parseFloat(raw string) (float64, error) {
+	raw = strings.TrimSpace(raw)
+	if v, err := strconv.ParseInt(raw, 0, 64); err == nil {
+		return float64(v), nil
+	}
+	return strconv.ParseFloat(raw, 64)
+}
@@ -9,16 +9,22 @@ import (
 	"github.com/yisaer/arxml-converter/util"
 )
 
+// implementationTypedef 为 TYPE_REFERENCE 类型, ref 为被引用类型的 AR 路径
+type implementationTypedef struct {
+	shortName string
+	ref       string
+}
+
 func (dp *DataTypesParser) parseImplementationDataTypes(node *etree.Element) error {
 	for index, idt := range node.FindElements("//IMPLEMENTATION-DATA-TYPE") {
-		if err := dp.parseImplementationValueDataType(idt); err != nil {
+		if err := dp.parseImplementationDataType(idt); err != nil {
 			return fmt.Errorf("parse %v ImplementationDataType failed, err:%v", index, err.Error())
 		}
 	}
-	return nil
+	return dp.resolveImplementationTypedefs()
 }
 
-func (dp *DataTypesParser) parseImplementationValueDataType(root *etree.Element) (err error) {
+func (dp *DataTypesParser) parseImplementationDataType(root *etree.Element) (err error) {
 	sn, err := util.GetShortname(root)
 	if err != nil {
 		return err
@@ -33,10 +39,42 @@ func (dp *DataTypesParser) parseImplementationValueDataType(root *etree.Element)
 	if err != nil {
 		return err
 	}
-	if category != "VALUE" {
+	path := util.GetArPath(root)
+	switch category {
+	case "TYPE_REFERENCE":
+		ref, err := dp.getImplementationDataTypeRef(root)
+		if err != nil {
+			return err
+		}
+		dp.implementationTypedefs[path] = implementationTypedef{shortName: sn, ref: ref}
 		return nil
+	case "VALUE", "STRUCTURE", "ARRAY", "UNION":
+		return dp.parseImplementationType(path, sn, category, root)
+	}
+	return nil
+}
+
+// parseImplementationType 解析 IMPLEMENTATION-DATA-TYPE 或匿名的 IMPLEMENTATION-DATA-TYPE-ELEMENT, 以 path 登记
+func (dp *DataTypesParser) parseImplementationType(path, sn, category string, node *etree.Element) error {
+	switch category {
+	case "VALUE":
+		return dp.parseImplementationValueType(path, sn, category, node)
+	case "STRUCTURE":
+		// VSA_LINEAR 结构体是变长数组的 C 表示, 其 size indicator 对应 SOME/IP 的长度字段
+		if profile := node.SelectElement("DYNAMIC-ARRAY-SIZE-PROFILE"); profile != nil && profile.Text() == "VSA_LINEAR" {
+			return dp.parseImplementationVSALinear(path, sn, node)
+		}
+		return dp.parseImplementationStructureType(path, sn, category, node)
+	case "ARRAY":
+		return dp.parseImplementationArrayType(path, sn, node)
+	case "UNION":
+		return dp.parseImplementationUnionDataType(path, sn, category, node)
 	}
-	sddpc, err := util.GetSWDataDefPropsConditional(root)
+	return fmt.Errorf("unsupported category:%v", category)
+}
+
+func (dp *DataTypesParser) parseImplementationValueType(path, sn, category string, node *etree.Element) error {
+	sddpc, err := util.GetSWDataDefPropsConditional(node)
 	if err != nil {
 		return err
 	}
@@ -44,10 +82,213 @@ func (dp *DataTypesParser) parseImplementationValueDataType(root *etree.Element)
 	if byr == nil {
 		return fmt.Errorf("no BASE-TYPE-REF found")
 	}
-	ref := byr.Text()
-	if err := util.ValidBasicType(ref); err != nil {
+	ref, err := dp.index.RefPath(byr)
+	if err != nil {
+		return err
+	}
+	kind, err := dp.resolveBaseType(ref)
+	if err != nil {
+		return err
+	}
+	dp.implementationDataTypes[path] = ast.NewBasicDataType(sn, category, ref, kind)
+	return nil
+}
+
+func (dp *DataTypesParser) parseImplementationStructureType(path, sn, category string, node *etree.Element) error {
+	subElements := node.SelectElement("SUB-ELEMENTS")
+	if subElements == nil {
+		return fmt.Errorf("no SUB-ELEMENTS found")
+	}
+	s := &ast.Structure{
+		STRList: make([]*ast.StructureTypRef, 0),
+	}
+	for _, element := range subElements.SelectElements("IMPLEMENTATION-DATA-TYPE-ELEMENT") {
+		elementSN, err := util.GetShortname(element)
+		if err != nil {
+			return err
+		}
+		ref, err := dp.parseImplementationElement(element)
+		if err != nil {
+			return fmt.Errorf("structure member %v: %v", elementSN, err.Error())
+		}
+		isOptional, err := util.GetIsOptional(element)
+		if err != nil {
+			return err
+		}
+		str := &ast.StructureTypRef{
+			ShorName:   elementSN,
+			Ref:        ref,
+			IsOptional: isOptional,
+		}
+		if dataID, ok := dp.tlvDataIDs[util.GetArPath(element)]; ok {
+			str.DataID = &dataID
+		}
+		s.STRList = append(s.STRList, str)
+	}
+	dp.implementationDataTypes[path] = ast.NewStructureDataType(sn, category, s)
+	return nil
+}
+
+// parseImplementationArrayType 解析 ARRAY, 唯一的 sub element 描述元素类型与 ARRAY-SIZE
+func (dp *DataTypesParser) parseImplementationArrayType(path, sn string, node *etree.Element) error {
+	subElements := node.SelectElement("SUB-ELEMENTS")
+	if subElements == nil {
+		return fmt.Errorf("no SUB-ELEMENTS found")
+	}
+	elements := subElements.SelectElements("IMPLEMENTATION-DATA-TYPE-ELEMENT")
+	if len(elements) != 1 {
+		return fmt.Errorf("expect 1 IMPLEMENTATION-DATA-TYPE-ELEMENT for ARRAY, got %v", len(elements))
+	}
+	element := elements[0]
+	ref, err := dp.parseImplementationElement(element)
+	if err != nil {
 		return err
 	}
-	dp.implementationDataTypes[sn] = ast.NewBasicDataType(sn, category, ref)
+	isDynamicArray := false
+	if element.SelectElement("ARRAY-SIZE-SEMANTICS") != nil {
+		isDynamicArray, err = util.GetArraySizeSemantics(element)
+		if err != nil {
+			return err
+		}
+	}
+	if isDynamicArray {
+		dp.implementationDataTypes[path] = ast.NewArrayDataType(sn, "ARRAY", ref, 0)
+		return nil
+	}
+	arraySize := element.SelectElement("ARRAY-SIZE")
+	if arraySize == nil {
+		return fmt.Errorf("no ARRAY-SIZE found")
+	}
+	size, err := util.ToInt64(arraySize.Text())
+	if err != nil || size < 1 {
+		return fmt.Errorf("invalid ARRAY-SIZE element:%v", arraySize.Text())
+	}
+	dp.implementationDataTypes[path] = ast.NewArrayDataType(sn, "ARRAY", ref, size)
+	return nil
+}
+
+func (dp *DataTypesParser) parseImplementationVSALinear(path, sn string, node *etree.Element) error {
+	subElements := node.SelectElement("SUB-ELEMENTS")
+	if subElements == nil {
+		return fmt.Errorf("no SUB-ELEMENTS found")
+	}
+	for _, element := range subElements.SelectElements("IMPLEMENTATION-DATA-TYPE-ELEMENT") {
+		if category, err := util.GetCategory(element); err == nil && category == "ARRAY" {
+			return dp.parseImplementationArrayType(path, sn, element)
+		}
+	}
+	return fmt.Errorf("no ARRAY element found for VSA_LINEAR structure")
+}
+
+// parseImplementationUnionDataType 解析 UNION, SUB-ELEMENTS 的顺序即 type selector 的取值顺序
+func (dp *DataTypesParser) parseImplementationUnionDataType(path, sn, category string, root *etree.Element) error {
+	subElements := root.SelectElement("SUB-ELEMENTS")
+	if subElements == nil {
+		return fmt.Errorf("no SUB-ELEMENTS found")
+	}
+	u := &ast.Union{
+		Members: make([]*ast.StructureTypRef, 0),
+	}
+	for _, element := range subElements.SelectElements("IMPLEMENTATION-DATA-TYPE-ELEMENT") {
+		elementSN, err := util.GetShortname(element)
+		if err != nil {
+			return err
+		}
+		ref, err := dp.parseImplementationElement(element)
+		if err != nil {
+			return fmt.Errorf("union member %v: %v", elementSN, err.Error())
+		}
+		u.Members = append(u.Members, &ast.StructureTypRef{
+			ShorName: elementSN,
+			Ref:      ref,
+		})
+	}
+	if len(u.Members) < 1 {
+		return fmt.Errorf("no IMPLEMENTATION-DATA-TYPE-ELEMENT found")
+	}
+	dp.implementationDataTypes[path] = ast.NewUnionDataType(sn, category, u)
+	return nil
+}
+
+// parseImplementationElement 返回 sub element 的类型引用. TYPE_REFERENCE 直接返回被引用类型,
+// 其它匿名定义的 sub element 以自身 AR 路径登记为类型
+func (dp *DataTypesParser) parseImplementationElement(element *etree.Element) (string, error) {
+	sn, err := util.GetShortname(element)
+	if err != nil {
+		return "", err
+	}
+	category, err := getImplementationElementCategory(element)
+	if err != nil {
+		return "", err
+	}
+	if category == "TYPE_REFERENCE" {
+		return dp.getImplementationDataTypeRef(element)
+	}
+	path := util.GetArPath(element)
+	if err := dp.parseImplementationType(path, sn, category, element); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// getImplementationElementCategory 未配置 CATEGORY 时根据引用推断
+func getImplementationElementCategory(element *etree.Element) (string, error) {
+	if element.SelectElement("CATEGORY") != nil {
+		return util.GetCategory(element)
+	}
+	sddpc, err := util.GetSWDataDefPropsConditional(element)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case sddpc.SelectElement("IMPLEMENTATION-DATA-TYPE-REF") != nil:
+		return "TYPE_REFERENCE", nil
+	case sddpc.SelectElement("BASE-TYPE-REF") != nil:
+		return "VALUE", nil
+	}
+	return "", fmt.Errorf("no IMPLEMENTATION-DATA-TYPE-REF or BASE-TYPE-REF found")
+}
+
+func (dp *DataTypesParser) getImplementationDataTypeRef(node *etree.Element) (string, error) {
+	sddpc, err := util.GetSWDataDefPropsConditional(node)
+	if err != nil {
+		return "", err
+	}
+	ref := sddpc.SelectElement("IMPLEMENTATION-DATA-TYPE-REF")
+	if ref == nil {
+		return "", fmt.Errorf("no IMPLEMENTATION-DATA-TYPE-REF found")
+	}
+	return dp.index.RefPath(ref)
+}
+
+// resolveImplementationTypedefs 将 TYPE_REFERENCE 类型登记为其最终引用类型的副本
+func (dp *DataTypesParser) resolveImplementationTypedefs() error {
+	for path := range dp.implementationTypedefs {
+		if _, err := dp.resolveImplementationTypedef(path, make(map[string]bool)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func (dp *DataTypesParser) resolveImplementationTypedef(path string, visiting map[string]bool) (*ast.DataType, error) {
+	if dt, ok := dp.implementationDataTypes[path]; ok {
+		return dt, nil
+	}
+	typedef, ok := dp.implementationTypedefs[path]
+	if !ok {
+		return nil, fmt.Errorf("implementation data type %v not found", path)
+	}
+	if visiting[path] {
+		return nil, fmt.Errorf("cyclic TYPE_REFERENCE found at %v", path)
+	}
+	visiting[path] = true
+	dt, err := dp.resolveImplementationTypedef(typedef.ref, visiting)
+	if err != nil {
+		return nil, fmt.Errorf("resolve TYPE_REFERENCE %v failed, err:%v", typedef.shortName, err.Error())
+	}
+	td := *dt
+	td.ShorName = typedef.shortName
+	dp.implementationDataTypes[path] = &td
+	return &td, nil
+}
@@ -0,0 +1,221 @@
+package datatypes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+func newStringDataTypeElement(t *testing.T, semantics, baseType string) *etree.Element {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(`<APPLICATION-PRIMITIVE-DATA-TYPE>
+  <SHORT-NAME>adt_Name</SHORT-NAME>
+  <CATEGORY>STRING</CATEGORY>
+  <SW-DATA-DEF-PROPS>
+    <SW-DATA-DEF-PROPS-VARIANTS>
+      <SW-DATA-DEF-PROPS-CONDITIONAL>
+        <SW-TEXT-PROPS>
+          <ARRAY-SIZE-SEMANTICS>`+semantics+`</ARRAY-SIZE-SEMANTICS>
+          <MAX-NUMBER-OF-CHARS>16</MAX-NUMBER-OF-CHARS>
+          <BASE-TYPE-REF DEST="SW-BASE-TYPE">/DataTypes/BaseTypes/`+baseType+`</BASE-TYPE-REF>
+        </SW-TEXT-PROPS>
+      </SW-DATA-DEF-PROPS-CONDITIONAL>
+    </SW-DATA-DEF-PROPS-VARIANTS>
+  </SW-DATA-DEF-PROPS>
+</APPLICATION-PRIMITIVE-DATA-TYPE>`))
+	return doc.Root()
+}
+
+func TestParseStringDataType(t *testing.T) {
+	testcases := []struct {
+		semantics  string
+		baseType   string
+		stringSize int64
+		encoding   ast.StringEncoding
+	}{
+		{"VARIABLE-SIZE", "utf_8", 0, ast.StringUTF8},
+		{"FIXED-SIZE", "utf_8", 20, ast.StringUTF8},
+		{"FIXED-SIZE", "utf_16", 36, ast.StringUTF16},
+		{"VARIABLE-SIZE", "MyUcs2", 0, ast.StringUTF16},
+	}
+	for _, tc := range testcases {
+		dp := NewDataTypesParser(map[string]string{}, nil)
+		bt, err := ast.NewBaseType("MyUcs2", 16, "UCS-2", "")
+		require.NoError(t, err)
+		dp.baseTypes.Register("/DataTypes/BaseTypes/MyUcs2", bt)
+		require.NoError(t, dp.ParseApplicationDataType(newStringDataTypeElement(t, tc.semantics, tc.baseType)))
+		dt, ok := dp.GetApplicationDataTypes()["/adt_Name"]
+		require.True(t, ok)
+		require.Equal(t, tc.stringSize, dt.StringSize, tc.baseType)
+		require.Equal(t, tc.encoding, dt.Encoding, tc.baseType)
+	}
+
+	dp := NewDataTypesParser(map[string]string{}, nil)
+	require.Error(t, dp.ParseApplicationDataType(newStringDataTypeElement(t, "FIXED-SIZE", "uint8")))
+}
+
+const implementationDataTypesXML = `<AR-PACKAGE>
+  <SHORT-NAME>DataTypes</SHORT-NAME>
+  <ELEMENTS>
+    <IMPLEMENTATION-DATA-TYPE>
+      <SHORT-NAME>Point</SHORT-NAME>
+      <CATEGORY>STRUCTURE</CATEGORY>
+      <SUB-ELEMENTS>
+        <IMPLEMENTATION-DATA-TYPE-ELEMENT>
+          <SHORT-NAME>x</SHORT-NAME>
+          <CATEGORY>VALUE</CATEGORY>
+          <SW-DATA-DEF-PROPS>
+            <SW-DATA-DEF-PROPS-VARIANTS>
+              <SW-DATA-DEF-PROPS-CONDITIONAL>
+                <BASE-TYPE-REF DEST="SW-BASE-TYPE">/AUTOSAR_Platform/BaseTypes/uint16</BASE-TYPE-REF>
+              </SW-DATA-DEF-PROPS-CONDITIONAL>
+            </SW-DATA-DEF-PROPS-VARIANTS>
+          </SW-DATA-DEF-PROPS>
+        </IMPLEMENTATION-DATA-TYPE-ELEMENT>
+        <IMPLEMENTATION-DATA-TYPE-ELEMENT>
+          <SHORT-NAME>tags</SHORT-NAME>
+          <CATEGORY>ARRAY</CATEGORY>
+          <SUB-ELEMENTS>
+            <IMPLEMENTATION-DATA-TYPE-ELEMENT>
+              <SHORT-NAME>tag</SHORT-NAME>
+              <SW-DATA-DEF-PROPS>
+                <SW-DATA-DEF-PROPS-VARIANTS>
+                  <SW-DATA-DEF-PROPS-CONDITIONAL>
+                    <IMPLEMENTATION-DATA-TYPE-REF DEST="IMPLEMENTATION-DATA-TYPE">/DataTypes/Byte</IMPLEMENTATION-DATA-TYPE-REF>
+                  </SW-DATA-DEF-PROPS-CONDITIONAL>
+                </SW-DATA-DEF-PROPS-VARIANTS>
+              </SW-DATA-DEF-PROPS>
+              <ARRAY-SIZE>4</ARRAY-SIZE>
+            </IMPLEMENTATION-DATA-TYPE-ELEMENT>
+          </SUB-ELEMENTS>
+        </IMPLEMENTATION-DATA-TYPE-ELEMENT>
+      </SUB-ELEMENTS>
+    </IMPLEMENTATION-DATA-TYPE>
+    <IMPLEMENTATION-DATA-TYPE>
+      <SHORT-NAME>Points</SHORT-NAME>
+      <CATEGORY>ARRAY</CATEGORY>
+      <SUB-ELEMENTS>
+        <IMPLEMENTATION-DATA-TYPE-ELEMENT>
+          <SHORT-NAME>point</SHORT-NAME>
+          <CATEGORY>TYPE_REFERENCE</CATEGORY>
+          <ARRAY-SIZE-SEMANTICS>VARIABLE-SIZE</ARRAY-SIZE-SEMANTICS>
+          <SW-DATA-DEF-PROPS>
+            <SW-DATA-DEF-PROPS-VARIANTS>
+              <SW-DATA-DEF-PROPS-CONDITIONAL>
+                <IMPLEMENTATION-DATA-TYPE-REF DEST="IMPLEMENTATION-DATA-TYPE">/DataTypes/PointAlias</IMPLEMENTATION-DATA-TYPE-REF>
+              </SW-DATA-DEF-PROPS-CONDITIONAL>
+            </SW-DATA-DEF-PROPS-VARIANTS>
+          </SW-DATA-DEF-PROPS>
+        </IMPLEMENTATION-DATA-TYPE-ELEMENT>
+      </SUB-ELEMENTS>
+    </IMPLEMENTATION-DATA-TYPE>
+    <IMPLEMENTATION-DATA-TYPE>
+      <SHORT-NAME>Byte</SHORT-NAME>
+      <CATEGORY>VALUE</CATEGORY>
+      <SW-DATA-DEF-PROPS>
+        <SW-DATA-DEF-PROPS-VARIANTS>
+          <SW-DATA-DEF-PROPS-CONDITIONAL>
+            <BASE-TYPE-REF DEST="SW-BASE-TYPE">/AUTOSAR_Platform/BaseTypes/uint8</BASE-TYPE-REF>
+          </SW-DATA-DEF-PROPS-CONDITIONAL>
+        </SW-DATA-DEF-PROPS-VARIANTS>
+      </SW-DATA-DEF-PROPS>
+    </IMPLEMENTATION-DATA-TYPE>
+    <IMPLEMENTATION-DATA-TYPE>
+      <SHORT-NAME>PointAlias</SHORT-NAME>
+      <CATEGORY>TYPE_REFERENCE</CATEGORY>
+      <SW-DATA-DEF-PROPS>
+        <SW-DATA-DEF-PROPS-VARIANTS>
+          <SW-DATA-DEF-PROPS-CONDITIONAL>
+            <IMPLEMENTATION-DATA-TYPE-REF DEST="IMPLEMENTATION-DATA-TYPE">/DataTypes/PointTypedef</IMPLEMENTATION-DATA-TYPE-REF>
+          </SW-DATA-DEF-PROPS-CONDITIONAL>
+        </SW-DATA-DEF-PROPS-VARIANTS>
+      </SW-DATA-DEF-PROPS>
+    </IMPLEMENTATION-DATA-TYPE>
+    <IMPLEMENTATION-DATA-TYPE>
+      <SHORT-NAME>PointTypedef</SHORT-NAME>
+      <CATEGORY>TYPE_REFERENCE</CATEGORY>
+      <SW-DATA-DEF-PROPS>
+        <SW-DATA-DEF-PROPS-VARIANTS>
+          <SW-DATA-DEF-PROPS-CONDITIONAL>
+            <IMPLEMENTATION-DATA-TYPE-REF DEST="IMPLEMENTATION-DATA-TYPE">/DataTypes/Point</IMPLEMENTATION-DATA-TYPE-REF>
+          </SW-DATA-DEF-PROPS-CONDITIONAL>
+        </SW-DATA-DEF-PROPS-VARIANTS>
+      </SW-DATA-DEF-PROPS>
+    </IMPLEMENTATION-DATA-TYPE>
+  </ELEMENTS>
+</AR-PACKAGE>`
+
+func parseImplementationDataTypesFromString(t *testing.T, s string) (*DataTypesParser, error) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(s))
+	index, err := util.NewArIndex(doc.Root())
+	require.NoError(t, err)
+	dp := NewDataTypesParser(map[string]string{}, index)
+	return dp, dp.parseImplementationDataTypes(doc.Root())
+}
+
+func TestParseImplementationDataTypes(t *testing.T) {
+	dp, err := parseImplementationDataTypesFromString(t, implementationDataTypesXML)
+	require.NoError(t, err)
+	dataTypes := dp.GetDataTypes()
+
+	point, ok := dataTypes["/DataTypes/Point"]
+	require.True(t, ok)
+	require.Equal(t, "STRUCTURE", point.Category)
+	require.Len(t, point.STRList, 2)
+	require.Equal(t, "/DataTypes/Point/x", point.STRList[0].Ref)
+	require.Equal(t, "/DataTypes/Point/tags", point.STRList[1].Ref)
+	require.Equal(t, ast.BasicUint16, dataTypes["/DataTypes/Point/x"].Kind)
+	tags := dataTypes["/DataTypes/Point/tags"]
+	require.Equal(t, "/DataTypes/Byte", tags.Array.RefType)
+	require.Equal(t, int64(4), tags.Array.ArraySize)
+
+	points, ok := dataTypes["/DataTypes/Points"]
+	require.True(t, ok)
+	require.Equal(t, "/DataTypes/PointAlias", points.Array.RefType)
+	require.Equal(t, int64(0), points.Array.ArraySize)
+
+	alias, ok := dataTypes["/DataTypes/PointAlias"]
+	require.True(t, ok)
+	require.Equal(t, "PointAlias", alias.ShorName)
+	require.Equal(t, point.Structure, alias.Structure)
+
+	cyclic := strings.ReplaceAll(implementationDataTypesXML, "/DataTypes/Point</IMPLEMENTATION-DATA-TYPE-REF>", "/DataTypes/PointAlias</IMPLEMENTATION-DATA-TYPE-REF>")
+	_, err = parseImplementationDataTypesFromString(t, cyclic)
+	require.ErrorContains(t, err, "cyclic TYPE_REFERENCE")
+}
+
+const unsupportedBaseTypesXML = `<AR-PACKAGE>
+  <SHORT-NAME>DataTypes</SHORT-NAME>
+  <ELEMENTS>
+    <SW-BASE-TYPE>
+      <SHORT-NAME>uint8</SHORT-NAME>
+      <BASE-TYPE-SIZE>8</BASE-TYPE-SIZE>
+      <BASE-TYPE-ENCODING>NONE</BASE-TYPE-ENCODING>
+    </SW-BASE-TYPE>
+    <SW-BASE-TYPE>
+      <SHORT-NAME>sint24</SHORT-NAME>
+      <BASE-TYPE-SIZE>24</BASE-TYPE-SIZE>
+      <BASE-TYPE-ENCODING>2C</BASE-TYPE-ENCODING>
+    </SW-BASE-TYPE>
+  </ELEMENTS>
+</AR-PACKAGE>`
+
+func TestParseUnsupportedBaseTypes(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(unsupportedBaseTypesXML))
+	dp := NewDataTypesParser(map[string]string{}, nil)
+	require.NoError(t, dp.ParseBaseTypes(doc.Root()))
+
+	kind, err := dp.resolveBaseType("/DataTypes/uint8")
+	require.NoError(t, err)
+	require.Equal(t, ast.BasicUint8, kind)
+
+	_, err = dp.resolveBaseType("/DataTypes/sint24")
+	require.Error(t, err)
+}
@@ -2,7 +2,6 @@ package parser
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/beevik/etree"
 	idlAst "github.com/yisaer/idl-parser/ast"
@@ -16,18 +15,18 @@ import (
 	"github.com/yisaer/arxml-converter/cp/parser/system"
 	"github.com/yisaer/arxml-converter/cp/parser/topology"
 	"github.com/yisaer/arxml-converter/cp/parser/tpConfig"
+	"github.com/yisaer/arxml-converter/e2e"
+	"github.com/yisaer/arxml-converter/util"
 )
 
 type Parser struct {
-	Path                       string
-	Doc                        *etree.Document
-	dataTypesElement           *etree.Element
-	dataTypeMappingSetsElement *etree.Element
-	topologyElement            *etree.Element
-	communicationElement       *etree.Element
-	systemElement              *etree.Element
-	softwareTypesElement       *etree.Element
-	tpConfigElement            *etree.Element
+	Path                 string
+	Doc                  *etree.Document
+	dataTypesElement     *etree.Element
+	topologyElement      *etree.Element
+	communicationElement *etree.Element
+	softwareTypesElement *etree.Element
+	tpConfigElement      *etree.Element
 
 	dataTypesParser     *datatypes.DataTypesParser
 	topologyParser      *topology.TopoLogyParser
@@ -36,10 +35,16 @@ type Parser struct {
 	softwareTypesParser *softwareTypes.SoftwareTypesParser
 	tpConfigParser      *tpConfig.TpConfigParser
 
-	dataTypeMappings map[string]string
+	// dataTypeMappings 为 application data type 到 implementation data type 的 AR 路径映射
+	dataTypeMappings         map[string]string
+	dataTypeMappingConflicts []*DataTypeMappingConflict
+	// component 为选择 DATA-TYPE-MAPPING-SET 的 software component, 为空时使用全部 mapping set 的合并结果
+	component string
+	index     *util.ArIndex
 
 	transformer *ast.TransformHelper
 	idlModule   *idlAst.Module
+	resolutions *ResolutionTable
 }
 
 func NewParserWithDoc(doc *etree.Document) *Parser {
@@ -63,6 +68,16 @@ func NewParser(path string) (*Parser, error) {
 	return p, nil
 }
 
+// SetSoftwareComponent 以 software component 的 AR 路径或 SHORT-NAME 选择其 DATA-TYPE-MAPPING-REFS 引用的 mapping set, 需在 Parse 前调用
+func (p *Parser) SetSoftwareComponent(component string) {
+	p.component = component
+}
+
+// GetDataTypeMappingConflicts 返回合并 DATA-TYPE-MAPPING-SET 时发现的冲突
+func (p *Parser) GetDataTypeMappingConflicts() []*DataTypeMappingConflict {
+	return p.dataTypeMappingConflicts
+}
+
 func (p *Parser) Parse() error {
 	autosar := p.Doc.SelectElement("AUTOSAR")
 	if autosar == nil {
@@ -73,47 +88,65 @@ func (p *Parser) Parse() error {
 		return fmt.Errorf("no AR-PACKAGES found")
 	}
 
+	index, err := util.NewArIndex(autosar)
+	if err != nil {
+		return fmt.Errorf("index ar packages: %w", err)
+	}
+	p.index = index
 	if err := p.search(arPackages); err != nil {
 		return err
 	}
 	if err := p.parse(); err != nil {
 		return err
 	}
-	p.transformer = ast.NewTransformHelper(p.dataTypesParser.GetApplicationDataTypes())
+	p.transformer = ast.NewTransformHelper(p.dataTypesParser.GetDataTypes())
+	p.transformer.SetBaseTypes(p.dataTypesParser.GetBaseTypes())
 	m, err := p.transformer.TransformIntoModule()
 	if err != nil {
 		return fmt.Errorf("transform error: %s", err)
 	}
 	p.idlModule = m
+	p.resolutions = p.buildResolutionTable()
 	return nil
 }
 
 func (p *Parser) parse() error {
-	if err := p.parseDataTypeMappingSets(p.dataTypeMappingSetsElement); err != nil {
+	if err := p.parseDataTypeMappingSets(p.Doc.Root()); err != nil {
 		return fmt.Errorf("parse dataTypeMappingSets: %w", err)
 	}
-	p.dataTypesParser = datatypes.NewDataTypesParser(p.dataTypeMappings)
+	p.dataTypesParser = datatypes.NewDataTypesParser(p.dataTypeMappings, p.index)
+	tlvDataIDs, err := util.ParseTLVDataIDs(p.Doc.Root())
+	if err != nil {
+		return fmt.Errorf("parse TLV data ids: %w", err)
+	}
+	p.dataTypesParser.SetTLVDataIDs(tlvDataIDs)
+	if err := p.dataTypesParser.ParseBaseTypes(p.Doc.Root()); err != nil {
+		return fmt.Errorf("parse base types: %w", err)
+	}
+	if err := p.dataTypesParser.ParseCompuMethods(p.Doc.Root()); err != nil {
+		return fmt.Errorf("parse compu methods: %w", err)
+	}
 	if err := p.dataTypesParser.ParseDataTypes(p.dataTypesElement); err != nil {
 		return fmt.Errorf("parse dataTypes: %w", err)
 	}
-	p.topologyParser = topology.NewTopoLogyParser()
+	p.topologyParser = topology.NewTopoLogyParser(p.index)
 	if err := p.topologyParser.ParseTopoLogy(p.topologyElement); err != nil {
 		return fmt.Errorf("parse topology: %w", err)
 	}
-	p.communicationParser = communication.NewCommunicationParser()
+	p.communicationParser = communication.NewCommunicationParser(p.index)
 	if err := p.communicationParser.ParseCommunication(p.communicationElement); err != nil {
 		return fmt.Errorf("parse communication: %w", err)
 	}
-	p.systemParser = system.NewSystemParser()
-	if err := p.systemParser.ParseSystem(p.systemElement); err != nil {
+	p.systemParser = system.NewSystemParser(p.index)
+	if err := p.systemParser.ParseSystem(p.Doc.Root()); err != nil {
 		return fmt.Errorf("parse system: %w", err)
 	}
-	p.softwareTypesParser = softwareTypes.NewSoftwareTypesParser()
+	p.softwareTypesParser = softwareTypes.NewSoftwareTypesParser(p.index)
 	if err := p.softwareTypesParser.ParseSoftwareTypes(p.softwareTypesElement); err != nil {
 		return fmt.Errorf("parse softwareTypes: %w", err)
 	}
 	if p.tpConfigElement != nil {
-		p.tpConfigParser = tpConfig.NewTpConfigParser()
+		p.tpConfigParser = tpConfig.NewTpConfigParser(p.index)
 		if err := p.tpConfigParser.ParseTpConfig(p.tpConfigElement); err != nil {
 			return fmt.Errorf("parse tpConfig: %w", err)
 		}
@@ -122,73 +155,266 @@ func (p *Parser) parse() error {
 }
 
 func (p *Parser) FindTypeRefByID(serviceID uint16, headerID uint32) (string, typeref.TypeRef, error) {
-	serviceIDMap := p.topologyParser.GetServiceIDMap()
-	_, ok := serviceIDMap[serviceID]
-	if !ok {
-		return "", nil, fmt.Errorf("no service found for %d", serviceID)
+	name, _, tr, err := p.FindDataTypeByID(serviceID, headerID)
+	return name, tr, err
+}
+
+// FindDataTypeByID 返回 data type 的 SHORT-NAME, AR 路径与对应的 TypeRef
+func (p *Parser) FindDataTypeByID(serviceID uint16, headerID uint32) (string, string, typeref.TypeRef, error) {
+	return p.FindDataTypeByVersion(serviceID, ast.AnyMajorVersion, headerID)
+}
+
+// FindDataTypeByVersion 与 FindDataTypeByID 相同, 并校验 service 的 interface major version
+func (p *Parser) FindDataTypeByVersion(serviceID uint16, majorVersion int, headerID uint32) (string, string, typeref.TypeRef, error) {
+	return p.FindDataTypeOnChannel("", serviceID, majorVersion, headerID)
+}
+
+// FindDataTypeOnChannel 与 FindDataTypeByVersion 相同, 只在 channel 对应的物理通道中查找 header id,
+// channel 为空时在全部物理通道中查找
+func (p *Parser) FindDataTypeOnChannel(channel string, serviceID uint16, majorVersion int, headerID uint32) (string, string, typeref.TypeRef, error) {
+	r, err := p.Resolve(channel, serviceID, majorVersion, headerID, false)
+	if err != nil {
+		return "", "", nil, err
 	}
-	PDUTRIGGERINGREF, err := p.getPDUTRIGGERINGREFByHeaderID(headerID)
+	return r.GetDataType()
+}
+
+// FindOperation 返回 header id 对应的 client/server operation 与 request 或 response 所在 I-SIGNAL 的 AR 路径,
+// header id 对应 event 时 operation 为 nil
+func (p *Parser) FindOperation(serviceID uint16, majorVersion int, headerID uint32, response bool) (*softwareTypes.Operation, string, error) {
+	return p.FindOperationOnChannel("", serviceID, majorVersion, headerID, response)
+}
+
+// FindOperationOnChannel 与 FindOperation 相同, 只在 channel 对应的物理通道中查找 header id
+func (p *Parser) FindOperationOnChannel(channel string, serviceID uint16, majorVersion int, headerID uint32, response bool) (*softwareTypes.Operation, string, error) {
+	r, err := p.Resolve(channel, serviceID, majorVersion, headerID, response)
 	if err != nil {
-		return "", nil, err
+		return nil, "", err
 	}
-	ISignalIPDUShortName := PDUTRIGGERINGREF
-	tpSDURef, ok := p.getTpSDURefByPDUTRIGGERINGREF(PDUTRIGGERINGREF)
-	if ok {
-		ISignalIPDUShortName = tpSDURef
+	operation, err := r.GetOperation()
+	if err != nil {
+		return nil, "", err
 	}
-	ISIGNALREF, err := p.getISignalRefByISignalIPDU(ISignalIPDUShortName)
+	return operation, r.GetISignalRef(), nil
+}
+
+// FindE2EConfig 返回 header id 对应的 I-SIGNAL 的 AR 路径与 E2E 配置, 未配置 E2E 保护时配置为 nil
+func (p *Parser) FindE2EConfig(headerID uint32) (string, *e2e.Config, error) {
+	return p.FindE2EConfigOnChannel("", headerID)
+}
+
+// FindE2EConfigOnChannel 与 FindE2EConfig 相同, 只在 channel 对应的物理通道中查找 header id
+func (p *Parser) FindE2EConfigOnChannel(channel string, headerID uint32) (string, *e2e.Config, error) {
+	r, err := p.resolveHeader(channel, ast.AnyMajorVersion, headerID, false)
 	if err != nil {
 		return "", nil, err
 	}
-	communicationPDURefMap := p.communicationParser.GetPduRefMap()
-	communicationPduRef, ok := communicationPDURefMap[extractLast(ISIGNALREF)]
-	if !ok {
-		return "", nil, fmt.Errorf("no pdu triggering ref for %v", ISIGNALREF)
+	return r.GetISignalRef(), r.GetE2EConfig(), nil
+}
+
+// GetE2EConfig 返回 I-SIGNAL 的 E2E 配置, 未配置 E2E 保护时返回 nil
+func (p *Parser) GetE2EConfig(iSignalRef string) *e2e.Config {
+	return p.communicationParser.GetE2EProtections()[iSignalRef]
+}
+
+// getISignalRefByHeaderID 返回 header id 对应的 I-SIGNAL, response 为 true 时选择 RETURN-SIGNAL 所在的 PDU
+func (p *Parser) getISignalRefByHeaderID(channel string, majorVersion int, headerID uint32, response bool) (string, error) {
+	iPDURef, err := p.getIPDURefByHeaderID(channel, majorVersion, headerID, response)
+	if err != nil {
+		return "", err
 	}
-	systemSignalRef, ok := p.communicationParser.GetSignalRefMap()[extractLast(communicationPduRef)]
-	if !ok {
-		return "", nil, fmt.Errorf("no signal ref for %v", communicationPduRef)
+	return p.communicationParser.GetPduRefMap()[iPDURef], nil
+}
+
+// getIPDURefByHeaderID 返回 header id 对应的 I-SIGNAL-I-PDU, 包含 RETURN-SIGNAL 的 PDU 为 response
+func (p *Parser) getIPDURefByHeaderID(channel string, majorVersion int, headerID uint32, response bool) (string, error) {
+	pduTriggeringRefs, err := p.topologyParser.LookupHeader(channel, majorVersion, headerID)
+	if err != nil {
+		return "", err
+	}
+	var firstErr error
+	for _, pduTriggeringRef := range pduTriggeringRefs {
+		iPDURef, err := p.getIPDURefByPDUTriggeringRef(pduTriggeringRef)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if p.isResponsePDU(iPDURef) == response {
+			return iPDURef, nil
+		}
+	}
+	if firstErr != nil {
+		return "", firstErr
 	}
+	if response {
+		return "", fmt.Errorf("no response pdu for header %d", headerID)
+	}
+	return "", fmt.Errorf("no request pdu for header %d", headerID)
+}
 
-	find := false
-	operationRef := ""
-	orMap := p.systemParser.GetOperationRef()
-	for k, v := range orMap {
-		if k == systemSignalRef {
-			find = true
-			operationRef = v
+func (p *Parser) isResponsePDU(iPDURef string) bool {
+	for _, mapping := range p.communicationParser.GetPduSignals()[iPDURef] {
+		systemSignalRef := p.communicationParser.GetSignalRefMap()[mapping.ISignalRef]
+		if !mapping.IsGroup && p.systemParser.IsReturnSignal(systemSignalRef) {
+			return true
 		}
 	}
-	if !find {
-		return "", nil, fmt.Errorf("no operation ref for %v", communicationPduRef)
+	return false
+}
+
+func (p *Parser) getIPDURefByPDUTriggeringRef(pduTriggeringRef string) (string, error) {
+	if tpSDURef, ok := p.getTpSDURefByPDUTRIGGERINGREF(pduTriggeringRef); ok {
+		pduTriggeringRef = tpSDURef
+	}
+	iPDURef, err := p.getIPDURefByPDUTriggering(pduTriggeringRef)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := p.communicationParser.GetPduRefMap()[iPDURef]; !ok {
+		return "", fmt.Errorf("no pdu triggering ref for %v", iPDURef)
+	}
+	return iPDURef, nil
+}
+
+// FindPDUSignals 返回 header id 对应的 I-SIGNAL-I-PDU 的 AR 路径与其中全部的 signal mapping
+func (p *Parser) FindPDUSignals(serviceID uint16, majorVersion int, headerID uint32, response bool) (string, []*communication.SignalMapping, error) {
+	if _, err := p.topologyParser.LookupService(serviceID, majorVersion); err != nil {
+		return "", nil, err
 	}
-	InterfaceRefMap := p.softwareTypesParser.GetInterfaceRefMap()
-	csiKey, csoKey, err := extractLast2(operationRef)
+	iPDURef, err := p.getIPDURefByHeaderID("", majorVersion, headerID, response)
 	if err != nil {
 		return "", nil, err
 	}
-	csoMap, ok := InterfaceRefMap[csiKey]
+	return iPDURef, p.communicationParser.GetPduSignals()[iPDURef], nil
+}
+
+// ResolveSignalType 返回 I-SIGNAL 的数据类型的 AR 路径
+func (p *Parser) ResolveSignalType(iSignalRef string) (string, error) {
+	operationRef, err := p.getOperationRefByISignal(iSignalRef)
+	if err != nil {
+		return "", err
+	}
+	tRef, ok := p.softwareTypesParser.GetInterfaceRefMap()[operationRef]
 	if !ok {
-		return "", nil, fmt.Errorf("no interface ref for %v", operationRef)
+		return "", fmt.Errorf("no interface ref for %v", operationRef)
 	}
-	tRef, ok := csoMap[csoKey]
+	return tRef, nil
+}
+
+// ResolveSignalOperation 返回 I-SIGNAL 承载的 client/server operation 以及该 I-SIGNAL 是否为 response,
+// I-SIGNAL 承载 data element 时 operation 为 nil
+func (p *Parser) ResolveSignalOperation(iSignalRef string) (*softwareTypes.Operation, bool, error) {
+	operationRef, err := p.getOperationRefByISignal(iSignalRef)
+	if err != nil {
+		return nil, false, err
+	}
+	systemSignalRef := p.communicationParser.GetSignalRefMap()[iSignalRef]
+	return p.softwareTypesParser.GetOperations()[operationRef], p.systemParser.IsReturnSignal(systemSignalRef), nil
+}
+
+// GetSignalLength 返回 I-SIGNAL 的 LENGTH (bit), 未配置时返回 false
+func (p *Parser) GetSignalLength(iSignalRef string) (int, bool) {
+	length, ok := p.communicationParser.GetSignalLengths()[iSignalRef]
+	return length, ok
+}
+
+// GetPDUSignals 返回 I-SIGNAL-I-PDU 中按 START-POSITION 排列的 signal mapping
+func (p *Parser) GetPDUSignals(pduRef string) ([]*communication.SignalMapping, error) {
+	mappings, ok := p.communicationParser.GetPduSignals()[pduRef]
 	if !ok {
-		return "", nil, fmt.Errorf("no interface ref for %v", operationRef)
+		return nil, fmt.Errorf("no I-SIGNAL-I-PDU %v found", pduRef)
 	}
-	tr, ok := p.transformer.GetConverterRef()[strings.ToLower(extractLast(tRef))]
+	return mappings, nil
+}
+
+// GetPDULength 返回 I-PDU 的 LENGTH (字节), 未配置时返回 -1
+func (p *Parser) GetPDULength(pduRef string) int {
+	length, ok := p.communicationParser.GetPduLengths()[pduRef]
 	if !ok {
-		return "", nil, fmt.Errorf("no converter ref for %v", tRef)
+		return -1
 	}
-	return extractLast(tRef), tr, nil
+	return length
+}
+
+// GetContainerPDU 返回 AR 路径对应的 CONTAINER-I-PDU
+func (p *Parser) GetContainerPDU(pduRef string) (*communication.ContainerPDU, bool) {
+	container, ok := p.communicationParser.GetContainerPDUs()[pduRef]
+	return container, ok
+}
+
+// GetMultiplexedPDU 返回 AR 路径对应的 MULTIPLEXED-I-PDU
+func (p *Parser) GetMultiplexedPDU(pduRef string) (*communication.MultiplexedPDU, bool) {
+	multiplexed, ok := p.communicationParser.GetMultiplexedPDUs()[pduRef]
+	return multiplexed, ok
 }
 
-func (p *Parser) getPDUTRIGGERINGREFByHeaderID(headerID uint32) (string, error) {
-	headerIDMap := p.topologyParser.GetHeaderRef()
-	pduRef, ok := headerIDMap[headerID]
+// GetSecuredPDU 返回 AR 路径对应的 SECURED-I-PDU
+func (p *Parser) GetSecuredPDU(pduRef string) (*communication.SecuredPDU, bool) {
+	secured, ok := p.communicationParser.GetSecuredPDUs()[pduRef]
+	return secured, ok
+}
+
+// GetSignalRepresentation 返回 I-SIGNAL 在总线上的 base type 名称, 基础类型与 compu method,
+// 未配置 base type 时名称与类型为空, 未配置 compu method 时为 nil
+func (p *Parser) GetSignalRepresentation(iSignalRef string) (string, ast.BasicKind, *ast.CompuMethod) {
+	representation, ok := p.communicationParser.GetSignalRepresentations()[iSignalRef]
 	if !ok {
-		return "", fmt.Errorf("no header ref for %d", headerID)
+		return "", "", nil
+	}
+	var name string
+	var kind ast.BasicKind
+	if representation.BaseTypeRef != "" {
+		name = util.ExtractLast(representation.BaseTypeRef)
+		if bt, ok := p.dataTypesParser.GetBaseTypes().Lookup(representation.BaseTypeRef); ok {
+			kind = bt.Kind
+		} else if standard, ok := ast.GetStandardBasicKind(representation.BaseTypeRef); ok {
+			kind = standard
+		}
 	}
-	return pduRef, nil
+	compuMethod, _ := p.dataTypesParser.GetCompuMethods().Lookup(representation.CompuMethodRef)
+	return name, kind, compuMethod
+}
+
+// LookupFrame 返回 CAN/LIN/FlexRay 物理通道上 identifier 对应的 frame triggering
+func (p *Parser) LookupFrame(channel string, identifier uint32) (*topology.FrameTriggering, error) {
+	return p.topologyParser.LookupFrame(channel, identifier)
+}
+
+// GetSignalGroupMembers 返回 I-SIGNAL-GROUP 的成员 I-SIGNAL
+func (p *Parser) GetSignalGroupMembers(iSignalGroupRef string) []string {
+	return p.communicationParser.GetSignalGroups()[iSignalGroupRef]
+}
+
+func (p *Parser) getOperationRefByISignal(iSignalRef string) (string, error) {
+	systemSignalRef, ok := p.communicationParser.GetSignalRefMap()[iSignalRef]
+	if !ok {
+		return "", fmt.Errorf("no signal ref for %v", iSignalRef)
+	}
+	operationRef, ok := p.systemParser.GetOperationRef()[systemSignalRef]
+	if !ok {
+		return "", fmt.Errorf("no operation ref for %v", iSignalRef)
+	}
+	return operationRef, nil
+}
+
+// GetTpConnection 返回物理通道 channel 中 header id 对应的 SOMEIP-TP-CONNECTION, header id 不使用 SOME/IP-TP 时返回 false.
+// channel 为空时 header id 需只位于一个物理通道, majorVersion 用于区分不同版本 service 的相同 header id
+func (p *Parser) GetTpConnection(channel string, majorVersion int, headerID uint32) (*tpConfig.Connection, bool, error) {
+	if p.tpConfigParser == nil {
+		return nil, false, nil
+	}
+	pduTriggeringRefs, err := p.topologyParser.LookupHeader(channel, majorVersion, headerID)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, pduTriggeringRef := range pduTriggeringRefs {
+		if connection, ok := p.tpConfigParser.GetConnections()[pduTriggeringRef]; ok {
+			return connection, true, nil
+		}
+	}
+	return nil, false, nil
 }
 
 func (p *Parser) getTpSDURefByPDUTRIGGERINGREF(PDUTRIGGERINGREF string) (string, bool) {
@@ -199,33 +425,24 @@ func (p *Parser) getTpSDURefByPDUTRIGGERINGREF(PDUTRIGGERINGREF string) (string,
 	return got, ok
 }
 
-func (p *Parser) getISignalRefByISignalIPDU(shortname string) (string, error) {
-	pduTriggeringRef := p.topologyParser.GetPDUTriggeringRef()
-	ISIGNALREF, ok := pduTriggeringRef[extractLast(shortname)]
+func (p *Parser) getIPDURefByPDUTriggering(pduTriggeringRef string) (string, error) {
+	iPDURef, ok := p.topologyParser.GetPDUTriggeringRef()[pduTriggeringRef]
 	if !ok {
-		return "", fmt.Errorf("no pdu triggered for %v", shortname)
+		return "", fmt.Errorf("no pdu triggered for %v", pduTriggeringRef)
 	}
-	return ISIGNALREF, nil
+	return iPDURef, nil
 }
 
-func (p *Parser) GetModule() *idlAst.Module {
-	return p.idlModule
+func (p *Parser) GetEventGroups() *ast.EventGroupRegistry {
+	return p.topologyParser.GetEventGroups()
 }
 
-func extractLast(ref string) string {
-	parts := strings.Split(ref, "/")
-	if len(parts) > 0 {
-		return parts[len(parts)-1]
-	}
-	return ref
+func (p *Parser) GetModule() *idlAst.Module {
+	return p.idlModule
 }
 
-func extractLast2(ref string) (string, string, error) {
-	parts := strings.Split(ref, "/")
-	if len(parts) > 1 {
-		return parts[len(parts)-2], parts[len(parts)-1], nil
-	}
-	return "", "", fmt.Errorf("no last 2 element in %v", ref)
+func (p *Parser) GetTransformer() *ast.TransformHelper {
+	return p.transformer
 }
 
 func (p *Parser) GetTargetStruct(target string) *struct_type.Struct {
@@ -239,3 +456,13 @@ func (p *Parser) GetTargetStruct(target string) *struct_type.Struct {
 	}
 	return nil
 }
+
+// LookupServiceInstance 返回 service instance 的 SHORT-NAME
+func (p *Parser) LookupServiceInstance(serviceID, instanceID uint16) (string, bool) {
+	return p.topologyParser.LookupServiceInstance(serviceID, instanceID)
+}
+
+// LookupService 返回提供该 service 的 PROVIDED-SERVICE-INSTANCE 的 SHORT-NAME
+func (p *Parser) LookupService(serviceID uint16, majorVersion int) (string, error) {
+	return p.topologyParser.LookupService(serviceID, majorVersion)
+}
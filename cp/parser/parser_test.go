@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/beevik/etree"
 	"github.com/stretchr/testify/require"
 
 	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/cp/parser/system"
 )
 
 func TestParser(t *testing.T) {
@@ -26,3 +28,246 @@ func TestParser(t *testing.T) {
 	fmt.Println(p.systemParser.GetOperationRef())
 	fmt.Println(p.softwareTypesParser.GetInterfaceRefMap())
 }
+
+func TestDataTypeMappingSets(t *testing.T) {
+	newParser := func(t *testing.T, component string) *Parser {
+		doc := etree.NewDocument()
+		require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+		idts := doc.FindElement("//AR-PACKAGE[SHORT-NAME='ImplementationDataTypes']/ELEMENTS")
+		require.NotNil(t, idts)
+		idt := etree.NewDocument()
+		require.NoError(t, idt.ReadFromString(`<IMPLEMENTATION-DATA-TYPE>
+	<SHORT-NAME>WiFiSwitchStatus_u8</SHORT-NAME>
+	<CATEGORY>VALUE</CATEGORY>
+	<SW-DATA-DEF-PROPS>
+		<SW-DATA-DEF-PROPS-VARIANTS>
+			<SW-DATA-DEF-PROPS-CONDITIONAL>
+				<BASE-TYPE-REF DEST="SW-BASE-TYPE">/DataTypes/BaseTypes/uint8</BASE-TYPE-REF>
+			</SW-DATA-DEF-PROPS-CONDITIONAL>
+		</SW-DATA-DEF-PROPS-VARIANTS>
+	</SW-DATA-DEF-PROPS>
+</IMPLEMENTATION-DATA-TYPE>`))
+		idts.AddChild(idt.Root())
+		pkg := etree.NewDocument()
+		require.NoError(t, pkg.ReadFromString(`<AR-PACKAGE>
+	<SHORT-NAME>ConsumerMappings</SHORT-NAME>
+	<ELEMENTS>
+		<DATA-TYPE-MAPPING-SET>
+			<SHORT-NAME>Consumer_Type_Mappings</SHORT-NAME>
+			<DATA-TYPE-MAPS>
+				<DATA-TYPE-MAP>
+					<APPLICATION-DATA-TYPE-REF DEST="APPLICATION-PRIMITIVE-DATA-TYPE">/DataTypes/ApplicationDataType/adt_WiFiSwitchStatus</APPLICATION-DATA-TYPE-REF>
+					<IMPLEMENTATION-DATA-TYPE-REF DEST="IMPLEMENTATION-DATA-TYPE">/DataTypes/ImplementationDataTypes/WiFiSwitchStatus_u8</IMPLEMENTATION-DATA-TYPE-REF>
+				</DATA-TYPE-MAP>
+			</DATA-TYPE-MAPS>
+		</DATA-TYPE-MAPPING-SET>
+	</ELEMENTS>
+</AR-PACKAGE>`))
+		doc.FindElement("//AUTOSAR/AR-PACKAGES").AddChild(pkg.Root())
+		behavior := etree.NewDocument()
+		require.NoError(t, behavior.ReadFromString(`<INTERNAL-BEHAVIORS>
+	<SWC-INTERNAL-BEHAVIOR>
+		<SHORT-NAME>INI_WiFiStation_1_Consumer_Behavior</SHORT-NAME>
+		<DATA-TYPE-MAPPING-REFS>
+			<DATA-TYPE-MAPPING-REF DEST="DATA-TYPE-MAPPING-SET">/ConsumerMappings/Consumer_Type_Mappings</DATA-TYPE-MAPPING-REF>
+		</DATA-TYPE-MAPPING-REFS>
+	</SWC-INTERNAL-BEHAVIOR>
+</INTERNAL-BEHAVIORS>`))
+		consumer := doc.FindElement("//APPLICATION-SW-COMPONENT-TYPE[SHORT-NAME='INI_WiFiStation_1_Consumer']")
+		require.NotNil(t, consumer)
+		consumer.AddChild(behavior.Root())
+		p := NewParserWithDoc(doc)
+		p.SetSoftwareComponent(component)
+		require.NoError(t, p.Parse())
+		return p
+	}
+	const adt = "/DataTypes/ApplicationDataType/adt_WiFiSwitchStatus"
+
+	// 未选择 software component 时保留先出现的 Data_Type_Mappings, 冲突被报告
+	p := newParser(t, "")
+	require.Equal(t, "/DataTypes/ImplementationDataTypes/WiFiSwitchStatus", p.dataTypeMappings[adt])
+	require.Equal(t, ast.BasicInt32, p.dataTypesParser.GetApplicationDataTypes()[adt].Kind)
+	require.Equal(t, []*DataTypeMappingConflict{{
+		ApplicationDataType:       adt,
+		MappingSet:                "/DataTypeMappingSets/Data_Type_Mappings",
+		ImplementationDataType:    "/DataTypes/ImplementationDataTypes/WiFiSwitchStatus",
+		ConflictingSet:            "/ConsumerMappings/Consumer_Type_Mappings",
+		ConflictingImplementation: "/DataTypes/ImplementationDataTypes/WiFiSwitchStatus_u8",
+	}}, p.GetDataTypeMappingConflicts())
+
+	for _, component := range []string{"INI_WiFiStation_1_Consumer", "/SoftwareTypes/ComponentTypes/INI_WiFiStation_1_Consumer"} {
+		p = newParser(t, component)
+		require.Equal(t, "/DataTypes/ImplementationDataTypes/WiFiSwitchStatus_u8", p.dataTypeMappings[adt])
+		require.Equal(t, ast.BasicUint8, p.dataTypesParser.GetApplicationDataTypes()[adt].Kind)
+		// 其余 application data type 仍使用合并结果
+		require.Equal(t, "/DataTypes/ImplementationDataTypes/WiFiApList", p.dataTypeMappings["/DataTypes/ApplicationDataType/adt_WiFiApList"])
+	}
+	// 未引用 mapping set 的 software component 使用合并结果
+	p = newParser(t, "INI_WiFiStation_1_Provider")
+	require.Equal(t, "/DataTypes/ImplementationDataTypes/WiFiSwitchStatus", p.dataTypeMappings[adt])
+
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	p = NewParserWithDoc(doc)
+	p.SetSoftwareComponent("INI_WiFiStation_1_Unknown")
+	require.Error(t, p.Parse())
+}
+
+func TestParseSystems(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromFile("../../test/s1_cp_test.xml"))
+	pkg := etree.NewDocument()
+	require.NoError(t, pkg.ReadFromString(`<AR-PACKAGE>
+	<SHORT-NAME>EcuExtract</SHORT-NAME>
+	<ELEMENTS>
+		<SYSTEM>
+			<SHORT-NAME>CDC_EcuExtract</SHORT-NAME>
+			<CATEGORY>ECU_EXTRACT</CATEGORY>
+			<MAPPINGS>
+				<SYSTEM-MAPPING>
+					<SHORT-NAME>EventMappings</SHORT-NAME>
+					<DATA-MAPPINGS>
+						<SENDER-RECEIVER-TO-SIGNAL-MAPPING>
+							<DATA-ELEMENT-IREF>
+								<TARGET-DATA-PROTOTYPE-REF DEST="VARIABLE-DATA-PROTOTYPE">/SoftwareTypes/Interfaces/INI_WiFiStation_reportWiFiApList/WiFiApList_param</TARGET-DATA-PROTOTYPE-REF>
+							</DATA-ELEMENT-IREF>
+							<SYSTEM-SIGNAL-REF DEST="SYSTEM-SIGNAL">/Communication/SystemSignals/SysSig_Event_reportWiFiApList_INI_WiFiStation_1</SYSTEM-SIGNAL-REF>
+						</SENDER-RECEIVER-TO-SIGNAL-MAPPING>
+					</DATA-MAPPINGS>
+				</SYSTEM-MAPPING>
+				<SYSTEM-MAPPING>
+					<SHORT-NAME>GroupMappings</SHORT-NAME>
+					<DATA-MAPPINGS>
+						<SENDER-RECEIVER-TO-SIGNAL-GROUP-MAPPING>
+							<DATA-ELEMENT-IREF>
+								<TARGET-DATA-PROTOTYPE-REF DEST="VARIABLE-DATA-PROTOTYPE">/SoftwareTypes/Interfaces/INI_WiFiStation_reportWiFiApInfo/WiFiApInfo_param</TARGET-DATA-PROTOTYPE-REF>
+							</DATA-ELEMENT-IREF>
+							<SIGNAL-GROUP-REF DEST="SYSTEM-SIGNAL-GROUP">/Communication/SystemSignals/SysSigGroup_WiFiApInfo</SIGNAL-GROUP-REF>
+							<TYPE-MAPPING>
+								<SENDER-REC-RECORD-TYPE-MAPPING>
+									<RECORD-ELEMENT-MAPPINGS>
+										<SENDER-REC-RECORD-ELEMENT-MAPPING>
+											<APPLICATION-RECORD-ELEMENT-REF DEST="APPLICATION-RECORD-ELEMENT">/DataTypes/ApplicationDataType/adt_WiFiApInfo/wiFiApName</APPLICATION-RECORD-ELEMENT-REF>
+											<SYSTEM-SIGNAL-REF DEST="SYSTEM-SIGNAL">/Communication/SystemSignals/SysSig_WiFiApName</SYSTEM-SIGNAL-REF>
+										</SENDER-REC-RECORD-ELEMENT-MAPPING>
+										<SENDER-REC-RECORD-ELEMENT-MAPPING>
+											<APPLICATION-RECORD-ELEMENT-REF DEST="APPLICATION-RECORD-ELEMENT">/DataTypes/ApplicationDataType/adt_WiFiApInfo/wiFiQuality</APPLICATION-RECORD-ELEMENT-REF>
+											<TYPE-MAPPING>
+												<SENDER-REC-RECORD-TYPE-MAPPING>
+													<RECORD-ELEMENT-MAPPINGS>
+														<SENDER-REC-RECORD-ELEMENT-MAPPING>
+															<IMPLEMENTATION-RECORD-ELEMENT-REF DEST="IMPLEMENTATION-DATA-TYPE-ELEMENT">/DataTypes/ImplementationDataTypes/WiFiQuality/strength</IMPLEMENTATION-RECORD-ELEMENT-REF>
+															<SYSTEM-SIGNAL-REF DEST="SYSTEM-SIGNAL">/Communication/SystemSignals/SysSig_WiFiStrength</SYSTEM-SIGNAL-REF>
+														</SENDER-REC-RECORD-ELEMENT-MAPPING>
+													</RECORD-ELEMENT-MAPPINGS>
+												</SENDER-REC-RECORD-TYPE-MAPPING>
+											</TYPE-MAPPING>
+										</SENDER-REC-RECORD-ELEMENT-MAPPING>
+									</RECORD-ELEMENT-MAPPINGS>
+								</SENDER-REC-RECORD-TYPE-MAPPING>
+							</TYPE-MAPPING>
+						</SENDER-RECEIVER-TO-SIGNAL-GROUP-MAPPING>
+					</DATA-MAPPINGS>
+				</SYSTEM-MAPPING>
+			</MAPPINGS>
+		</SYSTEM>
+	</ELEMENTS>
+</AR-PACKAGE>`))
+	doc.FindElement("//AUTOSAR/AR-PACKAGES").AddChild(pkg.Root())
+	p := NewParserWithDoc(doc)
+	require.NoError(t, p.Parse())
+
+	sp := p.systemParser
+	require.Equal(t, []string{"/System/SystemDescription", "/EcuExtract/CDC_EcuExtract"}, sp.GetSystems())
+	// 两个 SYSTEM 中相同的映射均可追溯
+	const apListSignal = "/Communication/SystemSignals/SysSig_Event_reportWiFiApList_INI_WiFiStation_1"
+	require.Equal(t, []*system.DataMapping{
+		{System: "/System/SystemDescription", SystemMapping: "/System/SystemDescription/Mappings", TargetRef: "/SoftwareTypes/Interfaces/INI_WiFiStation_reportWiFiApList/WiFiApList_param"},
+		{System: "/EcuExtract/CDC_EcuExtract", SystemMapping: "/EcuExtract/CDC_EcuExtract/EventMappings", TargetRef: "/SoftwareTypes/Interfaces/INI_WiFiStation_reportWiFiApList/WiFiApList_param"},
+	}, sp.GetDataMappings(apListSignal))
+
+	require.Equal(t, &system.SignalGroupMapping{
+		System:         "/EcuExtract/CDC_EcuExtract",
+		SignalGroupRef: "/Communication/SystemSignals/SysSigGroup_WiFiApInfo",
+		DataElementRef: "/SoftwareTypes/Interfaces/INI_WiFiStation_reportWiFiApInfo/WiFiApInfo_param",
+		RecordElements: map[string]string{
+			"/Communication/SystemSignals/SysSig_WiFiApName":   "/DataTypes/ApplicationDataType/adt_WiFiApInfo/wiFiApName",
+			"/Communication/SystemSignals/SysSig_WiFiStrength": "/DataTypes/ImplementationDataTypes/WiFiQuality/strength",
+		},
+	}, sp.GetSignalGroupMappings()["/Communication/SystemSignals/SysSigGroup_WiFiApInfo"])
+	require.Equal(t, "/EcuExtract/CDC_EcuExtract/GroupMappings", sp.GetDataMappings("/Communication/SystemSignals/SysSig_WiFiStrength")[0].SystemMapping)
+
+	// 同一 system signal 在不同 SYSTEM 中映射到不同目标
+	doc.FindElement("//SYSTEM[SHORT-NAME='CDC_EcuExtract']//TARGET-DATA-PROTOTYPE-REF").SetText("/SoftwareTypes/Interfaces/INI_WiFiStation_reportWiFiConnStatus/WiFiConnStatus_param")
+	require.Error(t, NewParserWithDoc(doc).Parse())
+}
+
+func TestResolutionTable(t *testing.T) {
+	p, err := NewParser("../../test/s1_cp_test.xml")
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+
+	for key := range p.topologyParser.GetHeaderRef() {
+		for _, channel := range []string{"", key.Channel} {
+			_, ok := p.resolutions.resolutions[resolutionKey{channel: channel, majorVersion: ast.AnyMajorVersion, headerID: key.HeaderID}]
+			require.True(t, ok, "%v on %q", key.HeaderID, channel)
+		}
+	}
+
+	r, err := p.Resolve("", 33282, ast.AnyMajorVersion, 2181169157, false)
+	require.NoError(t, err)
+	name, path, tr, err := r.GetDataType()
+	require.NoError(t, err)
+	require.Equal(t, "adt_WiFiApName", name)
+	require.Equal(t, "/DataTypes/ApplicationDataType/adt_WiFiApName", path)
+	require.Equal(t, "string", tr.TypeName())
+	operation, err := r.GetOperation()
+	require.NoError(t, err)
+	require.Equal(t, "removeWiFiLoginInfo", operation.ShortName)
+
+	// 以物理通道的 SHORT-NAME 或 AR 路径查找得到同一结果
+	for _, channel := range []string{"ChannelCommunication_VLAN62", "/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62"} {
+		got, err := p.Resolve(channel, 33282, 1, 2181169157, true)
+		require.NoError(t, err)
+		require.Same(t, p.resolutions.resolutions[resolutionKey{channel: "/Topology/Clusters/EthernetCluster/ChannelCommunication_VLAN62", majorVersion: 1, headerID: 2181169157, response: true}], got)
+	}
+
+	// data element 的 request 与 response 共用同一解析结果
+	request, err := p.Resolve("", 33282, 1, 2181201922, false)
+	require.NoError(t, err)
+	response, err := p.Resolve("", 33282, 1, 2181201922, true)
+	require.NoError(t, err)
+	require.Same(t, request, response)
+	require.Equal(t, "/SoftwareTypes/Interfaces/INI_WiFiStation_reportWiFiConnStatus/WiFiConnStatus_param", response.GetTargetRef())
+
+	_, err = p.Resolve("", 33282, 2, 2181169157, false)
+	require.Error(t, err)
+	_, err = p.Resolve("", 33282, ast.AnyMajorVersion, 2181169159, false)
+	require.Error(t, err)
+	_, err = p.Resolve("ChannelCommunication_Unknown", 33282, ast.AnyMajorVersion, 2181169157, false)
+	require.Error(t, err)
+}
+
+func BenchmarkResolve(b *testing.B) {
+	p, err := NewParser("../../test/s1_cp_test.xml")
+	require.NoError(b, err)
+	require.NoError(b, p.Parse())
+	b.Run("table", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := p.Resolve("", 33282, ast.AnyMajorVersion, 2181169157, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// 逐级解析, 作为对照
+	b.Run("chain", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := p.topologyParser.LookupService(33282, ast.AnyMajorVersion); err != nil {
+				b.Fatal(err)
+			}
+			if r := p.resolve("", ast.AnyMajorVersion, 2181169157, false); r.typeErr != nil {
+				b.Fatal(r.typeErr)
+			}
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/yisaer/idl-parser/ast/typeref"
+
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/cp/parser/softwareTypes"
+	"github.com/yisaer/arxml-converter/e2e"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+// Resolution 为 header id 在 Parse 时解析出的 header id -> PDU triggering -> TP SDU -> I-SIGNAL -> system signal ->
+// operation / data element -> 数据类型 的结果, 创建后只读, 由多个查找共享
+type Resolution struct {
+	iSignalRef string
+	// targetRef 为 operation 或 data element 的 AR 路径
+	targetRef string
+	// operation 为 client/server operation, data element 时为 nil
+	operation *softwareTypes.Operation
+	// typeName, typePath 与 typeRef 为数据类型的 SHORT-NAME, AR 路径与对应的 TypeRef
+	typeName string
+	typePath string
+	typeRef  typeref.TypeRef
+	// requiresSomeIPDecoder 为 true 时 payload 需由 someip.Decoder 解析
+	requiresSomeIPDecoder bool
+	// e2eConfig 为 I-SIGNAL 的 E2E 配置, 未配置 E2E 保护时为 nil
+	e2eConfig *e2e.Config
+
+	// err 为 header id 无法解析到 I-SIGNAL 的原因, targetErr 与 typeErr 为 operation / data element 与数据类型无法解析的原因
+	err       error
+	targetErr error
+	typeErr   error
+}
+
+// GetISignalRef 返回 I-SIGNAL 的 AR 路径
+func (r *Resolution) GetISignalRef() string {
+	return r.iSignalRef
+}
+
+// GetTargetRef 返回 operation 或 data element 的 AR 路径
+func (r *Resolution) GetTargetRef() string {
+	return r.targetRef
+}
+
+// GetE2EConfig 返回 I-SIGNAL 的 E2E 配置, 未配置 E2E 保护时返回 nil
+func (r *Resolution) GetE2EConfig() *e2e.Config {
+	return r.e2eConfig
+}
+
+// RequiresSomeIPDecoder 判断 payload 是否需由 someip.Decoder 解析
+func (r *Resolution) RequiresSomeIPDecoder() bool {
+	return r.requiresSomeIPDecoder
+}
+
+// GetOperation 返回 operation 及解析失败的原因
+func (r *Resolution) GetOperation() (*softwareTypes.Operation, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.operation, r.targetErr
+}
+
+// GetDataType 返回数据类型的 SHORT-NAME, AR 路径与 TypeRef
+func (r *Resolution) GetDataType() (string, string, typeref.TypeRef, error) {
+	if r.err != nil {
+		return "", "", nil, r.err
+	}
+	if r.typeErr != nil {
+		return "", "", nil, r.typeErr
+	}
+	return r.typeName, r.typePath, r.typeRef, nil
+}
+
+// isDataElement 判断 header id 是否解析到 data element, data element 不区分 request 与 response
+func (r *Resolution) isDataElement() bool {
+	return r.err == nil && r.targetErr == nil && r.operation == nil
+}
+
+type resolutionKey struct {
+	channel      string
+	majorVersion int
+	headerID     uint32
+	response     bool
+}
+
+type serviceLookupKey struct {
+	serviceID    uint16
+	majorVersion int
+}
+
+// ResolutionTable 以 (物理通道, service major version, header id, 是否为 response) 索引 Resolution,
+// channel 为空与 majorVersion 为 ast.AnyMajorVersion 的 key 对应不指定通道与版本的查找.
+// 表在 Parse 时一次生成, 之后只读, 可以并发查找
+type ResolutionTable struct {
+	resolutions map[resolutionKey]*Resolution
+	// channels 为物理通道的 AR 路径与唯一的 SHORT-NAME 到 AR 路径的映射
+	channels map[string]string
+	// services 为可以唯一确定 service 的 (service id, major version)
+	services map[serviceLookupKey]bool
+}
+
+// buildResolutionTable 对 topology 中的每个 header id 依次在每个物理通道与不指定通道, 每个 major version 与不指定版本时
+// 解析 request 与 response. 无法确定版本的 header id 对 service 的每个版本都生成一项, data element 的 response 项与 request 项相同
+func (p *Parser) buildResolutionTable() *ResolutionTable {
+	t := &ResolutionTable{
+		resolutions: make(map[resolutionKey]*Resolution),
+		channels:    make(map[string]string),
+		services:    make(map[serviceLookupKey]bool),
+	}
+	for key := range p.topologyParser.GetHeaderRef() {
+		versions := []int{ast.AnyMajorVersion}
+		if key.MajorVersion != ast.AnyMajorVersion {
+			versions = append(versions, key.MajorVersion)
+		} else {
+			for serviceKey := range p.topologyParser.GetServiceIDMap() {
+				if uint32(serviceKey.ServiceID) == key.HeaderID>>16 {
+					versions = append(versions, int(serviceKey.MajorVersion))
+				}
+			}
+		}
+		for _, channel := range []string{"", key.Channel} {
+			for _, majorVersion := range versions {
+				rk := resolutionKey{channel: channel, majorVersion: majorVersion, headerID: key.HeaderID}
+				if _, ok := t.resolutions[rk]; ok {
+					continue
+				}
+				request := p.resolve(channel, majorVersion, key.HeaderID, false)
+				t.resolutions[rk] = request
+				rk.response = true
+				if request.isDataElement() {
+					t.resolutions[rk] = request
+				} else {
+					t.resolutions[rk] = p.resolve(channel, majorVersion, key.HeaderID, true)
+				}
+			}
+		}
+		t.channels[key.Channel] = key.Channel
+		sn := util.ExtractLast(key.Channel)
+		if path, err := p.topologyParser.ResolveChannel(sn); err == nil && path == key.Channel {
+			t.channels[sn] = key.Channel
+		}
+	}
+	for key := range p.topologyParser.GetServiceIDMap() {
+		for _, majorVersion := range []int{ast.AnyMajorVersion, int(key.MajorVersion)} {
+			if _, err := p.topologyParser.LookupService(key.ServiceID, majorVersion); err == nil {
+				t.services[serviceLookupKey{serviceID: key.ServiceID, majorVersion: majorVersion}] = true
+			}
+		}
+	}
+	return t
+}
+
+func (p *Parser) resolve(channel string, majorVersion int, headerID uint32, response bool) *Resolution {
+	r := &Resolution{}
+	r.iSignalRef, r.err = p.getISignalRefByHeaderID(channel, majorVersion, headerID, response)
+	if r.err != nil {
+		return r
+	}
+	r.e2eConfig = p.GetE2EConfig(r.iSignalRef)
+	if r.targetRef, r.targetErr = p.getOperationRefByISignal(r.iSignalRef); r.targetErr != nil {
+		r.typeErr = r.targetErr
+		return r
+	}
+	r.operation = p.softwareTypesParser.GetOperations()[r.targetRef]
+	tRef, ok := p.softwareTypesParser.GetInterfaceRefMap()[r.targetRef]
+	if !ok {
+		r.typeErr = fmt.Errorf("no interface ref for %v", r.targetRef)
+		return r
+	}
+	dt, ok := p.transformer.LookupDataType(tRef)
+	if !ok {
+		r.typeErr = fmt.Errorf("no data type for %v", tRef)
+		return r
+	}
+	tr, ok := p.transformer.GetConverterRef()[tRef]
+	if !ok {
+		r.typeErr = fmt.Errorf("no converter ref for %v", tRef)
+		return r
+	}
+	r.typeName, r.typePath, r.typeRef = dt.ShorName, tRef, tr
+	r.requiresSomeIPDecoder = p.transformer.RequiresSomeIPDecoder(tRef)
+	return r
+}
+
+// Resolve 校验 service 后返回 header id 在该 major version 下预先解析的结果, 查找失败时返回与逐级解析相同的错误
+func (p *Parser) Resolve(channel string, serviceID uint16, majorVersion int, headerID uint32, response bool) (*Resolution, error) {
+	if !p.resolutions.services[serviceLookupKey{serviceID: serviceID, majorVersion: majorVersion}] {
+		if _, err := p.topologyParser.LookupService(serviceID, majorVersion); err != nil {
+			return nil, err
+		}
+	}
+	return p.resolveHeader(channel, majorVersion, headerID, response)
+}
+
+func (p *Parser) resolveHeader(channel string, majorVersion int, headerID uint32, response bool) (*Resolution, error) {
+	if channel != "" {
+		if path, ok := p.resolutions.channels[channel]; ok {
+			channel = path
+		}
+	}
+	r, ok := p.resolutions.resolutions[resolutionKey{channel: channel, majorVersion: majorVersion, headerID: headerID, response: response}]
+	if !ok {
+		// 表中没有的 header id, 物理通道或版本, 逐级解析以返回具体的错误
+		r = p.resolve(channel, majorVersion, headerID, false)
+		if response && !r.isDataElement() {
+			r = p.resolve(channel, majorVersion, headerID, true)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r, nil
+}
@@ -5,24 +5,42 @@ import (
 
 	"github.com/beevik/etree"
 
+	"github.com/yisaer/arxml-converter/ast"
 	"github.com/yisaer/arxml-converter/util"
 )
 
 type SoftwareTypesParser struct {
 	interfacesElement *etree.Element
-	interfaceRefMap   map[string]map[string]string
+	index             *util.ArIndex
+	// interfaceRefMap 为 CLIENT-SERVER-OPERATION / VARIABLE-DATA-PROTOTYPE 的 AR 路径到 TYPE-TREF 的映射
+	interfaceRefMap map[string]string
+	// operations 以 CLIENT-SERVER-OPERATION 的 AR 路径为 key
+	operations map[string]*Operation
 }
 
-func NewSoftwareTypesParser() *SoftwareTypesParser {
+// Operation 为 CLIENT-SERVER-OPERATION, Arguments 按定义顺序排列
+type Operation struct {
+	ShortName      string
+	Arguments      []*ast.Argument
+	PossibleErrors []*ast.ApplicationError
+}
+
+func NewSoftwareTypesParser(index *util.ArIndex) *SoftwareTypesParser {
 	return &SoftwareTypesParser{
-		interfaceRefMap: make(map[string]map[string]string),
+		index:           index,
+		interfaceRefMap: make(map[string]string),
+		operations:      make(map[string]*Operation),
 	}
 }
 
-func (sp *SoftwareTypesParser) GetInterfaceRefMap() map[string]map[string]string {
+func (sp *SoftwareTypesParser) GetInterfaceRefMap() map[string]string {
 	return sp.interfaceRefMap
 }
 
+func (sp *SoftwareTypesParser) GetOperations() map[string]*Operation {
+	return sp.operations
+}
+
 func (sp *SoftwareTypesParser) ParseSoftwareTypes(node *etree.Element) (err error) {
 	defer func() {
 		if err != nil {
@@ -86,7 +104,7 @@ func (sp *SoftwareTypesParser) parseSENDERRECEIVERINTERFACE(node *etree.Element)
 			return fmt.Errorf("parsing %v VARIABLE-DATA-PROTOTYPE: %w", index, err)
 		}
 		if len(k) > 0 && len(v) > 0 {
-			sp.addClientServerInterfaceMap(sn, k, v)
+			sp.interfaceRefMap[util.GetArPath(VARIABLEDATAPROTOTYPE)] = v
 		}
 	}
 	return nil
@@ -101,7 +119,11 @@ func (sp *SoftwareTypesParser) parseVARIABLEDATAPROTOTYPE(node *etree.Element) (
 	if typeRefElement == nil {
 		return "", "", nil
 	}
-	return sn, typeRefElement.Text(), nil
+	typeRef, err := sp.index.RefPath(typeRefElement)
+	if err != nil {
+		return "", "", err
+	}
+	return sn, typeRef, nil
 }
 
 func (sp *SoftwareTypesParser) parseClientServerInterface(node *etree.Element) (err error) {
@@ -114,36 +136,60 @@ func (sp *SoftwareTypesParser) parseClientServerInterface(node *etree.Element) (
 			err = fmt.Errorf("searching client server interface %v: %w", sn, err)
 		}
 	}()
+	possibleErrors, err := sp.parsePossibleErrors(node)
+	if err != nil {
+		return err
+	}
 	operationsElement := node.SelectElement("OPERATIONS")
 	if operationsElement == nil {
 		return nil
 	}
 	for index, cso := range operationsElement.SelectElements("CLIENT-SERVER-OPERATION") {
-		csoShortName, tref, err := sp.parseClientServerOperation(cso)
+		operation, err := sp.parseClientServerOperation(cso, possibleErrors)
 		if err != nil {
 			return fmt.Errorf("parsing %v client server operation: %w", index, err)
 		}
-		if len(csoShortName) > 0 && len(tref) > 0 {
-			sp.addClientServerInterfaceMap(sn, csoShortName, tref)
+		sp.operations[util.GetArPath(cso)] = operation
+		// interfaceRefMap 保留第一个 IN 参数的类型
+		for _, arg := range operation.Arguments {
+			if arg.Direction == ast.ArgumentIn {
+				sp.interfaceRefMap[util.GetArPath(cso)] = arg.TypeRef
+				break
+			}
 		}
 	}
 	return nil
 }
 
-func (sp *SoftwareTypesParser) addClientServerInterfaceMap(csiShortname, csoShortname, tref string) {
-	csoMap, ok := sp.interfaceRefMap[csiShortname]
-	if !ok {
-		csoMap = make(map[string]string)
-		sp.interfaceRefMap[csiShortname] = csoMap
+// parsePossibleErrors 返回 APPLICATION-ERROR 的 AR 路径到 application error 的映射
+func (sp *SoftwareTypesParser) parsePossibleErrors(node *etree.Element) (map[string]*ast.ApplicationError, error) {
+	possibleErrors := make(map[string]*ast.ApplicationError)
+	possibleErrorsElement := node.SelectElement("POSSIBLE-ERRORS")
+	if possibleErrorsElement == nil {
+		return possibleErrors, nil
+	}
+	for index, applicationError := range possibleErrorsElement.SelectElements("APPLICATION-ERROR") {
+		sn, err := util.GetShortname(applicationError)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %v APPLICATION-ERROR: %w", index, err)
+		}
+		errorCodeElement := applicationError.SelectElement("ERROR-CODE")
+		if errorCodeElement == nil {
+			return nil, fmt.Errorf("APPLICATION-ERROR %v has no ERROR-CODE", sn)
+		}
+		errorCode, err := util.ToInt64(errorCodeElement.Text())
+		if err != nil || errorCode < 0 || errorCode > 0xFF {
+			return nil, fmt.Errorf("invalid ERROR-CODE %v in APPLICATION-ERROR %v", errorCodeElement.Text(), sn)
+		}
+		possibleErrors[util.GetArPath(applicationError)] = &ast.ApplicationError{ShortName: sn, ErrorCode: uint8(errorCode)}
 	}
-	csoMap[csoShortname] = tref
-	sp.interfaceRefMap[csiShortname] = csoMap
+	return possibleErrors, nil
 }
 
-func (sp *SoftwareTypesParser) parseClientServerOperation(node *etree.Element) (shortname, tref string, err error) {
+func (sp *SoftwareTypesParser) parseClientServerOperation(node *etree.Element, possibleErrors map[string]*ast.ApplicationError) (operation *Operation, err error) {
 	sn, err := util.GetShortname(node)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	defer func() {
 		if err != nil {
@@ -151,24 +197,60 @@ func (sp *SoftwareTypesParser) parseClientServerOperation(node *etree.Element) (
 		}
 	}()
 
-	argumentsElement := node.SelectElement("ARGUMENTS")
-	if argumentsElement == nil {
-		return "", "", nil
+	operation = &Operation{
+		ShortName:      sn,
+		Arguments:      make([]*ast.Argument, 0),
+		PossibleErrors: make([]*ast.ApplicationError, 0),
 	}
-	for index, argument := range argumentsElement.SelectElements("ARGUMENT-DATA-PROTOTYPE") {
-		DIRECTIONElement := argument.SelectElement("DIRECTION")
-		if DIRECTIONElement == nil {
-			continue
+	if argumentsElement := node.SelectElement("ARGUMENTS"); argumentsElement != nil {
+		for index, argument := range argumentsElement.SelectElements("ARGUMENT-DATA-PROTOTYPE") {
+			arg, err := sp.parseArgument(argument)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %v ARGUMENT-DATA-PROTOTYPE: %w", index, err)
+			}
+			operation.Arguments = append(operation.Arguments, arg)
 		}
-		if DIRECTIONElement.Text() == "IN" {
-			typeRefElement := argument.SelectElement("TYPE-TREF")
-			if typeRefElement == nil {
-				return "", "", fmt.Errorf("parsing %v ARGUMENT-DATA-PROTOTYPE no TYPE-TREF", index)
+	}
+	if refsElement := node.SelectElement("POSSIBLE-ERROR-REFS"); refsElement != nil {
+		for _, ref := range refsElement.SelectElements("POSSIBLE-ERROR-REF") {
+			errorRef, err := sp.index.RefPath(ref)
+			if err != nil {
+				return nil, err
+			}
+			applicationError, ok := possibleErrors[errorRef]
+			if !ok {
+				return nil, fmt.Errorf("possible error %v not found", errorRef)
 			}
-			return sn, typeRefElement.Text(), nil
+			operation.PossibleErrors = append(operation.PossibleErrors, applicationError)
 		}
 	}
-	return "", "", nil
+	return operation, nil
+}
+
+func (sp *SoftwareTypesParser) parseArgument(node *etree.Element) (*ast.Argument, error) {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return nil, err
+	}
+	directionElement := node.SelectElement("DIRECTION")
+	if directionElement == nil {
+		return nil, fmt.Errorf("argument %v has no DIRECTION", sn)
+	}
+	direction := ast.ArgumentDirection(directionElement.Text())
+	switch direction {
+	case ast.ArgumentIn, ast.ArgumentOut, ast.ArgumentInOut:
+	default:
+		return nil, fmt.Errorf("argument %v has invalid DIRECTION %v", sn, directionElement.Text())
+	}
+	typeRefElement := node.SelectElement("TYPE-TREF")
+	if typeRefElement == nil {
+		return nil, fmt.Errorf("argument %v has no TYPE-TREF", sn)
+	}
+	typeRef, err := sp.index.RefPath(typeRefElement)
+	if err != nil {
+		return nil, err
+	}
+	return &ast.Argument{ShortName: sn, TypeRef: typeRef, Direction: direction}, nil
 }
 
 func (sp *SoftwareTypesParser) searchInterfaces(arpackageList []*etree.Element) error {
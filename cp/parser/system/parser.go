@@ -9,12 +9,44 @@ import (
 )
 
 type SystemParser struct {
+	index *util.ArIndex
+	// systems 为按文档顺序解析的 SYSTEM 的 AR 路径
+	systems []string
+	// operationRef 为 SYSTEM-SIGNAL / SYSTEM-SIGNAL-GROUP 的 AR 路径到 operation / data element AR 路径的映射, 合并全部 SYSTEM-MAPPING
 	operationRef map[string]string
+	// dataMappings 以 SYSTEM-SIGNAL / SYSTEM-SIGNAL-GROUP 的 AR 路径为 key, 记录映射来自的 SYSTEM
+	dataMappings map[string][]*DataMapping
+	// signalGroupMappings 以 SYSTEM-SIGNAL-GROUP 的 AR 路径为 key
+	signalGroupMappings map[string]*SignalGroupMapping
+	// returnSignals 为 CLIENT-SERVER-TO-SIGNAL-MAPPING 中 RETURN-SIGNAL-REF 引用的 SYSTEM-SIGNAL
+	returnSignals map[string]bool
 }
 
-func NewSystemParser() *SystemParser {
+// DataMapping 为 SYSTEM-MAPPING 中 system signal 或 signal group 到 operation / data element 的映射
+type DataMapping struct {
+	// System 与 SystemMapping 为映射所在 SYSTEM 与 SYSTEM-MAPPING 的 AR 路径
+	System        string
+	SystemMapping string
+	TargetRef     string
+}
+
+// SignalGroupMapping 为 SENDER-RECEIVER-TO-SIGNAL-GROUP-MAPPING, RecordElements 为成员 SYSTEM-SIGNAL 到
+// record element AR 路径的映射, 嵌套的 record 展开到最内层的 record element
+type SignalGroupMapping struct {
+	System         string
+	SignalGroupRef string
+	DataElementRef string
+	RecordElements map[string]string
+}
+
+func NewSystemParser(index *util.ArIndex) *SystemParser {
 	return &SystemParser{
-		operationRef: make(map[string]string),
+		index:               index,
+		systems:             make([]string, 0),
+		operationRef:        make(map[string]string),
+		dataMappings:        make(map[string][]*DataMapping),
+		signalGroupMappings: make(map[string]*SignalGroupMapping),
+		returnSignals:       make(map[string]bool),
 	}
 }
 
@@ -22,68 +54,96 @@ func (sp *SystemParser) GetOperationRef() map[string]string {
 	return sp.operationRef
 }
 
-func (sp *SystemParser) ParseSystem(node *etree.Element) (err error) {
+// GetSystems 返回解析的全部 SYSTEM 的 AR 路径
+func (sp *SystemParser) GetSystems() []string {
+	return sp.systems
+}
+
+// GetDataMappings 返回 SYSTEM-SIGNAL 或 SYSTEM-SIGNAL-GROUP 在各个 SYSTEM 中的映射
+func (sp *SystemParser) GetDataMappings(systemSignalRef string) []*DataMapping {
+	return sp.dataMappings[systemSignalRef]
+}
+
+// GetSignalGroupMappings 返回 SENDER-RECEIVER-TO-SIGNAL-GROUP-MAPPING, 以 SYSTEM-SIGNAL-GROUP 的 AR 路径为 key
+func (sp *SystemParser) GetSignalGroupMappings() map[string]*SignalGroupMapping {
+	return sp.signalGroupMappings
+}
+
+// IsReturnSignal 判断 SYSTEM-SIGNAL 是否为 client/server operation 的 response
+func (sp *SystemParser) IsReturnSignal(systemSignalRef string) bool {
+	return sp.returnSignals[systemSignalRef]
+}
+
+// ParseSystem 解析文档中全部 SYSTEM 的全部 SYSTEM-MAPPING 并合并其 data mapping,
+// 同一 system signal 在不同 SYSTEM 中映射到不同目标时返回错误
+func (sp *SystemParser) ParseSystem(root *etree.Element) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("parse system error: %s", err.Error())
 		}
 	}()
-	elements, err := util.GetElements(node)
-	if err != nil {
-		return err
-	}
-	systemElement := elements.SelectElement("SYSTEM")
-	if systemElement == nil {
+	systemElements := root.FindElements("//SYSTEM")
+	if len(systemElements) == 0 {
 		return fmt.Errorf("no system")
 	}
-	systemSN, err := util.GetShortname(systemElement)
-	if err != nil {
-		return err
-	}
-	if systemSN == "SystemDescription" {
-		return sp.parseSystemMapping(systemElement)
-	}
-	categoryElement := systemElement.SelectElement("CATEGORY")
-	if categoryElement != nil && categoryElement.Text() == "SYSTEM_DESCRIPTION" {
-		return sp.parseSystemMapping(systemElement)
+	for _, systemElement := range systemElements {
+		systemPath := util.GetArPath(systemElement)
+		sp.systems = append(sp.systems, systemPath)
+		for index, systemMappingElement := range systemElement.FindElements("MAPPINGS/SYSTEM-MAPPING") {
+			if err := sp.parseSystemMapping(systemPath, systemMappingElement); err != nil {
+				return fmt.Errorf("system %v: parse %v SYSTEM-MAPPING error: %s", systemPath, index, err.Error())
+			}
+		}
 	}
-	return fmt.Errorf("unsupported system type: %s", systemSN)
+	return nil
 }
 
-func (sp *SystemParser) parseSystemMapping(systemElement *etree.Element) (err error) {
-	mappingsElement := systemElement.SelectElement("MAPPINGS")
-	if mappingsElement == nil {
-		return fmt.Errorf("no mappings")
-	}
-	systemMappingElement := mappingsElement.SelectElement("SYSTEM-MAPPING")
-	if systemMappingElement == nil {
-		return fmt.Errorf("no SYSTEM-MAPPING ")
-	}
+func (sp *SystemParser) parseSystemMapping(systemPath string, systemMappingElement *etree.Element) (err error) {
 	dataMappingsElement := systemMappingElement.SelectElement("DATA-MAPPINGS")
 	if dataMappingsElement == nil {
-		return fmt.Errorf("no DATA-MAPPINGS")
+		return nil
 	}
+	mapping := &DataMapping{System: systemPath, SystemMapping: util.GetArPath(systemMappingElement)}
 
 	clientServerToSignalMappingList := dataMappingsElement.SelectElements("CLIENT-SERVER-TO-SIGNAL-MAPPING")
 	for index, clientServerToSignalMappingElement := range clientServerToSignalMappingList {
-		if err := sp.parseCLIENTSERVERTOSIGNALMAPPING(clientServerToSignalMappingElement); err != nil {
+		if err := sp.parseCLIENTSERVERTOSIGNALMAPPING(mapping, clientServerToSignalMappingElement); err != nil {
 			return fmt.Errorf("parse %v CLIENT-SERVER-TO-SIGNAL-MAPPING error: %s", index, err.Error())
 		}
 	}
 	SENDERRECEIVERTOSIGNALMAPPINGList := dataMappingsElement.SelectElements("SENDER-RECEIVER-TO-SIGNAL-MAPPING")
 	for index, SENDERRECEIVERTOSIGNALMAPPINGElement := range SENDERRECEIVERTOSIGNALMAPPINGList {
-		if err := sp.paraseSENDERRECEIVERTOSIGNALMAPPING(SENDERRECEIVERTOSIGNALMAPPINGElement); err != nil {
+		if err := sp.paraseSENDERRECEIVERTOSIGNALMAPPING(mapping, SENDERRECEIVERTOSIGNALMAPPINGElement); err != nil {
 			return fmt.Errorf("parse %v SENDER-RECEIVER-TO-SIGNAL-MAPPING error: %s", index, err.Error())
 		}
 	}
+	for index, signalGroupMappingElement := range dataMappingsElement.SelectElements("SENDER-RECEIVER-TO-SIGNAL-GROUP-MAPPING") {
+		if err := sp.parseSignalGroupMapping(mapping, signalGroupMappingElement); err != nil {
+			return fmt.Errorf("parse %v SENDER-RECEIVER-TO-SIGNAL-GROUP-MAPPING error: %s", index, err.Error())
+		}
+	}
 	return nil
 }
 
-func (sp *SystemParser) parseCLIENTSERVERTOSIGNALMAPPING(node *etree.Element) (err error) {
-	callSignalRefElement := node.SelectElement("CALL-SIGNAL-REF")
-	if callSignalRefElement == nil {
-		return nil
+// addMapping 记录 system signal 到目标的映射, 同一 SYSTEM-MAPPING 中重复的映射 (如 provider 与 consumer) 只记录一次
+func (sp *SystemParser) addMapping(mapping *DataMapping, systemSignalRef, targetRef string) error {
+	if existing, ok := sp.operationRef[systemSignalRef]; ok && existing != targetRef {
+		return fmt.Errorf("system signal %v is mapped to %v in %v and to %v in %v",
+			systemSignalRef, existing, sp.dataMappings[systemSignalRef][0].System, targetRef, mapping.System)
 	}
+	sp.operationRef[systemSignalRef] = targetRef
+	for _, existing := range sp.dataMappings[systemSignalRef] {
+		if existing.SystemMapping == mapping.SystemMapping {
+			return nil
+		}
+	}
+	m := *mapping
+	m.TargetRef = targetRef
+	sp.dataMappings[systemSignalRef] = append(sp.dataMappings[systemSignalRef], &m)
+	return nil
+}
+
+func (sp *SystemParser) parseCLIENTSERVERTOSIGNALMAPPING(mapping *DataMapping, node *etree.Element) (err error) {
 	clientServerOperationIRefElement := node.SelectElement("CLIENT-SERVER-OPERATION-IREF")
 	if clientServerOperationIRefElement == nil {
 		return nil
@@ -92,24 +152,130 @@ func (sp *SystemParser) parseCLIENTSERVERTOSIGNALMAPPING(node *etree.Element) (e
 	if targetOperationRefElement == nil {
 		return nil
 	}
-	a := targetOperationRefElement.Text()
-	sp.operationRef[callSignalRefElement.Text()] = a
+	a, err := sp.index.RefPath(targetOperationRefElement)
+	if err != nil {
+		return err
+	}
+	if callSignalRefElement := node.SelectElement("CALL-SIGNAL-REF"); callSignalRefElement != nil {
+		callSignalRef, err := sp.index.RefPath(callSignalRefElement)
+		if err != nil {
+			return err
+		}
+		if err := sp.addMapping(mapping, callSignalRef, a); err != nil {
+			return err
+		}
+	}
+	if returnSignalRefElement := node.SelectElement("RETURN-SIGNAL-REF"); returnSignalRefElement != nil {
+		returnSignalRef, err := sp.index.RefPath(returnSignalRefElement)
+		if err != nil {
+			return err
+		}
+		if err := sp.addMapping(mapping, returnSignalRef, a); err != nil {
+			return err
+		}
+		sp.returnSignals[returnSignalRef] = true
+	}
 	return nil
 }
 
-func (sp *SystemParser) paraseSENDERRECEIVERTOSIGNALMAPPING(node *etree.Element) (err error) {
+func (sp *SystemParser) paraseSENDERRECEIVERTOSIGNALMAPPING(mapping *DataMapping, node *etree.Element) (err error) {
 	srElement := node.SelectElement("SYSTEM-SIGNAL-REF")
 	if srElement == nil {
 		return nil
 	}
+	targetRef, ok, err := sp.dataElementRef(node)
+	if err != nil || !ok {
+		return err
+	}
+	systemSignalRef, err := sp.index.RefPath(srElement)
+	if err != nil {
+		return err
+	}
+	return sp.addMapping(mapping, systemSignalRef, targetRef)
+}
+
+func (sp *SystemParser) parseSignalGroupMapping(mapping *DataMapping, node *etree.Element) error {
+	signalGroupRefElement := node.SelectElement("SIGNAL-GROUP-REF")
+	if signalGroupRefElement == nil {
+		return nil
+	}
+	dataElementRef, ok, err := sp.dataElementRef(node)
+	if err != nil || !ok {
+		return err
+	}
+	signalGroupRef, err := sp.index.RefPath(signalGroupRefElement)
+	if err != nil {
+		return err
+	}
+	if err := sp.addMapping(mapping, signalGroupRef, dataElementRef); err != nil {
+		return err
+	}
+	groupMapping := &SignalGroupMapping{
+		System:         mapping.System,
+		SignalGroupRef: signalGroupRef,
+		DataElementRef: dataElementRef,
+		RecordElements: make(map[string]string),
+	}
+	if typeMapping := node.SelectElement("TYPE-MAPPING"); typeMapping != nil {
+		if err := sp.parseRecordTypeMapping(mapping, typeMapping, groupMapping.RecordElements); err != nil {
+			return fmt.Errorf("signal group %v: %v", signalGroupRef, err)
+		}
+	}
+	if _, ok := sp.signalGroupMappings[signalGroupRef]; !ok {
+		sp.signalGroupMappings[signalGroupRef] = groupMapping
+	}
+	return nil
+}
+
+// parseRecordTypeMapping 解析 SENDER-REC-RECORD-TYPE-MAPPING 中的 record element 到 SYSTEM-SIGNAL 的映射
+func (sp *SystemParser) parseRecordTypeMapping(mapping *DataMapping, typeMapping *etree.Element, recordElements map[string]string) error {
+	for _, elementMapping := range typeMapping.FindElements("SENDER-REC-RECORD-TYPE-MAPPING/RECORD-ELEMENT-MAPPINGS/SENDER-REC-RECORD-ELEMENT-MAPPING") {
+		if nested := elementMapping.SelectElement("TYPE-MAPPING"); nested != nil {
+			if err := sp.parseRecordTypeMapping(mapping, nested, recordElements); err != nil {
+				return err
+			}
+			continue
+		}
+		signalRefElement := elementMapping.SelectElement("SYSTEM-SIGNAL-REF")
+		if signalRefElement == nil {
+			continue
+		}
+		recordElementRefElement := elementMapping.SelectElement("APPLICATION-RECORD-ELEMENT-REF")
+		if recordElementRefElement == nil {
+			recordElementRefElement = elementMapping.SelectElement("IMPLEMENTATION-RECORD-ELEMENT-REF")
+		}
+		if recordElementRefElement == nil {
+			return fmt.Errorf("no APPLICATION-RECORD-ELEMENT-REF or IMPLEMENTATION-RECORD-ELEMENT-REF found")
+		}
+		systemSignalRef, err := sp.index.RefPath(signalRefElement)
+		if err != nil {
+			return err
+		}
+		recordElementRef, err := sp.index.RefPath(recordElementRefElement)
+		if err != nil {
+			return err
+		}
+		if err := sp.addMapping(mapping, systemSignalRef, recordElementRef); err != nil {
+			return err
+		}
+		recordElements[systemSignalRef] = recordElementRef
+	}
+	return nil
+}
+
+// dataElementRef 返回 DATA-ELEMENT-IREF 的 TARGET-DATA-PROTOTYPE-REF, 未配置时第二个返回值为 false
+func (sp *SystemParser) dataElementRef(node *etree.Element) (string, bool, error) {
 	DATAELEMENTIREF := node.SelectElement("DATA-ELEMENT-IREF")
 	if DATAELEMENTIREF == nil {
-		return nil
+		return "", false, nil
 	}
 	TARGETDATAPROTOTYPEREF := DATAELEMENTIREF.SelectElement("TARGET-DATA-PROTOTYPE-REF")
 	if TARGETDATAPROTOTYPEREF == nil {
-		return nil
+		return "", false, nil
 	}
-	sp.operationRef[srElement.Text()] = TARGETDATAPROTOTYPEREF.Text()
-	return nil
+	targetRef, err := sp.index.RefPath(TARGETDATAPROTOTYPEREF)
+	if err != nil {
+		return "", false, err
+	}
+	return targetRef, true, nil
 }
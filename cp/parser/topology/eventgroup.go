@@ -0,0 +1,260 @@
+package topology
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/ast"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+// parseEventGroups 解析 SO-AD-CONFIG 中的 event group. event group 的成员 event 为引用同一
+// SO-AD-ROUTING-GROUP 的 SOCKET-CONNECTION-IPDU-IDENTIFIER 的 header id
+func (tp *TopoLogyParser) parseEventGroups(soAdConfigElement *etree.Element) error {
+	routingGroupHeaderIDs := make(map[string][]uint32)
+	for _, identifier := range soAdConfigElement.FindElements(".//SOCKET-CONNECTION-IPDU-IDENTIFIER") {
+		headerIDElement := identifier.SelectElement("HEADER-ID")
+		if headerIDElement == nil {
+			continue
+		}
+		headerID, err := util.ToUint32(headerIDElement.Text())
+		if err != nil {
+			return fmt.Errorf("parse HEADER-ID err: %v", err)
+		}
+		for _, ref := range routingGroupRefs(identifier) {
+			path, err := tp.index.RefPath(ref)
+			if err != nil {
+				return err
+			}
+			routingGroupHeaderIDs[path] = append(routingGroupHeaderIDs[path], headerID)
+		}
+	}
+	// 先登记 provider 的 EVENT-HANDLER, 再合并 consumer 的 CONSUMED-EVENT-GROUP
+	for _, handler := range soAdConfigElement.FindElements(".//PROVIDED-SERVICE-INSTANCE/EVENT-HANDLERS/EVENT-HANDLER") {
+		if err := tp.parseEventHandler(handler, routingGroupHeaderIDs); err != nil {
+			return fmt.Errorf("parse EVENT-HANDLER err: %v", err)
+		}
+	}
+	for _, ceg := range soAdConfigElement.FindElements(".//CONSUMED-SERVICE-INSTANCE/CONSUMED-EVENT-GROUPS/CONSUMED-EVENT-GROUP") {
+		if err := tp.parseConsumedEventGroup(ceg, routingGroupHeaderIDs); err != nil {
+			return fmt.Errorf("parse CONSUMED-EVENT-GROUP err: %v", err)
+		}
+	}
+	return nil
+}
+
+func (tp *TopoLogyParser) parseEventHandler(node *etree.Element, routingGroupHeaderIDs map[string][]uint32) error {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return err
+	}
+	serviceID, ok, err := getServiceIdentifier(findAncestor(node, "PROVIDED-SERVICE-INSTANCE"))
+	if err != nil || !ok {
+		return err
+	}
+	eventGroupIDs := make([]uint16, 0)
+	if identifier := node.SelectElement("EVENT-GROUP-IDENTIFIER"); identifier != nil {
+		id, err := util.ToUint16(identifier.Text())
+		if err != nil {
+			return fmt.Errorf("event handler %v: %v", sn, err)
+		}
+		eventGroupIDs = append(eventGroupIDs, id)
+	} else if cegRefs := node.SelectElement("CONSUMED-EVENT-GROUP-REFS"); cegRefs != nil {
+		// 未配置 EVENT-GROUP-IDENTIFIER 时使用所服务的 CONSUMED-EVENT-GROUP 的 id
+		for _, ref := range cegRefs.SelectElements("CONSUMED-EVENT-GROUP-REF") {
+			ceg, err := tp.index.Resolve(ref)
+			if err != nil {
+				return fmt.Errorf("event handler %v: %v", sn, err)
+			}
+			identifier := ceg.SelectElement("EVENT-GROUP-IDENTIFIER")
+			if identifier == nil {
+				continue
+			}
+			id, err := util.ToUint16(identifier.Text())
+			if err != nil {
+				return fmt.Errorf("event handler %v: %v", sn, err)
+			}
+			eventGroupIDs = append(eventGroupIDs, id)
+		}
+	}
+	for _, id := range eventGroupIDs {
+		eg, err := tp.newEventGroup(sn, serviceID, id, node, routingGroupHeaderIDs)
+		if err != nil {
+			return fmt.Errorf("event handler %v: %v", sn, err)
+		}
+		if threshold := node.SelectElement("MULTICAST-THRESHOLD"); threshold != nil {
+			v, err := util.ToUint16(threshold.Text())
+			if err != nil {
+				return fmt.Errorf("event handler %v: invalid MULTICAST-THRESHOLD %v", sn, threshold.Text())
+			}
+			if v > 0 {
+				if eg.Multicast == nil {
+					eg.Multicast = &ast.EventGroupMulticast{}
+				}
+				eg.Multicast.Threshold = int(v)
+			}
+		}
+		tp.eventGroups.Register(eg)
+	}
+	return nil
+}
+
+func (tp *TopoLogyParser) parseConsumedEventGroup(node *etree.Element, routingGroupHeaderIDs map[string][]uint32) error {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return err
+	}
+	identifier := node.SelectElement("EVENT-GROUP-IDENTIFIER")
+	if identifier == nil {
+		return nil
+	}
+	id, err := util.ToUint16(identifier.Text())
+	if err != nil {
+		return fmt.Errorf("consumed event group %v: %v", sn, err)
+	}
+	csi := findAncestor(node, "CONSUMED-SERVICE-INSTANCE")
+	serviceID, ok, err := getServiceIdentifier(csi)
+	if err != nil {
+		return err
+	}
+	if !ok && csi != nil {
+		// consumer 未配置 SERVICE-IDENTIFIER 时使用所引用的 PROVIDED-SERVICE-INSTANCE
+		if psiRef := csi.SelectElement("PROVIDED-SERVICE-INSTANCE-REF"); psiRef != nil {
+			psi, err := tp.index.Resolve(psiRef)
+			if err != nil {
+				return fmt.Errorf("consumed event group %v: %v", sn, err)
+			}
+			serviceID, ok, err = getServiceIdentifier(psi)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !ok {
+		return nil
+	}
+	eg, err := tp.newEventGroup(sn, serviceID, id, node, routingGroupHeaderIDs)
+	if err != nil {
+		return fmt.Errorf("consumed event group %v: %v", sn, err)
+	}
+	address, port, err := tp.getEventMulticastAddress(node)
+	if err != nil {
+		return fmt.Errorf("consumed event group %v: %v", sn, err)
+	}
+	if address != "" {
+		if eg.Multicast == nil {
+			eg.Multicast = &ast.EventGroupMulticast{}
+		}
+		eg.Multicast.Address, eg.Multicast.Port = address, port
+	}
+	tp.eventGroups.Register(eg)
+	return nil
+}
+
+// newEventGroup 根据 node 的 ROUTING-GROUP-REFS 收集成员 event, 组播激活的 routing group 产生组播配置
+func (tp *TopoLogyParser) newEventGroup(sn string, serviceID, eventGroupID uint16, node *etree.Element, routingGroupHeaderIDs map[string][]uint32) (*ast.EventGroup, error) {
+	eg := &ast.EventGroup{
+		ShortName:    sn,
+		ServiceID:    serviceID,
+		EventGroupID: eventGroupID,
+		EventIDs:     make([]uint16, 0),
+	}
+	for _, ref := range routingGroupRefs(node) {
+		routingGroup, err := tp.index.Resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, headerID := range routingGroupHeaderIDs[util.GetArPath(routingGroup)] {
+			if uint16(headerID>>16) == serviceID {
+				eg.AddEvent(uint16(headerID))
+			}
+		}
+		controlType := routingGroup.SelectElement("EVENT-GROUP-CONTROL-TYPE")
+		if controlType != nil && strings.Contains(controlType.Text(), "MULTICAST") {
+			eg.Multicast = &ast.EventGroupMulticast{ControlType: strings.TrimSpace(controlType.Text())}
+		}
+	}
+	return eg, nil
+}
+
+// getEventMulticastAddress 返回 EVENT-MULTICAST-ADDRESSS 引用的 APPLICATION-ENDPOINT 的 IP 与 UDP 端口
+func (tp *TopoLogyParser) getEventMulticastAddress(node *etree.Element) (string, int, error) {
+	addresses := node.SelectElement("EVENT-MULTICAST-ADDRESSS")
+	if addresses == nil {
+		return "", 0, nil
+	}
+	for _, ref := range addresses.FindElements(".//APPLICATION-ENDPOINT-REF") {
+		aep, err := tp.index.Resolve(ref)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid EVENT-MULTICAST-ADDRESSS: %v", err)
+		}
+		nepRef := aep.SelectElement("NETWORK-ENDPOINT-REF")
+		if nepRef == nil {
+			return "", 0, fmt.Errorf("no NETWORK-ENDPOINT-REF in %v", util.GetArPath(aep))
+		}
+		nep, err := tp.index.Resolve(nepRef)
+		if err != nil {
+			return "", 0, err
+		}
+		var address string
+		for _, path := range []string{"NETWORK-ENDPOINT-ADDRESSES/IPV-4-CONFIGURATION/IPV-4-ADDRESS", "NETWORK-ENDPOINT-ADDRESSES/IPV-6-CONFIGURATION/IPV-6-ADDRESS"} {
+			raw := nep.FindElement(path)
+			if raw == nil {
+				continue
+			}
+			if ip := net.ParseIP(strings.TrimSpace(raw.Text())); ip != nil {
+				address = ip.String()
+				break
+			}
+		}
+		if address == "" {
+			return "", 0, fmt.Errorf("no ip address in %v", util.GetArPath(nep))
+		}
+		port := 0
+		if raw := aep.FindElement("TP-CONFIGURATION/UDP-TP/UDP-TP-PORT/PORT-NUMBER"); raw != nil {
+			v, err := util.ToUint16(raw.Text())
+			if err != nil {
+				return "", 0, err
+			}
+			port = int(v)
+		}
+		return address, port, nil
+	}
+	return "", 0, nil
+}
+
+func routingGroupRefs(node *etree.Element) []*etree.Element {
+	refs := node.SelectElement("ROUTING-GROUP-REFS")
+	if refs == nil {
+		return nil
+	}
+	return refs.SelectElements("ROUTING-GROUP-REF")
+}
+
+// getServiceIdentifier 未配置 SERVICE-IDENTIFIER 时 ok 为 false
+func getServiceIdentifier(node *etree.Element) (uint16, bool, error) {
+	if node == nil {
+		return 0, false, nil
+	}
+	serviceIDElement := node.SelectElement("SERVICE-IDENTIFIER")
+	if serviceIDElement == nil {
+		return 0, false, nil
+	}
+	serviceID, err := util.ToUint16(serviceIDElement.Text())
+	if err != nil {
+		return 0, false, err
+	}
+	return serviceID, true, nil
+}
+
+func findAncestor(e *etree.Element, tag string) *etree.Element {
+	for parent := e.Parent(); parent != nil; parent = parent.Parent() {
+		if parent.Tag == tag {
+			return parent
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package topology
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/cp/parser/communication"
+	"github.com/yisaer/arxml-converter/util"
+)
+
+// FrameTriggering 为 CAN/LIN/FlexRay 物理通道中的 frame triggering,
+// Identifier 为 CAN id, LIN 的 frame id 或 FlexRay 的 slot id
+type FrameTriggering struct {
+	ShortName string
+	// Channel 为物理通道的 AR 路径
+	Channel     string
+	Identifier  uint32
+	FrameRef    string
+	FrameLength int
+	PDUs        []*FramePDU
+}
+
+// FramePDU 为 frame 的 PDU-TO-FRAME-MAPPING
+type FramePDU struct {
+	PDURef           string
+	StartPosition    int
+	PackingByteOrder communication.ByteOrder
+}
+
+type frameKey struct {
+	channel    string
+	identifier uint32
+}
+
+// busClusters 为基于 signal 的总线, 依次为 cluster, 物理通道与 frame triggering 的元素名
+var busClusters = []struct {
+	cluster, channel, frameTriggering string
+}{
+	{"CAN-CLUSTER", "CAN-PHYSICAL-CHANNEL", "CAN-FRAME-TRIGGERING"},
+	{"LIN-CLUSTER", "LIN-PHYSICAL-CHANNEL", "LIN-FRAME-TRIGGERING"},
+	{"FLEXRAY-CLUSTER", "FLEXRAY-PHYSICAL-CHANNEL", "FLEXRAY-FRAME-TRIGGERING"},
+}
+
+func (tp *TopoLogyParser) parseBusClusters(elements *etree.Element) error {
+	for _, bus := range busClusters {
+		for _, cluster := range elements.SelectElements(bus.cluster) {
+			sn, err := util.GetShortname(cluster)
+			if err != nil {
+				return fmt.Errorf("%v has err: %v", bus.cluster, err)
+			}
+			for _, channel := range cluster.FindElements(".//PHYSICAL-CHANNELS/" + bus.channel) {
+				if err := tp.parseBusChannel(channel, bus.frameTriggering); err != nil {
+					return fmt.Errorf("%v %v: %v", bus.cluster, sn, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (tp *TopoLogyParser) parseBusChannel(node *etree.Element, frameTriggeringTag string) error {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return err
+	}
+	channel := util.GetArPath(node)
+	tp.channelNames[sn] = append(tp.channelNames[sn], channel)
+	frameTriggerings := node.SelectElement("FRAME-TRIGGERINGS")
+	if frameTriggerings == nil {
+		return nil
+	}
+	for index, frameTriggering := range frameTriggerings.SelectElements(frameTriggeringTag) {
+		ft, err := tp.parseFrameTriggering(frameTriggering)
+		if err != nil {
+			return fmt.Errorf("channel %v parse %v %v err: %v", sn, index, frameTriggeringTag, err)
+		}
+		ft.Channel = channel
+		key := frameKey{channel: channel, identifier: ft.Identifier}
+		if other, ok := tp.frameTriggerings[key]; ok {
+			return fmt.Errorf("channel %v: frame triggerings %v and %v share identifier %v", sn, other.ShortName, ft.ShortName, ft.Identifier)
+		}
+		tp.frameTriggerings[key] = ft
+	}
+	return nil
+}
+
+func (tp *TopoLogyParser) parseFrameTriggering(node *etree.Element) (*FrameTriggering, error) {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return nil, err
+	}
+	ft := &FrameTriggering{
+		ShortName: sn,
+		PDUs:      make([]*FramePDU, 0),
+	}
+	identifierElement := node.SelectElement("IDENTIFIER")
+	if identifierElement == nil {
+		// FlexRay 以 slot id 标识 frame
+		identifierElement = node.FindElement("ABSOLUTELY-SCHEDULED-TIMINGS/FLEXRAY-ABSOLUTELY-SCHEDULED-TIMING/SLOT-ID")
+	}
+	if identifierElement == nil {
+		return nil, fmt.Errorf("no IDENTIFIER in frame triggering %v", sn)
+	}
+	if ft.Identifier, err = util.ToUint32(identifierElement.Text()); err != nil {
+		return nil, fmt.Errorf("frame triggering %v: %v", sn, err)
+	}
+	frameRefElement := node.SelectElement("FRAME-REF")
+	if frameRefElement == nil {
+		return nil, fmt.Errorf("no FRAME-REF in frame triggering %v", sn)
+	}
+	frame, err := tp.index.Resolve(frameRefElement)
+	if err != nil {
+		return nil, fmt.Errorf("frame triggering %v: %v", sn, err)
+	}
+	ft.FrameRef = util.GetArPath(frame)
+	if e := frame.SelectElement("FRAME-LENGTH"); e != nil {
+		length, err := util.ToInt64(e.Text())
+		if err != nil {
+			return nil, fmt.Errorf("frame %v has invalid FRAME-LENGTH %v", ft.FrameRef, e.Text())
+		}
+		ft.FrameLength = int(length)
+	}
+	mappings := frame.SelectElement("PDU-TO-FRAME-MAPPINGS")
+	if mappings == nil {
+		return ft, nil
+	}
+	for _, mapping := range mappings.SelectElements("PDU-TO-FRAME-MAPPING") {
+		pdu, err := tp.parsePDUToFrameMapping(mapping)
+		if err != nil {
+			return nil, fmt.Errorf("frame %v: %v", ft.FrameRef, err)
+		}
+		ft.PDUs = append(ft.PDUs, pdu)
+	}
+	return ft, nil
+}
+
+func (tp *TopoLogyParser) parsePDUToFrameMapping(node *etree.Element) (*FramePDU, error) {
+	pduRefElement := node.SelectElement("PDU-REF")
+	if pduRefElement == nil {
+		return nil, fmt.Errorf("no PDU-REF in PDU-TO-FRAME-MAPPING")
+	}
+	pduRef, err := tp.index.RefPath(pduRefElement)
+	if err != nil {
+		return nil, err
+	}
+	pdu := &FramePDU{PDURef: pduRef, PackingByteOrder: communication.ByteOrderOpaque}
+	if e := node.SelectElement("PACKING-BYTE-ORDER"); e != nil {
+		pdu.PackingByteOrder = communication.ByteOrder(e.Text())
+	}
+	if e := node.SelectElement("START-POSITION"); e != nil {
+		v, err := util.ToInt64(e.Text())
+		if err != nil {
+			return nil, fmt.Errorf("invalid START-POSITION %v", e.Text())
+		}
+		pdu.StartPosition = int(v)
+	}
+	return pdu, nil
+}
+
+// LookupFrame 返回物理通道上 identifier 对应的 frame triggering, channel 为物理通道的 AR 路径或 SHORT-NAME
+func (tp *TopoLogyParser) LookupFrame(channel string, identifier uint32) (*FrameTriggering, error) {
+	if ft, ok := tp.frameTriggerings[frameKey{channel: channel, identifier: identifier}]; ok {
+		return ft, nil
+	}
+	channelPath, err := tp.ResolveChannel(channel)
+	if err != nil {
+		return nil, err
+	}
+	if ft, ok := tp.frameTriggerings[frameKey{channel: channelPath, identifier: identifier}]; ok {
+		return ft, nil
+	}
+	return nil, fmt.Errorf("no frame with identifier 0x%x found on channel %v", identifier, channel)
+}
@@ -5,10 +5,12 @@ import (
 
 	"github.com/beevik/etree"
 
+	"github.com/yisaer/arxml-converter/ast"
 	"github.com/yisaer/arxml-converter/util"
 )
 
-func (tp *TopoLogyParser) parseSOCKETCONNECTIONBUNDLE(node *etree.Element) (err error) {
+// parseSOCKETCONNECTIONBUNDLE 解析 bundle 与其中全部 SOCKET-CONNECTION 的 SOCKET-CONNECTION-IPDU-IDENTIFIER
+func (tp *TopoLogyParser) parseSOCKETCONNECTIONBUNDLE(node *etree.Element, channel string) (err error) {
 	sn, err := util.GetShortname(node)
 	if err != nil {
 		return err
@@ -18,23 +20,59 @@ func (tp *TopoLogyParser) parseSOCKETCONNECTIONBUNDLE(node *etree.Element) (err
 			err = fmt.Errorf("parse %v SOCKETCONNECTIONBUNDLE error: %v", sn, err)
 		}
 	}()
-	bundleConnectionsElement := node.SelectElement("BUNDLED-CONNECTIONS")
-	if bundleConnectionsElement == nil {
-		return nil
-	}
-	socketConnectionElement := bundleConnectionsElement.SelectElement("SOCKET-CONNECTION")
-	if socketConnectionElement == nil {
-		return nil
-	}
-	pdusElement := socketConnectionElement.SelectElement("PDUS")
-	if pdusElement == nil {
-		return nil
-	}
-	socketConnectionIPDUIdentifierList := pdusElement.SelectElements("SOCKET-CONNECTION-IPDU-IDENTIFIER")
-	for index, scipdui := range socketConnectionIPDUIdentifierList {
-		if err := tp.parseSOCKETCONNECTIONIPDUIDENTIFIER(scipdui); err != nil {
-			return fmt.Errorf("parse %v SOCKET-CONNECTION-IPDU-IDENTIFIER err: %v", index, err)
+	serverPort, err := tp.socketPortRef(node, "SERVER-PORT-REF")
+	if err != nil {
+		return err
+	}
+	type socketConnectionPdus struct {
+		pdus       *etree.Element
+		clientPort string
+	}
+	pdusElements := make([]socketConnectionPdus, 0)
+	if pdusElement := node.SelectElement("PDUS"); pdusElement != nil {
+		pdusElements = append(pdusElements, socketConnectionPdus{pdus: pdusElement})
+	}
+	if bundleConnectionsElement := node.SelectElement("BUNDLED-CONNECTIONS"); bundleConnectionsElement != nil {
+		for _, socketConnectionElement := range bundleConnectionsElement.SelectElements("SOCKET-CONNECTION") {
+			pdusElement := socketConnectionElement.SelectElement("PDUS")
+			if pdusElement == nil {
+				continue
+			}
+			clientPort, err := tp.socketPortRef(socketConnectionElement, "CLIENT-PORT-REF")
+			if err != nil {
+				return err
+			}
+			pdusElements = append(pdusElements, socketConnectionPdus{pdus: pdusElement, clientPort: clientPort})
+		}
+	}
+	for _, pdusElement := range pdusElements {
+		socketConnectionIPDUIdentifierList := pdusElement.pdus.SelectElements("SOCKET-CONNECTION-IPDU-IDENTIFIER")
+		for index, scipdui := range socketConnectionIPDUIdentifierList {
+			if err := tp.parseSOCKETCONNECTIONIPDUIDENTIFIER(scipdui, channel, serverPort, pdusElement.clientPort); err != nil {
+				return fmt.Errorf("parse %v SOCKET-CONNECTION-IPDU-IDENTIFIER err: %v", index, err)
+			}
 		}
 	}
 	return nil
 }
+
+func (tp *TopoLogyParser) socketPortRef(node *etree.Element, tag string) (string, error) {
+	ref := node.SelectElement(tag)
+	if ref == nil {
+		return "", nil
+	}
+	return tp.index.RefPath(ref)
+}
+
+// headerMajorVersion 根据 server port 上的 PROVIDED-SERVICE-INSTANCE 或 client port 上的 CONSUMED-SERVICE-INSTANCE
+// 确定 header id 所属 service 的 major version, 无法确定时返回 ast.AnyMajorVersion
+func (tp *TopoLogyParser) headerMajorVersion(serverPort, clientPort string, headerID uint32) int {
+	serviceID := uint16(headerID >> 16)
+	if v, ok := tp.providedVersions[socketServiceKey{socket: serverPort, serviceID: serviceID}]; ok {
+		return v
+	}
+	if v, ok := tp.consumedVersions[socketServiceKey{socket: clientPort, serviceID: serviceID}]; ok {
+		return v
+	}
+	return ast.AnyMajorVersion
+}
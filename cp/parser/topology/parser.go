@@ -2,40 +2,160 @@ package topology
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/beevik/etree"
 
+	"github.com/yisaer/arxml-converter/ast"
 	"github.com/yisaer/arxml-converter/util"
 )
 
 type TopoLogyParser struct {
-	clusterArPackage *etree.Element
-	serviceIDMap     map[uint16]string
-	headerIdRef      map[uint32]string
+	clusterArPackages []*etree.Element
+	index             *util.ArIndex
+	serviceIDMap      map[ast.ServiceKey]string
+	// headerIdRef 为物理通道内 header id 到 PDU-TRIGGERING 的 AR 路径的映射, method 的 call 与 return 共用 header id,
+	// headerChannels 为 header id 所在物理通道的 AR 路径, headerVersions 为物理通道内 header id 所属 service 的 major version
+	headerIdRef    map[HeaderKey][]string
+	headerChannels map[uint32][]string
+	headerVersions map[channelHeaderKey][]int
+	// providedVersions 与 consumedVersions 为 socket address 上 service instance 的 major version
+	providedVersions map[socketServiceKey]int
+	consumedVersions map[socketServiceKey]int
+	// pduTriggeringRef 为 PDU-TRIGGERING 的 AR 路径到 I-PDU-REF 的映射
 	pduTriggeringRef map[string]string
+	eventGroups      *ast.EventGroupRegistry
+	// serviceInstances 与 consumedServiceInstances 为 service instance 到 SHORT-NAME 的映射
+	serviceInstances         map[serviceInstanceKey]string
+	consumedServiceInstances map[serviceInstanceKey]string
+	// frameTriggerings 为 CAN/LIN/FlexRay 的 frame triggering, channelNames 为全部物理通道 SHORT-NAME 到 AR 路径的映射
+	frameTriggerings map[frameKey]*FrameTriggering
+	channelNames     map[string][]string
 }
 
-func NewTopoLogyParser() *TopoLogyParser {
+// HeaderKey 以物理通道的 AR 路径与 service 的 major version 区分相同的 header id,
+// MajorVersion 为 ast.AnyMajorVersion 时 PDU 所在的 socket 没有对应的 service instance
+type HeaderKey struct {
+	Channel      string
+	MajorVersion int
+	HeaderID     uint32
+}
+
+type channelHeaderKey struct {
+	channel  string
+	headerID uint32
+}
+
+type socketServiceKey struct {
+	socket    string
+	serviceID uint16
+}
+
+type serviceInstanceKey struct {
+	serviceID  uint16
+	instanceID uint16
+}
+
+func NewTopoLogyParser(index *util.ArIndex) *TopoLogyParser {
 	return &TopoLogyParser{
-		serviceIDMap:     make(map[uint16]string),
-		headerIdRef:      make(map[uint32]string),
+		index:            index,
+		serviceIDMap:     make(map[ast.ServiceKey]string),
+		headerIdRef:      make(map[HeaderKey][]string),
+		headerChannels:   make(map[uint32][]string),
+		headerVersions:   make(map[channelHeaderKey][]int),
+		providedVersions: make(map[socketServiceKey]int),
+		consumedVersions: make(map[socketServiceKey]int),
 		pduTriggeringRef: make(map[string]string),
+		eventGroups:      ast.NewEventGroupRegistry(),
+
+		serviceInstances:         make(map[serviceInstanceKey]string),
+		consumedServiceInstances: make(map[serviceInstanceKey]string),
+		frameTriggerings:         make(map[frameKey]*FrameTriggering),
+		channelNames:             make(map[string][]string),
 	}
 }
 
-func (tp *TopoLogyParser) GetServiceIDMap() map[uint16]string {
+func (tp *TopoLogyParser) GetServiceIDMap() map[ast.ServiceKey]string {
 	return tp.serviceIDMap
 }
 
-func (tp *TopoLogyParser) GetHeaderRef() map[uint32]string {
+func (tp *TopoLogyParser) GetHeaderRef() map[HeaderKey][]string {
 	return tp.headerIdRef
 }
 
+// LookupHeader 返回 header id 对应的 PDU-TRIGGERING 的 AR 路径, channel 为物理通道的 AR 路径或 SHORT-NAME.
+// channel 为空时在全部物理通道中查找, header id 位于多个物理通道时返回错误.
+// majorVersion 为 ast.AnyMajorVersion 时 header id 在该物理通道中必须只属于一个版本的 service
+func (tp *TopoLogyParser) LookupHeader(channel string, majorVersion int, headerID uint32) ([]string, error) {
+	if channel == "" {
+		channels := tp.headerChannels[headerID]
+		switch len(channels) {
+		case 0:
+			return nil, fmt.Errorf("no header ref for %d", headerID)
+		case 1:
+			channel = channels[0]
+		default:
+			return nil, fmt.Errorf("header id %d is ambiguous, found on channels %v", headerID, channels)
+		}
+	}
+	channelPath, err := tp.ResolveChannel(channel)
+	if err != nil {
+		return nil, err
+	}
+	versions, ok := tp.headerVersions[channelHeaderKey{channel: channelPath, headerID: headerID}]
+	if !ok {
+		return nil, fmt.Errorf("no header ref for %d on channel %v", headerID, channel)
+	}
+	version, err := selectHeaderVersion(versions, majorVersion)
+	if err != nil {
+		return nil, fmt.Errorf("header id %d on channel %v: %v", headerID, channel, err)
+	}
+	return tp.headerIdRef[HeaderKey{Channel: channelPath, MajorVersion: version, HeaderID: headerID}], nil
+}
+
+// selectHeaderVersion 选择与 majorVersion 匹配的版本, 没有匹配的版本时使用无法确定版本的 PDU
+func selectHeaderVersion(versions []int, majorVersion int) (int, error) {
+	if majorVersion == ast.AnyMajorVersion {
+		if len(versions) > 1 {
+			return 0, fmt.Errorf("used by major versions %v, interface version is required", versions)
+		}
+		return versions[0], nil
+	}
+	found := false
+	for _, v := range versions {
+		if v == majorVersion {
+			return v, nil
+		}
+		found = found || v == ast.AnyMajorVersion
+	}
+	if found {
+		return ast.AnyMajorVersion, nil
+	}
+	return 0, fmt.Errorf("no pdu for major version %d", majorVersion)
+}
+
+// ResolveChannel 返回物理通道的 AR 路径, channel 为 SHORT-NAME 时需唯一
+func (tp *TopoLogyParser) ResolveChannel(channel string) (string, error) {
+	paths := tp.channelNames[channel]
+	switch len(paths) {
+	case 0:
+		if _, ok := tp.index.Lookup(channel); !ok {
+			return "", fmt.Errorf("no physical channel %v found", channel)
+		}
+		return channel, nil
+	case 1:
+		return paths[0], nil
+	}
+	return "", fmt.Errorf("physical channel name %v is ambiguous: %v", channel, paths)
+}
+
 func (tp *TopoLogyParser) GetPDUTriggeringRef() map[string]string {
 	return tp.pduTriggeringRef
 }
 
+func (tp *TopoLogyParser) GetEventGroups() *ast.EventGroupRegistry {
+	return tp.eventGroups
+}
+
 func (tp *TopoLogyParser) ParseTopoLogy(node *etree.Element) (err error) {
 	defer func() {
 		if err != nil {
@@ -50,26 +170,53 @@ func (tp *TopoLogyParser) ParseTopoLogy(node *etree.Element) (err error) {
 	if err := tp.searchCluster(arpackagesList); err != nil {
 		return err
 	}
-	if err := tp.parseCluster(); err != nil {
-		return fmt.Errorf("parse cluster err: %v", err)
+	ethClusters := 0
+	for _, clusterArPackage := range tp.clusterArPackages {
+		n, err := tp.parseCluster(clusterArPackage)
+		if err != nil {
+			return fmt.Errorf("parse cluster err: %v", err)
+		}
+		ethClusters += n
+	}
+	if ethClusters == 0 && len(tp.frameTriggerings) == 0 {
+		return fmt.Errorf("parse cluster err: ETHERNET-CLUSTER not found")
 	}
 	return nil
 }
 
-func (tp *TopoLogyParser) parseCluster() (err error) {
+// parseCluster 解析 Clusters package 中的全部 cluster, 返回 ETHERNET-CLUSTER 的数量
+func (tp *TopoLogyParser) parseCluster(clusterArPackage *etree.Element) (n int, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("parseCluster err: %v", err)
 		}
 	}()
-	elements, err := util.GetElements(tp.clusterArPackage)
+	elements, err := util.GetElements(clusterArPackage)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	ethClusterElement := elements.SelectElement("ETHERNET-CLUSTER")
-	if ethClusterElement == nil {
-		return fmt.Errorf("ETHERNET-CLUSTER not found")
+	if err := tp.parseBusClusters(elements); err != nil {
+		return 0, err
+	}
+	ethClusterElements := elements.SelectElements("ETHERNET-CLUSTER")
+	for _, ethClusterElement := range ethClusterElements {
+		if err := tp.parseEthernetCluster(ethClusterElement); err != nil {
+			return 0, err
+		}
+	}
+	return len(ethClusterElements), nil
+}
+
+func (tp *TopoLogyParser) parseEthernetCluster(ethClusterElement *etree.Element) (err error) {
+	sn, err := util.GetShortname(ethClusterElement)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("ETHERNET-CLUSTER %v: %v", sn, err)
+		}
+	}()
 	ethClusterVar := ethClusterElement.SelectElement("ETHERNET-CLUSTER-VARIANTS")
 	if ethClusterVar == nil {
 		return fmt.Errorf("ETHERNET-CLUSTER-VARIANTS not found")
@@ -92,11 +239,17 @@ func (tp *TopoLogyParser) parseCluster() (err error) {
 }
 
 func (tp *TopoLogyParser) parseETHERNETPHYSICALCHANNEL(node *etree.Element) (err error) {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return err
+	}
+	channel := util.GetArPath(node)
+	tp.channelNames[sn] = append(tp.channelNames[sn], channel)
 	soAdConfigElement := node.SelectElement("SO-AD-CONFIG")
 	if soAdConfigElement == nil {
 		return fmt.Errorf("SO-AD-CONFIG not found")
 	}
-	if err := tp.parseSoAdConfig(soAdConfigElement); err != nil {
+	if err := tp.parseSoAdConfig(soAdConfigElement, channel); err != nil {
 		return fmt.Errorf("parse So-AD-CONFIG err: %v", err)
 	}
 	pduTriggeringsElement := node.SelectElement("PDU-TRIGGERINGS")
@@ -109,7 +262,7 @@ func (tp *TopoLogyParser) parseETHERNETPHYSICALCHANNEL(node *etree.Element) (err
 	return nil
 }
 
-func (tp *TopoLogyParser) parseSoAdConfig(soAdConfigElement *etree.Element) (err error) {
+func (tp *TopoLogyParser) parseSoAdConfig(soAdConfigElement *etree.Element, channel string) (err error) {
 	// parse service id
 	socketAddresssElement := soAdConfigElement.SelectElement("SOCKET-ADDRESSS")
 	if socketAddresssElement == nil {
@@ -129,10 +282,15 @@ func (tp *TopoLogyParser) parseSoAdConfig(soAdConfigElement *etree.Element) (err
 	}
 	socketConnectionBundleList := connectionBundlesElement.SelectElements("SOCKET-CONNECTION-BUNDLE")
 	for index, socketConnectionBundle := range socketConnectionBundleList {
-		if err := tp.parseSOCKETCONNECTIONBUNDLE(socketConnectionBundle); err != nil {
+		if err := tp.parseSOCKETCONNECTIONBUNDLE(socketConnectionBundle, channel); err != nil {
 			return fmt.Errorf("parse %v SOCKET-CONNECTION-BUNDLE err :%v", index, err)
 		}
 	}
+
+	// parse event group
+	if err := tp.parseEventGroups(soAdConfigElement); err != nil {
+		return fmt.Errorf("parse event groups err: %v", err)
+	}
 	return nil
 }
 
@@ -143,14 +301,16 @@ func (tp *TopoLogyParser) searchCluster(arpackagesList []*etree.Element) error {
 			return err
 		}
 		if sn == "Clusters" {
-			tp.clusterArPackage = arPackage
-			return nil
+			tp.clusterArPackages = append(tp.clusterArPackages, arPackage)
 		}
 	}
-	return fmt.Errorf("AR-PACKAGES Cluster not found")
+	if len(tp.clusterArPackages) == 0 {
+		return fmt.Errorf("AR-PACKAGES Cluster not found")
+	}
+	return nil
 }
 
-func (tp *TopoLogyParser) parseSOCKETCONNECTIONIPDUIDENTIFIER(node *etree.Element) (err error) {
+func (tp *TopoLogyParser) parseSOCKETCONNECTIONIPDUIDENTIFIER(node *etree.Element, channel, serverPort, clientPort string) (err error) {
 	headerIDElement := node.SelectElement("HEADER-ID")
 	if headerIDElement == nil {
 		return fmt.Errorf("HEADER-ID not found")
@@ -160,9 +320,27 @@ func (tp *TopoLogyParser) parseSOCKETCONNECTIONIPDUIDENTIFIER(node *etree.Elemen
 		return fmt.Errorf("parse HEADER-ID err: %v", err)
 	}
 	pduTriggeringRefElement := node.SelectElement("PDU-TRIGGERING-REF")
-	pduTriggeringRefElementRaw := pduTriggeringRefElement.Text()
-	if !strings.Contains(pduTriggeringRefElementRaw, "return") {
-		tp.headerIdRef[headerID] = pduTriggeringRefElementRaw
+	if pduTriggeringRefElement == nil {
+		return fmt.Errorf("PDU-TRIGGERING-REF not found")
+	}
+	pduTriggeringRefElementRaw, err := tp.index.RefPath(pduTriggeringRefElement)
+	if err != nil {
+		return err
+	}
+	key := HeaderKey{Channel: channel, MajorVersion: tp.headerMajorVersion(serverPort, clientPort, headerID), HeaderID: headerID}
+	refs, ok := tp.headerIdRef[key]
+	if !ok {
+		chKey := channelHeaderKey{channel: channel, headerID: headerID}
+		if _, ok := tp.headerVersions[chKey]; !ok {
+			tp.headerChannels[headerID] = append(tp.headerChannels[headerID], channel)
+		}
+		tp.headerVersions[chKey] = append(tp.headerVersions[chKey], key.MajorVersion)
+	}
+	for _, ref := range refs {
+		if ref == pduTriggeringRefElementRaw {
+			return nil
+		}
 	}
+	tp.headerIdRef[key] = append(refs, pduTriggeringRefElementRaw)
 	return nil
 }
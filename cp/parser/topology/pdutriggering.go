@@ -19,14 +19,17 @@ func (tp *TopoLogyParser) parsePDUTRIGGERINGS(node *etree.Element) error {
 }
 
 func (tp *TopoLogyParser) parsePDUTRIGGERING(node *etree.Element) error {
-	sn, err := util.GetShortname(node)
-	if err != nil {
+	if _, err := util.GetShortname(node); err != nil {
 		return err
 	}
 	iPDURefElement := node.SelectElement("I-PDU-REF")
 	if iPDURefElement == nil {
 		return fmt.Errorf("I-PDU-REF element not found")
 	}
-	tp.pduTriggeringRef[sn] = iPDURefElement.Text()
+	iPDURef, err := tp.index.RefPath(iPDURefElement)
+	if err != nil {
+		return err
+	}
+	tp.pduTriggeringRef[util.GetArPath(node)] = iPDURef
 	return nil
 }
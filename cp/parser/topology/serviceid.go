@@ -2,9 +2,12 @@ package topology
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/beevik/etree"
 
+	"github.com/yisaer/arxml-converter/ast"
 	"github.com/yisaer/arxml-converter/util"
 )
 
@@ -13,20 +16,28 @@ func (tp *TopoLogyParser) parseSOCKETADDRESS(node *etree.Element) (err error) {
 	if applicationEndpointElement == nil {
 		return nil
 	}
+	socket := util.GetArPath(node)
+	if conServiceInstancesElement := applicationEndpointElement.SelectElement("CONSUMED-SERVICE-INSTANCES"); conServiceInstancesElement != nil {
+		for index, consumedInstance := range conServiceInstancesElement.SelectElements("CONSUMED-SERVICE-INSTANCE") {
+			if err := tp.parseConsumedServiceInstance(consumedInstance, socket); err != nil {
+				return fmt.Errorf("parse %v consumedServiceInstance err: %v", index, err)
+			}
+		}
+	}
 	proServiceInstancesElement := applicationEndpointElement.SelectElement("PROVIDED-SERVICE-INSTANCES")
 	if proServiceInstancesElement == nil {
 		return nil
 	}
 	providedServiceInstanceList := proServiceInstancesElement.SelectElements("PROVIDED-SERVICE-INSTANCE")
 	for index, providedInstance := range providedServiceInstanceList {
-		if err := tp.parseProvidedServiceInstance(providedInstance); err != nil {
+		if err := tp.parseProvidedServiceInstance(providedInstance, socket); err != nil {
 			return fmt.Errorf("parse %v providedServiceInstance err: %v", index, err)
 		}
 	}
 	return nil
 }
 
-func (tp *TopoLogyParser) parseProvidedServiceInstance(node *etree.Element) (err error) {
+func (tp *TopoLogyParser) parseProvidedServiceInstance(node *etree.Element, socket string) (err error) {
 	sn, err := util.GetShortname(node)
 	if err != nil {
 		return err
@@ -39,6 +50,113 @@ func (tp *TopoLogyParser) parseProvidedServiceInstance(node *etree.Element) (err
 	if err != nil {
 		return err
 	}
-	tp.serviceIDMap[serviceID] = sn
+	majorVersion, err := getServiceMajorVersion(node)
+	if err != nil {
+		return fmt.Errorf("provided service instance %v: %v", sn, err)
+	}
+	key := ast.ServiceKey{ServiceID: serviceID, MajorVersion: majorVersion}
+	// 同一版本的 service 可以在多个 socket address 上提供多个 instance
+	if _, ok := tp.serviceIDMap[key]; !ok {
+		tp.serviceIDMap[key] = sn
+	}
+	tp.providedVersions[socketServiceKey{socket: socket, serviceID: serviceID}] = int(majorVersion)
+	instanceID, ok, err := getInstanceIdentifier(node)
+	if err != nil {
+		return fmt.Errorf("provided service instance %v: %v", sn, err)
+	}
+	if ok {
+		tp.serviceInstances[serviceInstanceKey{serviceID: serviceID, instanceID: instanceID}] = sn
+	}
 	return nil
 }
+
+// parseConsumedServiceInstance 登记配置了 SERVICE-IDENTIFIER 与 INSTANCE-IDENTIFIER 的 consumer
+func (tp *TopoLogyParser) parseConsumedServiceInstance(node *etree.Element, socket string) error {
+	sn, err := util.GetShortname(node)
+	if err != nil {
+		return err
+	}
+	serviceID, ok, err := getServiceIdentifier(node)
+	if err != nil || !ok {
+		return err
+	}
+	if versionElement := node.FindElement("SD-CLIENT-CONFIG/CLIENT-SERVICE-MAJOR-VERSION"); versionElement != nil {
+		v, err := strconv.ParseUint(strings.TrimSpace(versionElement.Text()), 10, 8)
+		if err != nil {
+			return fmt.Errorf("consumed service instance %v: invalid %v %v", sn, versionElement.Tag, versionElement.Text())
+		}
+		tp.consumedVersions[socketServiceKey{socket: socket, serviceID: serviceID}] = int(v)
+	}
+	instanceID, ok, err := getInstanceIdentifier(node)
+	if err != nil {
+		return fmt.Errorf("consumed service instance %v: %v", sn, err)
+	}
+	if !ok {
+		return nil
+	}
+	key := serviceInstanceKey{serviceID: serviceID, instanceID: instanceID}
+	if _, ok := tp.consumedServiceInstances[key]; !ok {
+		tp.consumedServiceInstances[key] = sn
+	}
+	return nil
+}
+
+func getInstanceIdentifier(node *etree.Element) (uint16, bool, error) {
+	instanceIDElement := node.SelectElement("INSTANCE-IDENTIFIER")
+	if instanceIDElement == nil {
+		return 0, false, nil
+	}
+	instanceID, err := util.ToUint16(instanceIDElement.Text())
+	if err != nil {
+		return 0, false, err
+	}
+	return instanceID, true, nil
+}
+
+// LookupServiceInstance 根据 service id 与 instance id 查找 service instance 的 SHORT-NAME, PROVIDED-SERVICE-INSTANCE 优先
+func (tp *TopoLogyParser) LookupServiceInstance(serviceID, instanceID uint16) (string, bool) {
+	key := serviceInstanceKey{serviceID: serviceID, instanceID: instanceID}
+	if sn, ok := tp.serviceInstances[key]; ok {
+		return sn, true
+	}
+	sn, ok := tp.consumedServiceInstances[key]
+	return sn, ok
+}
+
+// getServiceMajorVersion 读取 SD-SERVER-CONFIG 的 SERVER-SERVICE-MAJOR-VERSION, 未配置时读取 instance 的 MAJOR-VERSION
+func getServiceMajorVersion(node *etree.Element) (uint8, error) {
+	versionElement := node.FindElement("SD-SERVER-CONFIG/SERVER-SERVICE-MAJOR-VERSION")
+	if versionElement == nil {
+		versionElement = node.SelectElement("MAJOR-VERSION")
+	}
+	if versionElement == nil {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(versionElement.Text()), 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v %v", versionElement.Tag, versionElement.Text())
+	}
+	return uint8(v), nil
+}
+
+// LookupService 根据 service id 与 interface major version 查找 PROVIDED-SERVICE-INSTANCE 的 SHORT-NAME,
+// majorVersion 为 ast.AnyMajorVersion 时 service 必须只有一个版本
+func (tp *TopoLogyParser) LookupService(serviceID uint16, majorVersion int) (string, error) {
+	found := ""
+	for key, sn := range tp.serviceIDMap {
+		if !key.Matches(serviceID, majorVersion) {
+			continue
+		}
+		if found != "" {
+			return "", fmt.Errorf("service %d has several major versions, interface version is required", serviceID)
+		}
+		found = sn
+	}
+	if found == "" {
+		if majorVersion == ast.AnyMajorVersion {
+			return "", fmt.Errorf("no service found for %d", serviceID)
+		}
+		return "", fmt.Errorf("no service found for %d major version %d", serviceID, majorVersion)
+	}
+	return found, nil
+}
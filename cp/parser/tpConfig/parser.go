@@ -1,18 +1,43 @@
 package tpConfig
 
-import "github.com/beevik/etree"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/util"
+)
+
+// Connection 为 SOMEIP-TP-CONNECTION, MaxSegmentLength 与 MaxMessageLength 分别为 transport PDU 与 TP SDU 的 LENGTH (字节),
+// 未配置时为 0. RxTimeout 为 SOMEIP-TP-CHANNEL 的 RX-TIMEOUT-TIME
+type Connection struct {
+	TransportPDURef  string
+	TpSDURef         string
+	MaxSegmentLength int
+	MaxMessageLength int
+	RxTimeout        time.Duration
+}
 
 type TpConfigParser struct {
+	index *util.ArIndex
+	// pduMap 为 TRANSPORT-PDU-REF 到 TP-SDU-REF 的映射, 均为 PDU-TRIGGERING 的 AR 路径
 	pduMap map[string]string
+	// connections 以 TRANSPORT-PDU-REF 为 key
+	connections map[string]*Connection
 }
 
-func NewTpConfigParser() *TpConfigParser {
-	return &TpConfigParser{pduMap: make(map[string]string)}
+func NewTpConfigParser(index *util.ArIndex) *TpConfigParser {
+	return &TpConfigParser{index: index, pduMap: make(map[string]string), connections: make(map[string]*Connection)}
 }
 
 func (p *TpConfigParser) ParseTpConfig(node *etree.Element) error {
 	for _, element := range node.FindElements("//SOMEIP-TP-CONNECTION") {
-		p.parseSOMEIPTPCONNECTION(element)
+		if err := p.parseSOMEIPTPCONNECTION(element); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -26,10 +51,68 @@ func (p *TpConfigParser) parseSOMEIPTPCONNECTION(node *etree.Element) error {
 	if TRANSPORTPDUREFElement == nil {
 		return nil
 	}
-	p.pduMap[TRANSPORTPDUREFElement.Text()] = tpSDUREFElement.Text()
+	tpSDURef, err := p.index.RefPath(tpSDUREFElement)
+	if err != nil {
+		return err
+	}
+	transportPDURef, err := p.index.RefPath(TRANSPORTPDUREFElement)
+	if err != nil {
+		return err
+	}
+	p.pduMap[transportPDURef] = tpSDURef
+	connection := &Connection{TransportPDURef: transportPDURef, TpSDURef: tpSDURef}
+	if connection.MaxSegmentLength, err = p.pduLength(transportPDURef); err != nil {
+		return fmt.Errorf("TRANSPORT-PDU-REF %v: %v", transportPDURef, err)
+	}
+	if connection.MaxMessageLength, err = p.pduLength(tpSDURef); err != nil {
+		return fmt.Errorf("TP-SDU-REF %v: %v", tpSDURef, err)
+	}
+	if channelRefElement := node.SelectElement("TP-CHANNEL-REF"); channelRefElement != nil {
+		channel, err := p.index.Resolve(channelRefElement)
+		if err != nil {
+			return err
+		}
+		if e := channel.SelectElement("RX-TIMEOUT-TIME"); e != nil {
+			seconds, err := strconv.ParseFloat(strings.TrimSpace(e.Text()), 64)
+			if err != nil {
+				return fmt.Errorf("invalid RX-TIMEOUT-TIME %v in %v", e.Text(), util.GetArPath(channel))
+			}
+			connection.RxTimeout = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	p.connections[transportPDURef] = connection
 	return nil
 }
 
+// pduLength 返回 PDU-TRIGGERING 引用的 I-PDU 的 LENGTH, 未配置时为 0
+func (p *TpConfigParser) pduLength(pduTriggeringRef string) (int, error) {
+	pduTriggering, ok := p.index.Lookup(pduTriggeringRef)
+	if !ok {
+		return 0, nil
+	}
+	iPDURefElement := pduTriggering.SelectElement("I-PDU-REF")
+	if iPDURefElement == nil {
+		return 0, nil
+	}
+	iPDU, err := p.index.Resolve(iPDURefElement)
+	if err != nil {
+		return 0, err
+	}
+	lengthElement := iPDU.SelectElement("LENGTH")
+	if lengthElement == nil {
+		return 0, nil
+	}
+	length, err := util.ToInt64(lengthElement.Text())
+	if err != nil {
+		return 0, fmt.Errorf("invalid LENGTH %v", lengthElement.Text())
+	}
+	return int(length), nil
+}
+
 func (p *TpConfigParser) GetTpConfigPDUMap() map[string]string {
 	return p.pduMap
 }
+
+func (p *TpConfigParser) GetConnections() map[string]*Connection {
+	return p.connections
+}
@@ -0,0 +1,101 @@
+package e2e
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+
+	"github.com/yisaer/arxml-converter/util"
+)
+
+// ParseConfig 根据 profile 配置 (AP 的 E-2-E-PROFILE-CONFIGURATION 或 CP 的 END-TO-END-TRANSFORMATION-DESCRIPTION)
+// 与受保护元素的 props (END-2-END-EVENT-PROTECTION-PROPS 或 END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS) 生成 Config.
+// ARXML 中的偏移与长度以 bit 为单位, defaultOffset 与 defaultUpperHeader 为未配置时的字节数
+func ParseConfig(profileConfig, props *etree.Element, defaultOffset, defaultUpperHeader int) (*Config, error) {
+	nameElement := profileConfig.SelectElement("PROFILE-NAME")
+	if nameElement == nil {
+		return nil, fmt.Errorf("no PROFILE-NAME in %v", util.GetArPath(profileConfig))
+	}
+	profile, err := ParseProfile(nameElement.Text())
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{Profile: profile, Offset: defaultOffset, UpperHeaderLength: defaultUpperHeader}
+	if v, ok, err := getUint(profileConfig, "MAX-DELTA-COUNTER"); err != nil {
+		return nil, err
+	} else if ok {
+		cfg.MaxDeltaCounter = uint32(v)
+	}
+	bytes := map[string]*int{
+		"OFFSET":                     &cfg.Offset,
+		"UPPER-HEADER-BITS-TO-SHIFT": &cfg.UpperHeaderLength,
+	}
+	for tag, target := range bytes {
+		if err := getBytes(profileConfig, tag, target); err != nil {
+			return nil, err
+		}
+	}
+	bytes = map[string]*int{
+		"DATA-LENGTH":     &cfg.DataLength,
+		"MIN-DATA-LENGTH": &cfg.MinDataLength,
+		"MAX-DATA-LENGTH": &cfg.MaxDataLength,
+	}
+	for tag, target := range bytes {
+		if err := getBytes(props, tag, target); err != nil {
+			return nil, err
+		}
+	}
+	dataIDs := make([]uint32, 0)
+	if dataIDsElement := props.SelectElement("DATA-IDS"); dataIDsElement != nil {
+		for _, e := range dataIDsElement.SelectElements("DATA-ID") {
+			v, err := util.ToInt64(e.Text())
+			if err != nil || v < 0 || v > 0xFFFFFFFF {
+				return nil, fmt.Errorf("invalid DATA-ID %v", e.Text())
+			}
+			dataIDs = append(dataIDs, uint32(v))
+		}
+	}
+	if v, ok, err := getUint(props, "DATA-ID"); err != nil {
+		return nil, err
+	} else if ok {
+		dataIDs = append(dataIDs, uint32(v))
+	}
+	if profile == Profile22 {
+		for _, id := range dataIDs {
+			if id > 0xFF {
+				return nil, fmt.Errorf("data id 0x%x of %v exceeds 8 bits", id, profile)
+			}
+			cfg.DataIDList = append(cfg.DataIDList, uint8(id))
+		}
+	} else if len(dataIDs) > 0 {
+		cfg.DataID = dataIDs[0]
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func getUint(e *etree.Element, tag string) (int64, bool, error) {
+	raw := e.SelectElement(tag)
+	if raw == nil {
+		return 0, false, nil
+	}
+	v, err := util.ToInt64(raw.Text())
+	if err != nil || v < 0 {
+		return 0, false, fmt.Errorf("invalid %v %v", tag, raw.Text())
+	}
+	return v, true, nil
+}
+
+func getBytes(e *etree.Element, tag string, target *int) error {
+	v, ok, err := getUint(e, tag)
+	if err != nil || !ok {
+		return err
+	}
+	if v%8 != 0 {
+		return fmt.Errorf("%v %v is not byte aligned", tag, v)
+	}
+	*target = int(v / 8)
+	return nil
+}
@@ -0,0 +1,52 @@
+package e2e
+
+import (
+	"hash/crc32"
+	"hash/crc64"
+)
+
+// crc32P4Table 为 CRC-32P4 (多项式 0xF4ACFB13) 的反射表
+var crc32P4Table = crc32.MakeTable(0xC8DF352F)
+
+var crc64ECMATable = crc64.MakeTable(crc64.ECMA)
+
+// crc8H2F 为 CRC-8H2F: 多项式 0x2F, 初始值 0xFF, 结果异或 0xFF
+func crc8H2F(data []byte) uint8 {
+	crc := uint8(0xFF)
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x2F
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc ^ 0xFF
+}
+
+// crc16CCITT 为 CRC-16/CCITT-FALSE: 多项式 0x1021, 初始值 0xFFFF
+func crc16CCITT(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func crc32P4(data []byte) uint32 {
+	return crc32.Checksum(data, crc32P4Table)
+}
+
+// crc64ECMA 为 profile 7 使用的 CRC-64 (ECMA-182 反射, 初始值与结果异或均为全 1)
+func crc64ECMA(data []byte) uint64 {
+	return crc64.Checksum(data, crc64ECMATable)
+}
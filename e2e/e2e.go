@@ -0,0 +1,370 @@
+package e2e
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Profile string
+
+const (
+	Profile04 Profile = "PROFILE_04"
+	Profile05 Profile = "PROFILE_05"
+	Profile06 Profile = "PROFILE_06"
+	Profile07 Profile = "PROFILE_07"
+	Profile22 Profile = "PROFILE_22"
+)
+
+// ParseProfile 解析 PROFILE-NAME, 支持 PROFILE_04, P04, E2E_P04 等写法
+func ParseProfile(name string) (Profile, error) {
+	n := strings.ToUpper(strings.TrimSpace(name))
+	for _, prefix := range []string{"PROFILE_", "PROFILE", "E2E_P", "P"} {
+		if strings.HasPrefix(n, prefix) {
+			n = n[len(prefix):]
+			break
+		}
+	}
+	v, err := strconv.Atoi(n)
+	if err == nil {
+		switch v {
+		case 4:
+			return Profile04, nil
+		case 5:
+			return Profile05, nil
+		case 6:
+			return Profile06, nil
+		case 7:
+			return Profile07, nil
+		case 22:
+			return Profile22, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported E2E profile %v", name)
+}
+
+// HeaderLength 返回 E2E header 字节数
+func (p Profile) HeaderLength() int {
+	switch p {
+	case Profile04:
+		return 12
+	case Profile05:
+		return 3
+	case Profile06:
+		return 5
+	case Profile07:
+		return 20
+	case Profile22:
+		return 2
+	}
+	return 0
+}
+
+func (p Profile) maxCounter() uint32 {
+	switch p {
+	case Profile04:
+		return 0xFFFF
+	case Profile05, Profile06:
+		return 0xFF
+	case Profile22:
+		return 0x0F
+	}
+	return 0xFFFFFFFF
+}
+
+// Config 为一个受保护的 event/signal 的 E2E 配置. 受保护数据由 upper header 与 payload 组成,
+// 长度与偏移均以字节为单位
+type Config struct {
+	Profile Profile
+	// DataID 为 profile 4/5/6/7 的 data id, profile 5/6 只使用低 16 位
+	DataID uint32
+	// DataIDList 为 profile 22 按 counter 选择的 16 个 data id
+	DataIDList []uint8
+	// Offset 为 E2E header 在受保护数据中的偏移
+	Offset int
+	// UpperHeaderLength 为参与 CRC 计算的上层 header 长度, 如 SOME/IP header 中 request id 之后的 8 字节
+	UpperHeaderLength int
+	// DataLength 为定长数据的长度, 0 表示不校验
+	DataLength    int
+	MinDataLength int
+	MaxDataLength int
+	// MaxDeltaCounter 为相邻两帧 counter 允许的最大差值, 0 表示不限制
+	MaxDeltaCounter uint32
+}
+
+func (c *Config) Validate() error {
+	if c.Profile.HeaderLength() == 0 {
+		return fmt.Errorf("unsupported E2E profile %v", c.Profile)
+	}
+	if c.Offset < c.UpperHeaderLength {
+		return fmt.Errorf("E2E offset %v is inside the upper header of %v bytes", c.Offset, c.UpperHeaderLength)
+	}
+	switch c.Profile {
+	case Profile05, Profile06:
+		if c.DataID > 0xFFFF {
+			return fmt.Errorf("data id 0x%x of %v exceeds 16 bits", c.DataID, c.Profile)
+		}
+	case Profile22:
+		if len(c.DataIDList) != 16 {
+			return fmt.Errorf("%v needs 16 data ids, got %v", c.Profile, len(c.DataIDList))
+		}
+	}
+	return nil
+}
+
+type Status string
+
+const (
+	StatusOK            Status = "OK"
+	StatusRepeated      Status = "REPEATED"
+	StatusWrongSequence Status = "WRONGSEQUENCE"
+	StatusError         Status = "ERROR"
+	// StatusNotAvailable 表示缺少 upper header, 未进行校验
+	StatusNotAvailable Status = "NOTAVAILABLE"
+)
+
+// Result 为 E2E header 的内容与校验结果
+type Result struct {
+	Status  Status  `json:"status"`
+	Profile Profile `json:"profile"`
+	Counter uint32  `json:"counter"`
+	DataID  uint32  `json:"data_id"`
+	CRC     uint64  `json:"crc"`
+}
+
+type header struct {
+	crc     uint64
+	counter uint32
+	dataID  uint32
+	// length 为 header 中的长度字段, 没有长度字段的 profile 为 -1
+	length int
+}
+
+func (c *Config) readHeader(b []byte) header {
+	h := b[c.Offset:]
+	switch c.Profile {
+	case Profile04:
+		return header{
+			length:  int(binary.BigEndian.Uint16(h[0:2])),
+			counter: uint32(binary.BigEndian.Uint16(h[2:4])),
+			dataID:  binary.BigEndian.Uint32(h[4:8]),
+			crc:     uint64(binary.BigEndian.Uint32(h[8:12])),
+		}
+	case Profile05:
+		return header{length: -1, crc: uint64(binary.LittleEndian.Uint16(h[0:2])), counter: uint32(h[2]), dataID: c.DataID}
+	case Profile06:
+		return header{
+			crc:     uint64(binary.BigEndian.Uint16(h[0:2])),
+			length:  int(binary.BigEndian.Uint16(h[2:4])),
+			counter: uint32(h[4]),
+			dataID:  c.DataID,
+		}
+	case Profile07:
+		return header{
+			crc:     binary.BigEndian.Uint64(h[0:8]),
+			length:  int(binary.BigEndian.Uint32(h[8:12])),
+			counter: binary.BigEndian.Uint32(h[12:16]),
+			dataID:  binary.BigEndian.Uint32(h[16:20]),
+		}
+	}
+	counter := uint32(h[1] & 0x0F)
+	return header{length: -1, crc: uint64(h[0]), counter: counter, dataID: uint32(c.DataIDList[counter])}
+}
+
+func (c *Config) writeHeader(b []byte, hd header) {
+	h := b[c.Offset:]
+	switch c.Profile {
+	case Profile04:
+		binary.BigEndian.PutUint16(h[0:2], uint16(hd.length))
+		binary.BigEndian.PutUint16(h[2:4], uint16(hd.counter))
+		binary.BigEndian.PutUint32(h[4:8], hd.dataID)
+		binary.BigEndian.PutUint32(h[8:12], uint32(hd.crc))
+	case Profile05:
+		binary.LittleEndian.PutUint16(h[0:2], uint16(hd.crc))
+		h[2] = uint8(hd.counter)
+	case Profile06:
+		binary.BigEndian.PutUint16(h[0:2], uint16(hd.crc))
+		binary.BigEndian.PutUint16(h[2:4], uint16(hd.length))
+		h[4] = uint8(hd.counter)
+	case Profile07:
+		binary.BigEndian.PutUint64(h[0:8], hd.crc)
+		binary.BigEndian.PutUint32(h[8:12], uint32(hd.length))
+		binary.BigEndian.PutUint32(h[12:16], hd.counter)
+		binary.BigEndian.PutUint32(h[16:20], hd.dataID)
+	case Profile22:
+		h[0] = uint8(hd.crc)
+		h[1] = h[1]&0xF0 | uint8(hd.counter&0x0F)
+	}
+}
+
+// computeCRC 计算除 CRC 字段外全部受保护数据的 CRC, profile 5/6/22 的 data id 追加在数据之后
+func (c *Config) computeCRC(b []byte, counter uint32) uint64 {
+	off := c.Offset
+	concat := func(parts ...[]byte) []byte {
+		data := make([]byte, 0, len(b)+1)
+		for _, part := range parts {
+			data = append(data, part...)
+		}
+		return data
+	}
+	switch c.Profile {
+	case Profile04:
+		return uint64(crc32P4(concat(b[:off+8], b[off+12:])))
+	case Profile05:
+		return uint64(crc16CCITT(concat(b[:off], b[off+2:], []byte{uint8(c.DataID), uint8(c.DataID >> 8)})))
+	case Profile06:
+		return uint64(crc16CCITT(concat(b[:off], b[off+2:], []byte{uint8(c.DataID >> 8), uint8(c.DataID)})))
+	case Profile07:
+		return crc64ECMA(concat(b[:off], b[off+8:]))
+	}
+	return uint64(crc8H2F(concat(b[:off], b[off+1:], []byte{c.DataIDList[counter&0x0F]})))
+}
+
+// Protect 在 payload 中插入 E2E header, 返回不含 upper header 的数据. 用于生成仿真与测试数据
+func (c *Config) Protect(upperHeader, payload []byte, counter uint32) ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if len(upperHeader) != c.UpperHeaderLength {
+		return nil, fmt.Errorf("E2E upper header needs %v bytes, got %v", c.UpperHeaderLength, len(upperHeader))
+	}
+	pos := c.Offset - c.UpperHeaderLength
+	if len(payload) < pos {
+		return nil, fmt.Errorf("E2E offset %v exceeds payload of %v bytes", pos, len(payload))
+	}
+	b := make([]byte, 0, len(upperHeader)+len(payload)+c.Profile.HeaderLength())
+	b = append(b, upperHeader...)
+	b = append(b, payload[:pos]...)
+	b = append(b, make([]byte, c.Profile.HeaderLength())...)
+	b = append(b, payload[pos:]...)
+	hd := header{counter: counter & c.Profile.maxCounter(), dataID: c.DataID, length: len(b)}
+	c.writeHeader(b, hd)
+	hd.crc = c.computeCRC(b, hd.counter)
+	c.writeHeader(b, hd)
+	return b[c.UpperHeaderLength:], nil
+}
+
+// Checker 校验同一个受保护 event/signal 的连续报文, 保存上一帧的 counter
+type Checker struct {
+	config *Config
+
+	mu          sync.Mutex
+	initialized bool
+	lastCounter uint32
+}
+
+func NewChecker(config *Config) (*Checker, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Checker{config: config}, nil
+}
+
+// Check 校验 E2E header 并返回去掉 E2E header 的 payload. 配置了 upper header 而 upperHeader 为 nil 时
+// 只去掉 E2E header, 状态为 StatusNotAvailable. 校验失败通过 Result.Status 返回, 数据不足以包含 E2E header 时返回 error
+func (c *Checker) Check(upperHeader, payload []byte) (*Result, []byte, error) {
+	cfg := c.config
+	verify := upperHeader != nil || cfg.UpperHeaderLength == 0
+	if upperHeader == nil {
+		upperHeader = make([]byte, cfg.UpperHeaderLength)
+	}
+	if len(upperHeader) != cfg.UpperHeaderLength {
+		return nil, nil, fmt.Errorf("E2E upper header needs %v bytes, got %v", cfg.UpperHeaderLength, len(upperHeader))
+	}
+	headerLength := cfg.Profile.HeaderLength()
+	if len(payload) < cfg.Offset-cfg.UpperHeaderLength+headerLength {
+		return nil, nil, fmt.Errorf("E2E %v header needs %v bytes at offset %v, got %v bytes",
+			cfg.Profile, headerLength, cfg.Offset-cfg.UpperHeaderLength, len(payload))
+	}
+	b := make([]byte, 0, len(upperHeader)+len(payload))
+	b = append(b, upperHeader...)
+	b = append(b, payload...)
+	hd := cfg.readHeader(b)
+	result := &Result{Profile: cfg.Profile, Counter: hd.counter, DataID: hd.dataID, CRC: hd.crc}
+
+	end := len(b)
+	if hd.length >= cfg.Offset+headerLength && hd.length < end {
+		end = hd.length
+	}
+	stripped := make([]byte, 0, end-headerLength)
+	stripped = append(stripped, b[cfg.UpperHeaderLength:cfg.Offset]...)
+	stripped = append(stripped, b[cfg.Offset+headerLength:end]...)
+	if !verify {
+		result.Status = StatusNotAvailable
+		return result, stripped, nil
+	}
+	result.Status = c.verify(b, hd)
+	return result, stripped, nil
+}
+
+func (c *Checker) verify(b []byte, hd header) Status {
+	cfg := c.config
+	switch {
+	case hd.length >= 0 && hd.length != len(b):
+		return StatusError
+	case cfg.DataLength > 0 && len(b) != cfg.DataLength:
+		return StatusError
+	case cfg.MinDataLength > 0 && len(b) < cfg.MinDataLength:
+		return StatusError
+	case cfg.MaxDataLength > 0 && len(b) > cfg.MaxDataLength:
+		return StatusError
+	case (cfg.Profile == Profile04 || cfg.Profile == Profile07) && hd.dataID != cfg.DataID:
+		return StatusError
+	case cfg.computeCRC(b, hd.counter) != hd.crc:
+		return StatusError
+	}
+	return c.checkCounter(hd.counter)
+}
+
+func (c *Checker) checkCounter(counter uint32) Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.initialized {
+		c.initialized = true
+		c.lastCounter = counter
+		return StatusOK
+	}
+	delta := (counter - c.lastCounter) & c.config.Profile.maxCounter()
+	if delta == 0 {
+		return StatusRepeated
+	}
+	c.lastCounter = counter
+	if c.config.MaxDeltaCounter > 0 && delta > c.config.MaxDeltaCounter {
+		return StatusWrongSequence
+	}
+	return StatusOK
+}
+
+type checkerKey struct {
+	source  string
+	element string
+}
+
+// Checkers 以发送端与受保护元素的 AR 路径保存 Checker, 不同发送端的 counter 分别校验
+type Checkers struct {
+	mu       sync.Mutex
+	checkers map[checkerKey]*Checker
+}
+
+func NewCheckers() *Checkers {
+	return &Checkers{checkers: make(map[checkerKey]*Checker)}
+}
+
+// Get 返回 source 发送的 element 对应的 Checker, 不存在时以 config 创建.
+// source 由调用方指定 (如发送端地址或 service instance), 为空时所有发送端共用一个 Checker
+func (s *Checkers) Get(source, element string, config *Config) (*Checker, error) {
+	key := checkerKey{source: source, element: element}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if checker, ok := s.checkers[key]; ok {
+		return checker, nil
+	}
+	checker, err := NewChecker(config)
+	if err != nil {
+		return nil, err
+	}
+	s.checkers[key] = checker
+	return checker, nil
+}
@@ -0,0 +1,171 @@
+package e2e
+
+import (
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRC(t *testing.T) {
+	check := []byte("123456789")
+	require.Equal(t, uint8(0xDF), crc8H2F(check))
+	require.Equal(t, uint16(0x29B1), crc16CCITT(check))
+	require.Equal(t, uint32(0x1697D06A), crc32P4(check))
+	require.Equal(t, uint64(0x995DC9BBDF1939FA), crc64ECMA(check))
+}
+
+func TestParseProfile(t *testing.T) {
+	for name, expected := range map[string]Profile{
+		"PROFILE_04": Profile04,
+		"P05":        Profile05,
+		"E2E_P06":    Profile06,
+		"PROFILE_07": Profile07,
+		"PROFILE_22": Profile22,
+	} {
+		profile, err := ParseProfile(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, profile)
+	}
+	_, err := ParseProfile("PROFILE_01")
+	require.Error(t, err)
+}
+
+func testConfigs() []*Config {
+	dataIDList := make([]uint8, 16)
+	for i := range dataIDList {
+		dataIDList[i] = uint8(0x10 + i)
+	}
+	return []*Config{
+		{Profile: Profile04, DataID: 0x0A0B0C0D, Offset: 8, UpperHeaderLength: 8, MaxDeltaCounter: 1},
+		{Profile: Profile05, DataID: 0x1234, DataLength: 13, MaxDeltaCounter: 1},
+		{Profile: Profile06, DataID: 0x1234, Offset: 8, UpperHeaderLength: 8, MaxDeltaCounter: 1},
+		{Profile: Profile07, DataID: 0x0A0B0C0D, Offset: 8, UpperHeaderLength: 8, MaxDeltaCounter: 1},
+		{Profile: Profile22, DataIDList: dataIDList, Offset: 2, MaxDeltaCounter: 1},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	upperHeader := []byte{0x00, 0x01, 0x00, 0x02, 0x01, 0x01, 0x02, 0x00}
+	payload := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A}
+	for _, cfg := range testConfigs() {
+		t.Run(string(cfg.Profile), func(t *testing.T) {
+			var upper []byte
+			if cfg.UpperHeaderLength > 0 {
+				upper = upperHeader
+			}
+			checker, err := NewChecker(cfg)
+			require.NoError(t, err)
+			pos := cfg.Offset - cfg.UpperHeaderLength
+			for i, tc := range []struct {
+				counter uint32
+				status  Status
+			}{
+				{counter: 1, status: StatusOK},
+				{counter: 2, status: StatusOK},
+				{counter: 2, status: StatusRepeated},
+				{counter: 4, status: StatusWrongSequence},
+				{counter: 5, status: StatusOK},
+			} {
+				data, err := cfg.Protect(upper, payload, tc.counter)
+				require.NoError(t, err)
+				require.Len(t, data, len(payload)+cfg.Profile.HeaderLength())
+				require.Equal(t, payload[:pos], data[:pos])
+				result, stripped, err := checker.Check(upper, data)
+				require.NoError(t, err)
+				require.Equal(t, tc.status, result.Status, "case %v", i)
+				require.Equal(t, tc.counter, result.Counter)
+				require.Equal(t, payload, stripped)
+			}
+
+			// CRC 错误不更新 counter
+			data, err := cfg.Protect(upper, payload, 6)
+			require.NoError(t, err)
+			data[len(data)-1] ^= 0xFF
+			result, stripped, err := checker.Check(upper, data)
+			require.NoError(t, err)
+			require.Equal(t, StatusError, result.Status)
+			require.Len(t, stripped, len(payload))
+			data, err = cfg.Protect(upper, payload, 6)
+			require.NoError(t, err)
+			result, _, err = checker.Check(upper, data)
+			require.NoError(t, err)
+			require.Equal(t, StatusOK, result.Status)
+
+			// 缺少 upper header 时不校验
+			if cfg.UpperHeaderLength > 0 {
+				result, stripped, err = checker.Check(nil, data)
+				require.NoError(t, err)
+				require.Equal(t, StatusNotAvailable, result.Status)
+				require.Equal(t, payload, stripped)
+			}
+
+			_, _, err = checker.Check(upper, data[:pos+cfg.Profile.HeaderLength()-1])
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestCheckDataID(t *testing.T) {
+	for _, cfg := range testConfigs() {
+		t.Run(string(cfg.Profile), func(t *testing.T) {
+			upper := make([]byte, cfg.UpperHeaderLength)
+			payload := make([]byte, 10)
+			data, err := cfg.Protect(upper, payload, 1)
+			require.NoError(t, err)
+			other := *cfg
+			other.DataID ^= 0x0101
+			other.DataIDList = append([]uint8{}, cfg.DataIDList...)
+			for i := range other.DataIDList {
+				other.DataIDList[i] ^= 0x01
+			}
+			checker, err := NewChecker(&other)
+			require.NoError(t, err)
+			result, _, err := checker.Check(upper, data)
+			require.NoError(t, err)
+			require.Equal(t, StatusError, result.Status)
+		})
+	}
+}
+
+func TestCounterWrapAround(t *testing.T) {
+	cfg := &Config{Profile: Profile05, DataID: 0x1234, MaxDeltaCounter: 1}
+	checker, err := NewChecker(cfg)
+	require.NoError(t, err)
+	for _, counter := range []uint32{0xFE, 0xFF, 0x00} {
+		data, err := cfg.Protect(nil, []byte{0x01}, counter)
+		require.NoError(t, err)
+		result, _, err := checker.Check(nil, data)
+		require.NoError(t, err)
+		require.Equal(t, StatusOK, result.Status)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(`<ROOT>
+<END-TO-END-TRANSFORMATION-DESCRIPTION>
+	<PROFILE-NAME>PROFILE_04</PROFILE-NAME>
+	<MAX-DELTA-COUNTER>3</MAX-DELTA-COUNTER>
+	<OFFSET>64</OFFSET>
+	<UPPER-HEADER-BITS-TO-SHIFT>64</UPPER-HEADER-BITS-TO-SHIFT>
+</END-TO-END-TRANSFORMATION-DESCRIPTION>
+<END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS-CONDITIONAL>
+	<DATA-IDS><DATA-ID>4660</DATA-ID></DATA-IDS>
+	<MIN-DATA-LENGTH>96</MIN-DATA-LENGTH>
+	<MAX-DATA-LENGTH>256</MAX-DATA-LENGTH>
+</END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS-CONDITIONAL>
+</ROOT>`))
+	root := doc.SelectElement("ROOT")
+	cfg, err := ParseConfig(root.SelectElement("END-TO-END-TRANSFORMATION-DESCRIPTION"), root.SelectElement("END-TO-END-TRANSFORMATION-I-SIGNAL-PROPS-CONDITIONAL"), 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, &Config{
+		Profile:           Profile04,
+		DataID:            4660,
+		Offset:            8,
+		UpperHeaderLength: 8,
+		MinDataLength:     12,
+		MaxDataLength:     32,
+		MaxDeltaCounter:   3,
+	}, cfg)
+}
@@ -0,0 +1,547 @@
+package someip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf16"
+
+	"github.com/yisaer/arxml-converter/ast"
+)
+
+// TypeResolver 根据引用查找 DataType 与基础类型, 由 ast.TransformHelper 实现
+type TypeResolver interface {
+	LookupDataType(ref string) (*ast.DataType, bool)
+	GetBasicKind(tr *ast.TypReference) (ast.BasicKind, bool)
+}
+
+// SOME/IP 默认 union 长度字段与 type selector 均为 32 bit
+const (
+	DefaultUnionLengthFieldLength  = 4
+	DefaultUnionTypeSelectorLength = 4
+)
+
+type Config struct {
+	IsLittleEndian    bool
+	LengthFieldLength int
+	// 动态数组 (含 map) 与字符串的长度字段长度 (字节), 为 0 时使用 LengthFieldLength
+	ArrayLengthFieldLength  int
+	StringLengthFieldLength int
+	// 结构体长度字段长度 (字节), 为 0 时非 TLV 结构体不带长度字段
+	StructLengthFieldLength int
+	// union 的长度字段与 type selector 字段长度 (字节)
+	UnionLengthFieldLength  int
+	UnionTypeSelectorLength int
+}
+
+// Decoder 按 ast.DataType 描述直接解析 SOME/IP payload, 用于 idl-parser 不支持的类型
+type Decoder struct {
+	config   Config
+	resolver TypeResolver
+}
+
+func NewDecoder(config Config, resolver TypeResolver) *Decoder {
+	return &Decoder{
+		config:   config,
+		resolver: resolver,
+	}
+}
+
+func (d *Decoder) DecodeByRef(ref string, data []byte) (interface{}, error) {
+	// 顶层 TLV 结构体没有长度字段, 成员一直持续到 payload 结束
+	if dt, ok := d.resolver.LookupDataType(ref); ok && dt.Structure != nil && dt.Structure.IsTLV() {
+		return d.decodeTLVMembers(dt, data)
+	}
+	v, _, err := d.decodeRef(ref, data)
+	return v, err
+}
+
+// DecodeArguments 按顺序解析 method 参数, 参数在 payload 中的序列化方式与结构体成员相同
+func (d *Decoder) DecodeArguments(args []*ast.Argument, data []byte) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		v, rest, err := d.decodeRef(arg.TypeRef, data)
+		if err != nil {
+			return nil, fmt.Errorf("decode argument %v failed, err:%w", arg.ShortName, err)
+		}
+		result[arg.ShortName] = v
+		data = rest
+	}
+	return result, nil
+}
+
+func (d *Decoder) Decode(dt *ast.DataType, data []byte) (interface{}, []byte, error) {
+	switch {
+	case dt.Union != nil:
+		return d.decodeUnion(dt, data)
+	case dt.Structure != nil && dt.Structure.IsTLV():
+		body, rest, err := d.readLengthPrefixed(data, d.tlvStructLengthFieldLength())
+		if err != nil {
+			return nil, nil, fmt.Errorf("read struct %v length failed, err:%v", dt.ShorName, err)
+		}
+		v, err := d.decodeTLVMembers(dt, body)
+		return v, rest, err
+	case dt.Structure != nil:
+		return d.decodeStructure(dt, data)
+	case dt.Map != nil:
+		body, rest, err := d.readLengthPrefixed(data, d.arrayLengthFieldLength())
+		if err != nil {
+			return nil, nil, fmt.Errorf("read map %v length failed, err:%v", dt.ShorName, err)
+		}
+		v, err := d.decodeMapBody(dt, body)
+		return v, rest, err
+	case dt.Category == "VECTOR" && dt.Vector != nil:
+		return d.decodeSequence(dt.Vector.RefType, data)
+	case dt.Category == "ARRAY" && dt.Array != nil:
+		if dt.Array.ArraySize > 0 {
+			return d.decodeArray(dt.Array.RefType, int(dt.Array.ArraySize), data)
+		}
+		return d.decodeSequence(dt.Array.RefType, data)
+	case dt.TypReference != nil:
+		// typedef 引用其它 DataType
+		if _, ok := d.resolver.LookupDataType(dt.TypReference.Ref); ok {
+			return d.decodeRef(dt.TypReference.Ref, data)
+		}
+		return d.decodeTypReference(dt.TypReference, data)
+	}
+	return nil, nil, fmt.Errorf("unsupported category %v for %v", dt.Category, dt.ShorName)
+}
+
+func (d *Decoder) decodeRef(ref string, data []byte) (interface{}, []byte, error) {
+	if dt, ok := d.resolver.LookupDataType(ref); ok {
+		return d.Decode(dt, data)
+	}
+	return d.decodeTypReference(&ast.TypReference{Ref: ref}, data)
+}
+
+func (d *Decoder) decodeUnion(dt *ast.DataType, data []byte) (interface{}, []byte, error) {
+	length, data, err := d.readUint(data, d.config.UnionLengthFieldLength)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read union %v length failed, err:%v", dt.ShorName, err)
+	}
+	selector, data, err := d.readUint(data, d.config.UnionTypeSelectorLength)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read union %v type selector failed, err:%v", dt.ShorName, err)
+	}
+	body, rest := data, data
+	if d.config.UnionLengthFieldLength > 0 {
+		if uint64(len(data)) < length {
+			return nil, nil, fmt.Errorf("union %v length %v exceeds remaining %v bytes", dt.ShorName, length, len(data))
+		}
+		body, rest = data[:length], data[length:]
+	}
+	v, remain, err := d.decodeUnionMember(dt, selector, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.config.UnionLengthFieldLength < 1 {
+		rest = remain
+	}
+	return v, rest, nil
+}
+
+// decodeUnionBody 解析长度字段已被替换 (TLV wire type 5/6/7) 的 union
+func (d *Decoder) decodeUnionBody(dt *ast.DataType, data []byte) (interface{}, []byte, error) {
+	selector, body, err := d.readUint(data, d.config.UnionTypeSelectorLength)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read union %v type selector failed, err:%v", dt.ShorName, err)
+	}
+	return d.decodeUnionMember(dt, selector, body)
+}
+
+func (d *Decoder) decodeUnionMember(dt *ast.DataType, selector uint64, body []byte) (interface{}, []byte, error) {
+	// type selector 为 0 表示 union 中没有元素
+	if selector == 0 {
+		return nil, body, nil
+	}
+	if selector > uint64(len(dt.Union.Members)) {
+		return nil, nil, fmt.Errorf("union %v has no member for type selector %v", dt.ShorName, selector)
+	}
+	member := dt.Union.Members[selector-1]
+	v, remain, err := d.decodeRef(member.Ref, body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode union %v member %v failed, err:%w", dt.ShorName, member.ShorName, err)
+	}
+	return map[string]interface{}{member.ShorName: v}, remain, nil
+}
+
+func (d *Decoder) decodeStructure(dt *ast.DataType, data []byte) (interface{}, []byte, error) {
+	result := make(map[string]interface{}, len(dt.Structure.STRList))
+	for _, str := range dt.Structure.STRList {
+		v, rest, err := d.decodeRef(str.Ref, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decode %v.%v failed, err:%w", dt.ShorName, str.ShorName, err)
+		}
+		result[str.ShorName] = v
+		data = rest
+	}
+	return result, data, nil
+}
+
+// decodeTLVMembers 解析 TLV 编码的结构体成员, 未知 data id 的成员会被跳过, 缺失的 optional 成员不出现在结果中
+func (d *Decoder) decodeTLVMembers(dt *ast.DataType, body []byte) (map[string]interface{}, error) {
+	members := make(map[uint16]*ast.StructureTypRef, len(dt.Structure.STRList))
+	for _, str := range dt.Structure.STRList {
+		if str.DataID != nil {
+			members[*str.DataID] = str
+		}
+	}
+	result := make(map[string]interface{}, len(dt.Structure.STRList))
+	for len(body) > 0 {
+		tag, remain, err := d.readUint(body, 2)
+		if err != nil {
+			return nil, fmt.Errorf("read struct %v tag failed, err:%v", dt.ShorName, err)
+		}
+		wireType := int(tag>>12) & 0x7
+		dataID := uint16(tag & 0x0FFF)
+		member := members[dataID]
+		var v interface{}
+		switch {
+		case wireType <= 3:
+			size := 1 << wireType
+			if len(remain) < size {
+				return nil, fmt.Errorf("struct %v data id %v needs %v bytes, got %v", dt.ShorName, dataID, size, len(remain))
+			}
+			if member != nil {
+				v, _, err = d.decodeRef(member.Ref, remain[:size])
+			}
+			body = remain[size:]
+		case wireType == 4:
+			if member == nil {
+				_, body, err = d.readLengthPrefixed(remain, d.tlvStructLengthFieldLength())
+				break
+			}
+			v, body, err = d.decodeWithLengthField(member.Ref, remain)
+		default:
+			// wire type 5/6/7 使用 1/2/4 字节长度字段替代成员本身的长度字段
+			var element []byte
+			element, body, err = d.readLengthPrefixed(remain, 1<<(wireType-5))
+			if err == nil && member != nil {
+				v, err = d.decodeRefBody(member.Ref, element)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode struct %v data id %v failed, err:%w", dt.ShorName, dataID, err)
+		}
+		if member != nil {
+			result[member.ShorName] = v
+		}
+	}
+	for _, str := range dt.Structure.STRList {
+		if _, ok := result[str.ShorName]; !ok && !str.IsOptional {
+			return nil, fmt.Errorf("struct %v missing mandatory member %v", dt.ShorName, str.ShorName)
+		}
+	}
+	return result, nil
+}
+
+// decodeWithLengthField 解析自身带长度字段的成员 (TLV wire type 4), 结构体需要额外读取结构体长度字段
+func (d *Decoder) decodeWithLengthField(ref string, data []byte) (interface{}, []byte, error) {
+	dt, ok := d.resolver.LookupDataType(ref)
+	if ok && dt.Structure != nil && !dt.Structure.IsTLV() {
+		body, rest, err := d.readLengthPrefixed(data, d.tlvStructLengthFieldLength())
+		if err != nil {
+			return nil, nil, err
+		}
+		v, _, err := d.decodeStructure(dt, body)
+		return v, rest, err
+	}
+	return d.decodeRef(ref, data)
+}
+
+// decodeRefBody 解析已去掉长度字段的成员内容
+func (d *Decoder) decodeRefBody(ref string, body []byte) (interface{}, error) {
+	dt, ok := d.resolver.LookupDataType(ref)
+	if !ok {
+		v, _, err := d.decodeTypReference(&ast.TypReference{Ref: ref}, body)
+		return v, err
+	}
+	switch {
+	case dt.Union != nil:
+		v, _, err := d.decodeUnionBody(dt, body)
+		return v, err
+	case dt.Structure != nil && dt.Structure.IsTLV():
+		return d.decodeTLVMembers(dt, body)
+	case dt.Map != nil:
+		return d.decodeMapBody(dt, body)
+	case dt.Category == "VECTOR" && dt.Vector != nil:
+		return d.decodeSequenceBody(dt.Vector.RefType, body)
+	case dt.Category == "ARRAY" && dt.Array != nil && dt.Array.ArraySize < 1:
+		return d.decodeSequenceBody(dt.Array.RefType, body)
+	case dt.Structure == nil && dt.TypReference != nil && dt.TypReference.StringSize < 1:
+		if kind, ok := d.resolver.GetBasicKind(dt.TypReference); ok && kind == ast.BasicString {
+			return d.decodeStringBytes(body, dt.TypReference.Encoding), nil
+		}
+	}
+	v, _, err := d.Decode(dt, body)
+	return v, err
+}
+
+func (d *Decoder) arrayLengthFieldLength() int {
+	if d.config.ArrayLengthFieldLength > 0 {
+		return d.config.ArrayLengthFieldLength
+	}
+	return d.config.LengthFieldLength
+}
+
+func (d *Decoder) stringLengthFieldLength() int {
+	if d.config.StringLengthFieldLength > 0 {
+		return d.config.StringLengthFieldLength
+	}
+	return d.config.LengthFieldLength
+}
+
+func (d *Decoder) tlvStructLengthFieldLength() int {
+	if d.config.StructLengthFieldLength > 0 {
+		return d.config.StructLengthFieldLength
+	}
+	return 4
+}
+
+// decodeMapBody 解析 key/value 序列, key 均为字符串或整数时返回 JSON object, 否则返回 {key, value} 列表
+func (d *Decoder) decodeMapBody(dt *ast.DataType, body []byte) (interface{}, error) {
+	type entry struct {
+		key, value interface{}
+	}
+	entries := make([]entry, 0)
+	for len(body) > 0 {
+		k, remain, err := d.decodeRef(dt.Map.KeyRef, body)
+		if err != nil {
+			return nil, fmt.Errorf("decode map %v key index %v failed, err:%w", dt.ShorName, len(entries), err)
+		}
+		v, remain, err := d.decodeRef(dt.Map.ValueRef, remain)
+		if err != nil {
+			return nil, fmt.Errorf("decode map %v value index %v failed, err:%w", dt.ShorName, len(entries), err)
+		}
+		if len(remain) == len(body) {
+			return nil, fmt.Errorf("decode map %v index %v consumed no data", dt.ShorName, len(entries))
+		}
+		entries = append(entries, entry{key: k, value: v})
+		body = remain
+	}
+	object := make(map[string]interface{}, len(entries))
+	for _, e := range entries {
+		key, ok := mapKeyString(e.key)
+		if !ok {
+			object = nil
+			break
+		}
+		object[key] = e.value
+	}
+	if object != nil {
+		return object, nil
+	}
+	pairs := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		pairs = append(pairs, map[string]interface{}{"key": e.key, "value": e.value})
+	}
+	return pairs, nil
+}
+
+func mapKeyString(key interface{}) (string, bool) {
+	switch k := key.(type) {
+	case string:
+		return k, true
+	case uint8, uint16, uint32, uint64, int8, int16, int32, int64:
+		return fmt.Sprint(k), true
+	}
+	return "", false
+}
+
+func (d *Decoder) decodeArray(ref string, size int, data []byte) (interface{}, []byte, error) {
+	result := make([]interface{}, 0, size)
+	for i := 0; i < size; i++ {
+		v, rest, err := d.decodeRef(ref, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decode array index %v failed, err:%w", i, err)
+		}
+		result = append(result, v)
+		data = rest
+	}
+	return result, data, nil
+}
+
+func (d *Decoder) decodeSequence(ref string, data []byte) (interface{}, []byte, error) {
+	body, rest, err := d.readLengthPrefixed(data, d.arrayLengthFieldLength())
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := d.decodeSequenceBody(ref, body)
+	return result, rest, err
+}
+
+func (d *Decoder) decodeSequenceBody(ref string, body []byte) ([]interface{}, error) {
+	result := make([]interface{}, 0)
+	for len(body) > 0 {
+		v, remain, err := d.decodeRef(ref, body)
+		if err != nil {
+			return nil, fmt.Errorf("decode sequence index %v failed, err:%w", len(result), err)
+		}
+		if len(remain) == len(body) {
+			return nil, fmt.Errorf("decode sequence index %v consumed no data", len(result))
+		}
+		result = append(result, v)
+		body = remain
+	}
+	return result, nil
+}
+
+func (d *Decoder) decodeTypReference(tr *ast.TypReference, data []byte) (interface{}, []byte, error) {
+	kind, ok := d.resolver.GetBasicKind(tr)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown type reference: %s", tr.Ref)
+	}
+	if kind == ast.BasicString {
+		return d.decodeString(tr, data)
+	}
+	return d.decodeBasic(kind, data)
+}
+
+func (d *Decoder) decodeString(tr *ast.TypReference, data []byte) (interface{}, []byte, error) {
+	var raw, rest []byte
+	if tr.StringSize > 0 {
+		if int64(len(data)) < tr.StringSize {
+			return nil, nil, fmt.Errorf("fixed length string needs %v bytes, got %v", tr.StringSize, len(data))
+		}
+		raw, rest = data[:tr.StringSize], data[tr.StringSize:]
+	} else {
+		var err error
+		raw, rest, err = d.readLengthPrefixed(data, d.stringLengthFieldLength())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return d.decodeStringBytes(raw, tr.Encoding), rest, nil
+}
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+)
+
+// decodeStringBytes 根据 BOM 确定字符串编码, 没有 BOM 时按声明的编码解析, 此时 UTF-16 使用配置的字节序
+func (d *Decoder) decodeStringBytes(raw []byte, encoding ast.StringEncoding) string {
+	switch {
+	case bytes.HasPrefix(raw, utf8BOM):
+		return trimUTF8(raw[len(utf8BOM):])
+	case bytes.HasPrefix(raw, utf16BEBOM):
+		return decodeUTF16(raw[len(utf16BEBOM):], binary.BigEndian)
+	case bytes.HasPrefix(raw, utf16LEBOM):
+		return decodeUTF16(raw[len(utf16LEBOM):], binary.LittleEndian)
+	case encoding == ast.StringUTF16:
+		return decodeUTF16(raw, d.byteOrder())
+	}
+	return trimUTF8(raw)
+}
+
+// trimUTF8 去掉结尾的 \0 及之后的填充
+func trimUTF8(raw []byte) string {
+	if i := bytes.IndexByte(raw, 0x00); i >= 0 {
+		raw = raw[:i]
+	}
+	return string(raw)
+}
+
+// decodeUTF16 解析到 0x0000 结束符为止, 奇数长度时忽略最后一个字节
+func decodeUTF16(raw []byte, order binary.ByteOrder) string {
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		u := order.Uint16(raw[i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	return string(utf16.Decode(units))
+}
+
+func (d *Decoder) decodeBasic(kind ast.BasicKind, data []byte) (interface{}, []byte, error) {
+	switch kind {
+	case ast.BasicUint8, ast.BasicInt8, ast.BasicBool:
+		v, rest, err := d.readUint(data, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch kind {
+		case ast.BasicInt8:
+			return int8(v), rest, nil
+		case ast.BasicBool:
+			return v != 0, rest, nil
+		}
+		return uint8(v), rest, nil
+	case ast.BasicUint16, ast.BasicInt16:
+		v, rest, err := d.readUint(data, 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		if kind == ast.BasicInt16 {
+			return int16(v), rest, nil
+		}
+		return uint16(v), rest, nil
+	case ast.BasicUint32, ast.BasicInt32, ast.BasicFloat:
+		v, rest, err := d.readUint(data, 4)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch kind {
+		case ast.BasicInt32:
+			return int32(v), rest, nil
+		case ast.BasicFloat:
+			return math.Float32frombits(uint32(v)), rest, nil
+		}
+		return uint32(v), rest, nil
+	case ast.BasicUint64, ast.BasicInt64, ast.BasicDouble:
+		v, rest, err := d.readUint(data, 8)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch kind {
+		case ast.BasicInt64:
+			return int64(v), rest, nil
+		case ast.BasicDouble:
+			return math.Float64frombits(v), rest, nil
+		}
+		return v, rest, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported basic type %v", kind)
+}
+
+func (d *Decoder) readLengthPrefixed(data []byte, lengthFieldLength int) ([]byte, []byte, error) {
+	length, data, err := d.readUint(data, lengthFieldLength)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read length field failed, err:%v", err)
+	}
+	if uint64(len(data)) < length {
+		return nil, nil, fmt.Errorf("length field %v exceeds remaining %v bytes", length, len(data))
+	}
+	return data[:length], data[length:], nil
+}
+
+func (d *Decoder) byteOrder() binary.ByteOrder {
+	if d.config.IsLittleEndian {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+func (d *Decoder) readUint(data []byte, size int) (uint64, []byte, error) {
+	if len(data) < size {
+		return 0, nil, fmt.Errorf("need %v bytes, got %v", size, len(data))
+	}
+	order := d.byteOrder()
+	switch size {
+	case 0:
+		return 0, data, nil
+	case 1:
+		return uint64(data[0]), data[1:], nil
+	case 2:
+		return uint64(order.Uint16(data)), data[2:], nil
+	case 4:
+		return uint64(order.Uint32(data)), data[4:], nil
+	case 8:
+		return order.Uint64(data), data[8:], nil
+	}
+	return 0, nil, fmt.Errorf("invalid field size %v", size)
+}
@@ -0,0 +1,251 @@
+package someip
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/arxml-converter/ast"
+)
+
+func TestDecodeUnion(t *testing.T) {
+	dataTypes := map[string]*ast.DataType{
+		"Status": ast.NewUnionDataType("Status", "UNION", &ast.Union{
+			Members: []*ast.StructureTypRef{
+				{ShorName: "code", Ref: "/DataTypes/BaseTypes/uint16"},
+				{ShorName: "text", Ref: "/DataTypes/ImplementationDataTypes/Text"},
+			},
+		}),
+		"/DataTypes/ImplementationDataTypes/Text": ast.NewStringDataType("Text", "TYPE_REFERENCE", 0),
+	}
+	d := NewDecoder(Config{
+		LengthFieldLength:       4,
+		UnionLengthFieldLength:  DefaultUnionLengthFieldLength,
+		UnionTypeSelectorLength: DefaultUnionTypeSelectorLength,
+	}, ast.NewTransformHelper(dataTypes))
+
+	// 长度字段覆盖 padding
+	v, err := d.DecodeByRef("Status", []byte{0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x01, 0x02, 0x00, 0x00})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"code": uint16(0x0102)}, v)
+
+	v, err = d.DecodeByRef("Status", []byte{0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03, 'a', 'b', 0x00})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"text": "ab"}, v)
+
+	// type selector 0 表示空 union
+	v, err = d.DecodeByRef("Status", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	_, err = d.DecodeByRef("Status", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03})
+	require.Error(t, err)
+
+	d = NewDecoder(Config{UnionLengthFieldLength: 0, UnionTypeSelectorLength: 1}, ast.NewTransformHelper(dataTypes))
+	v, err = d.DecodeByRef("Status", []byte{0x01, 0x00, 0x05})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"code": uint16(5)}, v)
+}
+
+func TestDecodeTLVStructure(t *testing.T) {
+	id := func(v uint16) *uint16 { return &v }
+	dataTypes := map[string]*ast.DataType{
+		"Outer": ast.NewStructureDataType("Outer", "STRUCTURE", &ast.Structure{
+			STRList: []*ast.StructureTypRef{
+				{ShorName: "count", Ref: "uint8", DataID: id(1)},
+				{ShorName: "name", Ref: "Text", DataID: id(2), IsOptional: true},
+				{ShorName: "inner", Ref: "Inner", DataID: id(3)},
+			},
+		}),
+		"Inner": ast.NewStructureDataType("Inner", "STRUCTURE", &ast.Structure{
+			STRList: []*ast.StructureTypRef{
+				{ShorName: "a", Ref: "uint16"},
+			},
+		}),
+		"Text": ast.NewStringDataType("Text", "TYPE_REFERENCE", 0),
+	}
+	d := NewDecoder(Config{LengthFieldLength: 4}, ast.NewTransformHelper(dataTypes))
+
+	v, err := d.DecodeByRef("Outer", []byte{
+		0x00, 0x01, 0x07, // count, wire type 0
+		0x40, 0x05, 0x00, 0x00, 0x00, 0x01, 0xAA, // 未知 data id 5, wire type 4
+		0x50, 0x02, 0x02, 'h', 'i', // name, wire type 5
+		0x40, 0x03, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02, // inner, wire type 4 带结构体长度字段
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"count": uint8(7),
+		"name":  "hi",
+		"inner": map[string]interface{}{"a": uint16(0x0102)},
+	}, v)
+
+	// optional 成员 name 缺失
+	v, err = d.DecodeByRef("Outer", []byte{0x00, 0x01, 0x07, 0x60, 0x03, 0x00, 0x02, 0x01, 0x02})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"count": uint8(7),
+		"inner": map[string]interface{}{"a": uint16(0x0102)},
+	}, v)
+
+	_, err = d.DecodeByRef("Outer", []byte{0x00, 0x01, 0x07})
+	require.Error(t, err)
+}
+
+func TestDecodeString(t *testing.T) {
+	utf16Name := ast.NewStringDataType("Name16", "STRING", 0)
+	utf16Name.Encoding = ast.StringUTF16
+	fixedName := ast.NewStringDataType("FixedName", "STRING", 8)
+	fixedName.Encoding = ast.StringUTF8
+	dataTypes := map[string]*ast.DataType{
+		"/DataTypes/Name16":    utf16Name,
+		"/DataTypes/FixedName": fixedName,
+	}
+	th := ast.NewTransformHelper(dataTypes)
+	require.True(t, th.RequiresSomeIPDecoder("/DataTypes/Name16"))
+	d := NewDecoder(Config{LengthFieldLength: 4}, th)
+
+	// UTF-16BE BOM
+	v, err := d.DecodeByRef("/DataTypes/Name16", []byte{0x00, 0x00, 0x00, 0x08, 0xFE, 0xFF, 0x00, 'h', 0x4E, 0x2D, 0x00, 0x00})
+	require.NoError(t, err)
+	require.Equal(t, "h中", v)
+	// UTF-16LE BOM
+	v, err = d.DecodeByRef("/DataTypes/Name16", []byte{0x00, 0x00, 0x00, 0x08, 0xFF, 0xFE, 'h', 0x00, 0x2D, 0x4E, 0x00, 0x00})
+	require.NoError(t, err)
+	require.Equal(t, "h中", v)
+	// 没有 BOM 时按配置的字节序解析
+	v, err = NewDecoder(Config{LengthFieldLength: 4, IsLittleEndian: true}, th).DecodeByRef("/DataTypes/Name16",
+		[]byte{0x04, 0x00, 0x00, 0x00, 'o', 0x00, 'k', 0x00})
+	require.NoError(t, err)
+	require.Equal(t, "ok", v)
+
+	// 定长字符串没有长度字段, 结束符后为填充
+	v, err = d.DecodeByRef("/DataTypes/FixedName", []byte{0xEF, 0xBB, 0xBF, 'a', 'b', 0x00, 0x00, 0x00})
+	require.NoError(t, err)
+	require.Equal(t, "ab", v)
+	_, err = d.DecodeByRef("/DataTypes/FixedName", []byte{'a', 'b', 0x00})
+	require.Error(t, err)
+}
+
+func TestDecodeFixedString(t *testing.T) {
+	// 定长字符串: BOM + 字符 + 结束符, 剩余部分为填充
+	testcases := []struct {
+		encoding ast.StringEncoding
+		length   int64
+		content  []byte
+	}{
+		{ast.StringUTF8, 12, []byte{0xEF, 0xBB, 0xBF, 'h', 0xE4, 0xB8, 0xAD, 0xF0, 0x9F, 0x98, 0x80, 'k', 0x00}},
+		{ast.StringUTF8, 9, []byte{0xEF, 0xBB, 0xBF, 'h', 0xE4, 0xB8, 0xAD, 0xF0, 0x9F, 0x98, 0x80, 'k', 0x00}},
+		{ast.StringUTF16, 4, []byte{0xFE, 0xFF, 0x00, 'h', 0x4E, 0x2D, 0x00, 'o', 0x00, 'k', 0x00, 0x00}},
+		{ast.StringUTF16, 6, []byte{0xFF, 0xFE, 'h', 0x00, 0x2D, 0x4E, 'o', 0x00, 'k', 0x00, 0x00, 0x00}},
+	}
+	expected := map[ast.StringEncoding]string{
+		ast.StringUTF8:  "h中😀k",
+		ast.StringUTF16: "h中ok",
+	}
+	for _, tc := range testcases {
+		size := tc.encoding.FixedStringSize(tc.length)
+		fixedName := ast.NewStringDataType("FixedName", "STRING", size)
+		fixedName.Encoding = tc.encoding
+		count := ast.NewBasicDataType("Count", "VALUE", "/StdTypes/uint8_t", ast.BasicUint8)
+		th := ast.NewTransformHelper(map[string]*ast.DataType{
+			"/DataTypes/FixedName": fixedName,
+			"/DataTypes/Count":     count,
+			"/DataTypes/Record": ast.NewStructureDataType("Record", "STRUCTURE", &ast.Structure{
+				STRList: []*ast.StructureTypRef{
+					{ShorName: "name", Ref: "/DataTypes/FixedName"},
+					{ShorName: "count", Ref: "/DataTypes/Count"},
+				},
+			}),
+		})
+		require.True(t, th.RequiresSomeIPDecoder("/DataTypes/Record"), tc.encoding)
+		d := NewDecoder(Config{LengthFieldLength: 4}, th)
+
+		payload := make([]byte, size)
+		copy(payload, tc.content)
+		v, err := d.DecodeByRef("/DataTypes/Record", append(payload, 0x07))
+		require.NoError(t, err, tc.encoding)
+		require.Equal(t, map[string]interface{}{"name": expected[tc.encoding], "count": uint8(7)}, v, tc.encoding)
+	}
+}
+
+func TestDecodeMap(t *testing.T) {
+	dataTypes := map[string]*ast.DataType{
+		"/dataTypes/Names": ast.NewMapDataType("Names", "ASSOCIATIVE_MAP", &ast.Map{
+			KeyRef:   "/StdTypes/uint16_t",
+			ValueRef: "/dataTypes/Text",
+		}),
+		"/dataTypes/Positions": ast.NewMapDataType("Positions", "ASSOCIATIVE_MAP", &ast.Map{
+			KeyRef:   "/dataTypes/Point",
+			ValueRef: "/StdTypes/bool",
+		}),
+		"/dataTypes/Point": ast.NewStructureDataType("Point", "STRUCTURE", &ast.Structure{
+			STRList: []*ast.StructureTypRef{
+				{ShorName: "x", Ref: "/StdTypes/uint8_t"},
+				{ShorName: "y", Ref: "/StdTypes/uint8_t"},
+			},
+		}),
+		"/dataTypes/Text":  ast.NewStringDataType("Text", "TYPE_REFERENCE", 0),
+		"/dataTypes/Empty": ast.NewStructureDataType("Empty", "STRUCTURE", &ast.Structure{}),
+		"/dataTypes/Flags": ast.NewMapDataType("Flags", "ASSOCIATIVE_MAP", &ast.Map{
+			KeyRef:   "/dataTypes/Empty",
+			ValueRef: "/dataTypes/Empty",
+		}),
+	}
+	th := ast.NewTransformHelper(dataTypes)
+	require.True(t, th.RequiresSomeIPDecoder("/dataTypes/Names"))
+	d := NewDecoder(Config{LengthFieldLength: 4}, th)
+
+	v, err := d.DecodeByRef("/dataTypes/Names", []byte{
+		0x00, 0x00, 0x00, 0x0F,
+		0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 'a',
+		0x00, 0x02, 0x00, 0x00, 0x00, 0x02, 'b', 'c',
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"1": "a", "2": "bc"}, v)
+
+	// key 不是字符串或整数时返回 key/value 列表
+	v, err = d.DecodeByRef("/dataTypes/Positions", []byte{0x00, 0x00, 0x00, 0x03, 0x01, 0x02, 0x01})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"key": map[string]interface{}{"x": uint8(1), "y": uint8(2)}, "value": true},
+	}, v)
+
+	_, err = d.DecodeByRef("/dataTypes/Names", []byte{0x00, 0x00, 0x00, 0x03, 0x00, 0x01, 0x00})
+	require.Error(t, err)
+
+	// key 与 value 都不消耗数据时不能无限循环
+	_, err = d.DecodeByRef("/dataTypes/Flags", []byte{0x00, 0x00, 0x00, 0x01, 0x01})
+	require.ErrorContains(t, err, "consumed no data")
+}
+
+func TestDecodeSequenceNoProgress(t *testing.T) {
+	th := ast.NewTransformHelper(map[string]*ast.DataType{
+		"/dataTypes/Empty":   ast.NewStructureDataType("Empty", "STRUCTURE", &ast.Structure{}),
+		"/dataTypes/Empties": ast.NewArrayDataType("Empties", "ARRAY", "/dataTypes/Empty", 0),
+	})
+	d := NewDecoder(Config{LengthFieldLength: 4}, th)
+	// 元素不消耗数据时不能无限循环
+	_, err := d.DecodeByRef("/dataTypes/Empties", []byte{0x00, 0x00, 0x00, 0x02, 0xAA, 0xBB})
+	require.ErrorContains(t, err, "consumed no data")
+	v, err := d.DecodeByRef("/dataTypes/Empties", []byte{0x00, 0x00, 0x00, 0x00})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{}, v)
+}
+
+func TestDecodeLengthFieldLengths(t *testing.T) {
+	th := ast.NewTransformHelper(map[string]*ast.DataType{
+		"/dataTypes/Text":  ast.NewStringDataType("Text", "TYPE_REFERENCE", 0),
+		"/dataTypes/Texts": ast.NewArrayDataType("Texts", "ARRAY", "/dataTypes/Text", 0),
+	})
+	// 数组与字符串分别使用各自配置的长度字段长度
+	d := NewDecoder(Config{LengthFieldLength: 4, ArrayLengthFieldLength: 2, StringLengthFieldLength: 1}, th)
+	v, err := d.DecodeByRef("/dataTypes/Texts", []byte{0x00, 0x05, 0x01, 'a', 0x02, 'b', 'c'})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"a", "bc"}, v)
+
+	// 未配置时使用 LengthFieldLength
+	d = NewDecoder(Config{LengthFieldLength: 4}, th)
+	v, err = d.DecodeByRef("/dataTypes/Texts", []byte{0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x01, 'a'})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"a"}, v)
+}
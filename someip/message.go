@@ -0,0 +1,79 @@
+package someip
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MessageType 为 SOME/IP header 中的 message type
+type MessageType uint8
+
+const (
+	MessageTypeRequest         MessageType = 0x00
+	MessageTypeRequestNoReturn MessageType = 0x01
+	MessageTypeNotification    MessageType = 0x02
+	MessageTypeResponse        MessageType = 0x80
+	MessageTypeError           MessageType = 0x81
+
+	// MessageTypeTPFlag 标识 SOME/IP-TP 分段消息
+	MessageTypeTPFlag MessageType = 0x20
+)
+
+// Base 去掉 TP 标志位
+func (m MessageType) Base() MessageType {
+	return m &^ MessageTypeTPFlag
+}
+
+func (m MessageType) IsRequest() bool {
+	return m.Base() == MessageTypeRequest || m.Base() == MessageTypeRequestNoReturn
+}
+
+func (m MessageType) IsResponse() bool {
+	return m.Base() == MessageTypeResponse
+}
+
+func (m MessageType) IsError() bool {
+	return m.Base() == MessageTypeError
+}
+
+// HeaderLength 为 SOME/IP header 长度
+const HeaderLength = 16
+
+// Header 为 SOME/IP header, Length 包含 request id 之后 8 字节的 header 与 payload
+type Header struct {
+	ServiceID        uint16
+	MethodID         uint16
+	Length           uint32
+	ClientID         uint16
+	SessionID        uint16
+	ProtocolVersion  uint8
+	InterfaceVersion uint8
+	MessageType      MessageType
+	ReturnCode       uint8
+}
+
+// ParseHeader 解析 SOME/IP header 并返回 payload, SOME/IP header 固定为大端序
+func ParseHeader(data []byte) (*Header, []byte, error) {
+	if len(data) < HeaderLength {
+		return nil, nil, fmt.Errorf("someip header needs %v bytes, got %v", HeaderLength, len(data))
+	}
+	h := &Header{
+		ServiceID:        binary.BigEndian.Uint16(data[0:2]),
+		MethodID:         binary.BigEndian.Uint16(data[2:4]),
+		Length:           binary.BigEndian.Uint32(data[4:8]),
+		ClientID:         binary.BigEndian.Uint16(data[8:10]),
+		SessionID:        binary.BigEndian.Uint16(data[10:12]),
+		ProtocolVersion:  data[12],
+		InterfaceVersion: data[13],
+		MessageType:      MessageType(data[14]),
+		ReturnCode:       data[15],
+	}
+	if h.Length < HeaderLength-8 {
+		return nil, nil, fmt.Errorf("invalid someip length %v", h.Length)
+	}
+	payloadLength := int(h.Length) - (HeaderLength - 8)
+	if len(data)-HeaderLength < payloadLength {
+		return nil, nil, fmt.Errorf("someip payload needs %v bytes, got %v", payloadLength, len(data)-HeaderLength)
+	}
+	return h, data[HeaderLength : HeaderLength+payloadLength], nil
+}
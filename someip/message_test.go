@@ -0,0 +1,35 @@
+package someip
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeader(t *testing.T) {
+	h, payload, err := ParseHeader([]byte{
+		0x82, 0x02, 0x80, 0x01, // service id, method id
+		0x00, 0x00, 0x00, 0x0A, // length
+		0x00, 0x01, 0x00, 0x02, // client id, session id
+		0x01, 0x02, 0x02, 0x00, // protocol version, interface version, message type, return code
+		0xAA, 0xBB, 0xCC,
+	})
+	require.NoError(t, err)
+	require.Equal(t, &Header{
+		ServiceID:        0x8202,
+		MethodID:         0x8001,
+		Length:           10,
+		ClientID:         1,
+		SessionID:        2,
+		ProtocolVersion:  1,
+		InterfaceVersion: 2,
+		MessageType:      MessageTypeNotification,
+	}, h)
+	// length 之外的字节不属于 payload
+	require.Equal(t, []byte{0xAA, 0xBB}, payload)
+
+	_, _, err = ParseHeader([]byte{0x82, 0x02, 0x80, 0x01, 0x00, 0x00, 0x00, 0x10})
+	require.Error(t, err)
+	_, _, err = ParseHeader([]byte{0x82, 0x02, 0x80, 0x01, 0x00, 0x00, 0x00, 0x10, 0x00, 0x01, 0x00, 0x02, 0x01, 0x01, 0x02, 0x00})
+	require.Error(t, err)
+}
@@ -0,0 +1,293 @@
+package someip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	SDServiceID uint16 = 0xFFFF
+	SDMethodID  uint16 = 0x8100
+
+	// SDAnyInstance 与 SDAnyMajorVersion 为 FindService 中的通配值
+	SDAnyInstance     uint16 = 0xFFFF
+	SDAnyMajorVersion uint8  = 0xFF
+
+	sdEntryLength = 16
+)
+
+// IsSD 判断报文是否为 SOME/IP-SD 报文
+func (h *Header) IsSD() bool {
+	return h.ServiceID == SDServiceID && h.MethodID == SDMethodID
+}
+
+type SDEntryType uint8
+
+const (
+	SDEntryFindService            SDEntryType = 0x00
+	SDEntryOfferService           SDEntryType = 0x01
+	SDEntrySubscribeEventgroup    SDEntryType = 0x06
+	SDEntrySubscribeEventgroupAck SDEntryType = 0x07
+)
+
+// IsEventgroup 判断 entry 是否为 eventgroup entry (0x04-0x07), 否则为 service entry (0x00-0x03)
+func (t SDEntryType) IsEventgroup() bool {
+	return t >= 0x04
+}
+
+// name 返回 entry 的含义, TTL 为 0 时 Offer/Subscribe/Ack 分别表示 Stop/Stop/Nack
+func (t SDEntryType) name(ttl uint32) string {
+	switch t {
+	case SDEntryFindService:
+		return "FindService"
+	case SDEntryOfferService:
+		if ttl == 0 {
+			return "StopOfferService"
+		}
+		return "OfferService"
+	case SDEntrySubscribeEventgroup:
+		if ttl == 0 {
+			return "StopSubscribeEventgroup"
+		}
+		return "SubscribeEventgroup"
+	case SDEntrySubscribeEventgroupAck:
+		if ttl == 0 {
+			return "SubscribeEventgroupNack"
+		}
+		return "SubscribeEventgroupAck"
+	}
+	return fmt.Sprintf("Unknown(0x%02x)", uint8(t))
+}
+
+type SDOptionType uint8
+
+const (
+	SDOptionConfiguration SDOptionType = 0x01
+	SDOptionLoadBalancing SDOptionType = 0x02
+	SDOptionIPv4Endpoint  SDOptionType = 0x04
+	SDOptionIPv6Endpoint  SDOptionType = 0x06
+	SDOptionIPv4Multicast SDOptionType = 0x14
+	SDOptionIPv6Multicast SDOptionType = 0x16
+	SDOptionIPv4SD        SDOptionType = 0x24
+	SDOptionIPv6SD        SDOptionType = 0x26
+)
+
+func (t SDOptionType) String() string {
+	switch t {
+	case SDOptionConfiguration:
+		return "Configuration"
+	case SDOptionLoadBalancing:
+		return "LoadBalancing"
+	case SDOptionIPv4Endpoint:
+		return "IPv4Endpoint"
+	case SDOptionIPv6Endpoint:
+		return "IPv6Endpoint"
+	case SDOptionIPv4Multicast:
+		return "IPv4Multicast"
+	case SDOptionIPv6Multicast:
+		return "IPv6Multicast"
+	case SDOptionIPv4SD:
+		return "IPv4SDEndpoint"
+	case SDOptionIPv6SD:
+		return "IPv6SDEndpoint"
+	}
+	return fmt.Sprintf("Unknown(0x%02x)", uint8(t))
+}
+
+// SDMessage 为 SOME/IP-SD 报文的 payload
+type SDMessage struct {
+	Reboot  bool        `json:"reboot"`
+	Unicast bool        `json:"unicast"`
+	Entries []*SDEntry  `json:"entries"`
+	Options []*SDOption `json:"options"`
+}
+
+// SDEntry 为 service entry 或 eventgroup entry, Options 为两个 option run 引用的 option
+type SDEntry struct {
+	Type         SDEntryType `json:"-"`
+	Name         string      `json:"type"`
+	ServiceID    uint16      `json:"service_id"`
+	InstanceID   uint16      `json:"instance_id"`
+	MajorVersion uint8       `json:"major_version"`
+	TTL          uint32      `json:"ttl"`
+	// MinorVersion 只用于 service entry
+	MinorVersion uint32 `json:"minor_version,omitempty"`
+	// Counter 与 EventgroupID 只用于 eventgroup entry
+	Counter      uint8       `json:"counter,omitempty"`
+	EventgroupID uint16      `json:"eventgroup_id,omitempty"`
+	Options      []*SDOption `json:"options,omitempty"`
+
+	// ServiceName, InstanceName 与 EventgroupName 为 ARXML 中对应的 SHORT-NAME
+	ServiceName    string `json:"service_name,omitempty"`
+	InstanceName   string `json:"instance_name,omitempty"`
+	EventgroupName string `json:"eventgroup_name,omitempty"`
+}
+
+type SDOption struct {
+	Type        SDOptionType `json:"-"`
+	Name        string       `json:"type"`
+	Discardable bool         `json:"discardable,omitempty"`
+	// Address, Protocol 与 Port 用于 endpoint 与 multicast option
+	Address  string `json:"address,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	// Configuration 为 configuration option 中的字符串, 如 key=value
+	Configuration []string `json:"configuration,omitempty"`
+	Priority      uint16   `json:"priority,omitempty"`
+	Weight        uint16   `json:"weight,omitempty"`
+}
+
+// SDCatalog 将 SD entry 中的 id 解析为 ARXML 中的 SHORT-NAME, 未找到时返回空字符串
+type SDCatalog interface {
+	ServiceName(serviceID uint16, majorVersion uint8) string
+	InstanceName(serviceID, instanceID uint16) string
+	EventgroupName(serviceID, eventgroupID uint16) string
+}
+
+// DecodeSD 解析 SOME/IP-SD payload, catalog 不为 nil 时填充 entry 的 SHORT-NAME
+func DecodeSD(payload []byte, catalog SDCatalog) (*SDMessage, error) {
+	if len(payload) < 8 {
+		return nil, fmt.Errorf("someip sd payload needs at least 8 bytes, got %v", len(payload))
+	}
+	msg := &SDMessage{
+		Reboot:  payload[0]&0x80 != 0,
+		Unicast: payload[0]&0x40 != 0,
+		Entries: make([]*SDEntry, 0),
+		Options: make([]*SDOption, 0),
+	}
+	entriesLength := int(binary.BigEndian.Uint32(payload[4:8]))
+	if entriesLength%sdEntryLength != 0 {
+		return nil, fmt.Errorf("invalid someip sd entries length %v", entriesLength)
+	}
+	if len(payload) < 8+entriesLength+4 {
+		return nil, fmt.Errorf("someip sd entries need %v bytes, got %v", entriesLength+4, len(payload)-8)
+	}
+	entries := payload[8 : 8+entriesLength]
+	optionsLength := int(binary.BigEndian.Uint32(payload[8+entriesLength : 12+entriesLength]))
+	options := payload[12+entriesLength:]
+	if len(options) < optionsLength {
+		return nil, fmt.Errorf("someip sd options need %v bytes, got %v", optionsLength, len(options))
+	}
+	options = options[:optionsLength]
+	for len(options) > 0 {
+		option, n, err := decodeSDOption(options)
+		if err != nil {
+			return nil, fmt.Errorf("option %v: %v", len(msg.Options), err)
+		}
+		msg.Options = append(msg.Options, option)
+		options = options[n:]
+	}
+	for i := 0; i < len(entries); i += sdEntryLength {
+		entry, err := decodeSDEntry(entries[i:i+sdEntryLength], msg.Options)
+		if err != nil {
+			return nil, fmt.Errorf("entry %v: %v", i/sdEntryLength, err)
+		}
+		if catalog != nil {
+			entry.resolve(catalog)
+		}
+		msg.Entries = append(msg.Entries, entry)
+	}
+	return msg, nil
+}
+
+func decodeSDEntry(b []byte, options []*SDOption) (*SDEntry, error) {
+	entry := &SDEntry{
+		Type:         SDEntryType(b[0]),
+		ServiceID:    binary.BigEndian.Uint16(b[4:6]),
+		InstanceID:   binary.BigEndian.Uint16(b[6:8]),
+		MajorVersion: b[8],
+		TTL:          uint32(b[9])<<16 | uint32(b[10])<<8 | uint32(b[11]),
+	}
+	entry.Name = entry.Type.name(entry.TTL)
+	if entry.Type.IsEventgroup() {
+		entry.Counter = b[13] & 0x0F
+		entry.EventgroupID = binary.BigEndian.Uint16(b[14:16])
+	} else {
+		entry.MinorVersion = binary.BigEndian.Uint32(b[12:16])
+	}
+	// 两个 option run 分别由起始 index 与数量描述
+	runs := [][2]int{{int(b[1]), int(b[3] >> 4)}, {int(b[2]), int(b[3] & 0x0F)}}
+	for _, run := range runs {
+		if run[1] == 0 {
+			continue
+		}
+		if run[0]+run[1] > len(options) {
+			return nil, fmt.Errorf("option run %v+%v exceeds %v options", run[0], run[1], len(options))
+		}
+		entry.Options = append(entry.Options, options[run[0]:run[0]+run[1]]...)
+	}
+	return entry, nil
+}
+
+func (e *SDEntry) resolve(catalog SDCatalog) {
+	e.ServiceName = catalog.ServiceName(e.ServiceID, e.MajorVersion)
+	if e.InstanceID != SDAnyInstance {
+		e.InstanceName = catalog.InstanceName(e.ServiceID, e.InstanceID)
+	}
+	if e.Type.IsEventgroup() {
+		e.EventgroupName = catalog.EventgroupName(e.ServiceID, e.EventgroupID)
+	}
+}
+
+// decodeSDOption 返回 option 与其占用的字节数, length 字段不包含 length 与 type 字段
+func decodeSDOption(b []byte) (*SDOption, int, error) {
+	if len(b) < 4 {
+		return nil, 0, fmt.Errorf("option header needs 4 bytes, got %v", len(b))
+	}
+	length := int(binary.BigEndian.Uint16(b[0:2]))
+	if length < 1 || len(b) < 3+length {
+		return nil, 0, fmt.Errorf("invalid option length %v", length)
+	}
+	option := &SDOption{
+		Type:        SDOptionType(b[2]),
+		Discardable: b[3]&0x80 != 0,
+	}
+	option.Name = option.Type.String()
+	content := b[4 : 3+length]
+	switch option.Type {
+	case SDOptionIPv4Endpoint, SDOptionIPv4Multicast, SDOptionIPv4SD:
+		if err := option.decodeEndpoint(content, net.IPv4len); err != nil {
+			return nil, 0, err
+		}
+	case SDOptionIPv6Endpoint, SDOptionIPv6Multicast, SDOptionIPv6SD:
+		if err := option.decodeEndpoint(content, net.IPv6len); err != nil {
+			return nil, 0, err
+		}
+	case SDOptionConfiguration:
+		option.Configuration = make([]string, 0)
+		for i := 0; i < len(content) && content[i] != 0; {
+			n := int(content[i])
+			if i+1+n > len(content) {
+				return nil, 0, fmt.Errorf("configuration string exceeds option")
+			}
+			option.Configuration = append(option.Configuration, string(content[i+1:i+1+n]))
+			i += 1 + n
+		}
+	case SDOptionLoadBalancing:
+		if len(content) < 4 {
+			return nil, 0, fmt.Errorf("load balancing option needs 4 bytes, got %v", len(content))
+		}
+		option.Priority = binary.BigEndian.Uint16(content[0:2])
+		option.Weight = binary.BigEndian.Uint16(content[2:4])
+	}
+	return option, 3 + length, nil
+}
+
+// decodeEndpoint 解析 IP, 保留字节, L4 协议与端口
+func (o *SDOption) decodeEndpoint(content []byte, ipLength int) error {
+	if len(content) < ipLength+4 {
+		return fmt.Errorf("%v option needs %v bytes, got %v", o.Name, ipLength+4, len(content))
+	}
+	o.Address = net.IP(content[:ipLength]).String()
+	switch proto := content[ipLength+1]; proto {
+	case 0x06:
+		o.Protocol = "TCP"
+	case 0x11:
+		o.Protocol = "UDP"
+	default:
+		o.Protocol = fmt.Sprintf("0x%02x", proto)
+	}
+	o.Port = int(binary.BigEndian.Uint16(content[ipLength+2 : ipLength+4]))
+	return nil
+}
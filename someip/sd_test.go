@@ -0,0 +1,132 @@
+package someip
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sdServiceEntry(typ SDEntryType, serviceID, instanceID uint16, major uint8, ttl uint32, minor uint32, run1, n1 uint8) []byte {
+	b := []byte{uint8(typ), run1, 0, n1 << 4, 0, 0, 0, 0, major, uint8(ttl >> 16), uint8(ttl >> 8), uint8(ttl), 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(b[4:6], serviceID)
+	binary.BigEndian.PutUint16(b[6:8], instanceID)
+	binary.BigEndian.PutUint32(b[12:16], minor)
+	return b
+}
+
+func sdEventgroupEntry(typ SDEntryType, serviceID, instanceID uint16, major uint8, ttl uint32, counter uint8, eventgroupID uint16, run1, n1, run2, n2 uint8) []byte {
+	b := []byte{uint8(typ), run1, run2, n1<<4 | n2, 0, 0, 0, 0, major, uint8(ttl >> 16), uint8(ttl >> 8), uint8(ttl), 0, counter, 0, 0}
+	binary.BigEndian.PutUint16(b[4:6], serviceID)
+	binary.BigEndian.PutUint16(b[6:8], instanceID)
+	binary.BigEndian.PutUint16(b[14:16], eventgroupID)
+	return b
+}
+
+func sdEndpointOption(typ SDOptionType, ip string, proto uint8, port uint16) []byte {
+	addr := net.ParseIP(ip)
+	if v4 := addr.To4(); v4 != nil {
+		addr = v4
+	}
+	b := make([]byte, 4, 4+len(addr)+4)
+	binary.BigEndian.PutUint16(b[0:2], uint16(len(addr)+5))
+	b[2] = uint8(typ)
+	b = append(b, addr...)
+	b = append(b, 0, proto, uint8(port>>8), uint8(port))
+	return b
+}
+
+func sdPayload(flags uint8, entries [][]byte, options [][]byte) []byte {
+	b := []byte{flags, 0, 0, 0}
+	var e, o []byte
+	for _, entry := range entries {
+		e = append(e, entry...)
+	}
+	for _, option := range options {
+		o = append(o, option...)
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(e)))
+	b = append(b, e...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(o)))
+	return append(b, o...)
+}
+
+type testCatalog struct{}
+
+func (testCatalog) ServiceName(serviceID uint16, majorVersion uint8) string {
+	if serviceID == 0x8202 {
+		return "INI_WiFiStation"
+	}
+	return ""
+}
+
+func (testCatalog) InstanceName(serviceID, instanceID uint16) string {
+	if serviceID == 0x8202 && instanceID == 1 {
+		return "INI_WiFiStation_1"
+	}
+	return ""
+}
+
+func (testCatalog) EventgroupName(serviceID, eventgroupID uint16) string {
+	if serviceID == 0x8202 && eventgroupID == 1 {
+		return "INI_WiFiStation_EventGroup"
+	}
+	return ""
+}
+
+func TestDecodeSD(t *testing.T) {
+	configuration := []byte{0x00, 0x0C, uint8(SDOptionConfiguration), 0x00, 0x05, 'a', '=', 'b', 'c', 'd', 0x03, 'x', '=', 'y', 0x00}
+	binary.BigEndian.PutUint16(configuration[0:2], uint16(len(configuration)-3))
+	payload := sdPayload(0xC0, [][]byte{
+		sdServiceEntry(SDEntryOfferService, 0x8202, 1, 1, 3, 1, 0, 1),
+		sdServiceEntry(SDEntryFindService, 0x8202, SDAnyInstance, SDAnyMajorVersion, 3, 0xFFFFFFFF, 0, 0),
+		sdEventgroupEntry(SDEntrySubscribeEventgroup, 0x8202, 1, 1, 3, 2, 1, 1, 1, 3, 1),
+		sdEventgroupEntry(SDEntrySubscribeEventgroupAck, 0x8202, 1, 1, 0, 0, 1, 2, 1, 0, 0),
+	}, [][]byte{
+		sdEndpointOption(SDOptionIPv4Endpoint, "192.168.62.1", 0x06, 30552),
+		sdEndpointOption(SDOptionIPv6Endpoint, "fd00::1", 0x11, 31452),
+		sdEndpointOption(SDOptionIPv4Multicast, "239.0.0.62", 0x11, 30501),
+		configuration,
+	})
+	msg, err := DecodeSD(payload, testCatalog{})
+	require.NoError(t, err)
+	require.True(t, msg.Reboot)
+	require.True(t, msg.Unicast)
+	require.Len(t, msg.Options, 4)
+	require.Equal(t, &SDOption{Type: SDOptionIPv4Endpoint, Name: "IPv4Endpoint", Address: "192.168.62.1", Protocol: "TCP", Port: 30552}, msg.Options[0])
+	require.Equal(t, &SDOption{Type: SDOptionIPv6Endpoint, Name: "IPv6Endpoint", Address: "fd00::1", Protocol: "UDP", Port: 31452}, msg.Options[1])
+	require.Equal(t, []string{"a=bcd", "x=y"}, msg.Options[3].Configuration)
+
+	require.Len(t, msg.Entries, 4)
+	offer := msg.Entries[0]
+	require.Equal(t, "OfferService", offer.Name)
+	require.Equal(t, uint32(1), offer.MinorVersion)
+	require.Equal(t, "INI_WiFiStation", offer.ServiceName)
+	require.Equal(t, "INI_WiFiStation_1", offer.InstanceName)
+	require.Equal(t, []*SDOption{msg.Options[0]}, offer.Options)
+
+	find := msg.Entries[1]
+	require.Equal(t, "FindService", find.Name)
+	require.Equal(t, SDAnyInstance, find.InstanceID)
+	require.Empty(t, find.InstanceName)
+
+	subscribe := msg.Entries[2]
+	require.Equal(t, "SubscribeEventgroup", subscribe.Name)
+	require.Equal(t, uint8(2), subscribe.Counter)
+	require.Equal(t, uint16(1), subscribe.EventgroupID)
+	require.Equal(t, "INI_WiFiStation_EventGroup", subscribe.EventgroupName)
+	require.Equal(t, []*SDOption{msg.Options[1], msg.Options[3]}, subscribe.Options)
+
+	nack := msg.Entries[3]
+	require.Equal(t, "SubscribeEventgroupNack", nack.Name)
+	require.Equal(t, []*SDOption{msg.Options[2]}, nack.Options)
+
+	// option run 超出 option 数量
+	_, err = DecodeSD(sdPayload(0, [][]byte{sdServiceEntry(SDEntryOfferService, 0x8202, 1, 1, 3, 1, 1, 1)}, [][]byte{
+		sdEndpointOption(SDOptionIPv4Endpoint, "192.168.62.1", 0x06, 30552),
+	}), nil)
+	require.Error(t, err)
+	_, err = DecodeSD(payload[:20], nil)
+	require.Error(t, err)
+}
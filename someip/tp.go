@@ -0,0 +1,225 @@
+package someip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// TPHeaderLength 为 SOME/IP-TP header 长度, 位于 SOME/IP header 之后
+	TPHeaderLength = 4
+	// tpOffsetUnit 为 offset 字段的单位, 非最后一个分段的长度也需为其整数倍
+	tpOffsetUnit = 16
+
+	DefaultTPMaxMessageLength = 1 << 20
+	DefaultTPMaxBuffers       = 64
+	DefaultTPTimeout          = 5 * time.Second
+)
+
+// IsTP 判断 message type 中是否设置了 TP 标志位
+func (m MessageType) IsTP() bool {
+	return m&MessageTypeTPFlag != 0
+}
+
+// TPHeader 为 SOME/IP-TP header, Offset 为分段在完整 payload 中的字节偏移
+type TPHeader struct {
+	Offset       uint32
+	MoreSegments bool
+}
+
+// ParseTPHeader 解析分段 payload 开头的 SOME/IP-TP header 并返回分段数据
+func ParseTPHeader(payload []byte) (*TPHeader, []byte, error) {
+	if len(payload) < TPHeaderLength {
+		return nil, nil, fmt.Errorf("someip tp header needs %v bytes, got %v", TPHeaderLength, len(payload))
+	}
+	v := binary.BigEndian.Uint32(payload[:TPHeaderLength])
+	// 高 28 位为以 16 字节为单位的 offset, 最低位为 more segments
+	return &TPHeader{Offset: v &^ 0xF, MoreSegments: v&0x1 != 0}, payload[TPHeaderLength:], nil
+}
+
+// TPConfig 为 SOMEIP-TP-CONNECTION 的配置, 为 0 的字段使用 Reassembler 的默认值
+type TPConfig struct {
+	// MaxSegmentLength 为每个分段中分段数据 (不含 SOME/IP header 与 TP header) 的最大长度
+	MaxSegmentLength int
+	// MaxMessageLength 为重组后 payload 的最大长度
+	MaxMessageLength int
+	Timeout          time.Duration
+}
+
+// TPConfigProvider 返回 service, method 与 interface version 对应的 SOME/IP-TP 配置, 未配置时返回 false,
+// 无法确定配置时返回错误
+type TPConfigProvider interface {
+	TPConfig(serviceID, methodID uint16, interfaceVersion uint8) (*TPConfig, bool, error)
+}
+
+// ReassemblerConfig 为 Reassembler 的全局限制
+type ReassemblerConfig struct {
+	MaxMessageLength int
+	// MaxBuffers 为同时重组的消息数量上限
+	MaxBuffers int
+	Timeout    time.Duration
+}
+
+type tpKey struct {
+	serviceID uint16
+	methodID  uint16
+	clientID  uint16
+	sessionID uint16
+}
+
+type tpBuffer struct {
+	data     []byte
+	deadline time.Time
+}
+
+// Reassembler 以 (service, method, client, session) 缓存 SOME/IP-TP 分段并按顺序重组.
+// 分段需按 offset 递增到达, 重复的分段被忽略, offset 为 0 的分段重新开始重组
+type Reassembler struct {
+	mu       sync.Mutex
+	config   ReassemblerConfig
+	provider TPConfigProvider
+	buffers  map[tpKey]*tpBuffer
+	now      func() time.Time
+}
+
+// NewReassembler 创建 Reassembler, provider 为 nil 时全部消息使用 config 中的限制
+func NewReassembler(config ReassemblerConfig, provider TPConfigProvider) *Reassembler {
+	if config.MaxMessageLength <= 0 {
+		config.MaxMessageLength = DefaultTPMaxMessageLength
+	}
+	if config.MaxBuffers <= 0 {
+		config.MaxBuffers = DefaultTPMaxBuffers
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTPTimeout
+	}
+	return &Reassembler{
+		config:   config,
+		provider: provider,
+		buffers:  make(map[tpKey]*tpBuffer),
+		now:      time.Now,
+	}
+}
+
+// Add 加入一个 SOME/IP 报文. 未设置 TP 标志位的报文原样返回; 收到最后一个分段时返回去掉 TP 标志位并更新 length 的完整报文,
+// 否则第二个返回值为 false
+func (r *Reassembler) Add(message []byte) ([]byte, bool, error) {
+	h, payload, err := ParseHeader(message)
+	if err != nil {
+		return nil, false, err
+	}
+	if !h.MessageType.IsTP() {
+		return message, true, nil
+	}
+	config, err := r.tpConfig(h)
+	if err != nil {
+		return nil, false, err
+	}
+	tp, segment, err := ParseTPHeader(payload)
+	if err != nil {
+		return nil, false, err
+	}
+	if config.MaxSegmentLength > 0 && len(segment) > config.MaxSegmentLength {
+		return nil, false, fmt.Errorf("someip tp segment of %v bytes exceeds %v bytes", len(segment), config.MaxSegmentLength)
+	}
+	if tp.MoreSegments && len(segment)%tpOffsetUnit != 0 {
+		return nil, false, fmt.Errorf("someip tp segment length %v is not a multiple of %v", len(segment), tpOffsetUnit)
+	}
+	if int(tp.Offset)+len(segment) > config.MaxMessageLength {
+		return nil, false, fmt.Errorf("someip tp message exceeds %v bytes", config.MaxMessageLength)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.expire(now)
+	key := tpKey{serviceID: h.ServiceID, methodID: h.MethodID, clientID: h.ClientID, sessionID: h.SessionID}
+	buffer, ok := r.buffers[key]
+	if !ok || tp.Offset == 0 {
+		if tp.Offset != 0 {
+			return nil, false, fmt.Errorf("someip tp segment at offset %v without first segment", tp.Offset)
+		}
+		if !ok && len(r.buffers) >= r.config.MaxBuffers {
+			return nil, false, fmt.Errorf("someip tp reassembly exceeds %v buffers", r.config.MaxBuffers)
+		}
+		buffer = &tpBuffer{deadline: now.Add(config.Timeout)}
+		r.buffers[key] = buffer
+	}
+	switch {
+	case int(tp.Offset) < len(buffer.data):
+		// 重复的分段
+		return nil, false, nil
+	case int(tp.Offset) > len(buffer.data):
+		delete(r.buffers, key)
+		return nil, false, fmt.Errorf("someip tp segment at offset %v, expected %v", tp.Offset, len(buffer.data))
+	}
+	buffer.data = append(buffer.data, segment...)
+	if tp.MoreSegments {
+		return nil, false, nil
+	}
+	delete(r.buffers, key)
+	return buffer.message(h), true, nil
+}
+
+// message 以最后一个分段的 header 组装完整报文
+func (b *tpBuffer) message(last *Header) []byte {
+	message := make([]byte, HeaderLength, HeaderLength+len(b.data))
+	binary.BigEndian.PutUint16(message[0:2], last.ServiceID)
+	binary.BigEndian.PutUint16(message[2:4], last.MethodID)
+	binary.BigEndian.PutUint32(message[4:8], uint32(HeaderLength-8+len(b.data)))
+	binary.BigEndian.PutUint16(message[8:10], last.ClientID)
+	binary.BigEndian.PutUint16(message[10:12], last.SessionID)
+	message[12] = last.ProtocolVersion
+	message[13] = last.InterfaceVersion
+	message[14] = uint8(last.MessageType.Base())
+	message[15] = last.ReturnCode
+	return append(message, b.data...)
+}
+
+func (r *Reassembler) tpConfig(h *Header) (TPConfig, error) {
+	config := TPConfig{MaxMessageLength: r.config.MaxMessageLength, Timeout: r.config.Timeout}
+	if r.provider == nil {
+		return config, nil
+	}
+	c, ok, err := r.provider.TPConfig(h.ServiceID, h.MethodID, h.InterfaceVersion)
+	if err != nil {
+		return config, fmt.Errorf("someip tp config of service %v method %v: %w", h.ServiceID, h.MethodID, err)
+	}
+	if ok {
+		config.MaxSegmentLength = c.MaxSegmentLength
+		if c.MaxMessageLength > 0 && c.MaxMessageLength < config.MaxMessageLength {
+			config.MaxMessageLength = c.MaxMessageLength
+		}
+		if c.Timeout > 0 {
+			config.Timeout = c.Timeout
+		}
+	}
+	return config, nil
+}
+
+// Expire 丢弃超时未完成的重组, 返回丢弃的数量
+func (r *Reassembler) Expire() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.expire(r.now())
+}
+
+func (r *Reassembler) expire(now time.Time) int {
+	n := 0
+	for key, buffer := range r.buffers {
+		if now.After(buffer.deadline) {
+			delete(r.buffers, key)
+			n++
+		}
+	}
+	return n
+}
+
+// Pending 返回正在重组的消息数量
+func (r *Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.buffers)
+}
@@ -0,0 +1,117 @@
+package someip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func tpSegment(sessionID uint16, offset uint32, more bool, data []byte) []byte {
+	message := make([]byte, HeaderLength+TPHeaderLength, HeaderLength+TPHeaderLength+len(data))
+	binary.BigEndian.PutUint16(message[0:2], 0x8202)
+	binary.BigEndian.PutUint16(message[2:4], 0x0005)
+	binary.BigEndian.PutUint32(message[4:8], uint32(8+TPHeaderLength+len(data)))
+	binary.BigEndian.PutUint16(message[8:10], 0x0001)
+	binary.BigEndian.PutUint16(message[10:12], sessionID)
+	message[12] = 0x01
+	message[13] = 0x01
+	message[14] = uint8(MessageTypeResponse | MessageTypeTPFlag)
+	tp := offset
+	if more {
+		tp |= 0x1
+	}
+	binary.BigEndian.PutUint32(message[HeaderLength:], tp)
+	return append(message, data...)
+}
+
+type testTPConfig struct{}
+
+func (testTPConfig) TPConfig(serviceID, methodID uint16, interfaceVersion uint8) (*TPConfig, bool, error) {
+	if serviceID != 0x8202 {
+		return nil, false, fmt.Errorf("unknown service %v", serviceID)
+	}
+	if methodID == 0x0005 {
+		return &TPConfig{MaxSegmentLength: 32, MaxMessageLength: 64, Timeout: time.Second}, true, nil
+	}
+	return nil, false, nil
+}
+
+func TestReassembler(t *testing.T) {
+	payload := make([]byte, 40)
+	for i := range payload {
+		payload[i] = uint8(i)
+	}
+	r := NewReassembler(ReassemblerConfig{}, testTPConfig{})
+	message, complete, err := r.Add(tpSegment(1, 0, true, payload[:32]))
+	require.NoError(t, err)
+	require.False(t, complete)
+	require.Nil(t, message)
+	// 重复的分段被忽略
+	_, complete, err = r.Add(tpSegment(1, 0, true, payload[:32]))
+	require.NoError(t, err)
+	require.False(t, complete)
+	// 不同 session 分别重组
+	_, _, err = r.Add(tpSegment(2, 0, true, payload[:16]))
+	require.NoError(t, err)
+	require.Equal(t, 2, r.Pending())
+
+	message, complete, err = r.Add(tpSegment(1, 32, false, payload[32:]))
+	require.NoError(t, err)
+	require.True(t, complete)
+	h, got, err := ParseHeader(message)
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeResponse, h.MessageType)
+	require.Equal(t, uint16(1), h.SessionID)
+	require.Equal(t, payload, got)
+	require.Equal(t, 1, r.Pending())
+
+	// 缺少中间的分段
+	_, _, err = r.Add(tpSegment(2, 32, false, payload[32:]))
+	require.Error(t, err)
+	require.Equal(t, 0, r.Pending())
+	_, _, err = r.Add(tpSegment(3, 16, false, payload[:8]))
+	require.Error(t, err)
+
+	// 非最后一个分段的长度需为 16 的整数倍, 分段数据与消息长度以 TPConfig 为上限
+	_, _, err = r.Add(tpSegment(4, 0, true, payload[:20]))
+	require.Error(t, err)
+	_, _, err = r.Add(tpSegment(4, 0, true, payload[:40]))
+	require.Error(t, err)
+	_, _, err = r.Add(tpSegment(4, 64, false, payload[:8]))
+	require.Error(t, err)
+
+	// 无法确定 TP 配置时返回错误
+	unknown := tpSegment(5, 0, true, payload[:16])
+	binary.BigEndian.PutUint16(unknown[0:2], 0x8203)
+	_, _, err = r.Add(unknown)
+	require.Error(t, err)
+
+	// 未设置 TP 标志位的报文原样返回
+	plain := []byte{0x82, 0x02, 0x00, 0x05, 0x00, 0x00, 0x00, 0x0A, 0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0x80, 0x00, 0x00, 0x01}
+	message, complete, err = r.Add(plain)
+	require.NoError(t, err)
+	require.True(t, complete)
+	require.Equal(t, plain, message)
+}
+
+func TestReassemblerLimits(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := NewReassembler(ReassemblerConfig{MaxBuffers: 1, Timeout: time.Second}, nil)
+	r.now = func() time.Time { return now }
+	data := make([]byte, 16)
+	_, _, err := r.Add(tpSegment(1, 0, true, data))
+	require.NoError(t, err)
+	_, _, err = r.Add(tpSegment(2, 0, true, data))
+	require.Error(t, err)
+
+	// 超时的重组被丢弃
+	now = now.Add(2 * time.Second)
+	require.Equal(t, 1, r.Expire())
+	_, _, err = r.Add(tpSegment(1, 16, false, data))
+	require.Error(t, err)
+	_, _, err = r.Add(tpSegment(2, 0, true, data))
+	require.NoError(t, err)
+}
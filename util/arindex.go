@@ -0,0 +1,88 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// ArIndex 为文档中所有带 SHORT-NAME 的元素建立 AR 绝对路径 (如 /Pkg/Sub/Element) 到元素的索引
+type ArIndex struct {
+	elements map[string]*etree.Element
+}
+
+func NewArIndex(root *etree.Element) (*ArIndex, error) {
+	i := &ArIndex{
+		elements: make(map[string]*etree.Element),
+	}
+	if root == nil {
+		return i, nil
+	}
+	if err := i.index(root, ""); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (i *ArIndex) index(node *etree.Element, path string) error {
+	if sn := node.SelectElement("SHORT-NAME"); sn != nil {
+		path = path + "/" + strings.TrimSpace(sn.Text())
+		if exist, ok := i.elements[path]; ok {
+			// 同一个 AR-PACKAGE 允许分多处定义, 其它元素路径必须唯一
+			if exist.Tag != "AR-PACKAGE" || node.Tag != "AR-PACKAGE" {
+				return fmt.Errorf("duplicate AR path %v: %v and %v", path, exist.Tag, node.Tag)
+			}
+		} else {
+			i.elements[path] = node
+		}
+	}
+	for _, child := range node.ChildElements() {
+		if err := i.index(child, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lookup 根据 AR 绝对路径查找元素
+func (i *ArIndex) Lookup(path string) (*etree.Element, bool) {
+	if i == nil {
+		return nil, false
+	}
+	e, ok := i.elements[strings.TrimSpace(path)]
+	return e, ok
+}
+
+// Resolve 解析引用元素指向的目标, 目标必须存在于文档中, 且引用带 DEST 属性时类型一致
+func (i *ArIndex) Resolve(ref *etree.Element) (*etree.Element, error) {
+	path := strings.TrimSpace(ref.Text())
+	target, ok := i.Lookup(path)
+	if !ok {
+		return nil, fmt.Errorf("%v %v not found", ref.Tag, path)
+	}
+	if err := checkDest(ref, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// RefPath 返回引用的 AR 路径. 目标不在文档中时 (如外部定义的 base type) 直接返回路径,
+// 目标存在时校验 DEST 与目标元素类型一致
+func (i *ArIndex) RefPath(ref *etree.Element) (string, error) {
+	path := strings.TrimSpace(ref.Text())
+	if target, ok := i.Lookup(path); ok {
+		if err := checkDest(ref, target); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+func checkDest(ref, target *etree.Element) error {
+	dest := ref.SelectAttrValue("DEST", "")
+	if dest != "" && dest != target.Tag {
+		return fmt.Errorf("%v %v expects DEST %v, got %v", ref.Tag, strings.TrimSpace(ref.Text()), dest, target.Tag)
+	}
+	return nil
+}
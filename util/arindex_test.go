@@ -0,0 +1,82 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/stretchr/testify/require"
+)
+
+const arIndexTestDoc = `<AUTOSAR>
+  <AR-PACKAGES>
+    <AR-PACKAGE>
+      <SHORT-NAME>A</SHORT-NAME>
+      <ELEMENTS>
+        <IMPLEMENTATION-DATA-TYPE><SHORT-NAME>Speed</SHORT-NAME></IMPLEMENTATION-DATA-TYPE>
+      </ELEMENTS>
+    </AR-PACKAGE>
+    <AR-PACKAGE>
+      <SHORT-NAME>B</SHORT-NAME>
+      <ELEMENTS>
+        <APPLICATION-PRIMITIVE-DATA-TYPE><SHORT-NAME>Speed</SHORT-NAME></APPLICATION-PRIMITIVE-DATA-TYPE>
+      </ELEMENTS>
+    </AR-PACKAGE>
+    <AR-PACKAGE>
+      <SHORT-NAME>A</SHORT-NAME>
+      <ELEMENTS>
+        <IMPLEMENTATION-DATA-TYPE><SHORT-NAME>Angle</SHORT-NAME></IMPLEMENTATION-DATA-TYPE>
+      </ELEMENTS>
+    </AR-PACKAGE>
+  </AR-PACKAGES>
+</AUTOSAR>`
+
+func newRef(tag, dest, path string) *etree.Element {
+	ref := etree.NewElement(tag)
+	ref.CreateAttr("DEST", dest)
+	ref.SetText(path)
+	return ref
+}
+
+func TestArIndex(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(arIndexTestDoc))
+	index, err := NewArIndex(doc.Root())
+	require.NoError(t, err)
+
+	// 同名元素按完整路径区分
+	e, ok := index.Lookup("/A/Speed")
+	require.True(t, ok)
+	require.Equal(t, "IMPLEMENTATION-DATA-TYPE", e.Tag)
+	e, ok = index.Lookup("/B/Speed")
+	require.True(t, ok)
+	require.Equal(t, "APPLICATION-PRIMITIVE-DATA-TYPE", e.Tag)
+	// 分多处定义的 AR-PACKAGE
+	e, ok = index.Lookup("/A/Angle")
+	require.True(t, ok)
+	require.Equal(t, "/A/Angle", GetArPath(e))
+
+	e, err = index.Resolve(newRef("TYPE-TREF", "APPLICATION-PRIMITIVE-DATA-TYPE", "/B/Speed"))
+	require.NoError(t, err)
+	require.Equal(t, "APPLICATION-PRIMITIVE-DATA-TYPE", e.Tag)
+	_, err = index.Resolve(newRef("TYPE-TREF", "APPLICATION-PRIMITIVE-DATA-TYPE", "/A/Speed"))
+	require.Error(t, err)
+	_, err = index.Resolve(newRef("TYPE-TREF", "APPLICATION-PRIMITIVE-DATA-TYPE", "/C/Speed"))
+	require.Error(t, err)
+
+	// 文档外的引用只返回路径
+	path, err := index.RefPath(newRef("BASE-TYPE-REF", "SW-BASE-TYPE", "/AUTOSAR_Platform/BaseTypes/uint8"))
+	require.NoError(t, err)
+	require.Equal(t, "/AUTOSAR_Platform/BaseTypes/uint8", path)
+	_, err = index.RefPath(newRef("BASE-TYPE-REF", "SW-BASE-TYPE", "/A/Speed"))
+	require.Error(t, err)
+}
+
+func TestArIndexDuplicatePath(t *testing.T) {
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(`<AUTOSAR><AR-PACKAGES><AR-PACKAGE><SHORT-NAME>A</SHORT-NAME><ELEMENTS>
+<IMPLEMENTATION-DATA-TYPE><SHORT-NAME>Speed</SHORT-NAME></IMPLEMENTATION-DATA-TYPE>
+<APPLICATION-PRIMITIVE-DATA-TYPE><SHORT-NAME>Speed</SHORT-NAME></APPLICATION-PRIMITIVE-DATA-TYPE>
+</ELEMENTS></AR-PACKAGE></AR-PACKAGES></AUTOSAR>`))
+	_, err := NewArIndex(doc.Root())
+	require.Error(t, err)
+}
@@ -69,45 +69,6 @@ func GetArraySizeSemantics(node *etree.Element) (isDynamic bool, err error) {
 	return false, fmt.Errorf("invalid ARRAY-SIZE-SEMANTICS:%v", ass.Text())
 }
 
-func ValidBasicType(ref string) error {
-	typeName := ExtractTypeNameFromRef(ref)
-	lowerName := strings.ToLower(typeName)
-	switch {
-	case strings.Contains(lowerName, "uint8"):
-		return nil
-	case strings.Contains(lowerName, "uint16"):
-		return nil
-	case strings.Contains(lowerName, "uint32"):
-		return nil
-	case strings.Contains(lowerName, "uint64"):
-		return nil
-	case strings.Contains(lowerName, "bool"):
-		return nil
-	case strings.Contains(lowerName, "int8"):
-		return nil
-	case strings.Contains(lowerName, "int16"):
-		return nil
-	case strings.Contains(lowerName, "int32"):
-		return nil
-	case strings.Contains(lowerName, "int64"):
-		return nil
-	case strings.Contains(lowerName, "float"):
-		return nil
-	case strings.Contains(lowerName, "double"):
-		return nil
-	}
-	return fmt.Errorf("invalid basic type:%v", ref)
-}
-
-func ExtractTypeNameFromRef(ref string) string {
-	// 移除路径前缀，只保留最后的类型名
-	parts := strings.Split(ref, "/")
-	if len(parts) > 0 {
-		return parts[len(parts)-1]
-	}
-	return ref
-}
-
 func GetArPackagesElement(node *etree.Element) (*etree.Element, error) {
 	arpackagesElement := node.SelectElement("AR-PACKAGES")
 	if arpackagesElement == nil {
@@ -115,3 +76,64 @@ func GetArPackagesElement(node *etree.Element) (*etree.Element, error) {
 	}
 	return arpackagesElement, nil
 }
+
+// GetArPath 根据祖先元素的 SHORT-NAME 计算元素的 AR 路径, 如 /Pkg/Sub/Element
+func GetArPath(node *etree.Element) string {
+	var parts []string
+	for e := node; e != nil; e = e.Parent() {
+		if sn := e.SelectElement("SHORT-NAME"); sn != nil {
+			parts = append([]string{strings.TrimSpace(sn.Text())}, parts...)
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+var tlvDataIDRefTags = []string{
+	"TLV-RECORD-ELEMENT-REF",
+	"TLV-IMPLEMENTATION-DATA-TYPE-ELEMENT-REF",
+	"TLV-IMPL-RECORD-ELEMENT-REF",
+	"TLV-ARGUMENT-REF",
+}
+
+// ParseTLVDataIDs 解析文档中所有 TLV-DATA-ID-DEFINITION, 返回被引用元素的 AR 路径到 data id 的映射
+func ParseTLVDataIDs(root *etree.Element) (map[string]uint16, error) {
+	dataIDs := make(map[string]uint16)
+	if root == nil {
+		return dataIDs, nil
+	}
+	for _, def := range root.FindElements("//TLV-DATA-ID-DEFINITION") {
+		idElement := def.SelectElement("ID")
+		if idElement == nil {
+			return nil, fmt.Errorf("no ID found in TLV-DATA-ID-DEFINITION")
+		}
+		id, err := ToUint16(idElement.Text())
+		if err != nil {
+			return nil, err
+		}
+		// data id 只有 12 bit
+		if id > 0x0FFF {
+			return nil, fmt.Errorf("TLV data id %v exceeds 12 bit", id)
+		}
+		for _, tag := range tlvDataIDRefTags {
+			if ref := def.SelectElement(tag); ref != nil {
+				dataIDs[strings.TrimSpace(ref.Text())] = id
+			}
+		}
+	}
+	return dataIDs, nil
+}
+
+// GetIsOptional 读取 IS-OPTIONAL, 未配置时为 false
+func GetIsOptional(node *etree.Element) (bool, error) {
+	isOptional := node.SelectElement("IS-OPTIONAL")
+	if isOptional == nil {
+		return false, nil
+	}
+	switch strings.TrimSpace(isOptional.Text()) {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid IS-OPTIONAL:%v", isOptional.Text())
+}